@@ -0,0 +1,29 @@
+// Package apierror maps the errors returned by the store onto HTTP status codes
+//
+// Keeping the mapping in one place means every handler responds to the
+// same store error in the same way.
+package apierror
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+)
+
+// Status returns the HTTP status code to respond with for an error returned by the store
+// Any error that is not one of the model errors is an internal error
+func Status(err error) int {
+	switch {
+	case errors.Is(err, model.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, model.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, model.ErrInvalidReference):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, model.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,39 @@
+package apierror_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/JoeReid/buffassignment/api/apierror"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatus(t *testing.T) {
+	var tests = []struct {
+		name   string
+		err    error
+		expect int
+	}{
+		{name: "not found", err: model.ErrNotFound, expect: http.StatusNotFound},
+		{name: "conflict", err: model.ErrConflict, expect: http.StatusConflict},
+		{name: "wrapped conflict", err: fmt.Errorf("%w: buff already exists", model.ErrConflict), expect: http.StatusConflict},
+		{name: "stream has buffs", err: &model.StreamHasBuffsError{Stream: model.VideoStreamID(uuid.New()), Buffs: 1}, expect: http.StatusConflict},
+		{name: "stream transition", err: &model.StreamTransitionError{Stream: model.VideoStreamID(uuid.New()), From: model.StreamEnded, To: model.StreamLive}, expect: http.StatusConflict},
+		{name: "stream not live", err: &model.StreamNotLiveError{Stream: model.VideoStreamID(uuid.New()), State: model.StreamEnded}, expect: http.StatusConflict},
+		{name: "buff kind", err: &model.BuffKindError{Buff: model.BuffID(uuid.New()), Kind: model.BuffQuiz}, expect: http.StatusConflict},
+		{name: "buff resolved", err: &model.BuffResolvedError{Buff: model.BuffID(uuid.New()), Answer: model.AnswerID(uuid.New())}, expect: http.StatusConflict},
+		{name: "invalid reference", err: model.ErrInvalidReference, expect: http.StatusUnprocessableEntity},
+		{name: "unavailable", err: model.ErrUnavailable, expect: http.StatusServiceUnavailable},
+		{name: "unknown error", err: errors.New("the world exploded"), expect: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, apierror.Status(tt.err))
+		})
+	}
+}
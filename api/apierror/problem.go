@@ -0,0 +1,97 @@
+package apierror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/apiutils/tracer"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/validation"
+	"github.com/go-chi/chi/middleware"
+	"github.com/opentracing/opentracing-go"
+)
+
+// NewProblem builds the types.Problem describing an error for the request
+//
+// The detail of server errors is recorded on the request's trace instead of
+// being returned, so clients never see the internals of the store. Client errors
+// caused by the storage are only detailed by their model error, see model.StoreError.
+func NewProblem(r *http.Request, status int, err error) types.Problem {
+	p := types.Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Instance:  r.URL.Path,
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+
+	sp := opentracing.SpanFromContext(r.Context())
+	if status >= http.StatusInternalServerError {
+		if sp != nil {
+			tracer.SetError(sp, err)
+		}
+		return p
+	}
+
+	var stored *model.StoreError
+	if !errors.As(err, &stored) {
+		p.Detail = err.Error()
+		return p
+	}
+
+	p.Detail = stored.Kind.Error()
+	if sp != nil {
+		tracer.Log(sp, err.Error())
+	}
+	return p
+}
+
+// Respond responds to the request with a types.Problem describing the error
+func Respond(c apiutils.Codec, w http.ResponseWriter, r *http.Request, status int, err error) {
+	c.Respond(r.Context(), w, status, NewProblem(r, status, err))
+}
+
+// NewInvalidProblem builds the types.Problem listing every rule broken by the request,
+// falling back to an internal error if err is not a validation.Errors
+func NewInvalidProblem(r *http.Request, err error) types.Problem {
+	var invalid validation.Errors
+	if !errors.As(err, &invalid) {
+		return NewProblem(r, http.StatusInternalServerError, err)
+	}
+
+	p := NewProblem(r, http.StatusUnprocessableEntity, fmt.Errorf("the request breaks %d validation rules", len(invalid)))
+	p.Errors = types.NewValidationErrors(invalid)
+	return p
+}
+
+// RespondInvalid responds with every rule broken by the request,
+// falling back to an internal error if err is not a validation.Errors
+func RespondInvalid(c apiutils.Codec, w http.ResponseWriter, r *http.Request, err error) {
+	p := NewInvalidProblem(r, err)
+	c.Respond(r.Context(), w, p.Status, p)
+}
+
+// Codec wraps a codec so that problems are sent with the given content type,
+// such as application/problem+json, rather than the codec's own
+func Codec(c apiutils.Codec, contentType string) apiutils.Codec {
+	return &problemCodec{Codec: c, contentType: contentType}
+}
+
+type problemCodec struct {
+	apiutils.Codec
+	contentType string
+}
+
+// Respond implements the apiutils.Codec interface
+// The content type is set before the wrapped codec writes the status code,
+// after which the codec's own content type no longer applies
+func (p *problemCodec) Respond(ctx context.Context, w http.ResponseWriter, code int, data interface{}) {
+	if _, ok := data.(types.Problem); ok && w != nil {
+		w.Header().Set("Content-Type", p.contentType)
+	}
+	p.Codec.Respond(ctx, w, code, data)
+}
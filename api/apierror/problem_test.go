@@ -0,0 +1,166 @@
+package apierror_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/JoeReid/apiutils/jsoncodec"
+	"github.com/JoeReid/apiutils/yamlcodec"
+	"github.com/JoeReid/buffassignment/api/apierror"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/validation"
+	"github.com/go-chi/chi/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProblem(t *testing.T) {
+	var tests = []struct {
+		name   string
+		status int
+		err    error
+		expect types.Problem
+	}{
+		{
+			name:   "client errors are detailed",
+			status: http.StatusNotFound,
+			err:    errors.New("no such buff"),
+			expect: types.Problem{
+				Type:      "about:blank",
+				Title:     "Not Found",
+				Status:    http.StatusNotFound,
+				Detail:    "no such buff",
+				Instance:  "/v1/buffs/42",
+				RequestID: "request-42",
+			},
+		},
+		{
+			name:   "client errors caused by the storage are only detailed by their model error",
+			status: http.StatusConflict,
+			err: fmt.Errorf("create buff: %w", &model.StoreError{
+				Kind: model.ErrConflict,
+				Err:  errors.New(`pq: duplicate key value violates unique constraint "questions_pkey"`),
+			}),
+			expect: types.Problem{
+				Type:      "about:blank",
+				Title:     "Conflict",
+				Status:    http.StatusConflict,
+				Detail:    model.ErrConflict.Error(),
+				Instance:  "/v1/buffs/42",
+				RequestID: "request-42",
+			},
+		},
+		{
+			name:   "server errors are not detailed",
+			status: http.StatusInternalServerError,
+			err:    errors.New("pq: password authentication failed"),
+			expect: types.Problem{
+				Type:      "about:blank",
+				Title:     "Internal Server Error",
+				Status:    http.StatusInternalServerError,
+				Instance:  "/v1/buffs/42",
+				RequestID: "request-42",
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/v1/buffs/42", nil)
+			require.NoError(t, err, "failed to build request for test")
+			req = req.WithContext(context.WithValue(req.Context(), middleware.RequestIDKey, "request-42"))
+
+			assert.Equal(t, tt.expect, apierror.NewProblem(req, tt.status, tt.err))
+		})
+	}
+}
+
+func TestRespondInvalid(t *testing.T) {
+	req, err := http.NewRequest("POST", "/v1/buffs", nil)
+	require.NoError(t, err, "failed to build request for test")
+
+	w := httptest.NewRecorder()
+	apierror.RespondInvalid(jsoncodec.New(), w, req, validation.Errors{
+		{Field: "question", Rule: "required", Message: "must not be empty"},
+	})
+
+	var p types.Problem
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&p), "failed to decode response")
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, types.Problem{
+		Type:     "about:blank",
+		Title:    "Unprocessable Entity",
+		Status:   http.StatusUnprocessableEntity,
+		Detail:   "the request breaks 1 validation rules",
+		Instance: "/v1/buffs",
+		Errors: []types.ValidationError{
+			{Field: "question", Rule: "required", Message: "must not be empty"},
+		},
+	}, p)
+}
+
+func TestRespondInvalidUnknownError(t *testing.T) {
+	req, err := http.NewRequest("POST", "/v1/buffs", nil)
+	require.NoError(t, err, "failed to build request for test")
+
+	w := httptest.NewRecorder()
+	apierror.RespondInvalid(jsoncodec.New(), w, req, errors.New("the world exploded"))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "the world exploded")
+}
+
+func TestCodecContentType(t *testing.T) {
+	var tests = []struct {
+		name        string
+		data        interface{}
+		expectType  string
+		expectCode  int
+		contentType string
+	}{
+		{
+			name:        "problems use the problem content type",
+			data:        types.Problem{Type: "about:blank", Title: "Not Found", Status: http.StatusNotFound},
+			expectType:  "application/problem+json",
+			expectCode:  http.StatusNotFound,
+			contentType: "application/problem+json",
+		},
+		{
+			name:        "other data is left to the codec",
+			data:        types.VideoStream{Title: "test"},
+			expectType:  "",
+			expectCode:  http.StatusOK,
+			contentType: "application/problem+json",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			apierror.Codec(jsoncodec.New(), tt.contentType).Respond(context.Background(), w, tt.expectCode, tt.data)
+
+			// The codecs set their own content type after writing the status code,
+			// so only the headers sent with the status are checked
+			assert.Equal(t, tt.expectCode, w.Code)
+			assert.Equal(t, tt.expectType, w.Result().Header.Get("Content-Type"))
+		})
+	}
+}
+
+func TestCodecYAML(t *testing.T) {
+	w := httptest.NewRecorder()
+	apierror.Codec(yamlcodec.New(), "application/problem+yaml").Respond(
+		context.Background(), w, http.StatusConflict,
+		types.Problem{Type: "about:blank", Title: "Conflict", Status: http.StatusConflict, Detail: "already exists"},
+	)
+
+	assert.Equal(t, "application/problem+yaml", w.Result().Header.Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "detail: already exists")
+}
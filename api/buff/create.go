@@ -0,0 +1,179 @@
+package buff
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/buffassignment/api/apierror"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/validation"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+// NewCreateHandler returns a new instance of the create action of
+// the buff API using the given store instance.
+//
+// The stream the buff belongs to is read from the request body.
+//
+// The new buff is checked against the rules of the given validator before it is stored.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewCreateHandler(store model.BuffStore, validator *validation.Validator) apiutils.Handler {
+	return &buffCreate{store, validator}
+}
+
+// NewCreateForStreamHandler returns a new instance of the create for stream action of
+// the buff API using the given store instance.
+//
+// The stream the buff belongs to is read from the URL, and any stream in the
+// request body is ignored.
+//
+// The new buff is checked against the rules of the given validator before it is stored.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewCreateForStreamHandler(store model.BuffStore, validator *validation.Validator) apiutils.Handler {
+	return &buffCreateForStream{store, validator}
+}
+
+// buffCreate implements the apiutils.Handler interface to provide the
+// create portion of the buff API
+type buffCreate struct {
+	store     model.BuffStore
+	validator *validation.Validator
+}
+
+// ServeCodec serves the API using the apiutils.Handler pattern
+// This allows the business logic to live here, and the encoding to live separate from it
+// This also makes testing easier, as there is a test codec that allows us to peek at the output
+// in a testing context.
+func (b *buffCreate) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
+	var req types.Buff
+	if err := c.Read(r.Context(), r, &req); err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	vID, err := uuid.Parse(req.VideoStreamUUID)
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	// IDs are always generated by the server
+	mb := buffFromRequest(model.BuffID(uuid.New()), model.VideoStreamID(vID), req, nil)
+	createBuff(b.store, b.validator, c, w, r, mb, authoringView{})
+}
+
+// buffCreateForStream implements the apiutils.Handler interface to provide the
+// create for stream portion of the buff API
+type buffCreateForStream struct {
+	store     model.BuffStore
+	validator *validation.Validator
+}
+
+// ServeCodec serves the API using the apiutils.Handler pattern
+// This allows the business logic to live here, and the encoding to live separate from it
+// This also makes testing easier, as there is a test codec that allows us to peek at the output
+// in a testing context.
+func (b *buffCreateForStream) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
+	vID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var req types.Buff
+	if err := c.Read(r.Context(), r, &req); err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	// IDs are always generated by the server
+	mb := buffFromRequest(model.BuffID(uuid.New()), model.VideoStreamID(vID), req, nil)
+	createBuff(b.store, b.validator, c, w, r, mb, authoringView{})
+}
+
+// createBuff holds the logic shared between all the create handlers,
+// once they have built the new buff from the request
+//
+// The created buff is sent back in the shape given by the view.
+func createBuff(
+	store model.BuffStore,
+	validator *validation.Validator,
+	c apiutils.Codec,
+	w http.ResponseWriter,
+	r *http.Request,
+	mb model.Buff,
+	v view,
+) {
+	// Timestamps are always generated by the server
+	mb.CreatedAt = time.Now().UTC()
+
+	if err := validator.Buff(mb); err != nil {
+		apierror.RespondInvalid(c, w, r, err)
+		return
+	}
+
+	if err := store.CreateBuff(r.Context(), mb); err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+	body, err := v.buff(r, mb)
+	if err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+	c.Respond(r.Context(), w, http.StatusCreated, body)
+}
+
+// buffFromRequest builds a model.Buff from the API representation
+//
+// The API representation has no answer IDs, so the IDs of any existing answers
+// are re-used where the answer text is unchanged. This keeps the answer IDs stable
+// across updates. Any other answers are given a new ID.
+//
+// The correct answer is always added for a quiz, and for any other kind when it is
+// set, leaving the validator to reject a correct answer on a buff that cannot have one.
+func buffFromRequest(id model.BuffID, stream model.VideoStreamID, req types.Buff, existing []model.Answer) model.Buff {
+	ids := make(map[string]model.AnswerID, len(existing))
+	for _, ans := range existing {
+		ids[ans.Text] = ans.ID
+	}
+
+	answer := func(text string, correct bool) model.Answer {
+		aID, ok := ids[text]
+		if !ok {
+			aID = model.AnswerID(uuid.New())
+		}
+		// Each ID can only be used once, even if the text is repeated
+		delete(ids, text)
+
+		return model.Answer{ID: aID, Text: text, Correct: correct}
+	}
+
+	mb := model.Buff{
+		ID:       id,
+		Stream:   stream,
+		Kind:     model.BuffKind(req.Kind),
+		Question: req.Question,
+		Answers:  make([]model.Answer, 0, len(req.IncorrectAnswers)+len(req.Answers)+1),
+		Schedule: req.Schedule.Model(),
+		Tags:     req.Tags,
+	}
+
+	if mb.Judged() || req.CorrectAnswer != "" {
+		mb.Answers = append(mb.Answers, answer(req.CorrectAnswer, true))
+	}
+	for _, text := range req.IncorrectAnswers {
+		mb.Answers = append(mb.Answers, answer(text, false))
+	}
+	for _, text := range req.Answers {
+		mb.Answers = append(mb.Answers, answer(text, false))
+	}
+	return mb
+}
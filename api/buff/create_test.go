@@ -0,0 +1,369 @@
+package buff_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/JoeReid/apiutils/testingcodec"
+	"github.com/JoeReid/buffassignment/api/buff"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/model/testmodel"
+	"github.com/JoeReid/buffassignment/internal/validation"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateBuff(t *testing.T) {
+	sentinelUUID := uuid.New()
+
+	var tests = []struct {
+		name                 string
+		requestBody          types.Buff
+		readError            error
+		storeError           error
+		expectResponseCode   int
+		expectResponseData   interface{}
+		expectStoreNotCalled bool
+	}{
+		{
+			name: "returns created on happy path",
+			requestBody: types.Buff{
+				VideoStreamUUID:  sentinelUUID.String(),
+				Question:         "what's the answer to life, the universe, and everything?",
+				CorrectAnswer:    "42",
+				IncorrectAnswers: []string{"43", "44"},
+			},
+			expectResponseCode: http.StatusCreated,
+		},
+		{
+			name: "returns created with a schedule",
+			requestBody: types.Buff{
+				VideoStreamUUID:  sentinelUUID.String(),
+				Question:         "what's the answer to life, the universe, and everything?",
+				CorrectAnswer:    "42",
+				IncorrectAnswers: []string{"43", "44"},
+				Schedule:         &types.Schedule{StartOffsetMS: 90000, DurationMS: 15000},
+			},
+			expectResponseCode: http.StatusCreated,
+		},
+		{
+			name: "returns created with tags",
+			requestBody: types.Buff{
+				VideoStreamUUID:  sentinelUUID.String(),
+				Question:         "what's the answer to life, the universe, and everything?",
+				CorrectAnswer:    "42",
+				IncorrectAnswers: []string{"43", "44"},
+				Tags:             []string{"trivia", "sponsor:acme"},
+			},
+			expectResponseCode: http.StatusCreated,
+		},
+		{
+			name: "returns created for a poll",
+			requestBody: types.Buff{
+				VideoStreamUUID: sentinelUUID.String(),
+				Kind:            "poll",
+				Question:        "who will you be cheering for?",
+				Answers:         []string{"home", "away"},
+			},
+			expectResponseCode: http.StatusCreated,
+		},
+		{
+			name: "returns unprocessable entity on a poll with a correct answer",
+			requestBody: types.Buff{
+				VideoStreamUUID: sentinelUUID.String(),
+				Kind:            "poll",
+				Question:        "who will you be cheering for?",
+				CorrectAnswer:   "home",
+				Answers:         []string{"away"},
+			},
+			expectResponseCode: http.StatusUnprocessableEntity,
+			expectResponseData: newInvalidProblem(
+				types.ValidationError{Field: "answers[0].correct", Rule: "not_correct", Message: "must not be correct, as a poll has no correct answer"},
+			),
+			expectStoreNotCalled: true,
+		},
+		{
+			name: "returns unprocessable entity on an unknown kind",
+			requestBody: types.Buff{
+				VideoStreamUUID:  sentinelUUID.String(),
+				Kind:             "survey",
+				Question:         "what's the answer to life, the universe, and everything?",
+				CorrectAnswer:    "42",
+				IncorrectAnswers: []string{"43"},
+			},
+			expectResponseCode: http.StatusUnprocessableEntity,
+			expectResponseData: newInvalidProblem(
+				types.ValidationError{Field: "kind", Rule: "one_of", Message: "must be one of quiz, poll or prediction"},
+			),
+			expectStoreNotCalled: true,
+		},
+		{
+			name: "returns unprocessable entity on invalid schedule",
+			requestBody: types.Buff{
+				VideoStreamUUID:  sentinelUUID.String(),
+				Question:         "what's the answer to life, the universe, and everything?",
+				CorrectAnswer:    "42",
+				IncorrectAnswers: []string{"43", "44"},
+				Schedule:         &types.Schedule{StartOffsetMS: -1, DurationMS: 0},
+			},
+			expectResponseCode: http.StatusUnprocessableEntity,
+			expectResponseData: newInvalidProblem(
+				types.ValidationError{Field: "schedule.offset", Rule: "not_negative", Message: "must not be before the start of the stream"},
+				types.ValidationError{Field: "schedule.duration", Rule: "positive", Message: "must be longer than zero"},
+			),
+			expectStoreNotCalled: true,
+		},
+		{
+			name: "returns unprocessable entity listing every broken rule",
+			requestBody: types.Buff{
+				VideoStreamUUID:  sentinelUUID.String(),
+				Question:         "",
+				CorrectAnswer:    "42",
+				IncorrectAnswers: []string{"42"},
+			},
+			expectResponseCode: http.StatusUnprocessableEntity,
+			expectResponseData: newInvalidProblem(
+				types.ValidationError{Field: "question", Rule: "required", Message: "must not be empty"},
+				types.ValidationError{Field: "answers[1].text", Rule: "unique", Message: "must not be repeated"},
+			),
+			expectStoreNotCalled: true,
+		},
+		{
+			name:                 "returns bad request on undecodable body",
+			readError:            errors.New("bad body"),
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, "bad body"),
+			expectStoreNotCalled: true,
+		},
+		{
+			name: "returns bad request on missformated stream uuid",
+			requestBody: types.Buff{
+				VideoStreamUUID: "not_a_valid_uuid",
+				Question:        "what's the answer to life, the universe, and everything?",
+				CorrectAnswer:   "42",
+			},
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, "invalid UUID length: 16"),
+			expectStoreNotCalled: true,
+		},
+		{
+			name: "returns unprocessable entity on unknown stream",
+			requestBody: types.Buff{
+				VideoStreamUUID:  sentinelUUID.String(),
+				Question:         "what's the answer to life, the universe, and everything?",
+				CorrectAnswer:    "42",
+				IncorrectAnswers: []string{"43"},
+			},
+			storeError:         model.ErrInvalidReference,
+			expectResponseCode: http.StatusUnprocessableEntity,
+			expectResponseData: newProblem(http.StatusUnprocessableEntity, model.ErrInvalidReference.Error()),
+		},
+		{
+			name: "returns conflict on duplicate data",
+			requestBody: types.Buff{
+				VideoStreamUUID:  sentinelUUID.String(),
+				Question:         "what's the answer to life, the universe, and everything?",
+				CorrectAnswer:    "42",
+				IncorrectAnswers: []string{"43"},
+			},
+			storeError:         model.ErrConflict,
+			expectResponseCode: http.StatusConflict,
+			expectResponseData: newProblem(http.StatusConflict, model.ErrConflict.Error()),
+		},
+		{
+			name: "returns internal error on unexpected store error",
+			requestBody: types.Buff{
+				VideoStreamUUID:  sentinelUUID.String(),
+				Question:         "what's the answer to life, the universe, and everything?",
+				CorrectAnswer:    "42",
+				IncorrectAnswers: []string{"43"},
+			},
+			storeError:         errors.New("the world exploded"),
+			expectResponseCode: http.StatusInternalServerError,
+			expectResponseData: newProblem(http.StatusInternalServerError, ""),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return the data configured in the test fixture
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("CreateBuff", mock.Anything, mock.Anything).Return(tt.storeError)
+
+			req, err := http.NewRequest("POST", "", nil)
+			require.NoError(t, err, "failed to build request for test")
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+			codec.On("Read", mock.Anything, mock.Anything, mock.Anything).Return(tt.readError).Run(func(args mock.Arguments) {
+				*args.Get(2).(*types.Buff) = tt.requestBody
+			})
+
+			// Create the handler under test, and execute it
+			handler := buff.NewCreateHandler(testingStore, newValidator(t))
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler responded only once
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			if tt.expectStoreNotCalled {
+				// assert that no calls to the store were made
+				testingStore.AssertNotCalled(t, "CreateBuff", mock.Anything, mock.Anything)
+				codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+				return
+			}
+
+			// The IDs are generated by the handler, so pull the created buff back out of the store call
+			testingStore.AssertNumberOfCalls(t, "CreateBuff", 1)
+			created := testingStore.Calls[0].Arguments.Get(1).(model.Buff)
+
+			assert.Equal(t, model.VideoStreamID(sentinelUUID), created.Stream)
+			assert.Equal(t, tt.requestBody.Question, created.Question)
+			assert.Equal(t, model.BuffKind(tt.requestBody.Kind), created.Kind)
+			assert.Equal(t, tt.requestBody.CorrectAnswer, types.NewBuff(created).CorrectAnswer)
+			assert.Equal(t, tt.requestBody.IncorrectAnswers, types.NewBuff(created).IncorrectAnswers)
+			assert.Equal(t, tt.requestBody.Answers, types.NewBuff(created).Answers)
+			assert.Equal(t, tt.requestBody.Schedule, types.NewSchedule(created.Schedule))
+			assert.Equal(t, tt.requestBody.Tags, created.Tags)
+			assert.False(t, created.CreatedAt.IsZero(), "the creation time should be set by the handler")
+
+			if tt.expectResponseData == nil {
+				tt.expectResponseData = types.NewBuff(created)
+			}
+			codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+		})
+	}
+}
+
+func TestCreateBuffForStream(t *testing.T) {
+	sentinelUUID := uuid.New()
+
+	var tests = []struct {
+		name                 string
+		requestParams        map[string]string
+		requestBody          types.Buff
+		storeError           error
+		expectResponseCode   int
+		expectResponseData   interface{}
+		expectStoreNotCalled bool
+	}{
+		{
+			name: "returns created on happy path using the stream from the url",
+			requestParams: map[string]string{
+				"uuid": sentinelUUID.String(),
+			},
+			requestBody: types.Buff{
+				VideoStreamUUID:  uuid.New().String(),
+				Question:         "what's the answer to life, the universe, and everything?",
+				CorrectAnswer:    "42",
+				IncorrectAnswers: []string{"43", "44"},
+			},
+			expectResponseCode: http.StatusCreated,
+		},
+		{
+			name: "returns bad request on missformated uuid",
+			requestParams: map[string]string{
+				"uuid": "not_a_valid_uuid",
+			},
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, "invalid UUID length: 16"),
+			expectStoreNotCalled: true,
+		},
+		{
+			name: "returns internal error on unexpected store error",
+			requestParams: map[string]string{
+				"uuid": sentinelUUID.String(),
+			},
+			requestBody: types.Buff{
+				Question:         "what's the answer to life, the universe, and everything?",
+				CorrectAnswer:    "42",
+				IncorrectAnswers: []string{"43"},
+			},
+			storeError:         errors.New("the world exploded"),
+			expectResponseCode: http.StatusInternalServerError,
+			expectResponseData: newProblem(http.StatusInternalServerError, ""),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return the data configured in the test fixture
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("CreateBuff", mock.Anything, mock.Anything).Return(tt.storeError)
+
+			// Build the request to the spec of the test fixture
+			rctx := chi.NewRouteContext()
+			for k, v := range tt.requestParams {
+				rctx.URLParams.Add(k, v)
+			}
+			req, err := http.NewRequest("POST", "", nil)
+			require.NoError(t, err, "failed to build request for test")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+			codec.On("Read", mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+				*args.Get(2).(*types.Buff) = tt.requestBody
+			})
+
+			// Create the handler under test, and execute it
+			handler := buff.NewCreateForStreamHandler(testingStore, newValidator(t))
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler responded only once
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			if tt.expectStoreNotCalled {
+				// assert that no calls to the store were made
+				testingStore.AssertNotCalled(t, "CreateBuff", mock.Anything, mock.Anything)
+				codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+				return
+			}
+
+			// The stream should always come from the url, not the body
+			testingStore.AssertNumberOfCalls(t, "CreateBuff", 1)
+			created := testingStore.Calls[0].Arguments.Get(1).(model.Buff)
+			assert.Equal(t, model.VideoStreamID(sentinelUUID), created.Stream)
+
+			if tt.expectResponseData == nil {
+				tt.expectResponseData = types.NewBuff(created)
+			}
+			codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+		})
+	}
+}
+
+// newValidator returns a validator using the default rules
+func newValidator(t *testing.T) *validation.Validator {
+	v, err := validation.New()
+	require.NoError(t, err, "failed to build validator")
+	return v
+}
+
+// newProblem returns the problem a handler is expected to respond with
+// The test requests have no path and no request id
+func newProblem(status int, detail string) types.Problem {
+	return types.Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// newInvalidProblem returns the problem a handler is expected to respond with
+// when the request breaks the given validation rules
+func newInvalidProblem(errs ...types.ValidationError) types.Problem {
+	p := newProblem(http.StatusUnprocessableEntity, fmt.Sprintf("the request breaks %d validation rules", len(errs)))
+	p.Errors = errs
+	return p
+}
@@ -0,0 +1,44 @@
+package buff
+
+import (
+	"net/http"
+
+	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/buffassignment/api/apierror"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+// NewDeleteHandler returns a new instance of the delete action of
+// the buff API using the given store instance.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewDeleteHandler(store model.BuffStore) apiutils.Handler {
+	return &buffDelete{store}
+}
+
+// buffDelete implements the apiutils.Handler interface to provide the
+// delete portion of the buff API
+type buffDelete struct {
+	store model.BuffStore
+}
+
+// ServeCodec serves the API using the apiutils.Handler pattern
+// This allows the business logic to live here, and the encoding to live separate from it
+// This also makes testing easier, as there is a test codec that allows us to peek at the output
+// in a testing context.
+func (b *buffDelete) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
+	bID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := b.store.DeleteBuff(r.Context(), model.BuffID(bID)); err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+	c.Respond(r.Context(), w, http.StatusNoContent, nil)
+}
@@ -4,7 +4,7 @@ import (
 	"net/http"
 
 	"github.com/JoeReid/apiutils"
-	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/api/apierror"
 	"github.com/JoeReid/buffassignment/internal/model"
 	"github.com/go-chi/chi"
 	"github.com/google/uuid"
@@ -13,16 +13,19 @@ import (
 // NewGetHandler returns a new instance of the get action of
 // the buff API using the given store instance.
 //
+// The buff is served in the authoring shape, revealing the correct answer.
+//
 // The store is provided as an argument for easy dependency injection in tests
 // I.E: using the testing mock store rather than a full DB for API testing
 func NewGetHandler(store model.BuffStore) apiutils.Handler {
-	return &buffGet{store}
+	return &buffGet{store, authoringView{}}
 }
 
 // buffGet implements the apiutils.Handler interface to provide the
 // get portion of the buff API
 type buffGet struct {
 	store model.BuffStore
+	view  view
 }
 
 // ServeCodec serves the API using the apiutils.Handler pattern
@@ -32,18 +35,21 @@ type buffGet struct {
 func (b *buffGet) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
 	bID, err := uuid.Parse(chi.URLParam(r, "uuid"))
 	if err != nil {
-		c.Respond(r.Context(), w, http.StatusBadRequest, err)
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	buff, err := b.store.GetBuff(model.BuffID(bID))
+	buff, err := b.store.GetBuff(r.Context(), model.BuffID(bID))
 	if err != nil {
-		if err == model.ErrNotFound {
-			c.Respond(r.Context(), w, http.StatusNotFound, err)
-			return
-		}
-		c.Respond(r.Context(), w, http.StatusInternalServerError, err)
+		apierror.Respond(c, w, r, apierror.Status(err), err)
 		return
 	}
-	c.Respond(r.Context(), w, http.StatusOK, types.NewBuff(*buff))
+
+	if err := b.view.stream(r, buff.Stream); err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+
+	body, err := b.view.buff(r, *buff)
+	respond(c, w, r, body, err)
 }
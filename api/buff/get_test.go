@@ -61,7 +61,7 @@ func TestGetBuffs(t *testing.T) {
 			},
 			storeResponse:      nil,
 			storeError:         model.ErrNotFound,
-			expectResponseData: model.ErrNotFound,
+			expectResponseData: newProblem(http.StatusNotFound, model.ErrNotFound.Error()),
 			expectResponseCode: http.StatusNotFound,
 		},
 		{
@@ -71,10 +71,20 @@ func TestGetBuffs(t *testing.T) {
 			},
 			storeResponse:        nil,
 			storeError:           nil,
-			expectResponseData:   errors.New("invalid UUID length: 16"),
+			expectResponseData:   newProblem(http.StatusBadRequest, "invalid UUID length: 16"),
 			expectResponseCode:   http.StatusBadRequest,
 			expectStoreNotCalled: true,
 		},
+		{
+			name: "returns service unavailable when the store can't be reached",
+			requestParams: map[string]string{
+				"uuid": sentinelUUID.String(),
+			},
+			storeResponse:      nil,
+			storeError:         model.ErrUnavailable,
+			expectResponseData: newProblem(http.StatusServiceUnavailable, ""),
+			expectResponseCode: http.StatusServiceUnavailable,
+		},
 		{
 			name: "returns internal error on unexpected store error",
 			requestParams: map[string]string{
@@ -82,7 +92,7 @@ func TestGetBuffs(t *testing.T) {
 			},
 			storeResponse:      nil,
 			storeError:         errors.New("the world exploded"),
-			expectResponseData: errors.New("the world exploded"),
+			expectResponseData: newProblem(http.StatusInternalServerError, ""),
 			expectResponseCode: http.StatusInternalServerError,
 		},
 	}
@@ -92,7 +102,7 @@ func TestGetBuffs(t *testing.T) {
 
 			// Setup the mock store object to return the data configured in the test fixture
 			testingStore := testmodel.NewModelMock()
-			testingStore.On("GetBuff", mock.Anything).Return(tt.storeResponse, tt.storeError)
+			testingStore.On("GetBuff", mock.Anything, mock.Anything).Return(tt.storeResponse, tt.storeError)
 
 			// Build the request to the spec of the test fixture
 			rctx := chi.NewRouteContext()
@@ -120,10 +130,10 @@ func TestGetBuffs(t *testing.T) {
 			// If the handler needs to use the store, assert it made the right call
 			if tt.expectStoreNotCalled {
 				// assert that no calls to the store were made
-				testingStore.AssertNotCalled(t, "GetBuff", mock.Anything)
+				testingStore.AssertNotCalled(t, "GetBuff", mock.Anything, mock.Anything)
 			} else {
 				// assert that the store was called with the correct uuid
-				testingStore.AssertCalled(t, "GetBuff", model.BuffID(sentinelUUID))
+				testingStore.AssertCalled(t, "GetBuff", mock.Anything, model.BuffID(sentinelUUID))
 			}
 		})
 	}
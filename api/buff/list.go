@@ -1,37 +1,95 @@
 package buff
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/JoeReid/apiutils"
-	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/api/apierror"
+	"github.com/JoeReid/buffassignment/api/paginate"
 	"github.com/JoeReid/buffassignment/internal/model"
 	"github.com/go-chi/chi"
 	"github.com/google/uuid"
 )
 
+const (
+	// ActiveAtKey is the URL param holding the offset into the stream, in milliseconds,
+	// at which the buffs of a stream should be active
+	ActiveAtKey = "active_at"
+	// TagKey is the URL param holding a tag the listed buffs have
+	// It can be given more than once, to list the buffs having every one of the tags
+	TagKey = "tag"
+)
+
+var (
+	// ErrInvalidActiveAt is returned when the active_at param is not a whole number of milliseconds
+	ErrInvalidActiveAt = errors.New("buff list error: active_at must be a whole number of milliseconds, not before the start of the stream")
+	// ErrActiveAtWithPagination is returned when a request sets active_at along with a cursor or skip
+	ErrActiveAtWithPagination = errors.New("buff list error: active_at cannot be used with a cursor or skip")
+)
+
+// activeAt reads the active_at param from the request
+// ok is false when the param is not set, in which case the buffs are listed as usual
+func activeAt(r *http.Request) (at time.Duration, ok bool, err error) {
+	query := r.URL.Query()
+
+	values, ok := query[ActiveAtKey]
+	if !ok {
+		return 0, false, nil
+	}
+
+	_, cursor := query[paginate.CursorKey]
+	if cursor || query.Get("skip") != "" {
+		return 0, true, ErrActiveAtWithPagination
+	}
+
+	ms, err := strconv.ParseInt(values[0], 10, 64)
+	if err != nil || ms < 0 {
+		return 0, true, ErrInvalidActiveAt
+	}
+	return time.Duration(ms) * time.Millisecond, true, nil
+}
+
+// buffQuery reads the filters of the list from the request
+func buffQuery(r *http.Request) model.BuffQuery {
+	query := model.BuffQuery{}
+	for _, tag := range r.URL.Query()[TagKey] {
+		if tag != "" {
+			query.Tags = append(query.Tags, tag)
+		}
+	}
+	return query
+}
+
 // NewListHandler returns a new instance of the list action of
 // the buff API using the given store instance.
 //
+// The buffs are served in the authoring shape, revealing the correct answers.
+//
 // The store is provided as an argument for easy dependency injection in tests
 // I.E: using the testing mock store rather than a full DB for API testing
 func NewListHandler(store model.BuffStore) apiutils.Handler {
-	return &buffList{store}
+	return &buffList{store, authoringView{}}
 }
 
 // NewListForStreamHandler returns a new instance of the list for stream action of
 // the buff API using the given store instance.
 //
+// The buffs are served in the authoring shape, revealing the correct answers.
+//
 // The store is provided as an argument for easy dependency injection in tests
 // I.E: using the testing mock store rather than a full DB for API testing
 func NewListForStreamHandler(store model.BuffStore) apiutils.Handler {
-	return &buffListForStream{store}
+	return &buffListForStream{store, authoringView{}}
 }
 
 // buffList implements the apiutils.Handler interface to provide the
 // list portion of the buff API
 type buffList struct {
 	store model.BuffStore
+	view  view
 }
 
 // ServeCodec serves the API using the apiutils.Handler pattern
@@ -39,28 +97,48 @@ type buffList struct {
 // This also makes testing easier, as there is a test codec that allows us to peek at the output
 // in a testing context.
 func (b *buffList) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
+	query := b.view.query(buffQuery(r))
+
+	after, count, ok, err := paginate.Cursor(r, apiutils.DefaultCount(10), apiutils.MaxCount(10))
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+	if ok {
+		// Ask for one extra buff to find out if there is a next page
+		buffs, err := b.store.ListBuffAfter(r.Context(), query, after, count+1)
+		if err != nil {
+			apierror.Respond(c, w, r, apierror.Status(err), err)
+			return
+		}
+		body, err := b.view.page(r, buffs, count)
+		respond(c, w, r, body, err)
+		return
+	}
+
 	count, skip, err := apiutils.Paginate(r, apiutils.DefaultCount(10), apiutils.MaxCount(10))
 	if err != nil {
-		c.Respond(r.Context(), w, http.StatusBadRequest, err)
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	buffs, err := b.store.ListBuff(count*skip, count)
+	buffs, err := b.store.ListBuff(r.Context(), query, count*skip, count)
 	if err != nil {
-		if err == model.ErrNotFound {
-			c.Respond(r.Context(), w, http.StatusOK, []types.Buff{})
+		if !errors.Is(err, model.ErrNotFound) {
+			apierror.Respond(c, w, r, apierror.Status(err), err)
 			return
 		}
-		c.Respond(r.Context(), w, http.StatusInternalServerError, err)
-		return
+		buffs = []model.Buff{}
 	}
-	c.Respond(r.Context(), w, http.StatusOK, types.NewBuffs(buffs))
+	body, err := b.view.buffs(r, buffs)
+	respond(c, w, r, body, err)
 }
 
 // buffListForStream implements the apiutils.Handler interface to provide the
 // list for stream portion of the buff API
 type buffListForStream struct {
 	store model.BuffStore
+	view  view
 }
 
 // ServeCodec serves the API using the apiutils.Handler pattern
@@ -70,19 +148,60 @@ type buffListForStream struct {
 func (b *buffListForStream) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
 	vID, err := uuid.Parse(chi.URLParam(r, "uuid"))
 	if err != nil {
-		c.Respond(r.Context(), w, http.StatusBadRequest, err)
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	// Assume the list is short, we can add pagination later if needed
-	buffs, err := b.store.ListBuffForStream(model.VideoStreamID(vID), 0, 0)
+	query := b.view.query(buffQuery(r))
+
+	at, ok, err := activeAt(r)
 	if err != nil {
-		if err == model.ErrNotFound {
-			c.Respond(r.Context(), w, http.StatusOK, []types.Buff{})
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := b.view.stream(r, model.VideoStreamID(vID)); err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+	if ok {
+		// Only a few buffs are open at once, so they are never paginated
+		buffs, err := b.store.ListBuffActiveForStream(r.Context(), model.VideoStreamID(vID), query, at)
+		if err != nil {
+			apierror.Respond(c, w, r, apierror.Status(err), err)
 			return
 		}
-		c.Respond(r.Context(), w, http.StatusInternalServerError, err)
+		body, err := b.view.buffs(r, buffs)
+		respond(c, w, r, body, err)
 		return
 	}
-	c.Respond(r.Context(), w, http.StatusOK, types.NewBuffs(buffs))
+
+	after, count, ok, err := paginate.Cursor(r, apiutils.DefaultCount(10), apiutils.MaxCount(10))
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+	if ok {
+		// Ask for one extra buff to find out if there is a next page
+		buffs, err := b.store.ListBuffForStreamAfter(r.Context(), model.VideoStreamID(vID), query, after, count+1)
+		if err != nil {
+			apierror.Respond(c, w, r, apierror.Status(err), err)
+			return
+		}
+		body, err := b.view.page(r, buffs, count)
+		respond(c, w, r, body, err)
+		return
+	}
+
+	// Without a cursor the whole list is returned, as it was before cursors were added
+	buffs, err := b.store.ListBuffForStream(r.Context(), model.VideoStreamID(vID), query, 0, 0)
+	if err != nil {
+		if !errors.Is(err, model.ErrNotFound) {
+			apierror.Respond(c, w, r, apierror.Status(err), err)
+			return
+		}
+		buffs = []model.Buff{}
+	}
+	body, err := b.view.buffs(r, buffs)
+	respond(c, w, r, body, err)
 }
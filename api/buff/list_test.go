@@ -6,9 +6,12 @@ import (
 	"net/http"
 	"net/url"
 	"testing"
+	"time"
 
+	"github.com/JoeReid/apiutils"
 	"github.com/JoeReid/apiutils/testingcodec"
 	"github.com/JoeReid/buffassignment/api/buff"
+	"github.com/JoeReid/buffassignment/api/paginate"
 	"github.com/JoeReid/buffassignment/api/types"
 	"github.com/JoeReid/buffassignment/internal/model"
 	"github.com/JoeReid/buffassignment/internal/model/testmodel"
@@ -77,7 +80,7 @@ func TestListBuffsForStream(t *testing.T) {
 			storeResponse:        nil,
 			storeError:           nil,
 			expectResponseCode:   http.StatusBadRequest,
-			expectResponseData:   errors.New("invalid UUID length: 16"),
+			expectResponseData:   newProblem(http.StatusBadRequest, "invalid UUID length: 16"),
 			expectStoreNotCalled: true,
 		},
 		{
@@ -88,7 +91,7 @@ func TestListBuffsForStream(t *testing.T) {
 			storeResponse:      nil,
 			storeError:         errors.New("the world exploded"),
 			expectResponseCode: http.StatusInternalServerError,
-			expectResponseData: errors.New("the world exploded"),
+			expectResponseData: newProblem(http.StatusInternalServerError, ""),
 		},
 	}
 	for _, tt := range tests {
@@ -96,7 +99,7 @@ func TestListBuffsForStream(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Setup the mock store object to return the data configured in the test fixture
 			testingStore := testmodel.NewModelMock()
-			testingStore.On("ListBuffForStream", mock.Anything, mock.Anything, mock.Anything).Return(tt.storeResponse, tt.storeError)
+			testingStore.On("ListBuffForStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(tt.storeResponse, tt.storeError)
 
 			// Build the request to the spec of the test fixture
 			rctx := chi.NewRouteContext()
@@ -124,10 +127,10 @@ func TestListBuffsForStream(t *testing.T) {
 			// If the handler needs to use the store, assert it made the right call
 			if tt.expectStoreNotCalled {
 				// assert that no calls to the store were made
-				testingStore.AssertNotCalled(t, "ListBuffForStream", mock.Anything, mock.Anything, mock.Anything)
+				testingStore.AssertNotCalled(t, "ListBuffForStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 			} else {
 				// assert that the store was called with the correct uuid
-				testingStore.AssertCalled(t, "ListBuffForStream", mock.Anything, 0, 0)
+				testingStore.AssertCalled(t, "ListBuffForStream", mock.Anything, mock.Anything, model.BuffQuery{}, 0, 0)
 			}
 		})
 	}
@@ -344,7 +347,7 @@ func TestListBuffs(t *testing.T) {
 			requestURLValues:     map[string]string{"count": "11"},
 			expectStoreNotCalled: true,
 			expectResponseCode:   http.StatusBadRequest,
-			expectResponseData:   errors.New("paginate error: count 11 must be < 10"),
+			expectResponseData:   newProblem(http.StatusBadRequest, "paginate error: count 11 must be < 10"),
 		},
 		{
 			name:             "custom pagination values correctly computed",
@@ -416,7 +419,7 @@ func TestListBuffs(t *testing.T) {
 			expectOffset:       0,
 			expectLimit:        10,
 			expectResponseCode: http.StatusInternalServerError,
-			expectResponseData: errors.New("the world exploded"),
+			expectResponseData: newProblem(http.StatusInternalServerError, ""),
 		},
 	}
 	for _, tt := range tests {
@@ -424,7 +427,7 @@ func TestListBuffs(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Setup the mock store object to return the data configured in the test fixture
 			testingStore := testmodel.NewModelMock()
-			testingStore.On("ListBuff", mock.Anything, mock.Anything, mock.Anything).Return(tt.storeResponse, tt.storeError)
+			testingStore.On("ListBuff", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(tt.storeResponse, tt.storeError)
 
 			// Build the request to the spec of the test fixture
 			req, err := http.NewRequest("GET", "", nil)
@@ -452,11 +455,453 @@ func TestListBuffs(t *testing.T) {
 			// If the handler needs to use the store, assert it made the right call
 			if tt.expectStoreNotCalled {
 				// assert that no calls to the store were made
-				testingStore.AssertNotCalled(t, "ListBuff", mock.Anything, mock.Anything)
+				testingStore.AssertNotCalled(t, "ListBuff", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 			} else {
 				// assert that the store was called with the correct uuid
-				testingStore.AssertCalled(t, "ListBuff", tt.expectOffset, tt.expectLimit)
+				testingStore.AssertCalled(t, "ListBuff", mock.Anything, model.BuffQuery{}, tt.expectOffset, tt.expectLimit)
 			}
 		})
 	}
 }
+
+// newCursorBuffs returns buffs for the cursor pagination tests
+func newCursorBuffs(n int, stream model.VideoStreamID) []model.Buff {
+	// Cursors hold UTC times without a monotonic clock reading
+	created := time.Now().UTC().Round(0)
+
+	buffs := make([]model.Buff, 0, n)
+	for i := 0; i < n; i++ {
+		buffs = append(buffs, model.Buff{
+			ID:        model.BuffID(uuid.New()),
+			Stream:    stream,
+			Question:  "what's the answer to life, the universe, and everything?",
+			CreatedAt: created,
+			Answers: []model.Answer{
+				{ID: model.AnswerID(uuid.New()), Text: "42", Correct: true},
+				{ID: model.AnswerID(uuid.New()), Text: "43", Correct: false},
+			},
+		})
+	}
+	return buffs
+}
+
+func TestListBuffsCursor(t *testing.T) {
+	buffs := newCursorBuffs(3, model.VideoStreamID(uuid.New()))
+	after := buffs[0].Cursor()
+
+	var tests = []struct {
+		name                 string
+		requestURLValues     map[string]string
+		storeResponse        []model.Buff
+		storeError           error
+		expectAfter          *model.Cursor
+		expectLimit          int
+		expectResponseCode   int
+		expectResponseData   interface{}
+		expectStoreNotCalled bool
+	}{
+		{
+			name:               "empty cursor returns the first page with the next cursor",
+			requestURLValues:   map[string]string{"cursor": "", "count": "2"},
+			storeResponse:      buffs,
+			expectAfter:        nil,
+			expectLimit:        3,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.BuffPage{
+				Buffs:      types.NewBuffs(buffs[:2]),
+				NextCursor: buffs[1].Cursor().String(),
+			},
+		},
+		{
+			name:               "last page has no next cursor",
+			requestURLValues:   map[string]string{"cursor": after.String()},
+			storeResponse:      buffs[1:],
+			expectAfter:        &after,
+			expectLimit:        11,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.BuffPage{
+				Buffs: types.NewBuffs(buffs[1:]),
+			},
+		},
+		{
+			name:                 "returns error on invalid cursor",
+			requestURLValues:     map[string]string{"cursor": "not a cursor"},
+			expectStoreNotCalled: true,
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, model.ErrInvalidCursor.Error()),
+		},
+		{
+			name:                 "returns error on skip with cursor",
+			requestURLValues:     map[string]string{"cursor": "", "skip": "2"},
+			expectStoreNotCalled: true,
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, paginate.ErrSkipWithCursor.Error()),
+		},
+		{
+			name:               "returns internal error on unexpected store error",
+			requestURLValues:   map[string]string{"cursor": ""},
+			storeResponse:      nil,
+			storeError:         errors.New("the world exploded"),
+			expectAfter:        nil,
+			expectLimit:        11,
+			expectResponseCode: http.StatusInternalServerError,
+			expectResponseData: newProblem(http.StatusInternalServerError, ""),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return the data configured in the test fixture
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("ListBuffAfter", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(tt.storeResponse, tt.storeError)
+
+			// Build the request to the spec of the test fixture
+			req, err := http.NewRequest("GET", "", nil)
+			require.NoError(t, err, "failed to build request for test")
+			vals := url.Values{}
+			for k, v := range tt.requestURLValues {
+				vals.Add(k, v)
+			}
+			req.URL.RawQuery = vals.Encode()
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+
+			// Create the handler under test, and execute it
+			handler := buff.NewListHandler(testingStore)
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler returns the expected data
+			codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+
+			// assert that the handler responded only once
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			// The offset pagination must never be used alongside a cursor
+			testingStore.AssertNotCalled(t, "ListBuff", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+			if tt.expectStoreNotCalled {
+				testingStore.AssertNotCalled(t, "ListBuffAfter", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				testingStore.AssertCalled(t, "ListBuffAfter", mock.Anything, model.BuffQuery{}, tt.expectAfter, tt.expectLimit)
+			}
+		})
+	}
+}
+
+func TestListBuffsForStreamCursor(t *testing.T) {
+	sentinelUUID := uuid.New()
+	buffs := newCursorBuffs(3, model.VideoStreamID(sentinelUUID))
+	after := buffs[0].Cursor()
+
+	var tests = []struct {
+		name                 string
+		requestURLValues     map[string]string
+		storeResponse        []model.Buff
+		storeError           error
+		expectAfter          *model.Cursor
+		expectLimit          int
+		expectResponseCode   int
+		expectResponseData   interface{}
+		expectStoreNotCalled bool
+	}{
+		{
+			name:               "empty cursor returns the first page with the next cursor",
+			requestURLValues:   map[string]string{"cursor": "", "count": "2"},
+			storeResponse:      buffs,
+			expectAfter:        nil,
+			expectLimit:        3,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.BuffPage{
+				Buffs:      types.NewBuffs(buffs[:2]),
+				NextCursor: buffs[1].Cursor().String(),
+			},
+		},
+		{
+			name:               "last page has no next cursor",
+			requestURLValues:   map[string]string{"cursor": after.String()},
+			storeResponse:      buffs[1:],
+			expectAfter:        &after,
+			expectLimit:        11,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.BuffPage{
+				Buffs: types.NewBuffs(buffs[1:]),
+			},
+		},
+		{
+			name:                 "returns error on invalid cursor",
+			requestURLValues:     map[string]string{"cursor": "not a cursor"},
+			expectStoreNotCalled: true,
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, model.ErrInvalidCursor.Error()),
+		},
+		{
+			name:               "returns internal error on unexpected store error",
+			requestURLValues:   map[string]string{"cursor": ""},
+			storeResponse:      nil,
+			storeError:         errors.New("the world exploded"),
+			expectAfter:        nil,
+			expectLimit:        11,
+			expectResponseCode: http.StatusInternalServerError,
+			expectResponseData: newProblem(http.StatusInternalServerError, ""),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return the data configured in the test fixture
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("ListBuffForStreamAfter", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(tt.storeResponse, tt.storeError)
+
+			// Build the request to the spec of the test fixture
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("uuid", sentinelUUID.String())
+
+			req, err := http.NewRequest("GET", "", nil)
+			require.NoError(t, err, "failed to build request for test")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			vals := url.Values{}
+			for k, v := range tt.requestURLValues {
+				vals.Add(k, v)
+			}
+			req.URL.RawQuery = vals.Encode()
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+
+			// Create the handler under test, and execute it
+			handler := buff.NewListForStreamHandler(testingStore)
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler returns the expected data
+			codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+
+			// assert that the handler responded only once
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			// The unpaginated list must never be used alongside a cursor
+			testingStore.AssertNotCalled(t, "ListBuffForStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+			if tt.expectStoreNotCalled {
+				testingStore.AssertNotCalled(t, "ListBuffForStreamAfter", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				testingStore.AssertCalled(t, "ListBuffForStreamAfter", mock.Anything, model.VideoStreamID(sentinelUUID), model.BuffQuery{}, tt.expectAfter, tt.expectLimit)
+			}
+		})
+	}
+}
+
+func TestListBuffsForStreamActiveAt(t *testing.T) {
+	sentinelUUID := uuid.New()
+	buffs := newCursorBuffs(2, model.VideoStreamID(sentinelUUID))
+	for i := range buffs {
+		buffs[i].Schedule = &model.Schedule{Offset: time.Duration(i) * time.Minute, Duration: 2 * time.Minute}
+	}
+
+	var tests = []struct {
+		name                 string
+		requestURLValues     map[string]string
+		storeResponse        []model.Buff
+		storeError           error
+		expectAt             time.Duration
+		expectResponseCode   int
+		expectResponseData   interface{}
+		expectStoreNotCalled bool
+	}{
+		{
+			name:               "happy path returns the active buffs",
+			requestURLValues:   map[string]string{"active_at": "90000"},
+			storeResponse:      buffs,
+			expectAt:           90 * time.Second,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.NewBuffs(buffs),
+		},
+		{
+			name:               "start of the stream",
+			requestURLValues:   map[string]string{"active_at": "0", "count": "1"},
+			storeResponse:      buffs[:1],
+			expectAt:           0,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.NewBuffs(buffs[:1]),
+		},
+		{
+			name:                 "returns bad request on negative offset",
+			requestURLValues:     map[string]string{"active_at": "-1"},
+			expectStoreNotCalled: true,
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, buff.ErrInvalidActiveAt.Error()),
+		},
+		{
+			name:                 "returns bad request on malformed offset",
+			requestURLValues:     map[string]string{"active_at": "1m30s"},
+			expectStoreNotCalled: true,
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, buff.ErrInvalidActiveAt.Error()),
+		},
+		{
+			name:                 "returns bad request with a cursor",
+			requestURLValues:     map[string]string{"active_at": "0", "cursor": ""},
+			expectStoreNotCalled: true,
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, buff.ErrActiveAtWithPagination.Error()),
+		},
+		{
+			name:                 "returns bad request with skip",
+			requestURLValues:     map[string]string{"active_at": "0", "skip": "1"},
+			expectStoreNotCalled: true,
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, buff.ErrActiveAtWithPagination.Error()),
+		},
+		{
+			name:               "returns internal error on unexpected store error",
+			requestURLValues:   map[string]string{"active_at": "0"},
+			storeResponse:      nil,
+			storeError:         errors.New("the world exploded"),
+			expectAt:           0,
+			expectResponseCode: http.StatusInternalServerError,
+			expectResponseData: newProblem(http.StatusInternalServerError, ""),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return the data configured in the test fixture
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("ListBuffActiveForStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(tt.storeResponse, tt.storeError)
+
+			// Build the request to the spec of the test fixture
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("uuid", sentinelUUID.String())
+
+			req, err := http.NewRequest("GET", "", nil)
+			require.NoError(t, err, "failed to build request for test")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			vals := url.Values{}
+			for k, v := range tt.requestURLValues {
+				vals.Add(k, v)
+			}
+			req.URL.RawQuery = vals.Encode()
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+
+			// Create the handler under test, and execute it
+			handler := buff.NewListForStreamHandler(testingStore)
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler returns the expected data
+			codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+
+			// assert that the handler responded only once
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			// The other lists must never be used alongside active_at
+			testingStore.AssertNotCalled(t, "ListBuffForStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			testingStore.AssertNotCalled(t, "ListBuffForStreamAfter", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+			if tt.expectStoreNotCalled {
+				testingStore.AssertNotCalled(t, "ListBuffActiveForStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				testingStore.AssertCalled(t, "ListBuffActiveForStream", mock.Anything, model.VideoStreamID(sentinelUUID), model.BuffQuery{}, tt.expectAt)
+			}
+		})
+	}
+}
+
+func TestListBuffsByTag(t *testing.T) {
+	sentinelUUID := uuid.New()
+	stream := model.VideoStreamID(sentinelUUID)
+	query := model.BuffQuery{Tags: []string{"sport", "football"}}
+
+	var tests = []struct {
+		name         string
+		handler      func(model.BuffStore) apiutils.Handler
+		rawQuery     string
+		expectMethod string
+		expectArgs   []interface{}
+	}{
+		{
+			name:         "list",
+			handler:      buff.NewListHandler,
+			rawQuery:     "tag=sport&tag=football",
+			expectMethod: "ListBuff",
+			expectArgs:   []interface{}{mock.Anything, query, 0, 10},
+		},
+		{
+			name:         "list with a cursor",
+			handler:      buff.NewListHandler,
+			rawQuery:     "tag=sport&tag=football&cursor=",
+			expectMethod: "ListBuffAfter",
+			expectArgs:   []interface{}{mock.Anything, query, (*model.Cursor)(nil), 11},
+		},
+		{
+			name:         "list for stream",
+			handler:      buff.NewListForStreamHandler,
+			rawQuery:     "tag=sport&tag=football",
+			expectMethod: "ListBuffForStream",
+			expectArgs:   []interface{}{mock.Anything, stream, query, 0, 0},
+		},
+		{
+			name:         "list for stream with a cursor",
+			handler:      buff.NewListForStreamHandler,
+			rawQuery:     "tag=sport&tag=football&cursor=",
+			expectMethod: "ListBuffForStreamAfter",
+			expectArgs:   []interface{}{mock.Anything, stream, query, (*model.Cursor)(nil), 11},
+		},
+		{
+			name:         "list active for stream",
+			handler:      buff.NewListForStreamHandler,
+			rawQuery:     "tag=sport&tag=football&active_at=0",
+			expectMethod: "ListBuffActiveForStream",
+			expectArgs:   []interface{}{mock.Anything, stream, query, time.Duration(0)},
+		},
+		{
+			name:         "empty tags are ignored",
+			handler:      buff.NewListHandler,
+			rawQuery:     "tag=",
+			expectMethod: "ListBuff",
+			expectArgs:   []interface{}{mock.Anything, model.BuffQuery{}, 0, 10},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return no buffs from any of the lists
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("ListBuff", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]model.Buff{}, nil)
+			testingStore.On("ListBuffAfter", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]model.Buff{}, nil)
+			testingStore.On("ListBuffForStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]model.Buff{}, nil)
+			testingStore.On("ListBuffForStreamAfter", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]model.Buff{}, nil)
+			testingStore.On("ListBuffActiveForStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]model.Buff{}, nil)
+
+			// Build the request to the spec of the test fixture
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("uuid", sentinelUUID.String())
+
+			req, err := http.NewRequest("GET", "", nil)
+			require.NoError(t, err, "failed to build request for test")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			req.URL.RawQuery = tt.rawQuery
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+
+			// Create the handler under test, and execute it
+			handler := tt.handler(testingStore)
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler responded only once, with a list
+			codec.AssertCalled(t, "Respond", mock.Anything, nil, http.StatusOK, mock.Anything)
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			// assert that the store was asked for the buffs with the tags
+			testingStore.AssertCalled(t, tt.expectMethod, tt.expectArgs...)
+		})
+	}
+}
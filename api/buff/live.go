@@ -0,0 +1,274 @@
+package buff
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/apiutils/tracer"
+	"github.com/JoeReid/buffassignment/api/apierror"
+	"github.com/JoeReid/buffassignment/internal/live"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/opentracing/opentracing-go"
+)
+
+const (
+	// liveReplayPage is how many missed buffs are read from the store at a time
+	liveReplayPage = 100
+
+	// liveRetry is how long clients wait before reconnecting
+	liveRetry = time.Second
+)
+
+// errStreamingUnsupported is returned when the connection can't be flushed,
+// which leaves no way of sending the events as they happen
+var errStreamingUnsupported = errors.New("the connection does not support streaming")
+
+// checkLive returns a *model.StreamNotLiveError unless the stream is live,
+// and so can have its buffs delivered
+func checkLive(ctx context.Context, store model.VideoStreamStore, id model.VideoStreamID) error {
+	stream, err := store.GetVideoStream(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if stream.State != model.StreamLive {
+		return &model.StreamNotLiveError{Stream: id, State: stream.State}
+	}
+	return nil
+}
+
+// NewLiveHandler returns a new instance of the live action of the buff API,
+// sending the buffs of a video stream to the client as Server-Sent Events
+// as soon as they are published to the hub.
+//
+// The buffs are sent in the shape shown to viewers, see NewViewerGetHandler.
+// Buffs are only delivered while the stream is live, connecting to any other stream is a conflict.
+// A client that reconnects with the Last-Event-ID header is first sent the buffs
+// it missed. The connection is closed after its max lifetime, which must be shorter
+// than the server's write timeout, and the client is expected to reconnect.
+//
+// The store and hub are provided as arguments for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewLiveHandler(store model.Store, hub *live.Hub, options ...LiveOption) apiutils.Handler {
+	return newLiveHandler(store, hub, newViewerView(store), options)
+}
+
+// NewV2LiveHandler returns a new instance of the live action of the v2 buff API
+// It is the same as NewLiveHandler, sending the buffs in the v2 shape shown to viewers.
+func NewV2LiveHandler(store model.Store, hub *live.Hub, options ...LiveOption) apiutils.Handler {
+	return newLiveHandler(store, hub, &viewerV2View{*newViewerView(store)}, options)
+}
+
+func newLiveHandler(store model.Store, hub *live.Hub, v view, options []LiveOption) *buffLive {
+	const (
+		defaultHeartbeat   = 3 * time.Second
+		defaultMaxLifetime = 9 * time.Second
+	)
+
+	b := &buffLive{
+		store:     store,
+		hub:       hub,
+		view:      v,
+		heartbeat: defaultHeartbeat,
+		lifetime:  defaultMaxLifetime,
+	}
+
+	for _, opt := range options {
+		opt(b)
+	}
+	return b
+}
+
+// LiveOption configures the live handlers
+type LiveOption func(*buffLive)
+
+// HeartbeatInterval sets how often an idle connection is sent a comment, to keep it open
+func HeartbeatInterval(d time.Duration) LiveOption {
+	return func(b *buffLive) {
+		b.heartbeat = d
+	}
+}
+
+// MaxLifetime sets how long a connection is kept before the client is asked to reconnect
+func MaxLifetime(d time.Duration) LiveOption {
+	return func(b *buffLive) {
+		b.lifetime = d
+	}
+}
+
+// buffLive implements the apiutils.Handler interface to provide the
+// live portion of the buff API
+type buffLive struct {
+	store     model.Store
+	hub       *live.Hub
+	view      view
+	heartbeat time.Duration
+	lifetime  time.Duration
+}
+
+// ServeCodec serves the API using the apiutils.Handler pattern
+//
+// The codec is only used for the errors found before the stream starts,
+// the events themselves are always JSON.
+func (b *buffLive) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
+	vID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+	stream := model.VideoStreamID(vID)
+
+	var after *model.Cursor
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		cursor, err := model.ParseCursor(id)
+		if err != nil {
+			apierror.Respond(c, w, r, http.StatusBadRequest, err)
+			return
+		}
+		after = &cursor
+	}
+
+	if err := checkLive(r.Context(), b.store, stream); err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		apierror.Respond(c, w, r, http.StatusInternalServerError, errStreamingUnsupported)
+		return
+	}
+
+	// Subscribe before catching up, so nothing created in between is missed
+	sub := b.hub.Subscribe(stream)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	if err := b.stream(w, flusher, r, sub, stream, after); err != nil {
+		if sp := opentracing.SpanFromContext(r.Context()); sp != nil {
+			tracer.SetError(sp, err)
+		}
+	}
+}
+
+// stream sends the events until the client goes away, the connection reaches its
+// max lifetime, or the subscription is dropped for falling behind
+func (b *buffLive) stream(
+	w io.Writer,
+	flusher http.Flusher,
+	r *http.Request,
+	sub *live.Subscription,
+	stream model.VideoStreamID,
+	after *model.Cursor,
+) error {
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", liveRetry.Milliseconds()); err != nil {
+		return err
+	}
+
+	// A new client is given an event ID without any data, which sets where it
+	// resumes from without dispatching an event, should it reconnect before any buffs are sent
+	if after == nil {
+		now := model.Cursor{CreatedAt: time.Now().UTC()}
+		if _, err := fmt.Fprintf(w, "id: %s\n\n", now); err != nil {
+			return err
+		}
+	}
+
+	// The buffs already sent while catching up may be published again
+	sent := make(map[model.BuffID]bool)
+	if after != nil {
+		err := replay(r, b.store, stream, *after, func(mb model.Buff) error {
+			sent[mb.ID] = true
+			return b.writeEvent(w, r, mb)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(b.heartbeat)
+	defer heartbeat.Stop()
+
+	lifetime := time.NewTimer(b.lifetime)
+	defer lifetime.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+
+		case <-lifetime.C:
+			return nil
+
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return err
+			}
+
+		case mb, ok := <-sub.Buffs():
+			if !ok {
+				// Dropped for falling behind, the client catches up when it reconnects
+				return nil
+			}
+			if sent[mb.ID] {
+				continue
+			}
+
+			if err := b.writeEvent(w, r, mb); err != nil {
+				return err
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent sends the buff as a single event, using its cursor as the event ID
+// so that a reconnecting client can be sent the buffs created after it
+func (b *buffLive) writeEvent(w io.Writer, r *http.Request, mb model.Buff) error {
+	body, err := b.view.buff(r, mb)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %s\nevent: buff\ndata: %s\n\n", mb.Cursor(), data)
+	return err
+}
+
+// replay sends every buff of the stream created after the cursor, oldest first,
+// reading them from the store a page at a time
+func replay(r *http.Request, store model.BuffStore, stream model.VideoStreamID, after model.Cursor, send func(model.Buff) error) error {
+	for {
+		buffs, err := store.ListBuffForStreamAfter(r.Context(), stream, model.BuffQuery{}, &after, liveReplayPage)
+		if err != nil && !errors.Is(err, model.ErrNotFound) {
+			return err
+		}
+
+		for _, mb := range buffs {
+			if err := send(mb); err != nil {
+				return err
+			}
+		}
+
+		if len(buffs) < liveReplayPage {
+			return nil
+		}
+		after = buffs[len(buffs)-1].Cursor()
+	}
+}
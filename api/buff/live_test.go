@@ -0,0 +1,303 @@
+package buff_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/apiutils/testingcodec"
+	"github.com/JoeReid/buffassignment/api/buff"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/live"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/model/memory"
+	"github.com/JoeReid/buffassignment/internal/model/testmodel"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newLiveStore returns a memory store publishing to a new hub, holding a single stream
+func newLiveStore(t *testing.T) (model.Store, *live.Hub, model.VideoStream) {
+	hub, err := live.NewHub()
+	require.NoError(t, err, "failed to create hub")
+
+	mem, err := memory.NewStore()
+	require.NoError(t, err, "failed to create store")
+
+	v := model.VideoStream{
+		ID:        model.VideoStreamID(uuid.New()),
+		Title:     "a stream",
+		State:     model.StreamLive,
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+	require.NoError(t, mem.CreateVideoStream(context.Background(), v), "failed to create video stream")
+
+	return live.NewStore(mem, hub), hub, v
+}
+
+// newLiveBuff returns a valid buff for the stream, created at the given time
+func newLiveBuff(stream model.VideoStreamID, created time.Time) model.Buff {
+	return model.Buff{
+		ID:       model.BuffID(uuid.New()),
+		Stream:   stream,
+		Question: "What is the meaning of life, the universe, and everything?",
+		Answers: []model.Answer{
+			{ID: model.AnswerID(uuid.New()), Text: "42", Correct: true},
+			{ID: model.AnswerID(uuid.New()), Text: "43", Correct: false},
+		},
+		CreatedAt: created.UTC(),
+	}
+}
+
+// openLive serves the handler, and connects to the live buffs of the stream
+func openLive(t *testing.T, handler http.Handler, stream model.VideoStreamID, lastEventID string) *bufio.Reader {
+	r := chi.NewRouter()
+	r.Method("GET", "/video_streams/{uuid}/buffs/live", handler)
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	req, err := http.NewRequest("GET", srv.URL+"/video_streams/"+stream.String()+"/buffs/live", nil)
+	require.NoError(t, err, "failed to build request for test")
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err, "failed to connect")
+	t.Cleanup(func() { resp.Body.Close() })
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	return bufio.NewReader(resp.Body)
+}
+
+// readEvent reads the fields of the next event, or io.EOF once the server closes the connection
+// Comments are returned under the empty field name
+func readEvent(t *testing.T, r *bufio.Reader) (map[string]string, error) {
+	event := make(map[string]string)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return event, nil
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		require.Len(t, parts, 2, "malformed line %q", line)
+		event[parts[0]] = strings.TrimPrefix(parts[1], " ")
+	}
+}
+
+// serveLive adapts the handler for the test server, its codec is only used for errors
+func serveLive(handler apiutils.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeCodec(testingcodec.New(), w, r)
+	})
+}
+
+func TestLiveBuffs(t *testing.T) {
+	store, hub, v := newLiveStore(t)
+	handler := buff.NewLiveHandler(store, hub, buff.HeartbeatInterval(20*time.Millisecond), buff.MaxLifetime(time.Second))
+
+	events := openLive(t, serveLive(handler), v.ID, "")
+
+	// The client is told how long to wait before reconnecting, and where to resume from
+	event, err := readEvent(t, events)
+	require.NoError(t, err, "failed to read event")
+	assert.Equal(t, "1000", event["retry"])
+
+	event, err = readEvent(t, events)
+	require.NoError(t, err, "failed to read event")
+	_, err = model.ParseCursor(event["id"])
+	assert.NoError(t, err, "the first event id should be a cursor")
+	assert.NotContains(t, event, "data")
+
+	// Buffs of other streams are not sent
+	other := newLiveBuff(model.VideoStreamID(uuid.New()), time.Now())
+	hub.Publish(other)
+
+	b := newLiveBuff(v.ID, time.Now())
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	// Skip over any heartbeats sent while the buff was created
+	for {
+		event, err = readEvent(t, events)
+		require.NoError(t, err, "failed to read event")
+		if _, ok := event[""]; !ok {
+			break
+		}
+		assert.Equal(t, "heartbeat", event[""])
+	}
+
+	assert.Equal(t, b.Cursor().String(), event["id"])
+	assert.Equal(t, "buff", event["event"])
+
+	// The buff is sent as it was stored
+	stored, err := store.GetBuff(context.Background(), b.ID)
+	require.NoError(t, err, "failed to get buff")
+
+	var sent types.ViewerBuff
+	require.NoError(t, json.Unmarshal([]byte(event["data"]), &sent), "the data should be JSON")
+	assert.Equal(t, types.NewViewerBuff(*stored, types.Viewing{}), sent)
+
+	// Heartbeats are sent until the connection reaches its max lifetime
+	heartbeats := 0
+	for {
+		event, err = readEvent(t, events)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err, "failed to read event")
+		assert.Equal(t, "heartbeat", event[""])
+		heartbeats++
+	}
+	assert.NotZero(t, heartbeats, "heartbeats should be sent while idle")
+}
+
+func TestLiveBuffsResume(t *testing.T) {
+	store, hub, v := newLiveStore(t)
+	handler := buff.NewLiveHandler(store, hub, buff.HeartbeatInterval(time.Hour), buff.MaxLifetime(100*time.Millisecond))
+
+	start := time.Now()
+	buffs := []model.Buff{
+		newLiveBuff(v.ID, start),
+		newLiveBuff(v.ID, start.Add(time.Second)),
+		newLiveBuff(v.ID, start.Add(2*time.Second)),
+	}
+	for _, b := range buffs {
+		require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+	}
+
+	// A client that saw the first buff is sent the others
+	events := openLive(t, serveLive(handler), v.ID, buffs[0].Cursor().String())
+
+	var ids []string
+	for {
+		event, err := readEvent(t, events)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err, "failed to read event")
+
+		if event["event"] == "buff" {
+			ids = append(ids, event["id"])
+		}
+	}
+	assert.Equal(t, []string{buffs[1].Cursor().String(), buffs[2].Cursor().String()}, ids)
+}
+
+func TestLiveBuffsErrors(t *testing.T) {
+	sentinelUUID := uuid.New()
+
+	var tests = []struct {
+		name                 string
+		requestParams        map[string]string
+		lastEventID          string
+		storeError           error
+		streamState          model.StreamState
+		expectResponseCode   int
+		expectResponseData   interface{}
+		expectStoreNotCalled bool
+	}{
+		{
+			name:                 "returns bad request on missformated uuid",
+			requestParams:        map[string]string{"uuid": "not_a_valid_uuid"},
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, "invalid UUID length: 16"),
+			expectStoreNotCalled: true,
+		},
+		{
+			name:                 "returns bad request on an invalid last event id",
+			requestParams:        map[string]string{"uuid": sentinelUUID.String()},
+			lastEventID:          "not a cursor",
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, model.ErrInvalidCursor.Error()),
+			expectStoreNotCalled: true,
+		},
+		{
+			name:               "returns not found when the stream doesn't exist",
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			storeError:         model.ErrNotFound,
+			expectResponseCode: http.StatusNotFound,
+			expectResponseData: newProblem(http.StatusNotFound, model.ErrNotFound.Error()),
+		},
+		{
+			name:               "returns conflict when the stream isn't live",
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			streamState:        model.StreamScheduled,
+			expectResponseCode: http.StatusConflict,
+			expectResponseData: newProblem(
+				http.StatusConflict,
+				(&model.StreamNotLiveError{Stream: model.VideoStreamID(sentinelUUID), State: model.StreamScheduled}).Error(),
+			),
+		},
+		{
+			name:               "returns internal error when the connection can't stream",
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			expectResponseCode: http.StatusInternalServerError,
+			expectResponseData: newProblem(http.StatusInternalServerError, ""),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return the data configured in the test fixture
+			state := tt.streamState
+			if state == "" {
+				state = model.StreamLive
+			}
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("GetVideoStream", mock.Anything, mock.Anything).Return(&model.VideoStream{State: state}, tt.storeError)
+
+			hub, err := live.NewHub()
+			require.NoError(t, err, "failed to create hub")
+
+			// Build the request to the spec of the test fixture
+			rctx := chi.NewRouteContext()
+			for k, v := range tt.requestParams {
+				rctx.URLParams.Add(k, v)
+			}
+			req, err := http.NewRequest("GET", "", nil)
+			require.NoError(t, err, "failed to build request for test")
+			if tt.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+
+			// Create the handler under test, and execute it
+			// The testing codec has no connection to write to, let alone flush
+			handler := buff.NewLiveHandler(testingStore, hub)
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler returns the expected data, only once
+			codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			if tt.expectStoreNotCalled {
+				testingStore.AssertNotCalled(t, "GetVideoStream", mock.Anything, mock.Anything)
+			} else {
+				testingStore.AssertCalled(t, "GetVideoStream", mock.Anything, model.VideoStreamID(sentinelUUID))
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package buff
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/buffassignment/api/apierror"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/validation"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+// NewResolveHandler returns a new instance of the resolve action of
+// the buff API using the given store instance.
+//
+// The prediction being resolved is read from the URL, and the winning answer and who
+// resolved it from the request body. Resolving a prediction again to the same answer
+// returns the first resolution, so a request can be safely retried.
+//
+// The resolution is checked against the rules of the given validator before it is stored.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewResolveHandler(store model.BuffStore, validator *validation.Validator) apiutils.Handler {
+	return &buffResolve{store, validator}
+}
+
+// buffResolve implements the apiutils.Handler interface to provide the
+// resolve portion of the buff API
+type buffResolve struct {
+	store     model.BuffStore
+	validator *validation.Validator
+}
+
+// ServeCodec serves the API using the apiutils.Handler pattern
+// This allows the business logic to live here, and the encoding to live separate from it
+// This also makes testing easier, as there is a test codec that allows us to peek at the output
+// in a testing context.
+func (b *buffResolve) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
+	bID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var req types.Resolution
+	if err := c.Read(r.Context(), r, &req); err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	aID, err := uuid.Parse(req.AnswerUUID)
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	res := model.Resolution{
+		Answer:     model.AnswerID(aID),
+		ResolvedBy: req.ResolvedBy,
+		ResolvedAt: time.Now().UTC(),
+	}
+
+	if err := b.validator.Resolution(res); err != nil {
+		apierror.RespondInvalid(c, w, r, err)
+		return
+	}
+
+	mb, err := b.store.ResolveBuff(r.Context(), model.BuffID(bID), res)
+	if err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+	c.Respond(r.Context(), w, http.StatusOK, types.NewResolution(mb.ID, *mb.Resolution))
+}
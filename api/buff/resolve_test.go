@@ -0,0 +1,199 @@
+package buff_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/JoeReid/apiutils/testingcodec"
+	"github.com/JoeReid/buffassignment/api/buff"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/model/testmodel"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// resolvedViewerBuff returns newViewerBuff as a prediction resolved to its second answer by the given user
+func resolvedViewerBuff(id, by string) model.Buff {
+	mb := newViewerBuff(id)
+	mb.Kind = model.BuffPrediction
+	mb.Answers[0].Correct = false
+	mb.Answers[1].Correct = true
+	mb.Resolution = &model.Resolution{
+		Answer:     mb.Answers[1].ID,
+		ResolvedBy: by,
+		ResolvedAt: time.Date(2020, 6, 1, 20, 45, 0, 0, time.UTC),
+		Responses:  12,
+		Correct:    5,
+	}
+	return mb
+}
+
+func TestResolveBuff(t *testing.T) {
+	const winner = "00000000-0000-0000-0000-000000000001"
+
+	resolved := resolvedViewerBuff(viewerBuffA, "match-official")
+	again := resolvedViewerBuff(viewerBuffA, "someone-else")
+	notPrediction := &model.BuffKindError{Buff: resolved.ID, Kind: model.BuffQuiz}
+	otherAnswer := &model.BuffResolvedError{Buff: resolved.ID, Answer: resolved.Answers[2].ID}
+
+	var tests = []struct {
+		name                 string
+		requestUUID          string
+		requestBody          types.Resolution
+		readError            error
+		storeResponse        *model.Buff
+		storeError           error
+		expectResponseCode   int
+		expectResponseData   interface{}
+		expectStoreNotCalled bool
+	}{
+		{
+			name:               "returns ok with the resolution on happy path",
+			requestUUID:        viewerBuffA,
+			requestBody:        types.Resolution{AnswerUUID: winner, ResolvedBy: "match-official"},
+			storeResponse:      &resolved,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.Resolution{
+				BuffUUID:         viewerBuffA,
+				AnswerUUID:       winner,
+				ResolvedBy:       "match-official",
+				ResolvedAt:       time.Date(2020, 6, 1, 20, 45, 0, 0, time.UTC),
+				Responses:        12,
+				CorrectResponses: 5,
+			},
+		},
+		{
+			name:               "returns the first resolution when resolved again",
+			requestUUID:        viewerBuffA,
+			requestBody:        types.Resolution{AnswerUUID: winner, ResolvedBy: "match-official"},
+			storeResponse:      &again,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.NewResolution(again.ID, *again.Resolution),
+		},
+		{
+			name:                 "returns bad request on missformated buff uuid",
+			requestUUID:          "not_a_valid_uuid",
+			requestBody:          types.Resolution{AnswerUUID: winner, ResolvedBy: "match-official"},
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, "invalid UUID length: 16"),
+			expectStoreNotCalled: true,
+		},
+		{
+			name:                 "returns bad request on undecodable body",
+			requestUUID:          viewerBuffA,
+			readError:            errors.New("bad body"),
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, "bad body"),
+			expectStoreNotCalled: true,
+		},
+		{
+			name:                 "returns bad request on missformated answer uuid",
+			requestUUID:          viewerBuffA,
+			requestBody:          types.Resolution{AnswerUUID: "not_a_valid_uuid", ResolvedBy: "match-official"},
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, "invalid UUID length: 16"),
+			expectStoreNotCalled: true,
+		},
+		{
+			name:               "returns unprocessable entity without who resolved it",
+			requestUUID:        viewerBuffA,
+			requestBody:        types.Resolution{AnswerUUID: winner},
+			expectResponseCode: http.StatusUnprocessableEntity,
+			expectResponseData: newInvalidProblem(
+				types.ValidationError{Field: "resolved_by", Rule: "required", Message: "must not be empty"},
+			),
+			expectStoreNotCalled: true,
+		},
+		{
+			name:               "returns not found on unknown buff",
+			requestUUID:        viewerBuffA,
+			requestBody:        types.Resolution{AnswerUUID: winner, ResolvedBy: "match-official"},
+			storeError:         model.ErrNotFound,
+			expectResponseCode: http.StatusNotFound,
+			expectResponseData: newProblem(http.StatusNotFound, model.ErrNotFound.Error()),
+		},
+		{
+			name:               "returns conflict on a buff that isn't a prediction",
+			requestUUID:        viewerBuffA,
+			requestBody:        types.Resolution{AnswerUUID: winner, ResolvedBy: "match-official"},
+			storeError:         notPrediction,
+			expectResponseCode: http.StatusConflict,
+			expectResponseData: newProblem(http.StatusConflict, notPrediction.Error()),
+		},
+		{
+			name:               "returns conflict on a buff resolved to another answer",
+			requestUUID:        viewerBuffA,
+			requestBody:        types.Resolution{AnswerUUID: winner, ResolvedBy: "match-official"},
+			storeError:         otherAnswer,
+			expectResponseCode: http.StatusConflict,
+			expectResponseData: newProblem(http.StatusConflict, otherAnswer.Error()),
+		},
+		{
+			name:               "returns unprocessable entity on an answer to another buff",
+			requestUUID:        viewerBuffA,
+			requestBody:        types.Resolution{AnswerUUID: winner, ResolvedBy: "match-official"},
+			storeError:         model.ErrInvalidReference,
+			expectResponseCode: http.StatusUnprocessableEntity,
+			expectResponseData: newProblem(http.StatusUnprocessableEntity, model.ErrInvalidReference.Error()),
+		},
+		{
+			name:               "returns internal error on unexpected store error",
+			requestUUID:        viewerBuffA,
+			requestBody:        types.Resolution{AnswerUUID: winner, ResolvedBy: "match-official"},
+			storeError:         errors.New("the world exploded"),
+			expectResponseCode: http.StatusInternalServerError,
+			expectResponseData: newProblem(http.StatusInternalServerError, ""),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return the data configured in the test fixture
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("ResolveBuff", mock.Anything, mock.Anything, mock.Anything).Return(tt.storeResponse, tt.storeError)
+
+			// Build the request to the spec of the test fixture
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("uuid", tt.requestUUID)
+			req, err := http.NewRequest("POST", "", nil)
+			require.NoError(t, err, "failed to build request for test")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+			codec.On("Read", mock.Anything, mock.Anything, mock.Anything).Return(tt.readError).Run(func(args mock.Arguments) {
+				*args.Get(2).(*types.Resolution) = tt.requestBody
+			})
+
+			// Create the handler under test, and execute it
+			handler := buff.NewResolveHandler(testingStore, newValidator(t))
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler returns the expected data, only once
+			codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			if tt.expectStoreNotCalled {
+				testingStore.AssertNotCalled(t, "ResolveBuff", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+
+			// The time of the resolution is set by the handler, so pull it back out of the store call
+			testingStore.AssertNumberOfCalls(t, "ResolveBuff", 1)
+			assert.Equal(t, resolved.ID, testingStore.Calls[0].Arguments.Get(1))
+
+			res := testingStore.Calls[0].Arguments.Get(2).(model.Resolution)
+			assert.Equal(t, model.AnswerID(uuid.MustParse(winner)), res.Answer)
+			assert.Equal(t, tt.requestBody.ResolvedBy, res.ResolvedBy)
+			assert.False(t, res.ResolvedAt.IsZero(), "the resolution time should be set by the handler")
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package buff
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/buffassignment/api/apierror"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+)
+
+// SearchKey is the URL param holding the text to search the buffs for
+const SearchKey = "q"
+
+// ErrEmptySearch is returned when a search is made without any text to search for
+var ErrEmptySearch = errors.New("buff search error: q must not be empty")
+
+// NewSearchHandler returns a new instance of the search action of
+// the buff API, serving the buffs matching the q param in the shape they are written in.
+//
+// The matches are ranked, best first, and paginated with count and skip.
+// Every buff is searched whatever the state of its stream, so it is only served to authors.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewSearchHandler(store model.Store) apiutils.Handler {
+	return &buffSearch{store, func(mms []model.BuffMatch) interface{} {
+		return types.NewBuffMatches(mms)
+	}}
+}
+
+// NewV2SearchHandler returns a new instance of the search action of
+// the v2 buff API, serving the buffs matching the q param in the v2 authoring shape.
+//
+// The matches are ranked and served in the same way as by NewSearchHandler.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewV2SearchHandler(store model.Store) apiutils.Handler {
+	return &buffSearch{store, func(mms []model.BuffMatch) interface{} {
+		return types.NewBuffMatchesV2(mms, authoringV2View{}.convert)
+	}}
+}
+
+// buffSearch implements the apiutils.Handler interface to provide the
+// search portion of the buff API
+//
+// The matches are converted into the shape of the version of the API serving them.
+type buffSearch struct {
+	store   model.BuffStore
+	matches func(mms []model.BuffMatch) interface{}
+}
+
+// ServeCodec serves the API using the apiutils.Handler pattern
+// This allows the business logic to live here, and the encoding to live separate from it
+// This also makes testing easier, as there is a test codec that allows us to peek at the output
+// in a testing context.
+func (b *buffSearch) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get(SearchKey))
+	if query == "" {
+		apierror.Respond(c, w, r, http.StatusBadRequest, ErrEmptySearch)
+		return
+	}
+
+	count, skip, err := apiutils.Paginate(r, apiutils.DefaultCount(10), apiutils.MaxCount(10))
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	matches, err := b.store.SearchBuffs(r.Context(), query, count*skip, count)
+	if err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+	c.Respond(r.Context(), w, http.StatusOK, b.matches(matches))
+}
@@ -0,0 +1,175 @@
+package buff_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/JoeReid/apiutils/testingcodec"
+	"github.com/JoeReid/buffassignment/api/buff"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/model/testmodel"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchBuffs(t *testing.T) {
+	mb := newViewerBuff(viewerBuffA)
+	match := model.BuffMatch{
+		Buff:              mb,
+		Rank:              0.5,
+		QuestionHighlight: "what's the <mark>answer</mark> to life, the universe, and everything?",
+		AnswerHighlights: map[model.AnswerID]string{
+			mb.Answers[0].ID: "<mark>42</mark>",
+			mb.Answers[1].ID: "<mark>43</mark>",
+		},
+	}
+
+	// The matched buff is served as it is written, with the correct answer
+	written := types.Buff{
+		UUID:             viewerBuffA,
+		VideoStreamUUID:  "00000000-0000-0000-0000-0000000000ff",
+		Question:         "what's the answer to life, the universe, and everything?",
+		CorrectAnswer:    "42",
+		IncorrectAnswers: []string{"43", "44"},
+	}
+
+	// The highlighted answers are listed in the order of their IDs
+	highlight := types.BuffHighlight{
+		Question: "what's the <mark>answer</mark> to life, the universe, and everything?",
+		Answers: []types.AnswerHighlight{
+			{UUID: "00000000-0000-0000-0000-000000000001", Text: "<mark>43</mark>"},
+			{UUID: "00000000-0000-0000-0000-000000000003", Text: "<mark>42</mark>"},
+		},
+	}
+
+	var tests = []struct {
+		name                 string
+		requestURLValues     url.Values
+		storeResponse        []model.BuffMatch
+		storeError           error
+		expectQuery          string
+		expectOffset         int
+		expectLimit          int
+		expectResponseCode   int
+		expectResponseData   interface{}
+		expectStoreNotCalled bool
+	}{
+		{
+			name:               "happy path returns ok",
+			requestURLValues:   url.Values{"q": {" answer "}},
+			storeResponse:      []model.BuffMatch{match},
+			expectQuery:        "answer",
+			expectLimit:        10,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: []types.BuffMatch{{Buff: written, Rank: 0.5, Highlight: highlight}},
+		},
+		{
+			name:               "paginated",
+			requestURLValues:   url.Values{"q": {"answer"}, "count": {"5"}, "skip": {"2"}},
+			storeResponse:      []model.BuffMatch{},
+			expectQuery:        "answer",
+			expectOffset:       10,
+			expectLimit:        5,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: []types.BuffMatch{},
+		},
+		{
+			name:                 "returns bad request without a query",
+			requestURLValues:     url.Values{"q": {"  "}},
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, buff.ErrEmptySearch.Error()),
+			expectStoreNotCalled: true,
+		},
+		{
+			name:               "returns service unavailable when the store can't be reached",
+			requestURLValues:   url.Values{"q": {"answer"}},
+			storeError:         model.ErrUnavailable,
+			expectQuery:        "answer",
+			expectLimit:        10,
+			expectResponseCode: http.StatusServiceUnavailable,
+			expectResponseData: newProblem(http.StatusServiceUnavailable, ""),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return the data configured in the test fixture
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("SearchBuffs", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(tt.storeResponse, tt.storeError)
+
+			// Build the request to the spec of the test fixture
+			req, err := http.NewRequest("GET", "", nil)
+			require.NoError(t, err, "failed to build request for test")
+			req.URL.RawQuery = tt.requestURLValues.Encode()
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+
+			// Create the handler under test, and execute it
+			handler := buff.NewSearchHandler(testingStore)
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler returns the expected data
+			codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+
+			// assert that the handler responded only once
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			if tt.expectStoreNotCalled {
+				testingStore.AssertNotCalled(t, "SearchBuffs", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				testingStore.AssertCalled(t, "SearchBuffs", mock.Anything, tt.expectQuery, tt.expectOffset, tt.expectLimit)
+			}
+		})
+	}
+}
+
+func TestV2SearchBuffs(t *testing.T) {
+	mb := newViewerBuff(viewerBuffA)
+	match := model.BuffMatch{
+		Buff:              mb,
+		Rank:              0.5,
+		QuestionHighlight: "what's the <mark>answer</mark> to life, the universe, and everything?",
+		AnswerHighlights:  map[model.AnswerID]string{mb.Answers[0].ID: "<mark>42</mark>"},
+	}
+
+	// Setup the mock store object to return the match
+	testingStore := testmodel.NewModelMock()
+	testingStore.On("SearchBuffs", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]model.BuffMatch{match}, nil)
+
+	// Build the request
+	req, err := http.NewRequest("GET", "?q=answer", nil)
+	require.NoError(t, err, "failed to build request for test")
+
+	// Use the testing codec to assert handler behaviour
+	codec := testingcodec.New()
+	codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+
+	// Create the handler under test, and execute it
+	handler := buff.NewV2SearchHandler(testingStore)
+	handler.ServeCodec(codec, nil, req)
+
+	// assert that the handler returns the match in the v2 authoring shape, only once
+	codec.AssertCalled(t, "Respond", mock.Anything, nil, http.StatusOK, []types.BuffMatchV2{{
+		Buff: types.BuffV2{
+			UUID:            viewerBuffA,
+			VideoStreamUUID: "00000000-0000-0000-0000-0000000000ff",
+			Question:        "what's the answer to life, the universe, and everything?",
+			Answers: []types.AnswerV2{
+				{UUID: "00000000-0000-0000-0000-000000000003", Text: "42", Correct: boolPtr(true)},
+				{UUID: "00000000-0000-0000-0000-000000000001", Text: "43", Correct: boolPtr(false)},
+				{UUID: "00000000-0000-0000-0000-000000000002", Text: "44", Correct: boolPtr(false)},
+			},
+			Links: v2Links("/v2/admin", viewerBuffA),
+		},
+		Rank: 0.5,
+		Highlight: types.BuffHighlight{
+			Question: "what's the <mark>answer</mark> to life, the universe, and everything?",
+			Answers:  []types.AnswerHighlight{{UUID: "00000000-0000-0000-0000-000000000003", Text: "<mark>42</mark>"}},
+		},
+	}})
+	codec.AssertNumberOfCalls(t, "Respond", 1)
+}
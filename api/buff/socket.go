@@ -0,0 +1,446 @@
+package buff
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/apiutils/tracer"
+	"github.com/JoeReid/buffassignment/api/apierror"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/live"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/validation"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/opentracing/opentracing-go"
+)
+
+const (
+	// socketWriteTimeout bounds writing a single message to the client
+	socketWriteTimeout = 10 * time.Second
+
+	// socketMaxMessage is the largest message the client may send, in bytes
+	socketMaxMessage = 4096
+
+	// socketReplies is how many replies can wait to be written before the server stops
+	// reading the client's messages, which pushes back on a client that won't read its replies
+	socketReplies = 16
+)
+
+var (
+	errNotSubscribed      = errors.New("the first message must be a subscribe message")
+	errAlreadySubscribed  = errors.New("the connection is already subscribed")
+	errUnsupportedVersion = fmt.Errorf("the only supported protocol version is %d", types.SocketVersion)
+	errUnknownMessage     = errors.New("unknown message type")
+)
+
+// NewSocketHandler returns a new instance of the socket action of the buff API,
+// a WebSocket over which a viewer follows the buffs of a video stream and answers them.
+//
+// The viewer is the user in the user_id param. Once subscribed, they are sent the buffs
+// of the stream as they are created, in the shape shown to viewers, see NewViewerGetHandler,
+// and the results of the buffs as they change. A buff they answer is sent again with its
+// answers revealed. Only a live stream can be followed, connecting to any other stream is a conflict.
+//
+// The store and hub are provided as arguments for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewSocketHandler(store model.Store, hub *live.Hub, validator *validation.Validator, options ...SocketOption) apiutils.Handler {
+	return newSocketHandler(store, hub, validator, newViewerView(store), options)
+}
+
+// NewV2SocketHandler returns a new instance of the socket action of the v2 buff API
+// It is the same as NewSocketHandler, sending the buffs in the v2 shape shown to viewers.
+func NewV2SocketHandler(store model.Store, hub *live.Hub, validator *validation.Validator, options ...SocketOption) apiutils.Handler {
+	return newSocketHandler(store, hub, validator, &viewerV2View{*newViewerView(store)}, options)
+}
+
+func newSocketHandler(store model.Store, hub *live.Hub, validator *validation.Validator, v view, options []SocketOption) *buffSocket {
+	const defaultPingInterval = 20 * time.Second
+
+	b := &buffSocket{
+		store:     store,
+		hub:       hub,
+		validator: validator,
+		view:      v,
+		ping:      defaultPingInterval,
+	}
+
+	for _, opt := range options {
+		opt(b)
+	}
+	return b
+}
+
+// SocketOption configures the socket handlers
+type SocketOption func(*buffSocket)
+
+// PingInterval sets how often the client is pinged
+// A client that hasn't answered, or sent anything else, for twice as long is disconnected.
+func PingInterval(d time.Duration) SocketOption {
+	return func(b *buffSocket) {
+		b.ping = d
+	}
+}
+
+// buffSocket implements the apiutils.Handler interface to provide the
+// socket portion of the buff API
+type buffSocket struct {
+	store     model.Store
+	hub       *live.Hub
+	validator *validation.Validator
+	view      view
+	ping      time.Duration
+}
+
+// ServeCodec serves the API using the apiutils.Handler pattern
+//
+// The codec is only used for the errors found before the connection is upgraded,
+// the messages themselves are always JSON.
+func (b *buffSocket) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
+	vID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+	stream := model.VideoStreamID(vID)
+
+	if err := checkLive(r.Context(), b.store, stream); err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+
+	upgrader := websocket.Upgrader{
+		Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+			apierror.Respond(c, w, r, status, reason)
+		},
+	}
+
+	// The upgrader has already responded if it fails
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	sc := &socketConn{
+		buffSocket: b,
+		conn:       conn,
+		r:          r,
+		stream:     stream,
+		user:       model.UserID(r.URL.Query().Get(UserKey)),
+		replies:    make(chan types.SocketMessage, socketReplies),
+		read:       make(chan struct{}),
+		written:    make(chan struct{}),
+	}
+	if err := sc.serve(); err != nil {
+		if sp := opentracing.SpanFromContext(r.Context()); sp != nil {
+			tracer.SetError(sp, err)
+		}
+	}
+}
+
+// socketConn is a single viewer's connection to the socket
+//
+// Only the writing goroutine writes messages, the reading goroutine
+// passes its replies on through the replies channel.
+type socketConn struct {
+	*buffSocket
+	conn   *websocket.Conn
+	r      *http.Request
+	stream model.VideoStreamID
+	user   model.UserID
+
+	replies chan types.SocketMessage
+
+	// read and written are closed when the reading and writing goroutines stop
+	read    chan struct{}
+	written chan struct{}
+}
+
+// serve subscribes the connection, then sends and receives messages until either side stops
+func (sc *socketConn) serve() error {
+	defer sc.conn.Close()
+
+	pongTimeout := 2 * sc.ping
+	sc.conn.SetReadLimit(socketMaxMessage)
+	sc.conn.SetReadDeadline(time.Now().Add(pongTimeout))
+	sc.conn.SetPongHandler(func(string) error {
+		return sc.conn.SetReadDeadline(time.Now().Add(pongTimeout))
+	})
+
+	msg, err := sc.receive(pongTimeout)
+	if err != nil {
+		return nil
+	}
+
+	after, err := parseSubscription(msg)
+	if err != nil {
+		sc.write(sc.problem(msg.ID, apierror.NewProblem(sc.r, http.StatusBadRequest, err)))
+		sc.close(websocket.ClosePolicyViolation, err.Error())
+		return nil
+	}
+
+	// Subscribe before catching up, so nothing created in between is missed
+	sub := sc.hub.Subscribe(sc.stream)
+	defer sub.Close()
+
+	if err := sc.write(types.SocketMessage{Type: types.SocketAck, Ref: msg.ID}); err != nil {
+		return err
+	}
+
+	// The buffs already sent while catching up may be published again
+	sent := make(map[model.BuffID]bool)
+	if after != nil {
+		err := replay(sc.r, sc.store, sc.stream, *after, func(mb model.Buff) error {
+			sent[mb.ID] = true
+			return sc.writeBuff(mb)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	go sc.readLoop(pongTimeout)
+	err = sc.writeLoop(sub, sent)
+
+	// Closing the connection stops the reading goroutine, which must be done with the request
+	sc.conn.Close()
+	<-sc.read
+	return err
+}
+
+// parseSubscription checks the message subscribes with a supported version of the protocol,
+// returning the cursor of the last buff the client was sent, if any
+func parseSubscription(msg types.SocketMessage) (*model.Cursor, error) {
+	if msg.Type != types.SocketSubscribe {
+		return nil, errNotSubscribed
+	}
+
+	var req types.SocketSubscription
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		return nil, err
+	}
+
+	if req.Version != types.SocketVersion {
+		return nil, errUnsupportedVersion
+	}
+
+	if req.After == "" {
+		return nil, nil
+	}
+
+	cursor, err := model.ParseCursor(req.After)
+	if err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// readLoop handles the client's messages until the connection is closed
+func (sc *socketConn) readLoop(pongTimeout time.Duration) {
+	defer close(sc.read)
+
+	for {
+		msg, err := sc.receive(pongTimeout)
+		if err != nil {
+			return
+		}
+
+		for _, reply := range sc.handle(msg) {
+			select {
+			case sc.replies <- reply:
+			case <-sc.written:
+				return
+			}
+		}
+	}
+}
+
+// receive reads the next message, which counts as a sign of life from the client
+// A message that isn't a SocketMessage is returned with no type, and is replied to as unknown.
+func (sc *socketConn) receive(pongTimeout time.Duration) (types.SocketMessage, error) {
+	_, data, err := sc.conn.ReadMessage()
+	if err != nil {
+		return types.SocketMessage{}, err
+	}
+	sc.conn.SetReadDeadline(time.Now().Add(pongTimeout))
+
+	var msg types.SocketMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return types.SocketMessage{}, nil
+	}
+	return msg, nil
+}
+
+// handle returns the replies to a message from a subscribed client
+func (sc *socketConn) handle(msg types.SocketMessage) []types.SocketMessage {
+	switch msg.Type {
+	case types.SocketAnswer:
+		return sc.answer(msg)
+	case types.SocketSubscribe:
+		return []types.SocketMessage{sc.problem(msg.ID, apierror.NewProblem(sc.r, http.StatusConflict, errAlreadySubscribed))}
+	default:
+		return []types.SocketMessage{sc.problem(msg.ID, apierror.NewProblem(sc.r, http.StatusBadRequest, errUnknownMessage))}
+	}
+}
+
+// answer stores the viewer's response to a buff of the stream
+// It is acknowledged with the response, and followed by the buff with its answers revealed.
+func (sc *socketConn) answer(msg types.SocketMessage) []types.SocketMessage {
+	fail := func(status int, err error) []types.SocketMessage {
+		return []types.SocketMessage{sc.problem(msg.ID, apierror.NewProblem(sc.r, status, err))}
+	}
+
+	var req types.Response
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		return fail(http.StatusBadRequest, err)
+	}
+
+	bID, err := uuid.Parse(req.BuffUUID)
+	if err != nil {
+		return fail(http.StatusBadRequest, err)
+	}
+
+	aID, err := uuid.Parse(req.AnswerUUID)
+	if err != nil {
+		return fail(http.StatusBadRequest, err)
+	}
+
+	mb, err := sc.store.GetBuff(sc.r.Context(), model.BuffID(bID))
+	if err != nil {
+		return fail(apierror.Status(err), err)
+	}
+
+	// Only the buffs of the stream can be answered over its socket
+	if mb.Stream != sc.stream {
+		return fail(http.StatusNotFound, fmt.Errorf("%w: the buff is not part of the video stream", model.ErrNotFound))
+	}
+
+	mr := model.Response{
+		Buff:      mb.ID,
+		User:      sc.user,
+		Answer:    model.AnswerID(aID),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err := sc.validator.Response(mr); err != nil {
+		return []types.SocketMessage{sc.problem(msg.ID, apierror.NewInvalidProblem(sc.r, err))}
+	}
+
+	if err := sc.store.CreateResponse(sc.r.Context(), mr); err != nil {
+		return fail(apierror.Status(err), err)
+	}
+
+	ack, err := types.NewSocketMessage(types.SocketAck, types.NewResponse(mr))
+	if err != nil {
+		return fail(http.StatusInternalServerError, err)
+	}
+	ack.Ref = msg.ID
+
+	revealed, err := sc.buffMessage(*mb)
+	if err != nil {
+		// The response is stored, so it is still acknowledged
+		return []types.SocketMessage{ack}
+	}
+	return []types.SocketMessage{ack, revealed}
+}
+
+// writeLoop sends the buffs and results of the stream, along with the replies to the
+// client's messages, until the connection is closed or the client falls behind
+func (sc *socketConn) writeLoop(sub *live.Subscription, sent map[model.BuffID]bool) error {
+	defer close(sc.written)
+
+	ping := time.NewTicker(sc.ping)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-sc.read:
+			// The client has gone away, or stopped answering pings
+			return nil
+
+		case reply := <-sc.replies:
+			if err := sc.write(reply); err != nil {
+				return err
+			}
+
+		case mb, ok := <-sub.Buffs():
+			if !ok {
+				// Dropped for falling behind, the client catches up when it reconnects
+				sc.close(websocket.CloseTryAgainLater, "fell behind the buffs of the stream")
+				return nil
+			}
+			if sent[mb.ID] {
+				continue
+			}
+
+			if err := sc.writeBuff(mb); err != nil {
+				return err
+			}
+
+		case <-sub.ResultsReady():
+			for _, res := range sub.TakeResults() {
+				msg, err := types.NewSocketMessage(types.SocketResults, types.NewResults(res))
+				if err != nil {
+					return err
+				}
+				if err := sc.write(msg); err != nil {
+					return err
+				}
+			}
+
+		case <-ping.C:
+			if err := sc.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteTimeout)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// writeBuff sends the buff as a single message
+func (sc *socketConn) writeBuff(mb model.Buff) error {
+	msg, err := sc.buffMessage(mb)
+	if err != nil {
+		return err
+	}
+	return sc.write(msg)
+}
+
+// buffMessage renders the buff as a message, with its cursor
+// so that a reconnecting client can subscribe after it
+func (sc *socketConn) buffMessage(mb model.Buff) (types.SocketMessage, error) {
+	body, err := sc.view.buff(sc.r, mb)
+	if err != nil {
+		return types.SocketMessage{}, err
+	}
+
+	msg, err := types.NewSocketMessage(types.SocketBuff, body)
+	if err != nil {
+		return types.SocketMessage{}, err
+	}
+	msg.Cursor = mb.Cursor().String()
+	return msg, nil
+}
+
+// problem builds an error message replying to the message with the given ID
+func (sc *socketConn) problem(ref string, p types.Problem) types.SocketMessage {
+	// A problem is plain data, which always encodes
+	msg, _ := types.NewSocketMessage(types.SocketError, p)
+	msg.Ref = ref
+	return msg
+}
+
+// write sends a single message, giving up if the client doesn't take it in time
+func (sc *socketConn) write(msg types.SocketMessage) error {
+	sc.conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+	return sc.conn.WriteJSON(msg)
+}
+
+// close tells the client why the connection is being closed
+func (sc *socketConn) close(code int, text string) {
+	sc.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(socketWriteTimeout))
+}
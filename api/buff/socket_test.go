@@ -0,0 +1,442 @@
+package buff_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JoeReid/apiutils/testingcodec"
+	"github.com/JoeReid/buffassignment/api/buff"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/live"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/model/testmodel"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// openSocket serves the handler, and connects to the socket of the stream as the given user
+func openSocket(t *testing.T, handler http.Handler, stream model.VideoStreamID, user string) *websocket.Conn {
+	r := chi.NewRouter()
+	r.Method("GET", "/video_streams/{uuid}/ws", handler)
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/video_streams/" + stream.String() + "/ws?user_id=" + user
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err, "failed to connect")
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// send sends a message of the given type to the socket
+func send(t *testing.T, conn *websocket.Conn, typ, id string, data interface{}) {
+	msg, err := types.NewSocketMessage(typ, data)
+	require.NoError(t, err, "failed to build message")
+	msg.ID = id
+
+	require.NoError(t, conn.WriteJSON(msg), "failed to send message")
+}
+
+// next reads the next message from the socket, of any type
+func next(t *testing.T, conn *websocket.Conn) types.SocketMessage {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	var msg types.SocketMessage
+	require.NoError(t, conn.ReadJSON(&msg), "failed to read message")
+	return msg
+}
+
+// subscribe subscribes the socket after the given cursor, checking the subscription is acknowledged
+func subscribe(t *testing.T, conn *websocket.Conn, after string) {
+	send(t, conn, types.SocketSubscribe, "sub", types.SocketSubscription{Version: types.SocketVersion, After: after})
+
+	msg := next(t, conn)
+	require.Equal(t, types.SocketAck, msg.Type, "the subscription should be acknowledged")
+	assert.Equal(t, "sub", msg.Ref)
+}
+
+func TestSocket(t *testing.T) {
+	store, hub, v := newLiveStore(t)
+	conn := openSocket(t, serveLive(buff.NewSocketHandler(store, hub, newValidator(t))), v.ID, "user-42")
+	subscribe(t, conn, "")
+
+	// A second subscription is refused, without closing the connection
+	send(t, conn, types.SocketSubscribe, "again", types.SocketSubscription{Version: types.SocketVersion})
+	msg := next(t, conn)
+	assert.Equal(t, types.SocketError, msg.Type)
+	assert.Equal(t, "again", msg.Ref)
+
+	// Buffs of other streams are not sent
+	hub.Publish(newLiveBuff(model.VideoStreamID(uuid.New()), time.Now()))
+
+	b := newLiveBuff(v.ID, time.Now())
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	msg = next(t, conn)
+	assert.Equal(t, types.SocketBuff, msg.Type)
+	assert.Equal(t, b.Cursor().String(), msg.Cursor)
+
+	// The buff is sent as it was stored
+	stored, err := store.GetBuff(context.Background(), b.ID)
+	require.NoError(t, err, "failed to get buff")
+
+	var sent types.ViewerBuff
+	require.NoError(t, json.Unmarshal(msg.Data, &sent), "the data should be a viewer buff")
+	assert.Equal(t, types.NewViewerBuff(*stored, types.Viewing{}), sent)
+
+	// Answering the buff is acknowledged, and reveals its answers
+	// Every viewer of the stream is sent the new results too, which may arrive before the ack
+	send(t, conn, types.SocketAnswer, "answer", types.Response{BuffUUID: b.ID.String(), AnswerUUID: b.Answers[1].ID.String()})
+
+	received := make(map[string]types.SocketMessage)
+	for len(received) < 3 {
+		msg = next(t, conn)
+		received[msg.Type] = msg
+	}
+
+	require.Contains(t, received, types.SocketAck)
+	assert.Equal(t, "answer", received[types.SocketAck].Ref)
+
+	var resp types.Response
+	require.NoError(t, json.Unmarshal(received[types.SocketAck].Data, &resp), "the data should be a response")
+	assert.Equal(t, "user-42", resp.UserID)
+	assert.Equal(t, b.Answers[1].ID.String(), resp.AnswerUUID)
+
+	require.Contains(t, received, types.SocketBuff)
+	require.NoError(t, json.Unmarshal(received[types.SocketBuff].Data, &sent), "the data should be a viewer buff")
+	assert.Equal(t, b.Answers[1].ID.String(), sent.UserAnswerUUID)
+
+	require.Contains(t, received, types.SocketResults)
+
+	var results types.Results
+	require.NoError(t, json.Unmarshal(received[types.SocketResults].Data, &results), "the data should be results")
+	assert.Equal(t, b.ID.String(), results.BuffUUID)
+	assert.Equal(t, 1, results.Total)
+
+	// Answering again is refused by the store
+	send(t, conn, types.SocketAnswer, "again", types.Response{BuffUUID: b.ID.String(), AnswerUUID: b.Answers[0].ID.String()})
+
+	msg = next(t, conn)
+	require.Equal(t, types.SocketError, msg.Type)
+	assert.Equal(t, "again", msg.Ref)
+
+	var problem types.Problem
+	require.NoError(t, json.Unmarshal(msg.Data, &problem), "the data should be a problem")
+	assert.Equal(t, http.StatusConflict, problem.Status)
+}
+
+func TestSocketAnswerErrors(t *testing.T) {
+	store, hub, v := newLiveStore(t)
+
+	// A buff of another stream, which can't be answered over this one's socket
+	elsewhere := model.VideoStream{
+		ID:        model.VideoStreamID(uuid.New()),
+		Title:     "another stream",
+		State:     model.StreamLive,
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+	require.NoError(t, store.CreateVideoStream(context.Background(), elsewhere), "failed to create video stream")
+	other := newLiveBuff(elsewhere.ID, time.Now())
+	require.NoError(t, store.CreateBuff(context.Background(), other), "failed to create buff")
+
+	b := newLiveBuff(v.ID, time.Now())
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	var tests = []struct {
+		name          string
+		user          string
+		typ           string
+		data          interface{}
+		expectStatus  int
+		expectInvalid bool
+	}{
+		{
+			name:         "unknown message types are refused",
+			user:         "user-42",
+			typ:          "dance",
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "answers to missformated buff ids are refused",
+			user:         "user-42",
+			typ:          types.SocketAnswer,
+			data:         types.Response{BuffUUID: "not_a_valid_uuid", AnswerUUID: b.Answers[0].ID.String()},
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "answers to missformated answer ids are refused",
+			user:         "user-42",
+			typ:          types.SocketAnswer,
+			data:         types.Response{BuffUUID: b.ID.String(), AnswerUUID: "not_a_valid_uuid"},
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "answers to unknown buffs are refused",
+			user:         "user-42",
+			typ:          types.SocketAnswer,
+			data:         types.Response{BuffUUID: uuid.New().String(), AnswerUUID: b.Answers[0].ID.String()},
+			expectStatus: http.StatusNotFound,
+		},
+		{
+			name:         "answers to the buffs of other streams are refused",
+			user:         "user-42",
+			typ:          types.SocketAnswer,
+			data:         types.Response{BuffUUID: other.ID.String(), AnswerUUID: other.Answers[0].ID.String()},
+			expectStatus: http.StatusNotFound,
+		},
+		{
+			name:          "answers from viewers without a user id are refused",
+			typ:           types.SocketAnswer,
+			data:          types.Response{BuffUUID: b.ID.String(), AnswerUUID: b.Answers[0].ID.String()},
+			expectStatus:  http.StatusUnprocessableEntity,
+			expectInvalid: true,
+		},
+		{
+			name:         "answers that are not answers of the buff are refused",
+			user:         "user-42",
+			typ:          types.SocketAnswer,
+			data:         types.Response{BuffUUID: b.ID.String(), AnswerUUID: uuid.New().String()},
+			expectStatus: http.StatusUnprocessableEntity,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			conn := openSocket(t, serveLive(buff.NewSocketHandler(store, hub, newValidator(t))), v.ID, tt.user)
+			subscribe(t, conn, "")
+
+			send(t, conn, tt.typ, "msg", tt.data)
+
+			msg := next(t, conn)
+			require.Equal(t, types.SocketError, msg.Type)
+			assert.Equal(t, "msg", msg.Ref)
+
+			var problem types.Problem
+			require.NoError(t, json.Unmarshal(msg.Data, &problem), "the data should be a problem")
+			assert.Equal(t, tt.expectStatus, problem.Status)
+			assert.Equal(t, tt.expectInvalid, len(problem.Errors) > 0, "only invalid answers should list the broken rules")
+
+			// The connection stays open after an error
+			send(t, conn, "dance", "still-open", nil)
+			assert.Equal(t, "still-open", next(t, conn).Ref)
+		})
+	}
+}
+
+func TestSocketAnswerNotLive(t *testing.T) {
+	store, hub, v := newLiveStore(t)
+
+	b := newLiveBuff(v.ID, time.Now())
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	conn := openSocket(t, serveLive(buff.NewSocketHandler(store, hub, newValidator(t))), v.ID, "user-42")
+	subscribe(t, conn, "")
+
+	// The stream ends while the viewer is still connected
+	require.NoError(t, store.TransitionVideoStream(context.Background(), v.ID, model.StreamEnded), "failed to end video stream")
+
+	send(t, conn, types.SocketAnswer, "msg", types.Response{BuffUUID: b.ID.String(), AnswerUUID: b.Answers[0].ID.String()})
+
+	msg := next(t, conn)
+	require.Equal(t, types.SocketError, msg.Type)
+	assert.Equal(t, "msg", msg.Ref)
+
+	var problem types.Problem
+	require.NoError(t, json.Unmarshal(msg.Data, &problem), "the data should be a problem")
+	assert.Equal(t, http.StatusConflict, problem.Status)
+
+	// assert that the answer was not stored
+	responses, err := store.ListResponseForUser(context.Background(), "user-42", []model.BuffID{b.ID})
+	require.NoError(t, err, "failed to list responses")
+	assert.Empty(t, responses)
+}
+
+func TestSocketResume(t *testing.T) {
+	store, hub, v := newLiveStore(t)
+
+	start := time.Now()
+	buffs := []model.Buff{
+		newLiveBuff(v.ID, start),
+		newLiveBuff(v.ID, start.Add(time.Second)),
+		newLiveBuff(v.ID, start.Add(2*time.Second)),
+	}
+	for _, b := range buffs {
+		require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+	}
+
+	// A client that saw the first buff is sent the others
+	conn := openSocket(t, serveLive(buff.NewSocketHandler(store, hub, newValidator(t))), v.ID, "user-42")
+	subscribe(t, conn, buffs[0].Cursor().String())
+
+	for _, b := range buffs[1:] {
+		msg := next(t, conn)
+		assert.Equal(t, types.SocketBuff, msg.Type)
+		assert.Equal(t, b.Cursor().String(), msg.Cursor)
+	}
+}
+
+func TestSocketSubscribeErrors(t *testing.T) {
+	var tests = []struct {
+		name string
+		typ  string
+		data interface{}
+	}{
+		{
+			name: "the first message must subscribe",
+			typ:  types.SocketAnswer,
+			data: types.Response{},
+		},
+		{
+			name: "unsupported versions are refused",
+			typ:  types.SocketSubscribe,
+			data: types.SocketSubscription{Version: types.SocketVersion + 1},
+		},
+		{
+			name: "invalid cursors are refused",
+			typ:  types.SocketSubscribe,
+			data: types.SocketSubscription{Version: types.SocketVersion, After: "not a cursor"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			store, hub, v := newLiveStore(t)
+			conn := openSocket(t, serveLive(buff.NewSocketHandler(store, hub, newValidator(t))), v.ID, "user-42")
+
+			send(t, conn, tt.typ, "sub", tt.data)
+
+			msg := next(t, conn)
+			require.Equal(t, types.SocketError, msg.Type)
+			assert.Equal(t, "sub", msg.Ref)
+
+			// The connection is then closed
+			_, _, err := conn.ReadMessage()
+			assert.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation), "expected a policy violation, got %v", err)
+		})
+	}
+}
+
+func TestSocketPing(t *testing.T) {
+	store, hub, v := newLiveStore(t)
+	conn := openSocket(t, serveLive(buff.NewSocketHandler(store, hub, newValidator(t), buff.PingInterval(10*time.Millisecond))), v.ID, "user-42")
+	subscribe(t, conn, "")
+
+	pinged := make(chan struct{}, 1)
+	conn.SetPingHandler(func(string) error {
+		select {
+		case pinged <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+
+	// Pings are only handled while reading
+	go conn.ReadMessage()
+
+	select {
+	case <-pinged:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the client was not pinged")
+	}
+}
+
+func TestSocketErrors(t *testing.T) {
+	sentinelUUID := uuid.New()
+
+	var tests = []struct {
+		name                 string
+		requestParams        map[string]string
+		storeError           error
+		streamState          model.StreamState
+		expectResponseCode   int
+		expectResponseData   interface{}
+		expectStoreNotCalled bool
+	}{
+		{
+			name:                 "returns bad request on missformated uuid",
+			requestParams:        map[string]string{"uuid": "not_a_valid_uuid"},
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, "invalid UUID length: 16"),
+			expectStoreNotCalled: true,
+		},
+		{
+			name:               "returns not found when the stream doesn't exist",
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			storeError:         model.ErrNotFound,
+			expectResponseCode: http.StatusNotFound,
+			expectResponseData: newProblem(http.StatusNotFound, model.ErrNotFound.Error()),
+		},
+		{
+			name:               "returns conflict when the stream isn't live",
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			streamState:        model.StreamScheduled,
+			expectResponseCode: http.StatusConflict,
+			expectResponseData: newProblem(
+				http.StatusConflict,
+				(&model.StreamNotLiveError{Stream: model.VideoStreamID(sentinelUUID), State: model.StreamScheduled}).Error(),
+			),
+		},
+		{
+			name:               "returns bad request when the request isn't a websocket handshake",
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			expectResponseCode: http.StatusBadRequest,
+			expectResponseData: newProblem(
+				http.StatusBadRequest,
+				"websocket: the client is not using the websocket protocol: 'upgrade' token not found in 'Connection' header",
+			),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return the data configured in the test fixture
+			state := tt.streamState
+			if state == "" {
+				state = model.StreamLive
+			}
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("GetVideoStream", mock.Anything, mock.Anything).Return(&model.VideoStream{State: state}, tt.storeError)
+
+			hub, err := live.NewHub()
+			require.NoError(t, err, "failed to create hub")
+
+			// Build the request to the spec of the test fixture
+			rctx := chi.NewRouteContext()
+			for k, v := range tt.requestParams {
+				rctx.URLParams.Add(k, v)
+			}
+			req, err := http.NewRequest("GET", "", nil)
+			require.NoError(t, err, "failed to build request for test")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+
+			// Create the handler under test, and execute it
+			handler := buff.NewSocketHandler(testingStore, hub, newValidator(t))
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler returns the expected data, only once
+			codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			if tt.expectStoreNotCalled {
+				testingStore.AssertNotCalled(t, "GetVideoStream", mock.Anything, mock.Anything)
+			} else {
+				testingStore.AssertCalled(t, "GetVideoStream", mock.Anything, model.VideoStreamID(sentinelUUID))
+			}
+		})
+	}
+}
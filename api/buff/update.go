@@ -0,0 +1,147 @@
+package buff
+
+import (
+	"net/http"
+
+	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/buffassignment/api/apierror"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/validation"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+// NewUpdateHandler returns a new instance of the update action of
+// the buff API using the given store instance.
+//
+// The update replaces the question and all the answers of the buff.
+// The stream a buff belongs to cannot be changed.
+//
+// The new state of the buff is checked against the rules of the given validator before it is stored.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewUpdateHandler(store model.BuffStore, validator *validation.Validator) apiutils.Handler {
+	return &buffUpdate{store, validator}
+}
+
+// NewPatchHandler returns a new instance of the patch action of
+// the buff API using the given store instance.
+//
+// The patch only replaces the fields that are set in the request.
+//
+// The new state of the buff is checked against the rules of the given validator before it is stored.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewPatchHandler(store model.BuffStore, validator *validation.Validator) apiutils.Handler {
+	return &buffPatch{store, validator}
+}
+
+// buffUpdate implements the apiutils.Handler interface to provide the
+// update portion of the buff API
+type buffUpdate struct {
+	store     model.BuffStore
+	validator *validation.Validator
+}
+
+// ServeCodec serves the API using the apiutils.Handler pattern
+// This allows the business logic to live here, and the encoding to live separate from it
+// This also makes testing easier, as there is a test codec that allows us to peek at the output
+// in a testing context.
+func (b *buffUpdate) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
+	bID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var req types.Buff
+	if err := c.Read(r.Context(), r, &req); err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	existing, err := b.store.GetBuff(r.Context(), model.BuffID(bID))
+	if err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+
+	mb := buffFromRequest(existing.ID, existing.Stream, req, existing.Answers)
+	updateBuff(b.store, b.validator, c, w, r, *existing, mb, authoringView{})
+}
+
+// buffPatch implements the apiutils.Handler interface to provide the
+// patch portion of the buff API
+type buffPatch struct {
+	store     model.BuffStore
+	validator *validation.Validator
+}
+
+// ServeCodec serves the API using the apiutils.Handler pattern
+// This allows the business logic to live here, and the encoding to live separate from it
+// This also makes testing easier, as there is a test codec that allows us to peek at the output
+// in a testing context.
+func (b *buffPatch) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
+	bID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var req types.BuffPatch
+	if err := c.Read(r.Context(), r, &req); err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	existing, err := b.store.GetBuff(r.Context(), model.BuffID(bID))
+	if err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+
+	// Apply the patch to the current state, and then treat it as a full update
+	mb := buffFromRequest(existing.ID, existing.Stream, req.Apply(types.NewBuff(*existing)), existing.Answers)
+	updateBuff(b.store, b.validator, c, w, r, *existing, mb, authoringView{})
+}
+
+// updateBuff holds the logic shared between the update and patch handlers,
+// once they have worked out the full new state of the buff
+//
+// The updated buff is sent back in the shape given by the view.
+func updateBuff(
+	store model.BuffStore,
+	validator *validation.Validator,
+	c apiutils.Codec,
+	w http.ResponseWriter,
+	r *http.Request,
+	existing model.Buff,
+	mb model.Buff,
+	v view,
+) {
+	// The responses to a resolved buff have been scored against its answers, so it can't be changed.
+	// The store would refuse it too, but only after the request had been validated as a prediction
+	// with a correct answer.
+	if existing.Resolution != nil {
+		err := &model.BuffResolvedError{Buff: existing.ID, Answer: existing.Resolution.Answer}
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+
+	mb.CreatedAt = existing.CreatedAt
+
+	if err := validator.Buff(mb); err != nil {
+		apierror.RespondInvalid(c, w, r, err)
+		return
+	}
+
+	if err := store.UpdateBuff(r.Context(), mb.ID, mb); err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+	body, err := v.buff(r, mb)
+	respond(c, w, r, body, err)
+}
@@ -0,0 +1,214 @@
+package buff_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/apiutils/testingcodec"
+	"github.com/JoeReid/buffassignment/api/buff"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/model/testmodel"
+	"github.com/JoeReid/buffassignment/internal/validation"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateBuff(t *testing.T) {
+	sentinelUUID := uuid.New()
+	correctUUID := uuid.New()
+	incorrectUUID := uuid.New()
+
+	existing := &model.Buff{
+		ID:       model.BuffID(sentinelUUID),
+		Stream:   model.VideoStreamID(sentinelUUID),
+		Question: "what's the answer to life?",
+		Answers: []model.Answer{
+			{ID: model.AnswerID(correctUUID), Text: "42", Correct: true},
+			{ID: model.AnswerID(incorrectUUID), Text: "43", Correct: false},
+		},
+	}
+
+	// A prediction resolved to its first answer
+	resolved := *existing
+	resolved.Kind = model.BuffPrediction
+	resolved.Resolution = &model.Resolution{Answer: model.AnswerID(correctUUID), ResolvedBy: "match-official"}
+	resolvedErr := &model.BuffResolvedError{Buff: resolved.ID, Answer: model.AnswerID(correctUUID)}
+
+	var tests = []struct {
+		name               string
+		handler            func(model.BuffStore, *validation.Validator) apiutils.Handler
+		requestParams      map[string]string
+		requestBody        interface{}
+		getResponse        *model.Buff
+		getError           error
+		updateError        error
+		expectResponseCode int
+		expectResponseData interface{}
+		expectUpdate       *model.Buff
+	}{
+		{
+			name:          "update replaces the buff, keeping matching answer ids",
+			handler:       buff.NewUpdateHandler,
+			requestParams: map[string]string{"uuid": sentinelUUID.String()},
+			requestBody: types.Buff{
+				Question:         "what's the answer to life, the universe, and everything?",
+				CorrectAnswer:    "42",
+				IncorrectAnswers: []string{"44"},
+			},
+			getResponse:        existing,
+			expectResponseCode: http.StatusOK,
+			expectUpdate: &model.Buff{
+				ID:       model.BuffID(sentinelUUID),
+				Stream:   model.VideoStreamID(sentinelUUID),
+				Question: "what's the answer to life, the universe, and everything?",
+				Answers: []model.Answer{
+					{ID: model.AnswerID(correctUUID), Text: "42", Correct: true},
+					{Text: "44", Correct: false},
+				},
+			},
+		},
+		{
+			name:          "patch only replaces the given fields",
+			handler:       buff.NewPatchHandler,
+			requestParams: map[string]string{"uuid": sentinelUUID.String()},
+			requestBody: types.BuffPatch{
+				Question: func(s string) *string { return &s }("what's the answer to life, the universe, and everything?"),
+			},
+			getResponse:        existing,
+			expectResponseCode: http.StatusOK,
+			expectUpdate: &model.Buff{
+				ID:       model.BuffID(sentinelUUID),
+				Stream:   model.VideoStreamID(sentinelUUID),
+				Question: "what's the answer to life, the universe, and everything?",
+				Answers: []model.Answer{
+					{ID: model.AnswerID(correctUUID), Text: "42", Correct: true},
+					{ID: model.AnswerID(incorrectUUID), Text: "43", Correct: false},
+				},
+			},
+		},
+		{
+			name:          "patch returns unprocessable entity if the result breaks the rules",
+			handler:       buff.NewPatchHandler,
+			requestParams: map[string]string{"uuid": sentinelUUID.String()},
+			requestBody: types.BuffPatch{
+				IncorrectAnswers: &[]string{},
+			},
+			getResponse:        existing,
+			expectResponseCode: http.StatusUnprocessableEntity,
+			expectResponseData: newInvalidProblem(
+				types.ValidationError{Field: "answers", Rule: "min_answers", Message: "must have at least 2 answers"},
+			),
+		},
+		{
+			name:               "update returns bad request on missformated uuid",
+			handler:            buff.NewUpdateHandler,
+			requestParams:      map[string]string{"uuid": "not_a_valid_uuid"},
+			expectResponseCode: http.StatusBadRequest,
+			expectResponseData: newProblem(http.StatusBadRequest, "invalid UUID length: 16"),
+		},
+		{
+			name:               "patch returns not found on store not found error",
+			handler:            buff.NewPatchHandler,
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			requestBody:        types.BuffPatch{},
+			getError:           model.ErrNotFound,
+			expectResponseCode: http.StatusNotFound,
+			expectResponseData: newProblem(http.StatusNotFound, model.ErrNotFound.Error()),
+		},
+		{
+			name:               "update returns not found if the buff disappears",
+			handler:            buff.NewUpdateHandler,
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			requestBody:        types.Buff{Question: "?", CorrectAnswer: "42", IncorrectAnswers: []string{"43"}},
+			getResponse:        existing,
+			updateError:        model.ErrNotFound,
+			expectResponseCode: http.StatusNotFound,
+			expectResponseData: newProblem(http.StatusNotFound, model.ErrNotFound.Error()),
+		},
+		{
+			name:               "patch returns conflict on a resolved buff",
+			handler:            buff.NewPatchHandler,
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			requestBody:        types.BuffPatch{},
+			getResponse:        &resolved,
+			expectResponseCode: http.StatusConflict,
+			expectResponseData: newProblem(http.StatusConflict, resolvedErr.Error()),
+		},
+		{
+			name:               "update returns internal error on unexpected store error",
+			handler:            buff.NewUpdateHandler,
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			requestBody:        types.Buff{Question: "?", CorrectAnswer: "42", IncorrectAnswers: []string{"43"}},
+			getResponse:        existing,
+			updateError:        errors.New("the world exploded"),
+			expectResponseCode: http.StatusInternalServerError,
+			expectResponseData: newProblem(http.StatusInternalServerError, ""),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return the data configured in the test fixture
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("GetBuff", mock.Anything, mock.Anything).Return(tt.getResponse, tt.getError)
+			testingStore.On("UpdateBuff", mock.Anything, mock.Anything, mock.Anything).Return(tt.updateError)
+
+			// Build the request to the spec of the test fixture
+			rctx := chi.NewRouteContext()
+			for k, v := range tt.requestParams {
+				rctx.URLParams.Add(k, v)
+			}
+			req, err := http.NewRequest("PUT", "", nil)
+			require.NoError(t, err, "failed to build request for test")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+			codec.On("Read", mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+				switch body := args.Get(2).(type) {
+				case *types.Buff:
+					*body = tt.requestBody.(types.Buff)
+				case *types.BuffPatch:
+					*body = tt.requestBody.(types.BuffPatch)
+				}
+			})
+
+			// Create the handler under test, and execute it
+			handler := tt.handler(testingStore, newValidator(t))
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler responded only once
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			if tt.expectUpdate == nil {
+				codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+				return
+			}
+
+			testingStore.AssertNumberOfCalls(t, "UpdateBuff", 1)
+			updated := testingStore.Calls[1].Arguments.Get(2).(model.Buff)
+
+			// Answers without an expected ID are new, so can be any ID
+			// other than the ones already in use
+			for i, ans := range tt.expectUpdate.Answers {
+				if ans.ID == (model.AnswerID{}) {
+					assert.NotEqual(t, model.AnswerID(correctUUID), updated.Answers[i].ID)
+					assert.NotEqual(t, model.AnswerID(incorrectUUID), updated.Answers[i].ID)
+					tt.expectUpdate.Answers[i].ID = updated.Answers[i].ID
+				}
+			}
+			assert.Equal(t, *tt.expectUpdate, updated)
+			testingStore.AssertCalled(t, "UpdateBuff", mock.Anything, model.BuffID(sentinelUUID), updated)
+
+			codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, types.NewBuff(updated))
+		})
+	}
+}
@@ -0,0 +1,420 @@
+package buff
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/buffassignment/api/apierror"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/validation"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+const (
+	// v2Root is the path the v2 API is mounted on, the links of v2 buffs are built from it
+	v2Root = "/v2"
+
+	// v2AdminRoot is the path of the v2 authoring routes
+	v2AdminRoot = v2Root + "/admin"
+)
+
+// NewV2GetHandler returns a new instance of the get action of
+// the v2 buff API using the given store instance.
+//
+// The buff is served in the v2 authoring shape, revealing the correct answers.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewV2GetHandler(store model.BuffStore) apiutils.Handler {
+	return &buffGet{store, authoringV2View{}}
+}
+
+// NewV2ListHandler returns a new instance of the list action of
+// the v2 buff API using the given store instance.
+//
+// The buffs are served in the v2 authoring shape, revealing the correct answers.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewV2ListHandler(store model.BuffStore) apiutils.Handler {
+	return &buffList{store, authoringV2View{}}
+}
+
+// NewV2ListForStreamHandler returns a new instance of the list for stream action of
+// the v2 buff API using the given store instance.
+//
+// The buffs are served in the v2 authoring shape, revealing the correct answers.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewV2ListForStreamHandler(store model.BuffStore) apiutils.Handler {
+	return &buffListForStream{store, authoringV2View{}}
+}
+
+// NewV2ViewerGetHandler returns a new instance of the get action of
+// the v2 buff API, serving the buff in the v2 shape shown to viewers.
+//
+// The correct answers are only revealed if the user in the user_id
+// param has responded to the buff.
+//
+// The stores are provided as arguments for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewV2ViewerGetHandler(store model.Store) apiutils.Handler {
+	return &buffGet{store, &viewerV2View{*newViewerView(store)}}
+}
+
+// NewV2ViewerListHandler returns a new instance of the list action of
+// the v2 buff API, serving the buffs in the v2 shape shown to viewers.
+//
+// The correct answers are only revealed for the buffs the user in the
+// user_id param has responded to.
+//
+// The stores are provided as arguments for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewV2ViewerListHandler(store model.Store) apiutils.Handler {
+	return &buffList{store, &viewerV2View{*newViewerView(store)}}
+}
+
+// NewV2ViewerListForStreamHandler returns a new instance of the list for stream action of
+// the v2 buff API, serving the buffs in the v2 shape shown to viewers.
+//
+// The correct answers are only revealed for the buffs the user in the
+// user_id param has responded to.
+//
+// The stores are provided as arguments for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewV2ViewerListForStreamHandler(store model.Store) apiutils.Handler {
+	return &buffListForStream{store, &viewerV2View{*newViewerView(store)}}
+}
+
+// NewV2CreateHandler returns a new instance of the create action of
+// the v2 buff API using the given store instance.
+//
+// The stream the buff belongs to is read from the request body.
+// Every answer is given a new ID, any answer_id in the request is ignored.
+//
+// The new buff is checked against the rules of the given validator before it is stored.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewV2CreateHandler(store model.BuffStore, validator *validation.Validator) apiutils.Handler {
+	return &buffV2Create{store, validator}
+}
+
+// NewV2CreateForStreamHandler returns a new instance of the create for stream action of
+// the v2 buff API using the given store instance.
+//
+// The stream the buff belongs to is read from the URL, and any stream in the
+// request body is ignored. Every answer is given a new ID, any answer_id in
+// the request is ignored.
+//
+// The new buff is checked against the rules of the given validator before it is stored.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewV2CreateForStreamHandler(store model.BuffStore, validator *validation.Validator) apiutils.Handler {
+	return &buffV2CreateForStream{store, validator}
+}
+
+// NewV2UpdateHandler returns a new instance of the update action of
+// the v2 buff API using the given store instance.
+//
+// The update replaces the question and all the answers of the buff.
+// Answers with an answer_id keep their ID, which must be one of the buff's
+// current answers, and answers without one are added as new answers.
+// The stream a buff belongs to cannot be changed.
+//
+// The new state of the buff is checked against the rules of the given validator before it is stored.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewV2UpdateHandler(store model.BuffStore, validator *validation.Validator) apiutils.Handler {
+	return &buffV2Update{store, validator}
+}
+
+// NewV2PatchHandler returns a new instance of the patch action of
+// the v2 buff API using the given store instance.
+//
+// The patch only replaces the fields that are set in the request,
+// the answers are replaced in the same way as an update.
+//
+// The new state of the buff is checked against the rules of the given validator before it is stored.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewV2PatchHandler(store model.BuffStore, validator *validation.Validator) apiutils.Handler {
+	return &buffV2Patch{store, validator}
+}
+
+// buffV2Create implements the apiutils.Handler interface to provide the
+// create portion of the v2 buff API
+type buffV2Create struct {
+	store     model.BuffStore
+	validator *validation.Validator
+}
+
+// ServeCodec serves the API using the apiutils.Handler pattern
+// This allows the business logic to live here, and the encoding to live separate from it
+// This also makes testing easier, as there is a test codec that allows us to peek at the output
+// in a testing context.
+func (b *buffV2Create) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
+	var req types.BuffV2
+	if err := c.Read(r.Context(), r, &req); err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	vID, err := uuid.Parse(req.VideoStreamUUID)
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	// IDs are always generated by the server
+	mb := buffFromV2Request(model.BuffID(uuid.New()), model.VideoStreamID(vID), req)
+	createBuff(b.store, b.validator, c, w, r, mb, authoringV2View{})
+}
+
+// buffV2CreateForStream implements the apiutils.Handler interface to provide the
+// create for stream portion of the v2 buff API
+type buffV2CreateForStream struct {
+	store     model.BuffStore
+	validator *validation.Validator
+}
+
+// ServeCodec serves the API using the apiutils.Handler pattern
+// This allows the business logic to live here, and the encoding to live separate from it
+// This also makes testing easier, as there is a test codec that allows us to peek at the output
+// in a testing context.
+func (b *buffV2CreateForStream) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
+	vID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var req types.BuffV2
+	if err := c.Read(r.Context(), r, &req); err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	// IDs are always generated by the server
+	mb := buffFromV2Request(model.BuffID(uuid.New()), model.VideoStreamID(vID), req)
+	createBuff(b.store, b.validator, c, w, r, mb, authoringV2View{})
+}
+
+// buffV2Update implements the apiutils.Handler interface to provide the
+// update portion of the v2 buff API
+type buffV2Update struct {
+	store     model.BuffStore
+	validator *validation.Validator
+}
+
+// ServeCodec serves the API using the apiutils.Handler pattern
+// This allows the business logic to live here, and the encoding to live separate from it
+// This also makes testing easier, as there is a test codec that allows us to peek at the output
+// in a testing context.
+func (b *buffV2Update) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
+	bID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var req types.BuffV2
+	if err := c.Read(r.Context(), r, &req); err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	existing, err := b.store.GetBuff(r.Context(), model.BuffID(bID))
+	if err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+
+	mb, err := buffFromV2Update(*existing, req)
+	if err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+	updateBuff(b.store, b.validator, c, w, r, *existing, mb, authoringV2View{})
+}
+
+// buffV2Patch implements the apiutils.Handler interface to provide the
+// patch portion of the v2 buff API
+type buffV2Patch struct {
+	store     model.BuffStore
+	validator *validation.Validator
+}
+
+// ServeCodec serves the API using the apiutils.Handler pattern
+// This allows the business logic to live here, and the encoding to live separate from it
+// This also makes testing easier, as there is a test codec that allows us to peek at the output
+// in a testing context.
+func (b *buffV2Patch) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
+	bID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var req types.BuffV2Patch
+	if err := c.Read(r.Context(), r, &req); err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	existing, err := b.store.GetBuff(r.Context(), model.BuffID(bID))
+	if err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+
+	// Apply the patch to the current state, and then treat it as a full update
+	mb, err := buffFromV2Update(*existing, req.Apply(types.NewBuffV2(*existing, nil)))
+	if err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+	updateBuff(b.store, b.validator, c, w, r, *existing, mb, authoringV2View{})
+}
+
+// buffFromV2Request builds a new model.Buff from the v2 API representation,
+// giving every answer a new ID
+func buffFromV2Request(id model.BuffID, stream model.VideoStreamID, req types.BuffV2) model.Buff {
+	mb := model.Buff{
+		ID:       id,
+		Stream:   stream,
+		Kind:     model.BuffKind(req.Kind),
+		Question: req.Question,
+		Answers:  make([]model.Answer, 0, len(req.Answers)),
+		Schedule: req.Schedule.Model(),
+		Tags:     req.Tags,
+	}
+
+	for _, ans := range req.Answers {
+		mb.Answers = append(mb.Answers, model.Answer{
+			ID:      model.AnswerID(uuid.New()),
+			Text:    ans.Text,
+			Correct: ans.Correct != nil && *ans.Correct,
+		})
+	}
+	return mb
+}
+
+// buffFromV2Update builds the new state of an existing buff from the v2 API representation
+//
+// Answers that name one of the buff's current answers keep its ID, answers without
+// an ID are given a new one. Any other ID returns model.ErrInvalidReference.
+func buffFromV2Update(existing model.Buff, req types.BuffV2) (model.Buff, error) {
+	ids := make(map[string]model.AnswerID, len(existing.Answers))
+	for _, ans := range existing.Answers {
+		ids[ans.ID.String()] = ans.ID
+	}
+
+	mb := buffFromV2Request(existing.ID, existing.Stream, req)
+	for i, ans := range req.Answers {
+		if ans.UUID == "" {
+			continue
+		}
+
+		aID, ok := ids[ans.UUID]
+		if !ok {
+			return model.Buff{}, fmt.Errorf("%w: answer %s is not an answer to buff %s", model.ErrInvalidReference, ans.UUID, existing.ID)
+		}
+		mb.Answers[i].ID = aID
+	}
+	return mb, nil
+}
+
+// v2Links returns the links of a buff served by the routes under root
+// The stream and results are always linked to the viewer routes
+func v2Links(root string, mb model.Buff) *types.BuffLinks {
+	return &types.BuffLinks{
+		Self:        types.Link{Href: fmt.Sprintf("%s/buffs/%s", root, mb.ID)},
+		Stream:      types.Link{Href: fmt.Sprintf("%s/video_streams/%s", v2Root, mb.Stream)},
+		StreamBuffs: types.Link{Href: fmt.Sprintf("%s/video_streams/%s/buffs", root, mb.Stream)},
+		Results:     types.Link{Href: fmt.Sprintf("%s/buffs/%s/results", v2Root, mb.ID)},
+	}
+}
+
+// authoringV2View serves buffs in the v2 authoring shape,
+// including which answers are correct
+type authoringV2View struct{}
+
+func (authoringV2View) stream(_ *http.Request, _ model.VideoStreamID) error {
+	return nil
+}
+
+func (authoringV2View) query(q model.BuffQuery) model.BuffQuery {
+	return q
+}
+
+func (authoringV2View) convert(mb model.Buff) types.BuffV2 {
+	return types.NewBuffV2(mb, v2Links(v2AdminRoot, mb))
+}
+
+func (v authoringV2View) buff(_ *http.Request, mb model.Buff) (interface{}, error) {
+	return v.convert(mb), nil
+}
+
+func (v authoringV2View) buffs(_ *http.Request, mbs []model.Buff) (interface{}, error) {
+	return convertV2(mbs, v.convert), nil
+}
+
+func (v authoringV2View) page(_ *http.Request, mbs []model.Buff, count int) (interface{}, error) {
+	return types.NewBuffV2Page(mbs, count, v.convert), nil
+}
+
+// viewerV2View serves buffs in the v2 viewer shape, without revealing
+// the correct answers unless the viewer has already responded or the buff has closed
+type viewerV2View struct {
+	viewerView
+}
+
+// converter returns a function converting buffs into the viewer shape,
+// revealing the answers the viewing allows
+func (v *viewerV2View) converter(viewing types.Viewing) func(model.Buff) types.BuffV2 {
+	return func(mb model.Buff) types.BuffV2 {
+		return types.NewViewerBuffV2(mb, viewing, v2Links(v2Root, mb))
+	}
+}
+
+func (v *viewerV2View) buff(r *http.Request, mb model.Buff) (interface{}, error) {
+	viewing, err := v.viewing(r, []model.Buff{mb})
+	if err != nil {
+		return nil, err
+	}
+	return v.converter(viewing)(mb), nil
+}
+
+func (v *viewerV2View) buffs(r *http.Request, mbs []model.Buff) (interface{}, error) {
+	viewing, err := v.viewing(r, mbs)
+	if err != nil {
+		return nil, err
+	}
+	return convertV2(mbs, v.converter(viewing)), nil
+}
+
+func (v *viewerV2View) page(r *http.Request, mbs []model.Buff, count int) (interface{}, error) {
+	viewing, err := v.viewing(r, mbs)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewBuffV2Page(mbs, count, v.converter(viewing)), nil
+}
+
+// convertV2 converts each of the buffs with conv
+func convertV2(mbs []model.Buff, conv func(model.Buff) types.BuffV2) []types.BuffV2 {
+	b := make([]types.BuffV2, 0, len(mbs))
+
+	for _, mb := range mbs {
+		b = append(b, conv(mb))
+	}
+	return b
+}
@@ -0,0 +1,470 @@
+package buff_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/apiutils/testingcodec"
+	"github.com/JoeReid/buffassignment/api/buff"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/model/testmodel"
+	"github.com/JoeReid/buffassignment/internal/validation"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// boolPtr returns a pointer to b, for the correct field of the v2 answers
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// v2Links returns the links expected for newViewerBuff(id) served under root
+func v2Links(root, id string) *types.BuffLinks {
+	const stream = "00000000-0000-0000-0000-0000000000ff"
+
+	return &types.BuffLinks{
+		Self:        types.Link{Href: fmt.Sprintf("%s/buffs/%s", root, id)},
+		Stream:      types.Link{Href: "/v2/video_streams/" + stream},
+		StreamBuffs: types.Link{Href: fmt.Sprintf("%s/video_streams/%s/buffs", root, stream)},
+		Results:     types.Link{Href: fmt.Sprintf("/v2/buffs/%s/results", id)},
+	}
+}
+
+func TestV2GetBuff(t *testing.T) {
+	mb := newViewerBuff(viewerBuffA)
+
+	// A second correct answer can't be shown by v1
+	multi := newViewerBuff(viewerBuffA)
+	multi.Answers[2].Correct = true
+
+	// A poll has no correct answers to show, even when authoring it
+	poll := newViewerBuff(viewerBuffA)
+	poll.Kind = model.BuffPoll
+	poll.Answers[0].Correct = false
+
+	// A prediction is judged once it is resolved, and shows the state of its resolution
+	prediction := newViewerBuff(viewerBuffA)
+	prediction.Kind = model.BuffPrediction
+	prediction.Answers[0].Correct = false
+	resolved := resolvedViewerBuff(viewerBuffA, "match-official")
+	resolvedAt := resolved.Resolution.ResolvedAt
+
+	var tests = []struct {
+		name               string
+		viewer             bool
+		storeResponse      *model.Buff
+		responsesResponse  []model.Response
+		requestURL         string
+		expectResponseData types.BuffV2
+	}{
+		{
+			name:          "authoring shape keeps every answer in order",
+			storeResponse: &multi,
+			expectResponseData: types.BuffV2{
+				UUID:            viewerBuffA,
+				VideoStreamUUID: "00000000-0000-0000-0000-0000000000ff",
+				Question:        "what's the answer to life, the universe, and everything?",
+				Answers: []types.AnswerV2{
+					{UUID: "00000000-0000-0000-0000-000000000003", Text: "42", Correct: boolPtr(true)},
+					{UUID: "00000000-0000-0000-0000-000000000001", Text: "43", Correct: boolPtr(false)},
+					{UUID: "00000000-0000-0000-0000-000000000002", Text: "44", Correct: boolPtr(true)},
+				},
+				Links: v2Links("/v2/admin", viewerBuffA),
+			},
+		},
+		{
+			name:          "authoring shape leaves the answers of a poll unmarked",
+			storeResponse: &poll,
+			expectResponseData: types.BuffV2{
+				UUID:            viewerBuffA,
+				VideoStreamUUID: "00000000-0000-0000-0000-0000000000ff",
+				Kind:            "poll",
+				Question:        "what's the answer to life, the universe, and everything?",
+				Answers: []types.AnswerV2{
+					{UUID: "00000000-0000-0000-0000-000000000003", Text: "42"},
+					{UUID: "00000000-0000-0000-0000-000000000001", Text: "43"},
+					{UUID: "00000000-0000-0000-0000-000000000002", Text: "44"},
+				},
+				Links: v2Links("/v2/admin", viewerBuffA),
+			},
+		},
+		{
+			name:              "viewer shape shows an unresolved prediction without marking its answers",
+			viewer:            true,
+			storeResponse:     &prediction,
+			responsesResponse: []model.Response{newViewerResponse(prediction, 1)},
+			requestURL:        "?user_id=alice",
+			expectResponseData: types.BuffV2{
+				UUID:            viewerBuffA,
+				VideoStreamUUID: "00000000-0000-0000-0000-0000000000ff",
+				Kind:            "prediction",
+				Question:        "what's the answer to life, the universe, and everything?",
+				Answers: []types.AnswerV2{
+					{UUID: "00000000-0000-0000-0000-000000000001", Text: "43"},
+					{UUID: "00000000-0000-0000-0000-000000000002", Text: "44"},
+					{UUID: "00000000-0000-0000-0000-000000000003", Text: "42"},
+				},
+				Resolution:     &types.ResolutionState{State: "unresolved"},
+				UserAnswerUUID: "00000000-0000-0000-0000-000000000001",
+				Links:          v2Links("/v2", viewerBuffA),
+			},
+		},
+		{
+			name:              "viewer shape reveals the winning answer of a resolved prediction",
+			viewer:            true,
+			storeResponse:     &resolved,
+			responsesResponse: []model.Response{newViewerResponse(resolved, 1)},
+			requestURL:        "?user_id=alice",
+			expectResponseData: types.BuffV2{
+				UUID:            viewerBuffA,
+				VideoStreamUUID: "00000000-0000-0000-0000-0000000000ff",
+				Kind:            "prediction",
+				Question:        "what's the answer to life, the universe, and everything?",
+				Answers: []types.AnswerV2{
+					{UUID: "00000000-0000-0000-0000-000000000001", Text: "43", Correct: boolPtr(true)},
+					{UUID: "00000000-0000-0000-0000-000000000002", Text: "44", Correct: boolPtr(false)},
+					{UUID: "00000000-0000-0000-0000-000000000003", Text: "42", Correct: boolPtr(false)},
+				},
+				Resolution: &types.ResolutionState{
+					State:      "resolved",
+					AnswerUUID: "00000000-0000-0000-0000-000000000001",
+					ResolvedAt: &resolvedAt,
+				},
+				UserAnswerUUID: "00000000-0000-0000-0000-000000000001",
+				Links:          v2Links("/v2", viewerBuffA),
+			},
+		},
+		{
+			name:              "viewer shape hides the correct answer until the user responds",
+			viewer:            true,
+			storeResponse:     &mb,
+			responsesResponse: []model.Response{},
+			requestURL:        "?user_id=alice",
+			expectResponseData: types.BuffV2{
+				UUID:            viewerBuffA,
+				VideoStreamUUID: "00000000-0000-0000-0000-0000000000ff",
+				Question:        "what's the answer to life, the universe, and everything?",
+				Answers: []types.AnswerV2{
+					{UUID: "00000000-0000-0000-0000-000000000001", Text: "43"},
+					{UUID: "00000000-0000-0000-0000-000000000002", Text: "44"},
+					{UUID: "00000000-0000-0000-0000-000000000003", Text: "42"},
+				},
+				Links: v2Links("/v2", viewerBuffA),
+			},
+		},
+		{
+			name:              "viewer shape reveals the correct answer once the user has responded",
+			viewer:            true,
+			storeResponse:     &mb,
+			responsesResponse: []model.Response{newViewerResponse(mb, 0)},
+			requestURL:        "?user_id=alice",
+			expectResponseData: types.BuffV2{
+				UUID:            viewerBuffA,
+				VideoStreamUUID: "00000000-0000-0000-0000-0000000000ff",
+				Question:        "what's the answer to life, the universe, and everything?",
+				Answers: []types.AnswerV2{
+					{UUID: "00000000-0000-0000-0000-000000000001", Text: "43", Correct: boolPtr(false)},
+					{UUID: "00000000-0000-0000-0000-000000000002", Text: "44", Correct: boolPtr(false)},
+					{UUID: "00000000-0000-0000-0000-000000000003", Text: "42", Correct: boolPtr(true)},
+				},
+				UserAnswerUUID: "00000000-0000-0000-0000-000000000003",
+				Links:          v2Links("/v2", viewerBuffA),
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return the data configured in the test fixture
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("GetBuff", mock.Anything, mock.Anything).Return(tt.storeResponse, nil)
+			testingStore.On("GetVideoStream", mock.Anything, tt.storeResponse.Stream).Return(&model.VideoStream{ID: tt.storeResponse.Stream, State: model.StreamLive}, nil)
+			testingStore.On("ListResponseForUser", mock.Anything, mock.Anything, mock.Anything).Return(tt.responsesResponse, nil)
+
+			// Build the request to the spec of the test fixture
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("uuid", viewerBuffA)
+			req, err := http.NewRequest("GET", tt.requestURL, nil)
+			require.NoError(t, err, "failed to build request for test")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+
+			// Create the handler under test, and execute it
+			handler := buff.NewV2GetHandler(testingStore)
+			if tt.viewer {
+				handler = buff.NewV2ViewerGetHandler(testingStore)
+			}
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler returns the expected data, only once
+			codec.AssertCalled(t, "Respond", mock.Anything, nil, http.StatusOK, tt.expectResponseData)
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+		})
+	}
+}
+
+func TestV2CreateBuff(t *testing.T) {
+	sentinelUUID := uuid.New()
+	bodyUUID := uuid.New()
+
+	var tests = []struct {
+		name                 string
+		handler              func(model.BuffStore, *validation.Validator) apiutils.Handler
+		options              []validation.Option
+		requestBody          types.BuffV2
+		expectStream         model.VideoStreamID
+		expectResponseCode   int
+		expectResponseData   interface{}
+		expectStoreNotCalled bool
+	}{
+		{
+			name:    "creates a buff with several correct answers when allowed",
+			handler: buff.NewV2CreateHandler,
+			options: []validation.Option{validation.ExactlyOneCorrect(false)},
+			requestBody: types.BuffV2{
+				VideoStreamUUID: bodyUUID.String(),
+				Question:        "which of these are even?",
+				Answers: []types.AnswerV2{
+					{Text: "42", Correct: boolPtr(true)},
+					{Text: "43"},
+					{UUID: uuid.New().String(), Text: "44", Correct: boolPtr(true)},
+				},
+			},
+			expectStream:       model.VideoStreamID(bodyUUID),
+			expectResponseCode: http.StatusCreated,
+		},
+		{
+			name:    "create for stream uses the stream in the URL",
+			handler: buff.NewV2CreateForStreamHandler,
+			requestBody: types.BuffV2{
+				VideoStreamUUID: bodyUUID.String(),
+				Question:        "what's the answer to life, the universe, and everything?",
+				Answers: []types.AnswerV2{
+					{Text: "42", Correct: boolPtr(true)},
+					{Text: "43", Correct: boolPtr(false)},
+				},
+			},
+			expectStream:       model.VideoStreamID(sentinelUUID),
+			expectResponseCode: http.StatusCreated,
+		},
+		{
+			name:    "returns unprocessable entity when the rules allow only one correct answer",
+			handler: buff.NewV2CreateHandler,
+			requestBody: types.BuffV2{
+				VideoStreamUUID: bodyUUID.String(),
+				Question:        "which of these are even?",
+				Answers: []types.AnswerV2{
+					{Text: "42", Correct: boolPtr(true)},
+					{Text: "44", Correct: boolPtr(true)},
+				},
+			},
+			expectResponseCode: http.StatusUnprocessableEntity,
+			expectResponseData: newInvalidProblem(
+				types.ValidationError{Field: "answers", Rule: "exactly_one_correct", Message: "must have exactly one correct answer, found 2"},
+			),
+			expectStoreNotCalled: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return the data configured in the test fixture
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("CreateBuff", mock.Anything, mock.Anything).Return(nil)
+
+			// Build the request to the spec of the test fixture
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("uuid", sentinelUUID.String())
+			req, err := http.NewRequest("POST", "", nil)
+			require.NoError(t, err, "failed to build request for test")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+			codec.On("Read", mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+				*args.Get(2).(*types.BuffV2) = tt.requestBody
+			})
+
+			// Create the handler under test, and execute it
+			validator, err := validation.New(tt.options...)
+			require.NoError(t, err, "failed to build validator")
+			handler := tt.handler(testingStore, validator)
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler responded only once
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			if tt.expectStoreNotCalled {
+				testingStore.AssertNotCalled(t, "CreateBuff", mock.Anything, mock.Anything)
+				codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+				return
+			}
+
+			// The IDs are generated by the handler, so pull the created buff back out of the store call
+			testingStore.AssertNumberOfCalls(t, "CreateBuff", 1)
+			created := testingStore.Calls[0].Arguments.Get(1).(model.Buff)
+
+			assert.Equal(t, tt.expectStream, created.Stream)
+			assert.Equal(t, tt.requestBody.Question, created.Question)
+			require.Len(t, created.Answers, len(tt.requestBody.Answers))
+			for i, ans := range tt.requestBody.Answers {
+				assert.NotEqual(t, ans.UUID, created.Answers[i].ID.String(), "answer ids should be generated by the handler")
+				assert.Equal(t, ans.Text, created.Answers[i].Text)
+				assert.Equal(t, ans.Correct != nil && *ans.Correct, created.Answers[i].Correct)
+			}
+
+			body := codec.Calls[1].Arguments.Get(3).(types.BuffV2)
+			assert.Equal(t, tt.expectResponseCode, codec.Calls[1].Arguments.Get(2))
+			assert.Equal(t, created.ID.String(), body.UUID)
+			assert.Equal(t, fmt.Sprintf("/v2/admin/buffs/%s", created.ID), body.Links.Self.Href)
+		})
+	}
+}
+
+func TestV2UpdateBuff(t *testing.T) {
+	sentinelUUID := uuid.New()
+	correctUUID := uuid.New()
+	incorrectUUID := uuid.New()
+
+	existing := &model.Buff{
+		ID:       model.BuffID(sentinelUUID),
+		Stream:   model.VideoStreamID(sentinelUUID),
+		Question: "what's the answer to life?",
+		Answers: []model.Answer{
+			{ID: model.AnswerID(correctUUID), Text: "42", Correct: true},
+			{ID: model.AnswerID(incorrectUUID), Text: "43", Correct: false},
+		},
+	}
+	question := "what's the answer to life, the universe, and everything?"
+	unknown := uuid.New().String()
+
+	var tests = []struct {
+		name               string
+		handler            func(model.BuffStore, *validation.Validator) apiutils.Handler
+		requestBody        interface{}
+		expectResponseCode int
+		expectResponseData interface{}
+		expectUpdate       *model.Buff
+	}{
+		{
+			name:    "update keeps the named answers and adds the others",
+			handler: buff.NewV2UpdateHandler,
+			requestBody: types.BuffV2{
+				Question: question,
+				Answers: []types.AnswerV2{
+					{UUID: incorrectUUID.String(), Text: "forty-three"},
+					{UUID: correctUUID.String(), Text: "42", Correct: boolPtr(true)},
+					{Text: "44", Correct: boolPtr(false)},
+				},
+			},
+			expectResponseCode: http.StatusOK,
+			expectUpdate: &model.Buff{
+				ID:       model.BuffID(sentinelUUID),
+				Stream:   model.VideoStreamID(sentinelUUID),
+				Question: question,
+				Answers: []model.Answer{
+					{ID: model.AnswerID(incorrectUUID), Text: "forty-three", Correct: false},
+					{ID: model.AnswerID(correctUUID), Text: "42", Correct: true},
+					{Text: "44", Correct: false},
+				},
+			},
+		},
+		{
+			name:    "update rejects answers of other buffs",
+			handler: buff.NewV2UpdateHandler,
+			requestBody: types.BuffV2{
+				Question: question,
+				Answers: []types.AnswerV2{
+					{UUID: correctUUID.String(), Text: "42", Correct: boolPtr(true)},
+					{UUID: unknown, Text: "43"},
+				},
+			},
+			expectResponseCode: http.StatusUnprocessableEntity,
+			expectResponseData: newProblem(http.StatusUnprocessableEntity, fmt.Sprintf(
+				"%s: answer %s is not an answer to buff %s", model.ErrInvalidReference, unknown, sentinelUUID,
+			)),
+		},
+		{
+			name:               "patch keeps the answers when only the question is set",
+			handler:            buff.NewV2PatchHandler,
+			requestBody:        types.BuffV2Patch{Question: &question},
+			expectResponseCode: http.StatusOK,
+			expectUpdate: &model.Buff{
+				ID:       model.BuffID(sentinelUUID),
+				Stream:   model.VideoStreamID(sentinelUUID),
+				Question: question,
+				Answers:  existing.Answers,
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return the data configured in the test fixture
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("GetBuff", mock.Anything, mock.Anything).Return(existing, nil)
+			testingStore.On("UpdateBuff", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+			// Build the request to the spec of the test fixture
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("uuid", sentinelUUID.String())
+			req, err := http.NewRequest("PUT", "", nil)
+			require.NoError(t, err, "failed to build request for test")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+			codec.On("Read", mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+				switch body := args.Get(2).(type) {
+				case *types.BuffV2:
+					*body = tt.requestBody.(types.BuffV2)
+				case *types.BuffV2Patch:
+					*body = tt.requestBody.(types.BuffV2Patch)
+				}
+			})
+
+			// Create the handler under test, and execute it
+			handler := tt.handler(testingStore, newValidator(t))
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler responded only once
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			if tt.expectUpdate == nil {
+				testingStore.AssertNotCalled(t, "UpdateBuff", mock.Anything, mock.Anything, mock.Anything)
+				codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+				return
+			}
+
+			testingStore.AssertNumberOfCalls(t, "UpdateBuff", 1)
+			updated := testingStore.Calls[1].Arguments.Get(2).(model.Buff)
+
+			// Answers without an expected ID are new, so can be any ID
+			// other than the ones already in use
+			for i, ans := range tt.expectUpdate.Answers {
+				if ans.ID == (model.AnswerID{}) {
+					assert.NotEqual(t, model.AnswerID(correctUUID), updated.Answers[i].ID)
+					assert.NotEqual(t, model.AnswerID(incorrectUUID), updated.Answers[i].ID)
+					tt.expectUpdate.Answers[i].ID = updated.Answers[i].ID
+				}
+			}
+			assert.Equal(t, *tt.expectUpdate, updated)
+
+			body := codec.Calls[1].Arguments.Get(3).(types.BuffV2)
+			assert.Equal(t, tt.expectResponseCode, codec.Calls[1].Arguments.Get(2))
+			assert.Equal(t, types.NewBuffV2(updated, body.Links), body)
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package buff
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/buffassignment/api/apierror"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+)
+
+// UserKey is the URL param holding the ID of the viewer making the request
+const UserKey = "user_id"
+
+// NewViewerGetHandler returns a new instance of the get action of
+// the buff API, serving the buff in the shape shown to viewers.
+// A buff is only served once its stream has started, any other buff is a conflict.
+//
+// The correct answer is only revealed if the user in the user_id
+// param has responded to the buff, or the buff has closed.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewViewerGetHandler(store model.Store) apiutils.Handler {
+	return &buffGet{store, newViewerView(store)}
+}
+
+// NewViewerListHandler returns a new instance of the list action of
+// the buff API, serving the buffs in the shape shown to viewers.
+// Only the buffs of streams that have started are listed.
+//
+// The correct answers are only revealed for the buffs the user in the
+// user_id param has responded to, and for the buffs that have closed.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewViewerListHandler(store model.Store) apiutils.Handler {
+	return &buffList{store, newViewerView(store)}
+}
+
+// NewViewerListForStreamHandler returns a new instance of the list for stream action of
+// the buff API, serving the buffs in the shape shown to viewers.
+// The buffs are only listed once the stream has started, any other stream is a conflict.
+//
+// The correct answers are only revealed for the buffs the user in the
+// user_id param has responded to, and for the buffs that have closed.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewViewerListForStreamHandler(store model.Store) apiutils.Handler {
+	return &buffListForStream{store, newViewerView(store)}
+}
+
+// respond sends the body rendered by a view, or the error it failed with
+func respond(c apiutils.Codec, w http.ResponseWriter, r *http.Request, body interface{}, err error) {
+	if err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+	c.Respond(r.Context(), w, http.StatusOK, body)
+}
+
+// view turns the buffs returned by the store into the body of the response
+//
+// It is what sets the authoring handlers apart from the viewer handlers,
+// which share the rest of their logic. The stream and query decide which
+// buffs the view can serve, before they are read from the store.
+type view interface {
+	stream(r *http.Request, id model.VideoStreamID) error
+	query(q model.BuffQuery) model.BuffQuery
+	buff(r *http.Request, mb model.Buff) (interface{}, error)
+	buffs(r *http.Request, mbs []model.Buff) (interface{}, error)
+	page(r *http.Request, mbs []model.Buff, count int) (interface{}, error)
+}
+
+// authoringView serves buffs in the shape they are written in,
+// including which answer is correct
+type authoringView struct{}
+
+func (authoringView) stream(_ *http.Request, _ model.VideoStreamID) error {
+	return nil
+}
+
+func (authoringView) query(q model.BuffQuery) model.BuffQuery {
+	return q
+}
+
+func (authoringView) buff(_ *http.Request, mb model.Buff) (interface{}, error) {
+	return types.NewBuff(mb), nil
+}
+
+func (authoringView) buffs(_ *http.Request, mbs []model.Buff) (interface{}, error) {
+	return types.NewBuffs(mbs), nil
+}
+
+func (authoringView) page(_ *http.Request, mbs []model.Buff, count int) (interface{}, error) {
+	return types.NewBuffPage(mbs, count), nil
+}
+
+// viewerView serves buffs without revealing the correct answers,
+// unless the viewer has already responded or the buff has closed
+//
+// Buffs are only served to viewers once their stream has started, so the timeline
+// of a stream that has ended can still be read back, but isn't given away beforehand.
+type viewerView struct {
+	responses model.ResponseStore
+	streams   model.VideoStreamStore
+}
+
+// newViewerView returns a viewerView reading the responses and streams from the store
+func newViewerView(store model.Store) *viewerView {
+	return &viewerView{responses: store, streams: store}
+}
+
+// viewerStreamStates are the states of the streams whose buffs are served to viewers
+var viewerStreamStates = []model.StreamState{model.StreamLive, model.StreamEnded, model.StreamArchived}
+
+func (v *viewerView) stream(r *http.Request, id model.VideoStreamID) error {
+	stream, err := v.streams.GetVideoStream(r.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	if stream.State == model.StreamScheduled {
+		return &model.StreamNotLiveError{Stream: id, State: stream.State}
+	}
+	return nil
+}
+
+func (v *viewerView) query(q model.BuffQuery) model.BuffQuery {
+	q.StreamStates = viewerStreamStates
+	return q
+}
+
+func (v *viewerView) buff(r *http.Request, mb model.Buff) (interface{}, error) {
+	viewing, err := v.viewing(r, []model.Buff{mb})
+	if err != nil {
+		return nil, err
+	}
+	return types.NewViewerBuff(mb, viewing), nil
+}
+
+func (v *viewerView) buffs(r *http.Request, mbs []model.Buff) (interface{}, error) {
+	viewing, err := v.viewing(r, mbs)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewViewerBuffs(mbs, viewing), nil
+}
+
+func (v *viewerView) page(r *http.Request, mbs []model.Buff, count int) (interface{}, error) {
+	viewing, err := v.viewing(r, mbs)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewViewerBuffPage(mbs, count, viewing), nil
+}
+
+// viewing looks up what decides which answers of the buffs the viewer is shown
+func (v *viewerView) viewing(r *http.Request, mbs []model.Buff) (types.Viewing, error) {
+	responses, err := v.userResponses(r, mbs)
+	if err != nil {
+		return types.Viewing{}, err
+	}
+
+	closed, err := v.closedBuffs(r, mbs)
+	if err != nil {
+		return types.Viewing{}, err
+	}
+	return types.Viewing{Responses: responses, Closed: closed}, nil
+}
+
+// closedBuffs returns the buffs that have closed on the timeline of their stream
+// Only the streams of the scheduled buffs are looked up, as no other buff ever closes.
+func (v *viewerView) closedBuffs(r *http.Request, mbs []model.Buff) (map[model.BuffID]bool, error) {
+	now := time.Now()
+	started := make(map[model.VideoStreamID]*time.Time)
+
+	rtn := make(map[model.BuffID]bool)
+	for _, mb := range mbs {
+		if mb.Schedule == nil {
+			continue
+		}
+
+		at, ok := started[mb.Stream]
+		if !ok {
+			stream, err := v.streams.GetVideoStream(r.Context(), mb.Stream)
+			if err != nil {
+				return nil, err
+			}
+			at = stream.StartedAt
+			started[mb.Stream] = at
+		}
+
+		if mb.ClosedBy(at, now) {
+			rtn[mb.ID] = true
+		}
+	}
+	return rtn, nil
+}
+
+// userResponses returns the responses the viewer has given to the buffs, keyed by buff
+// The store is only asked when the request names a viewer
+func (v *viewerView) userResponses(r *http.Request, mbs []model.Buff) (map[model.BuffID]model.Response, error) {
+	user := r.URL.Query().Get(UserKey)
+	if user == "" || len(mbs) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]model.BuffID, 0, len(mbs))
+	for _, mb := range mbs {
+		ids = append(ids, mb.ID)
+	}
+
+	responses, err := v.responses.ListResponseForUser(r.Context(), model.UserID(user), ids)
+	if err != nil {
+		return nil, err
+	}
+
+	rtn := make(map[model.BuffID]model.Response, len(responses))
+	for _, resp := range responses {
+		rtn[resp.Buff] = resp
+	}
+	return rtn, nil
+}
@@ -0,0 +1,480 @@
+package buff_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/JoeReid/apiutils/testingcodec"
+	"github.com/JoeReid/buffassignment/api/buff"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/model/testmodel"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newViewerBuff returns a buff whose answers are stored out of the order of their IDs,
+// with the correct answer first as the authoring shape would have it
+func newViewerBuff(id string) model.Buff {
+	return model.Buff{
+		ID:       model.BuffID(uuid.MustParse(id)),
+		Stream:   model.VideoStreamID(uuid.MustParse("00000000-0000-0000-0000-0000000000ff")),
+		Question: "what's the answer to life, the universe, and everything?",
+		Answers: []model.Answer{
+			{ID: model.AnswerID(uuid.MustParse("00000000-0000-0000-0000-000000000003")), Text: "42", Correct: true},
+			{ID: model.AnswerID(uuid.MustParse("00000000-0000-0000-0000-000000000001")), Text: "43", Correct: false},
+			{ID: model.AnswerID(uuid.MustParse("00000000-0000-0000-0000-000000000002")), Text: "44", Correct: false},
+		},
+	}
+}
+
+// hiddenViewerBuff is the viewer shape of newViewerBuff before the user has responded
+func hiddenViewerBuff(id string) types.ViewerBuff {
+	return types.ViewerBuff{
+		UUID:            id,
+		VideoStreamUUID: "00000000-0000-0000-0000-0000000000ff",
+		Question:        "what's the answer to life, the universe, and everything?",
+		Answers: []types.ViewerAnswer{
+			{UUID: "00000000-0000-0000-0000-000000000001", Text: "43"},
+			{UUID: "00000000-0000-0000-0000-000000000002", Text: "44"},
+			{UUID: "00000000-0000-0000-0000-000000000003", Text: "42"},
+		},
+	}
+}
+
+// revealedViewerBuff is the viewer shape of newViewerBuff once the user has responded with answer
+func revealedViewerBuff(id, answer string) types.ViewerBuff {
+	correct, incorrect := true, false
+
+	b := hiddenViewerBuff(id)
+	b.UserAnswerUUID = answer
+	b.Answers[0].Correct = &incorrect
+	b.Answers[1].Correct = &incorrect
+	b.Answers[2].Correct = &correct
+	return b
+}
+
+// answeredPollViewerBuff is the viewer shape of newViewerBuff as a poll once the user
+// has responded with answer, which never reveals any answer as correct
+func answeredPollViewerBuff(id, answer string) types.ViewerBuff {
+	b := hiddenViewerBuff(id)
+	b.Kind = string(model.BuffPoll)
+	b.UserAnswerUUID = answer
+	return b
+}
+
+// closedViewerBuff is the viewer shape of newViewerBuff once the buff has closed on the schedule,
+// revealing the correct answer to a user that hasn't responded
+func closedViewerBuff(id string, schedule model.Schedule) types.ViewerBuff {
+	b := revealedViewerBuff(id, "")
+	b.Schedule = types.NewSchedule(&schedule)
+	return b
+}
+
+// openViewerBuff is the viewer shape of newViewerBuff while the buff is open on the schedule
+func openViewerBuff(id string, schedule model.Schedule) types.ViewerBuff {
+	b := hiddenViewerBuff(id)
+	b.Schedule = types.NewSchedule(&schedule)
+	return b
+}
+
+const (
+	viewerBuffA = "00000000-0000-0000-0000-00000000000a"
+	viewerBuffB = "00000000-0000-0000-0000-00000000000b"
+)
+
+// newViewerResponse returns alice's response to the buff
+func newViewerResponse(mb model.Buff, answer int) model.Response {
+	return model.Response{Buff: mb.ID, User: "alice", Answer: mb.Answers[answer].ID, CreatedAt: time.Now()}
+}
+
+func TestViewerGetBuff(t *testing.T) {
+	mb := newViewerBuff(viewerBuffA)
+
+	schedule := model.Schedule{Offset: time.Minute, Duration: 30 * time.Second}
+	startedAt := func(at time.Time) *model.VideoStream {
+		return &model.VideoStream{ID: mb.Stream, State: model.StreamLive, StartedAt: &at}
+	}
+	notLive := func(state model.StreamState) interface{} {
+		return newProblem(http.StatusConflict, (&model.StreamNotLiveError{Stream: mb.Stream, State: state}).Error())
+	}
+
+	var tests = []struct {
+		name                     string
+		requestURLValues         map[string]string
+		kind                     model.BuffKind
+		schedule                 *model.Schedule
+		storeError               error
+		streamResponse           *model.VideoStream
+		streamError              error
+		responsesResponse        []model.Response
+		responsesError           error
+		expectResponseCode       int
+		expectResponseData       interface{}
+		expectResponsesNotCalled bool
+	}{
+		{
+			name:                     "hides the correct answer without a user",
+			expectResponseCode:       http.StatusOK,
+			expectResponseData:       hiddenViewerBuff(viewerBuffA),
+			expectResponsesNotCalled: true,
+		},
+		{
+			name:               "hides the correct answer until the user responds",
+			requestURLValues:   map[string]string{"user_id": "alice"},
+			responsesResponse:  []model.Response{},
+			expectResponseCode: http.StatusOK,
+			expectResponseData: hiddenViewerBuff(viewerBuffA),
+		},
+		{
+			name:               "reveals the correct answer once the user has responded",
+			requestURLValues:   map[string]string{"user_id": "alice"},
+			responsesResponse:  []model.Response{newViewerResponse(mb, 1)},
+			expectResponseCode: http.StatusOK,
+			expectResponseData: revealedViewerBuff(viewerBuffA, "00000000-0000-0000-0000-000000000001"),
+		},
+		{
+			name:               "never reveals a correct answer of a poll",
+			requestURLValues:   map[string]string{"user_id": "alice"},
+			kind:               model.BuffPoll,
+			responsesResponse:  []model.Response{newViewerResponse(mb, 1)},
+			expectResponseCode: http.StatusOK,
+			expectResponseData: answeredPollViewerBuff(viewerBuffA, "00000000-0000-0000-0000-000000000001"),
+		},
+		{
+			name:                     "reveals the correct answer once the buff has closed",
+			schedule:                 &schedule,
+			streamResponse:           startedAt(time.Now().Add(-time.Hour)),
+			expectResponseCode:       http.StatusOK,
+			expectResponseData:       closedViewerBuff(viewerBuffA, schedule),
+			expectResponsesNotCalled: true,
+		},
+		{
+			name:                     "hides the correct answer while the buff is open",
+			schedule:                 &schedule,
+			streamResponse:           startedAt(time.Now().Add(-75 * time.Second)),
+			expectResponseCode:       http.StatusOK,
+			expectResponseData:       openViewerBuff(viewerBuffA, schedule),
+			expectResponsesNotCalled: true,
+		},
+		{
+			name:                     "returns conflict before the stream starts",
+			requestURLValues:         map[string]string{"user_id": "alice"},
+			schedule:                 &schedule,
+			streamResponse:           &model.VideoStream{ID: mb.Stream, State: model.StreamScheduled},
+			expectResponseCode:       http.StatusConflict,
+			expectResponseData:       notLive(model.StreamScheduled),
+			expectResponsesNotCalled: true,
+		},
+		{
+			name:               "serves the buff once the stream has ended",
+			requestURLValues:   map[string]string{"user_id": "alice"},
+			streamResponse:     &model.VideoStream{ID: mb.Stream, State: model.StreamEnded},
+			responsesResponse:  []model.Response{newViewerResponse(mb, 1)},
+			expectResponseCode: http.StatusOK,
+			expectResponseData: revealedViewerBuff(viewerBuffA, "00000000-0000-0000-0000-000000000001"),
+		},
+		{
+			name:                     "returns service unavailable when the stream can't be reached",
+			streamError:              model.ErrUnavailable,
+			expectResponseCode:       http.StatusServiceUnavailable,
+			expectResponseData:       newProblem(http.StatusServiceUnavailable, ""),
+			expectResponsesNotCalled: true,
+		},
+		{
+			name:                     "returns not found without looking up responses",
+			requestURLValues:         map[string]string{"user_id": "alice"},
+			storeError:               model.ErrNotFound,
+			expectResponseCode:       http.StatusNotFound,
+			expectResponseData:       newProblem(http.StatusNotFound, model.ErrNotFound.Error()),
+			expectResponsesNotCalled: true,
+		},
+		{
+			name:               "returns service unavailable when the responses can't be reached",
+			requestURLValues:   map[string]string{"user_id": "alice"},
+			responsesError:     model.ErrUnavailable,
+			expectResponseCode: http.StatusServiceUnavailable,
+			expectResponseData: newProblem(http.StatusServiceUnavailable, ""),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return the data configured in the test fixture
+			var storeResponse *model.Buff
+			if tt.storeError == nil {
+				kb := mb
+				kb.Kind = tt.kind
+				kb.Schedule = tt.schedule
+				storeResponse = &kb
+			}
+			streamResponse := tt.streamResponse
+			if streamResponse == nil && tt.streamError == nil {
+				streamResponse = &model.VideoStream{ID: mb.Stream, State: model.StreamLive}
+			}
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("GetBuff", mock.Anything, mock.Anything).Return(storeResponse, tt.storeError)
+			testingStore.On("GetVideoStream", mock.Anything, mb.Stream).Return(streamResponse, tt.streamError)
+			testingStore.On("ListResponseForUser", mock.Anything, mock.Anything, mock.Anything).Return(tt.responsesResponse, tt.responsesError)
+
+			// Build the request to the spec of the test fixture
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("uuid", viewerBuffA)
+			req, err := http.NewRequest("GET", "", nil)
+			require.NoError(t, err, "failed to build request for test")
+			vals := url.Values{}
+			for k, v := range tt.requestURLValues {
+				vals.Add(k, v)
+			}
+			req.URL.RawQuery = vals.Encode()
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+
+			// Create the handler under test, and execute it
+			handler := buff.NewViewerGetHandler(testingStore)
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler returns the expected data
+			codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+
+			// assert that the handler responded only once
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			if tt.expectResponsesNotCalled {
+				testingStore.AssertNotCalled(t, "ListResponseForUser", mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				testingStore.AssertCalled(t, "ListResponseForUser", mock.Anything, model.UserID("alice"), []model.BuffID{mb.ID})
+			}
+		})
+	}
+}
+
+func TestViewerListBuffs(t *testing.T) {
+	mbs := []model.Buff{newViewerBuff(viewerBuffA), newViewerBuff(viewerBuffB)}
+
+	var tests = []struct {
+		name                     string
+		requestURLValues         map[string]string
+		storeResponse            []model.Buff
+		storeError               error
+		responsesResponse        []model.Response
+		expectResponseCode       int
+		expectResponseData       interface{}
+		expectResponsesNotCalled bool
+	}{
+		{
+			name:               "hides the correct answers without a user",
+			storeResponse:      mbs,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: []types.ViewerBuff{
+				hiddenViewerBuff(viewerBuffA),
+				hiddenViewerBuff(viewerBuffB),
+			},
+			expectResponsesNotCalled: true,
+		},
+		{
+			name:               "reveals only the buffs the user has responded to",
+			requestURLValues:   map[string]string{"user_id": "alice"},
+			storeResponse:      mbs,
+			responsesResponse:  []model.Response{newViewerResponse(mbs[1], 0)},
+			expectResponseCode: http.StatusOK,
+			expectResponseData: []types.ViewerBuff{
+				hiddenViewerBuff(viewerBuffA),
+				revealedViewerBuff(viewerBuffB, "00000000-0000-0000-0000-000000000003"),
+			},
+		},
+		{
+			name:               "reveals the buffs on a cursor page",
+			requestURLValues:   map[string]string{"user_id": "alice", "cursor": "", "count": "1"},
+			storeResponse:      mbs,
+			responsesResponse:  []model.Response{newViewerResponse(mbs[0], 2)},
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.ViewerBuffPage{
+				Buffs:      []types.ViewerBuff{revealedViewerBuff(viewerBuffA, "00000000-0000-0000-0000-000000000002")},
+				NextCursor: mbs[0].Cursor().String(),
+			},
+		},
+		{
+			name:                     "empty list doesn't look up responses",
+			requestURLValues:         map[string]string{"user_id": "alice"},
+			storeError:               model.ErrNotFound,
+			expectResponseCode:       http.StatusOK,
+			expectResponseData:       []types.ViewerBuff{},
+			expectResponsesNotCalled: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return the data configured in the test fixture
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("ListBuff", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(tt.storeResponse, tt.storeError)
+			testingStore.On("ListBuffAfter", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(tt.storeResponse, tt.storeError)
+			testingStore.On("ListResponseForUser", mock.Anything, mock.Anything, mock.Anything).Return(tt.responsesResponse, nil)
+
+			// Build the request to the spec of the test fixture
+			req, err := http.NewRequest("GET", "", nil)
+			require.NoError(t, err, "failed to build request for test")
+			vals := url.Values{}
+			for k, v := range tt.requestURLValues {
+				vals.Add(k, v)
+			}
+			req.URL.RawQuery = vals.Encode()
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+
+			// Create the handler under test, and execute it
+			handler := buff.NewViewerListHandler(testingStore)
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler returns the expected data
+			codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+
+			// assert that the handler responded only once
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			if tt.expectResponsesNotCalled {
+				testingStore.AssertNotCalled(t, "ListResponseForUser", mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				testingStore.AssertCalled(t, "ListResponseForUser", mock.Anything, model.UserID("alice"), mock.Anything)
+			}
+
+			// assert that only the buffs of streams that have started were listed
+			started := model.BuffQuery{StreamStates: []model.StreamState{model.StreamLive, model.StreamEnded, model.StreamArchived}}
+			if _, ok := tt.requestURLValues["cursor"]; ok {
+				testingStore.AssertCalled(t, "ListBuffAfter", mock.Anything, started, mock.Anything, mock.Anything)
+			} else {
+				testingStore.AssertCalled(t, "ListBuff", mock.Anything, started, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestViewerListBuffsForStream(t *testing.T) {
+	mbs := []model.Buff{newViewerBuff(viewerBuffA), newViewerBuff(viewerBuffB)}
+
+	// Setup the mock store object to return both buffs, with a response to the first
+	testingStore := testmodel.NewModelMock()
+	testingStore.On("GetVideoStream", mock.Anything, mbs[0].Stream).Return(&model.VideoStream{ID: mbs[0].Stream, State: model.StreamLive}, nil)
+	testingStore.On("ListBuffForStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(mbs, nil)
+	testingStore.On("ListResponseForUser", mock.Anything, mock.Anything, mock.Anything).Return([]model.Response{newViewerResponse(mbs[0], 0)}, nil)
+
+	// Build the request for alice
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("uuid", mbs[0].Stream.String())
+	req, err := http.NewRequest("GET", "?user_id=alice", nil)
+	require.NoError(t, err, "failed to build request for test")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Use the testing codec to assert handler behaviour
+	codec := testingcodec.New()
+	codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+
+	// Create the handler under test, and execute it
+	handler := buff.NewViewerListForStreamHandler(testingStore)
+	handler.ServeCodec(codec, nil, req)
+
+	// assert that the handler returns the expected data, only once
+	codec.AssertCalled(t, "Respond", mock.Anything, nil, http.StatusOK, []types.ViewerBuff{
+		revealedViewerBuff(viewerBuffA, "00000000-0000-0000-0000-000000000003"),
+		hiddenViewerBuff(viewerBuffB),
+	})
+	codec.AssertNumberOfCalls(t, "Respond", 1)
+
+	// assert that the responses were looked up for every buff in the list
+	testingStore.AssertCalled(t, "ListResponseForUser", mock.Anything, model.UserID("alice"), []model.BuffID{mbs[0].ID, mbs[1].ID})
+}
+
+func TestViewerListBuffsForStreamEnded(t *testing.T) {
+	mbs := []model.Buff{newViewerBuff(viewerBuffA), newViewerBuff(viewerBuffB)}
+	for i := range mbs {
+		mbs[i].Schedule = &model.Schedule{Offset: time.Minute, Duration: 30 * time.Second}
+	}
+	started := time.Now().Add(-time.Hour)
+
+	// Setup the mock store object to return a stream that has ended, with both buffs active
+	testingStore := testmodel.NewModelMock()
+	testingStore.On("GetVideoStream", mock.Anything, mbs[0].Stream).Return(&model.VideoStream{ID: mbs[0].Stream, State: model.StreamEnded, StartedAt: &started}, nil)
+	testingStore.On("ListBuffActiveForStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(mbs, nil)
+
+	// Build the request for the buffs active a minute into the stream
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("uuid", mbs[0].Stream.String())
+	req, err := http.NewRequest("GET", "?active_at=60000", nil)
+	require.NoError(t, err, "failed to build request for test")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	// Use the testing codec to assert handler behaviour
+	codec := testingcodec.New()
+	codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+
+	// Create the handler under test, and execute it
+	handler := buff.NewViewerListForStreamHandler(testingStore)
+	handler.ServeCodec(codec, nil, req)
+
+	// assert that the timeline of the ended stream is read back, with the buffs that have closed revealed
+	codec.AssertCalled(t, "Respond", mock.Anything, nil, http.StatusOK, []types.ViewerBuff{
+		closedViewerBuff(viewerBuffA, *mbs[0].Schedule),
+		closedViewerBuff(viewerBuffB, *mbs[1].Schedule),
+	})
+	codec.AssertNumberOfCalls(t, "Respond", 1)
+	testingStore.AssertCalled(t, "ListBuffActiveForStream", mock.Anything, mbs[0].Stream, mock.Anything, time.Minute)
+}
+
+func TestViewerListBuffsForStreamNotStarted(t *testing.T) {
+	stream := newViewerBuff(viewerBuffA).Stream
+
+	var tests = []struct {
+		name       string
+		requestURL string
+	}{
+		{name: "returns conflict before the stream starts", requestURL: "?user_id=alice"},
+		{name: "returns conflict for a cursor page", requestURL: "?cursor="},
+		{name: "returns conflict for the active buffs", requestURL: "?active_at=60000"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return a stream that hasn't started
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("GetVideoStream", mock.Anything, stream).Return(&model.VideoStream{ID: stream, State: model.StreamScheduled}, nil)
+
+			// Build the request to the spec of the test fixture
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("uuid", stream.String())
+			req, err := http.NewRequest("GET", tt.requestURL, nil)
+			require.NoError(t, err, "failed to build request for test")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+
+			// Create the handler under test, and execute it
+			handler := buff.NewViewerListForStreamHandler(testingStore)
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler responds with a conflict, only once
+			codec.AssertCalled(t, "Respond", mock.Anything, nil, http.StatusConflict, newProblem(
+				http.StatusConflict,
+				(&model.StreamNotLiveError{Stream: stream, State: model.StreamScheduled}).Error(),
+			))
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			// assert that no buffs were listed
+			testingStore.AssertNotCalled(t, "ListBuffForStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			testingStore.AssertNotCalled(t, "ListBuffForStreamAfter", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			testingStore.AssertNotCalled(t, "ListBuffActiveForStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
@@ -1,13 +1,25 @@
 package api
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/JoeReid/apiutils"
 	"github.com/JoeReid/apiutils/jsoncodec"
 	"github.com/JoeReid/apiutils/yamlcodec"
+	"github.com/JoeReid/buffassignment/api/apierror"
 	"github.com/JoeReid/buffassignment/api/buff"
+	"github.com/JoeReid/buffassignment/api/leaderboard"
+	"github.com/JoeReid/buffassignment/api/response"
+	"github.com/JoeReid/buffassignment/api/tag"
 	"github.com/JoeReid/buffassignment/api/videostream"
 	"github.com/JoeReid/buffassignment/internal/config"
+	"github.com/JoeReid/buffassignment/internal/live"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/model/memory"
 	"github.com/JoeReid/buffassignment/internal/model/postgres"
+	"github.com/JoeReid/buffassignment/internal/seed"
+	"github.com/JoeReid/buffassignment/internal/validation"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/httptracer"
@@ -31,65 +43,268 @@ func Versioned() (*chi.Mux, error) {
 				ServiceVersion: "unversioned",
 			},
 		),
+		middleware.RequestID,
 		middleware.Logger, // TODO: replace with tracing?
 		middleware.RedirectSlashes,
 	)
 
-	routerV1, err := v1()
-	if err != nil {
-		return nil, err
-	}
-
-	r.Mount("/v1", routerV1)
-	return r, nil
-}
-
-func v1() (*chi.Mux, error) {
-	r := chi.NewRouter()
-
 	// configure all the codec options
+	// errors are sent as problem details (RFC 7807) in the same format as the data
 	codecSelector, err := apiutils.NewRequestSelector(
 		apiutils.RegisterCodec(
-			jsoncodec.New(), "json", "application/json"),
+			apierror.Codec(jsoncodec.New(), "application/problem+json"),
+			"json", "application/json"),
 
 		apiutils.RegisterCodec(
-			jsoncodec.New(jsoncodec.SetIndent("", "\t")),
+			apierror.Codec(jsoncodec.New(jsoncodec.SetIndent("", "\t")), "application/problem+json"),
 			"json,pretty", "application/json,pretty"),
 
 		apiutils.RegisterCodec(
-			yamlcodec.New(), "yaml", "application/x-yaml"),
+			apierror.Codec(yamlcodec.New(), "application/problem+yaml"),
+			"yaml", "application/x-yaml"),
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	// TODO: how do we shut this down?
-	// Do we need to? can it just follow the lifecycle of the service?
-	dc, err := config.DBConfig()
+	vc, err := config.ValidationConfig()
 	if err != nil {
 		return nil, err
 	}
 
-	store, err := postgres.NewStore(
-		postgres.SetDBUser(dc.DBUser),
-		postgres.SetDBPassword(dc.DBPassword),
-		postgres.SetDBHostname(dc.DBHost),
-		postgres.SetDBPort(dc.DBPort),
-		postgres.SetDBName(dc.DBName),
-		postgres.SetConnectTimeout(dc.DBConnectTimeout),
+	// The same rules are used by the handlers and the store
+	validator, err := validation.New(
+		validation.MinAnswers(vc.BuffMinAnswers),
+		validation.MaxAnswers(vc.BuffMaxAnswers),
+		validation.MaxQuestionLength(vc.BuffMaxQuestionLength),
+		validation.ExactlyOneCorrect(vc.BuffExactlyOneCorrect),
+		validation.MaxTitleLength(vc.StreamMaxTitleLength),
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	lc, err := config.LiveConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	sc, err := config.ServerConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// The server would cut the connection off otherwise, rather than the client being asked to reconnect
+	if lc.MaxLifetime >= sc.ServerWiteTimeout {
+		return nil, fmt.Errorf("LIVE_MAX_LIFETIME %s must be shorter than SERVE_WRITE_TIMEOUT %s", lc.MaxLifetime, sc.ServerWiteTimeout)
+	}
+
+	hub, err := live.NewHub(live.SubscriberBuffer(lc.SubscriberBuffer))
+	if err != nil {
+		return nil, err
+	}
+
+	// Every version of the api shares the same store, which publishes the buffs created to the hub
+	store, err := newStore(validator, hub)
+	if err != nil {
+		return nil, err
+	}
+
+	liveOptions := []buff.LiveOption{
+		buff.HeartbeatInterval(lc.Heartbeat),
+		buff.MaxLifetime(lc.MaxLifetime),
+	}
+	socketOptions := []buff.SocketOption{
+		buff.PingInterval(lc.PingInterval),
+	}
+
+	r.Mount("/v1", v1(codecSelector, store, validator, hub, liveOptions, socketOptions))
+	r.Mount("/v2", v2(codecSelector, store, validator, hub, liveOptions, socketOptions))
+	return r, nil
+}
+
+// v1 builds the v1 api, which is frozen for existing clients
+func v1(
+	codecSelector apiutils.CodecSelector,
+	store model.Store,
+	validator *validation.Validator,
+	hub *live.Hub,
+	liveOptions []buff.LiveOption,
+	socketOptions []buff.SocketOption,
+) *chi.Mux {
+	r := chi.NewRouter()
+
+	// video_stream endpoint
+	videoStreamRoutes(r, codecSelector, store, validator)
+	r.Method("GET", "/video_streams/{uuid}/buffs", apiutils.HandlerWithSelector(codecSelector, buff.NewViewerListForStreamHandler(store)))
+	r.Method("GET", "/video_streams/{uuid}/buffs/live", apiutils.HandlerWithSelector(codecSelector, buff.NewLiveHandler(store, hub, liveOptions...)))
+	r.Method("GET", "/video_streams/{uuid}/ws", apiutils.HandlerWithSelector(codecSelector, buff.NewSocketHandler(store, hub, validator, socketOptions...)))
+
+	// buffs endpoint, in the shape shown to viewers
+	r.Method("GET", "/buffs", apiutils.HandlerWithSelector(codecSelector, buff.NewViewerListHandler(store)))
+	r.Method("GET", "/buffs/{uuid}", apiutils.HandlerWithSelector(codecSelector, buff.NewViewerGetHandler(store)))
+	responseRoutes(r, codecSelector, store, validator)
+
+	// tags endpoint, counting the buffs and streams with each tag
+	r.Method("GET", "/tags", apiutils.HandlerWithSelector(codecSelector, tag.NewListHandler(store)))
+
+	// buffs are written, and read with their correct answers, through the admin routes
+	r.Route("/admin", func(r chi.Router) {
+		r.Method("GET", "/video_streams/{uuid}/buffs", apiutils.HandlerWithSelector(codecSelector, buff.NewListForStreamHandler(store)))
+		r.Method("POST", "/video_streams/{uuid}/buffs", apiutils.HandlerWithSelector(codecSelector, buff.NewCreateForStreamHandler(store, validator)))
+
+		r.Method("GET", "/buffs", apiutils.HandlerWithSelector(codecSelector, buff.NewListHandler(store)))
+		r.Method("GET", "/buffs/search", apiutils.HandlerWithSelector(codecSelector, buff.NewSearchHandler(store)))
+		r.Method("POST", "/buffs", apiutils.HandlerWithSelector(codecSelector, buff.NewCreateHandler(store, validator)))
+		r.Method("GET", "/buffs/{uuid}", apiutils.HandlerWithSelector(codecSelector, buff.NewGetHandler(store)))
+		r.Method("PUT", "/buffs/{uuid}", apiutils.HandlerWithSelector(codecSelector, buff.NewUpdateHandler(store, validator)))
+		r.Method("PATCH", "/buffs/{uuid}", apiutils.HandlerWithSelector(codecSelector, buff.NewPatchHandler(store, validator)))
+		r.Method("DELETE", "/buffs/{uuid}", apiutils.HandlerWithSelector(codecSelector, buff.NewDeleteHandler(store)))
+
+		// a prediction is resolved once its result is known, scoring the responses given to it
+		r.Method("POST", "/buffs/{uuid}/resolve", apiutils.HandlerWithSelector(codecSelector, buff.NewResolveHandler(store, validator)))
+	})
+
+	return r
+}
+
+// v2 builds the v2 api
+//
+// Buffs are served with every answer and its ID, along with links to the
+// resources related to them. The other resources are the same as in v1.
+func v2(
+	codecSelector apiutils.CodecSelector,
+	store model.Store,
+	validator *validation.Validator,
+	hub *live.Hub,
+	liveOptions []buff.LiveOption,
+	socketOptions []buff.SocketOption,
+) *chi.Mux {
+	r := chi.NewRouter()
+
 	// video_stream endpoint
+	videoStreamRoutes(r, codecSelector, store, validator)
+	r.Method("GET", "/video_streams/{uuid}/buffs", apiutils.HandlerWithSelector(codecSelector, buff.NewV2ViewerListForStreamHandler(store)))
+	r.Method("GET", "/video_streams/{uuid}/buffs/live", apiutils.HandlerWithSelector(codecSelector, buff.NewV2LiveHandler(store, hub, liveOptions...)))
+	r.Method("GET", "/video_streams/{uuid}/ws", apiutils.HandlerWithSelector(codecSelector, buff.NewV2SocketHandler(store, hub, validator, socketOptions...)))
+
+	// buffs endpoint, in the shape shown to viewers
+	r.Method("GET", "/buffs", apiutils.HandlerWithSelector(codecSelector, buff.NewV2ViewerListHandler(store)))
+	r.Method("GET", "/buffs/{uuid}", apiutils.HandlerWithSelector(codecSelector, buff.NewV2ViewerGetHandler(store)))
+	responseRoutes(r, codecSelector, store, validator)
+
+	// tags endpoint, counting the buffs and streams with each tag
+	r.Method("GET", "/tags", apiutils.HandlerWithSelector(codecSelector, tag.NewListHandler(store)))
+
+	// buffs are written, and read with their correct answers, through the admin routes
+	r.Route("/admin", func(r chi.Router) {
+		r.Method("GET", "/video_streams/{uuid}/buffs", apiutils.HandlerWithSelector(codecSelector, buff.NewV2ListForStreamHandler(store)))
+		r.Method("POST", "/video_streams/{uuid}/buffs", apiutils.HandlerWithSelector(codecSelector, buff.NewV2CreateForStreamHandler(store, validator)))
+
+		r.Method("GET", "/buffs", apiutils.HandlerWithSelector(codecSelector, buff.NewV2ListHandler(store)))
+		r.Method("GET", "/buffs/search", apiutils.HandlerWithSelector(codecSelector, buff.NewV2SearchHandler(store)))
+		r.Method("POST", "/buffs", apiutils.HandlerWithSelector(codecSelector, buff.NewV2CreateHandler(store, validator)))
+		r.Method("GET", "/buffs/{uuid}", apiutils.HandlerWithSelector(codecSelector, buff.NewV2GetHandler(store)))
+		r.Method("PUT", "/buffs/{uuid}", apiutils.HandlerWithSelector(codecSelector, buff.NewV2UpdateHandler(store, validator)))
+		r.Method("PATCH", "/buffs/{uuid}", apiutils.HandlerWithSelector(codecSelector, buff.NewV2PatchHandler(store, validator)))
+		r.Method("DELETE", "/buffs/{uuid}", apiutils.HandlerWithSelector(codecSelector, buff.NewDeleteHandler(store)))
+
+		// a prediction is resolved once its result is known, scoring the responses given to it
+		r.Method("POST", "/buffs/{uuid}/resolve", apiutils.HandlerWithSelector(codecSelector, buff.NewResolveHandler(store, validator)))
+	})
+
+	return r
+}
+
+// videoStreamRoutes registers the video stream routes, which are the same in every version
+func videoStreamRoutes(r chi.Router, codecSelector apiutils.CodecSelector, store model.Store, validator *validation.Validator) {
 	r.Method("GET", "/video_streams", apiutils.HandlerWithSelector(codecSelector, videostream.NewListHandler(store)))
+	r.Method("POST", "/video_streams", apiutils.HandlerWithSelector(codecSelector, videostream.NewCreateHandler(store, validator)))
 	r.Method("GET", "/video_streams/{uuid}", apiutils.HandlerWithSelector(codecSelector, videostream.NewGetHandler(store)))
-	r.Method("GET", "/video_streams/{uuid}/buffs", apiutils.HandlerWithSelector(codecSelector, buff.NewListForStreamHandler(store)))
+	r.Method("PUT", "/video_streams/{uuid}", apiutils.HandlerWithSelector(codecSelector, videostream.NewUpdateHandler(store, validator)))
+	r.Method("PATCH", "/video_streams/{uuid}", apiutils.HandlerWithSelector(codecSelector, videostream.NewPatchHandler(store, validator)))
+	r.Method("DELETE", "/video_streams/{uuid}", apiutils.HandlerWithSelector(codecSelector, videostream.NewDeleteHandler(store)))
 
-	// buffs endpoint
-	r.Method("GET", "/buffs", apiutils.HandlerWithSelector(codecSelector, buff.NewListHandler(store)))
-	r.Method("GET", "/buffs/{uuid}", apiutils.HandlerWithSelector(codecSelector, buff.NewGetHandler(store)))
+	// the lifecycle of a stream is moved on by actions, rather than by writing its state
+	r.Method("POST", "/video_streams/{uuid}:start", apiutils.HandlerWithSelector(codecSelector, videostream.NewTransitionHandler(store, model.StreamLive)))
+	r.Method("POST", "/video_streams/{uuid}:end", apiutils.HandlerWithSelector(codecSelector, videostream.NewTransitionHandler(store, model.StreamEnded)))
+	r.Method("POST", "/video_streams/{uuid}:archive", apiutils.HandlerWithSelector(codecSelector, videostream.NewTransitionHandler(store, model.StreamArchived)))
 
-	return r, nil
+	// every stream ranks the users responding to its buffs, kept up to date as they respond
+	r.Method("GET", "/video_streams/{uuid}/leaderboard", apiutils.HandlerWithSelector(codecSelector, leaderboard.NewListHandler(store)))
+	r.Method("GET", "/video_streams/{uuid}/leaderboard/users/{user_id}", apiutils.HandlerWithSelector(codecSelector, leaderboard.NewGetHandler(store)))
+}
+
+// responseRoutes registers the routes for responding to buffs, which are the same in every version
+func responseRoutes(r chi.Router, codecSelector apiutils.CodecSelector, store model.Store, validator *validation.Validator) {
+	r.Method("POST", "/buffs/{uuid}/responses", apiutils.HandlerWithSelector(codecSelector, response.NewCreateHandler(store, validator)))
+	r.Method("GET", "/buffs/{uuid}/results", apiutils.HandlerWithSelector(codecSelector, response.NewResultsHandler(store)))
+}
+
+// newStore builds the storage backend selected in the application's environment
+// The buffs created in the store, and the results of the responses given, are published to the hub
+func newStore(validator *validation.Validator, hub *live.Hub) (model.Store, error) {
+	sc, err := config.StoreConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	scc, err := config.ScoringConfig()
+	if err != nil {
+		return nil, err
+	}
+	scoring := model.Scoring{Points: scc.Points, SpeedBonus: scc.SpeedBonus}
+
+	switch sc.Backend {
+	case "postgres":
+		// TODO: how do we shut this down?
+		// Do we need to? can it just follow the lifecycle of the service?
+		dc, err := config.DBConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		store, err := postgres.NewStore(
+			postgres.SetDBUser(dc.DBUser),
+			postgres.SetDBPassword(dc.DBPassword),
+			postgres.SetDBHostname(dc.DBHost),
+			postgres.SetDBPort(dc.DBPort),
+			postgres.SetDBName(dc.DBName),
+			postgres.SetConnectTimeout(dc.DBConnectTimeout),
+			postgres.WithValidator(validator),
+			postgres.WithScoring(scoring),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// The database tells every replica about the buffs created, and the responses given,
+		// through any of them, so the store itself doesn't publish.
+		// The listeners follow the lifecycle of the service.
+		if _, err := store.ListenBuffs(hub.Publish); err != nil {
+			return nil, err
+		}
+		if _, err := store.ListenResults(hub.PublishResults); err != nil {
+			return nil, err
+		}
+		return store, nil
+
+	case "memory":
+		store, err := memory.NewStore(memory.WithValidator(validator), memory.WithScoring(scoring))
+		if err != nil {
+			return nil, err
+		}
+
+		if sc.Seed {
+			if err := seed.Populate(context.Background(), store); err != nil {
+				return nil, err
+			}
+		}
+
+		// Nothing else can write to the memory store, so it publishes its own buffs and results
+		return live.NewStore(store, hub), nil
+
+	default:
+		return nil, fmt.Errorf("unknown store backend %q", sc.Backend)
+	}
 }
@@ -0,0 +1,49 @@
+package leaderboard
+
+import (
+	"net/http"
+
+	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/buffassignment/api/apierror"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+// NewGetHandler returns a new instance of the get action of
+// the leaderboard API using the given store instance.
+//
+// The score of the user in the user_id URL param is served, ranked against every
+// other user of the stream, however far down the leaderboard they are.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewGetHandler(store model.LeaderboardStore) apiutils.Handler {
+	return &leaderboardGet{store}
+}
+
+// leaderboardGet implements the apiutils.Handler interface to provide the
+// get portion of the leaderboard API
+type leaderboardGet struct {
+	store model.LeaderboardStore
+}
+
+// ServeCodec serves the API using the apiutils.Handler pattern
+// This allows the business logic to live here, and the encoding to live separate from it
+// This also makes testing easier, as there is a test codec that allows us to peek at the output
+// in a testing context.
+func (l *leaderboardGet) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
+	vID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	score, err := l.store.GetScore(r.Context(), model.VideoStreamID(vID), model.UserID(chi.URLParam(r, "user_id")))
+	if err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+	c.Respond(r.Context(), w, http.StatusOK, types.NewScore(*score))
+}
@@ -0,0 +1,105 @@
+package leaderboard_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/JoeReid/apiutils/testingcodec"
+	"github.com/JoeReid/buffassignment/api/leaderboard"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/model/testmodel"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetScore(t *testing.T) {
+	sentinelUUID := uuid.New()
+	scored := time.Now()
+
+	var tests = []struct {
+		name                 string
+		requestParams        map[string]string
+		storeResponse        *model.Score
+		storeError           error
+		expectResponseCode   int
+		expectResponseData   interface{}
+		expectStoreNotCalled bool
+	}{
+		{
+			name:               "returns score on happy path",
+			requestParams:      map[string]string{"uuid": sentinelUUID.String(), "user_id": "user-42"},
+			storeResponse:      &model.Score{User: "user-42", Rank: 1337, Points: 300, Correct: 3, Responses: 5, ScoredAt: scored},
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.Score{Rank: 1337, UserID: "user-42", Points: 300, CorrectAnswers: 3, Responses: 5, ScoredAt: scored},
+		},
+		{
+			name:               "returns not found on no score",
+			requestParams:      map[string]string{"uuid": sentinelUUID.String(), "user_id": "user-42"},
+			storeResponse:      (*model.Score)(nil),
+			storeError:         model.ErrNotFound,
+			expectResponseCode: http.StatusNotFound,
+			expectResponseData: newProblem(http.StatusNotFound, model.ErrNotFound.Error()),
+		},
+		{
+			name:                 "returns bad request on missformated uuid",
+			requestParams:        map[string]string{"uuid": "not_a_valid_uuid", "user_id": "user-42"},
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, "invalid UUID length: 16"),
+			expectStoreNotCalled: true,
+		},
+		{
+			name:               "returns internal error on unexpected store error",
+			requestParams:      map[string]string{"uuid": sentinelUUID.String(), "user_id": "user-42"},
+			storeResponse:      (*model.Score)(nil),
+			storeError:         errors.New("the world exploded"),
+			expectResponseCode: http.StatusInternalServerError,
+			expectResponseData: newProblem(http.StatusInternalServerError, ""),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return the data configured in the test fixture
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("GetScore", mock.Anything, mock.Anything, mock.Anything).Return(tt.storeResponse, tt.storeError)
+
+			// Build the request to the spec of the test fixture
+			rctx := chi.NewRouteContext()
+			for k, v := range tt.requestParams {
+				rctx.URLParams.Add(k, v)
+			}
+			req, err := http.NewRequest("GET", "", nil)
+			require.NoError(t, err, "failed to build request for test")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+
+			// Create the handler under test, and execute it
+			handler := leaderboard.NewGetHandler(testingStore)
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler returns the expected data
+			codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+
+			// assert that the handler responded only once
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			// If the handler needs to use the store, assert it made the right call
+			if tt.expectStoreNotCalled {
+				// assert that no calls to the store were made
+				testingStore.AssertNotCalled(t, "GetScore", mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				// assert that the store was called with the correct uuid and user
+				testingStore.AssertCalled(t, "GetScore", mock.Anything, model.VideoStreamID(sentinelUUID), model.UserID("user-42"))
+			}
+		})
+	}
+}
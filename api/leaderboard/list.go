@@ -0,0 +1,84 @@
+// Package leaderboard provides the API ranking the users that respond to the buffs of each video stream
+package leaderboard
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/buffassignment/api/apierror"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+// TopKey is the URL param holding how many of the best scores to list
+const TopKey = "top"
+
+// The number of scores listed when the top param isn't given, and the most that can be asked for
+const (
+	DefaultTop = 50
+	MaxTop     = 500
+)
+
+// ErrInvalidTop is returned when the top param isn't a number of scores that can be listed
+var ErrInvalidTop = fmt.Errorf("leaderboard error: top must be a whole number from 1 to %d", MaxTop)
+
+// NewListHandler returns a new instance of the list action of
+// the leaderboard API using the given store instance.
+//
+// The best scores of the stream are listed, as many as the top param asks for.
+// A user further down can find their own rank with the get action.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewListHandler(store model.LeaderboardStore) apiutils.Handler {
+	return &leaderboardList{store}
+}
+
+// leaderboardList implements the apiutils.Handler interface to provide the
+// list portion of the leaderboard API
+type leaderboardList struct {
+	store model.LeaderboardStore
+}
+
+// ServeCodec serves the API using the apiutils.Handler pattern
+// This allows the business logic to live here, and the encoding to live separate from it
+// This also makes testing easier, as there is a test codec that allows us to peek at the output
+// in a testing context.
+func (l *leaderboardList) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
+	vID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	top, err := parseTop(r)
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	scores, err := l.store.ListLeaderboard(r.Context(), model.VideoStreamID(vID), top)
+	if err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+	c.Respond(r.Context(), w, http.StatusOK, types.NewLeaderboard(model.VideoStreamID(vID), scores))
+}
+
+// parseTop reads the top param of the request, or DefaultTop if it isn't set
+func parseTop(r *http.Request) (int, error) {
+	values, ok := r.URL.Query()[TopKey]
+	if !ok {
+		return DefaultTop, nil
+	}
+
+	top, err := strconv.Atoi(values[0])
+	if err != nil || top < 1 || top > MaxTop {
+		return 0, ErrInvalidTop
+	}
+	return top, nil
+}
@@ -0,0 +1,162 @@
+package leaderboard_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/JoeReid/apiutils/testingcodec"
+	"github.com/JoeReid/buffassignment/api/leaderboard"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/model/testmodel"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListLeaderboard(t *testing.T) {
+	sentinelUUID := uuid.New()
+	scored := time.Now()
+
+	var tests = []struct {
+		name                 string
+		requestParams        map[string]string
+		requestQuery         string
+		storeResponse        []model.Score
+		storeError           error
+		expectTop            int
+		expectResponseCode   int
+		expectResponseData   interface{}
+		expectStoreNotCalled bool
+	}{
+		{
+			name:          "returns scores on happy path",
+			requestParams: map[string]string{"uuid": sentinelUUID.String()},
+			requestQuery:  "?top=2",
+			storeResponse: []model.Score{
+				{User: "alice", Rank: 1, Points: 200, Correct: 2, Responses: 2, ScoredAt: scored},
+				{User: "bob", Rank: 2, Points: 100, Correct: 1, Responses: 3, ScoredAt: scored},
+			},
+			expectTop:          2,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.Leaderboard{
+				VideoStreamUUID: sentinelUUID.String(),
+				Scores: []types.Score{
+					{Rank: 1, UserID: "alice", Points: 200, CorrectAnswers: 2, Responses: 2, ScoredAt: scored},
+					{Rank: 2, UserID: "bob", Points: 100, CorrectAnswers: 1, Responses: 3, ScoredAt: scored},
+				},
+			},
+		},
+		{
+			name:               "returns empty leaderboard on no scores",
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			storeResponse:      []model.Score{},
+			expectTop:          leaderboard.DefaultTop,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.Leaderboard{VideoStreamUUID: sentinelUUID.String(), Scores: []types.Score{}},
+		},
+		{
+			name:               "returns not found on unknown stream",
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			storeResponse:      []model.Score(nil),
+			storeError:         model.ErrNotFound,
+			expectTop:          leaderboard.DefaultTop,
+			expectResponseCode: http.StatusNotFound,
+			expectResponseData: newProblem(http.StatusNotFound, model.ErrNotFound.Error()),
+		},
+		{
+			name:                 "returns bad request on missformated uuid",
+			requestParams:        map[string]string{"uuid": "not_a_valid_uuid"},
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, "invalid UUID length: 16"),
+			expectStoreNotCalled: true,
+		},
+		{
+			name:                 "returns bad request on non numeric top",
+			requestParams:        map[string]string{"uuid": sentinelUUID.String()},
+			requestQuery:         "?top=ten",
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, leaderboard.ErrInvalidTop.Error()),
+			expectStoreNotCalled: true,
+		},
+		{
+			name:                 "returns bad request on zero top",
+			requestParams:        map[string]string{"uuid": sentinelUUID.String()},
+			requestQuery:         "?top=0",
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, leaderboard.ErrInvalidTop.Error()),
+			expectStoreNotCalled: true,
+		},
+		{
+			name:                 "returns bad request on top over the limit",
+			requestParams:        map[string]string{"uuid": sentinelUUID.String()},
+			requestQuery:         "?top=501",
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, leaderboard.ErrInvalidTop.Error()),
+			expectStoreNotCalled: true,
+		},
+		{
+			name:               "returns internal error on unexpected store error",
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			storeResponse:      []model.Score(nil),
+			storeError:         errors.New("the world exploded"),
+			expectTop:          leaderboard.DefaultTop,
+			expectResponseCode: http.StatusInternalServerError,
+			expectResponseData: newProblem(http.StatusInternalServerError, ""),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return the data configured in the test fixture
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("ListLeaderboard", mock.Anything, mock.Anything, mock.Anything).Return(tt.storeResponse, tt.storeError)
+
+			// Build the request to the spec of the test fixture
+			rctx := chi.NewRouteContext()
+			for k, v := range tt.requestParams {
+				rctx.URLParams.Add(k, v)
+			}
+			req, err := http.NewRequest("GET", tt.requestQuery, nil)
+			require.NoError(t, err, "failed to build request for test")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+
+			// Create the handler under test, and execute it
+			handler := leaderboard.NewListHandler(testingStore)
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler returns the expected data
+			codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+
+			// assert that the handler responded only once
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			// If the handler needs to use the store, assert it made the right call
+			if tt.expectStoreNotCalled {
+				// assert that no calls to the store were made
+				testingStore.AssertNotCalled(t, "ListLeaderboard", mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				// assert that the store was called with the correct uuid and top
+				testingStore.AssertCalled(t, "ListLeaderboard", mock.Anything, model.VideoStreamID(sentinelUUID), tt.expectTop)
+			}
+		})
+	}
+}
+
+// newProblem returns the problem a handler is expected to respond with
+func newProblem(status int, detail string) types.Problem {
+	return types.Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
@@ -0,0 +1,52 @@
+// Package paginate reads the cursor pagination parameters shared by the list endpoints
+//
+// Cursor pagination is opt in, the endpoints fall back to the count and skip
+// parameters of apiutils.Paginate when no cursor param is given, so that
+// existing clients keep working.
+package paginate
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/buffassignment/internal/model"
+)
+
+// CursorKey is the URL param holding the cursor
+const CursorKey = "cursor"
+
+// ErrSkipWithCursor is returned when a request sets both skip and cursor
+var ErrSkipWithCursor = errors.New("paginate error: skip cannot be used with a cursor")
+
+// Cursor reads the cursor and page size from the request
+//
+// ok is false when the cursor param is not set at all, in which case the
+// endpoint should use offset pagination. An empty cursor asks for the first
+// page, and is returned as a nil cursor.
+//
+// The page size is read from the count param, with the same rules as apiutils.Paginate.
+func Cursor(r *http.Request, opts ...apiutils.PaginateOption) (after *model.Cursor, count int, ok bool, err error) {
+	values, ok := r.URL.Query()[CursorKey]
+	if !ok {
+		return nil, 0, false, nil
+	}
+
+	count, skip, err := apiutils.Paginate(r, opts...)
+	if err != nil {
+		return nil, 0, true, err
+	}
+	if skip != 0 {
+		return nil, 0, true, ErrSkipWithCursor
+	}
+
+	if values[0] == "" {
+		return nil, count, true, nil
+	}
+
+	c, err := model.ParseCursor(values[0])
+	if err != nil {
+		return nil, 0, true, err
+	}
+	return &c, count, true, nil
+}
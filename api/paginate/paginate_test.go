@@ -0,0 +1,85 @@
+package paginate_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/buffassignment/api/paginate"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor(t *testing.T) {
+	sentinelCursor := model.Cursor{CreatedAt: time.Now().UTC().Round(0), ID: uuid.New()}
+
+	var tests = []struct {
+		name             string
+		requestURLValues url.Values
+		expectAfter      *model.Cursor
+		expectCount      int
+		expectOK         bool
+		expectError      bool
+	}{
+		{
+			name:             "no cursor param",
+			requestURLValues: url.Values{"count": {"5"}, "skip": {"2"}},
+			expectOK:         false,
+		},
+		{
+			name:             "empty cursor asks for the first page",
+			requestURLValues: url.Values{"cursor": {""}},
+			expectAfter:      nil,
+			expectCount:      10,
+			expectOK:         true,
+		},
+		{
+			name:             "cursor and count",
+			requestURLValues: url.Values{"cursor": {sentinelCursor.String()}, "count": {"5"}},
+			expectAfter:      &sentinelCursor,
+			expectCount:      5,
+			expectOK:         true,
+		},
+		{
+			name:             "invalid cursor",
+			requestURLValues: url.Values{"cursor": {"not a cursor"}},
+			expectOK:         true,
+			expectError:      true,
+		},
+		{
+			name:             "skip with a cursor",
+			requestURLValues: url.Values{"cursor": {""}, "skip": {"1"}},
+			expectOK:         true,
+			expectError:      true,
+		},
+		{
+			name:             "invalid count",
+			requestURLValues: url.Values{"cursor": {""}, "count": {"0"}},
+			expectOK:         true,
+			expectError:      true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "", nil)
+			require.NoError(t, err, "failed to build request for test")
+			req.URL.RawQuery = tt.requestURLValues.Encode()
+
+			after, count, ok, err := paginate.Cursor(req, apiutils.DefaultCount(10))
+			assert.Equal(t, tt.expectOK, ok)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectAfter, after)
+			assert.Equal(t, tt.expectCount, count)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// Package response provides the API for users to respond to buffs,
+// and for the results of those responses to be read back
+package response
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/buffassignment/api/apierror"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/validation"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+// NewCreateHandler returns a new instance of the create action of
+// the response API using the given store instance.
+//
+// The buff being responded to is read from the URL, and any buff in the
+// request body is ignored.
+//
+// The new response is checked against the rules of the given validator before it is stored.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewCreateHandler(store model.ResponseStore, validator *validation.Validator) apiutils.Handler {
+	return &responseCreate{store, validator}
+}
+
+// responseCreate implements the apiutils.Handler interface to provide the
+// create portion of the response API
+type responseCreate struct {
+	store     model.ResponseStore
+	validator *validation.Validator
+}
+
+// ServeCodec serves the API using the apiutils.Handler pattern
+// This allows the business logic to live here, and the encoding to live separate from it
+// This also makes testing easier, as there is a test codec that allows us to peek at the output
+// in a testing context.
+func (rc *responseCreate) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
+	bID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var req types.Response
+	if err := c.Read(r.Context(), r, &req); err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	aID, err := uuid.Parse(req.AnswerUUID)
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	mr := model.Response{
+		Buff:      model.BuffID(bID),
+		User:      model.UserID(req.UserID),
+		Answer:    model.AnswerID(aID),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err := rc.validator.Response(mr); err != nil {
+		apierror.RespondInvalid(c, w, r, err)
+		return
+	}
+
+	if err := rc.store.CreateResponse(r.Context(), mr); err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+	c.Respond(r.Context(), w, http.StatusCreated, types.NewResponse(mr))
+}
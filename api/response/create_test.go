@@ -0,0 +1,209 @@
+package response_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/JoeReid/apiutils/testingcodec"
+	"github.com/JoeReid/buffassignment/api/response"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/model/testmodel"
+	"github.com/JoeReid/buffassignment/internal/validation"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateResponse(t *testing.T) {
+	buffUUID := uuid.New()
+	answerUUID := uuid.New()
+
+	var tests = []struct {
+		name                 string
+		requestParams        map[string]string
+		requestBody          types.Response
+		readError            error
+		storeError           error
+		expectResponseCode   int
+		expectResponseData   interface{}
+		expectStoreNotCalled bool
+	}{
+		{
+			name:               "returns created on happy path",
+			requestParams:      map[string]string{"uuid": buffUUID.String()},
+			requestBody:        types.Response{UserID: "user-42", AnswerUUID: answerUUID.String()},
+			expectResponseCode: http.StatusCreated,
+		},
+		{
+			name:          "ignores the buff in the request body",
+			requestParams: map[string]string{"uuid": buffUUID.String()},
+			requestBody: types.Response{
+				BuffUUID:   uuid.New().String(),
+				UserID:     "user-42",
+				AnswerUUID: answerUUID.String(),
+			},
+			expectResponseCode: http.StatusCreated,
+		},
+		{
+			name:                 "returns bad request on missformated buff uuid",
+			requestParams:        map[string]string{"uuid": "not_a_valid_uuid"},
+			requestBody:          types.Response{UserID: "user-42", AnswerUUID: answerUUID.String()},
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, "invalid UUID length: 16"),
+			expectStoreNotCalled: true,
+		},
+		{
+			name:                 "returns bad request on undecodable body",
+			requestParams:        map[string]string{"uuid": buffUUID.String()},
+			readError:            errors.New("bad body"),
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, "bad body"),
+			expectStoreNotCalled: true,
+		},
+		{
+			name:                 "returns bad request on missformated answer uuid",
+			requestParams:        map[string]string{"uuid": buffUUID.String()},
+			requestBody:          types.Response{UserID: "user-42", AnswerUUID: "not_a_valid_uuid"},
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, "invalid UUID length: 16"),
+			expectStoreNotCalled: true,
+		},
+		{
+			name:               "returns unprocessable entity on missing user",
+			requestParams:      map[string]string{"uuid": buffUUID.String()},
+			requestBody:        types.Response{AnswerUUID: answerUUID.String()},
+			expectResponseCode: http.StatusUnprocessableEntity,
+			expectResponseData: newInvalidProblem(
+				types.ValidationError{Field: "user_id", Rule: "required", Message: "must not be empty"},
+			),
+			expectStoreNotCalled: true,
+		},
+		{
+			name:               "returns not found on unknown buff",
+			requestParams:      map[string]string{"uuid": buffUUID.String()},
+			requestBody:        types.Response{UserID: "user-42", AnswerUUID: answerUUID.String()},
+			storeError:         model.ErrNotFound,
+			expectResponseCode: http.StatusNotFound,
+			expectResponseData: newProblem(http.StatusNotFound, model.ErrNotFound.Error()),
+		},
+		{
+			name:               "returns unprocessable entity on an answer to another buff",
+			requestParams:      map[string]string{"uuid": buffUUID.String()},
+			requestBody:        types.Response{UserID: "user-42", AnswerUUID: answerUUID.String()},
+			storeError:         model.ErrInvalidReference,
+			expectResponseCode: http.StatusUnprocessableEntity,
+			expectResponseData: newProblem(http.StatusUnprocessableEntity, model.ErrInvalidReference.Error()),
+		},
+		{
+			name:               "returns conflict when the user has already responded",
+			requestParams:      map[string]string{"uuid": buffUUID.String()},
+			requestBody:        types.Response{UserID: "user-42", AnswerUUID: answerUUID.String()},
+			storeError:         model.ErrConflict,
+			expectResponseCode: http.StatusConflict,
+			expectResponseData: newProblem(http.StatusConflict, model.ErrConflict.Error()),
+		},
+		{
+			name:               "returns conflict when the stream isn't live",
+			requestParams:      map[string]string{"uuid": buffUUID.String()},
+			requestBody:        types.Response{UserID: "user-42", AnswerUUID: answerUUID.String()},
+			storeError:         &model.StreamNotLiveError{Stream: model.VideoStreamID(buffUUID), State: model.StreamEnded},
+			expectResponseCode: http.StatusConflict,
+			expectResponseData: newProblem(
+				http.StatusConflict,
+				(&model.StreamNotLiveError{Stream: model.VideoStreamID(buffUUID), State: model.StreamEnded}).Error(),
+			),
+		},
+		{
+			name:               "returns internal error on unexpected store error",
+			requestParams:      map[string]string{"uuid": buffUUID.String()},
+			requestBody:        types.Response{UserID: "user-42", AnswerUUID: answerUUID.String()},
+			storeError:         errors.New("the world exploded"),
+			expectResponseCode: http.StatusInternalServerError,
+			expectResponseData: newProblem(http.StatusInternalServerError, ""),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return the data configured in the test fixture
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("CreateResponse", mock.Anything, mock.Anything).Return(tt.storeError)
+
+			// Build the request to the spec of the test fixture
+			rctx := chi.NewRouteContext()
+			for k, v := range tt.requestParams {
+				rctx.URLParams.Add(k, v)
+			}
+			req, err := http.NewRequest("POST", "", nil)
+			require.NoError(t, err, "failed to build request for test")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+			codec.On("Read", mock.Anything, mock.Anything, mock.Anything).Return(tt.readError).Run(func(args mock.Arguments) {
+				*args.Get(2).(*types.Response) = tt.requestBody
+			})
+
+			// Create the handler under test, and execute it
+			handler := response.NewCreateHandler(testingStore, newValidator(t))
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler responded only once
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			if tt.expectStoreNotCalled {
+				// assert that no calls to the store were made
+				testingStore.AssertNotCalled(t, "CreateResponse", mock.Anything, mock.Anything)
+				codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+				return
+			}
+
+			// The creation time is set by the handler, so pull the response back out of the store call
+			testingStore.AssertNumberOfCalls(t, "CreateResponse", 1)
+			created := testingStore.Calls[0].Arguments.Get(1).(model.Response)
+
+			assert.Equal(t, model.BuffID(buffUUID), created.Buff)
+			assert.Equal(t, model.UserID(tt.requestBody.UserID), created.User)
+			assert.Equal(t, model.AnswerID(answerUUID), created.Answer)
+			assert.False(t, created.CreatedAt.IsZero(), "the creation time should be set by the handler")
+
+			if tt.expectResponseData == nil {
+				tt.expectResponseData = types.NewResponse(created)
+			}
+			codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+		})
+	}
+}
+
+// newValidator returns a validator using the default rules
+func newValidator(t *testing.T) *validation.Validator {
+	v, err := validation.New()
+	require.NoError(t, err, "failed to build validator")
+	return v
+}
+
+// newProblem returns the problem a handler is expected to respond with
+// The test requests have no path and no request id
+func newProblem(status int, detail string) types.Problem {
+	return types.Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// newInvalidProblem returns the problem a handler is expected to respond with
+// when the request breaks the given validation rules
+func newInvalidProblem(errs ...types.ValidationError) types.Problem {
+	p := newProblem(http.StatusUnprocessableEntity, fmt.Sprintf("the request breaks %d validation rules", len(errs)))
+	p.Errors = errs
+	return p
+}
@@ -0,0 +1,46 @@
+package response
+
+import (
+	"net/http"
+
+	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/buffassignment/api/apierror"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+// NewResultsHandler returns a new instance of the results action of
+// the response API using the given store instance.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewResultsHandler(store model.ResponseStore) apiutils.Handler {
+	return &responseResults{store}
+}
+
+// responseResults implements the apiutils.Handler interface to provide the
+// results portion of the response API
+type responseResults struct {
+	store model.ResponseStore
+}
+
+// ServeCodec serves the API using the apiutils.Handler pattern
+// This allows the business logic to live here, and the encoding to live separate from it
+// This also makes testing easier, as there is a test codec that allows us to peek at the output
+// in a testing context.
+func (rr *responseResults) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
+	bID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	results, err := rr.store.GetResults(r.Context(), model.BuffID(bID))
+	if err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+	c.Respond(r.Context(), w, http.StatusOK, types.NewResults(*results))
+}
@@ -0,0 +1,139 @@
+package response_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/JoeReid/apiutils/testingcodec"
+	"github.com/JoeReid/buffassignment/api/response"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/model/testmodel"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetResults(t *testing.T) {
+	sentinelUUID := uuid.New()
+	answers := []model.AnswerID{model.AnswerID(uuid.New()), model.AnswerID(uuid.New()), model.AnswerID(uuid.New())}
+
+	var tests = []struct {
+		name                 string
+		requestParams        map[string]string
+		storeResponse        *model.Results
+		storeError           error
+		expectResponseCode   int
+		expectResponseData   interface{}
+		expectStoreNotCalled bool
+	}{
+		{
+			name:          "returns counts and percentages on happy path",
+			requestParams: map[string]string{"uuid": sentinelUUID.String()},
+			storeResponse: &model.Results{
+				Buff:  model.BuffID(sentinelUUID),
+				Total: 3,
+				Answers: []model.AnswerResult{
+					{Answer: answers[0], Text: "42", Responses: 2},
+					{Answer: answers[1], Text: "43", Responses: 1},
+					{Answer: answers[2], Text: "44", Responses: 0},
+				},
+			},
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.Results{
+				BuffUUID: sentinelUUID.String(),
+				Total:    3,
+				Answers: []types.AnswerResult{
+					{AnswerUUID: answers[0].String(), Text: "42", Responses: 2, Percentage: 66.7},
+					{AnswerUUID: answers[1].String(), Text: "43", Responses: 1, Percentage: 33.3},
+					{AnswerUUID: answers[2].String(), Text: "44", Responses: 0, Percentage: 0},
+				},
+			},
+		},
+		{
+			name:          "returns zero percentages without responses",
+			requestParams: map[string]string{"uuid": sentinelUUID.String()},
+			storeResponse: &model.Results{
+				Buff: model.BuffID(sentinelUUID),
+				Answers: []model.AnswerResult{
+					{Answer: answers[0], Text: "42"},
+					{Answer: answers[1], Text: "43"},
+				},
+			},
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.Results{
+				BuffUUID: sentinelUUID.String(),
+				Answers: []types.AnswerResult{
+					{AnswerUUID: answers[0].String(), Text: "42"},
+					{AnswerUUID: answers[1].String(), Text: "43"},
+				},
+			},
+		},
+		{
+			name:               "returns not found on no data",
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			storeResponse:      (*model.Results)(nil),
+			storeError:         model.ErrNotFound,
+			expectResponseCode: http.StatusNotFound,
+			expectResponseData: newProblem(http.StatusNotFound, model.ErrNotFound.Error()),
+		},
+		{
+			name:                 "returns bad request on missformated uuid",
+			requestParams:        map[string]string{"uuid": "not_a_valid_uuid"},
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, "invalid UUID length: 16"),
+			expectStoreNotCalled: true,
+		},
+		{
+			name:               "returns internal error on unexpected store error",
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			storeResponse:      (*model.Results)(nil),
+			storeError:         errors.New("the world exploded"),
+			expectResponseCode: http.StatusInternalServerError,
+			expectResponseData: newProblem(http.StatusInternalServerError, ""),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return the data configured in the test fixture
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("GetResults", mock.Anything, mock.Anything).Return(tt.storeResponse, tt.storeError)
+
+			// Build the request to the spec of the test fixture
+			rctx := chi.NewRouteContext()
+			for k, v := range tt.requestParams {
+				rctx.URLParams.Add(k, v)
+			}
+			req, err := http.NewRequest("GET", "", nil)
+			require.NoError(t, err, "failed to build request for test")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+
+			// Create the handler under test, and execute it
+			handler := response.NewResultsHandler(testingStore)
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler returns the expected data
+			codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+
+			// assert that the handler responded only once
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			// If the handler needs to use the store, assert it made the right call
+			if tt.expectStoreNotCalled {
+				// assert that no calls to the store were made
+				testingStore.AssertNotCalled(t, "GetResults", mock.Anything, mock.Anything)
+			} else {
+				// assert that the store was called with the correct uuid
+				testingStore.AssertCalled(t, "GetResults", mock.Anything, model.BuffID(sentinelUUID))
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+// Package tag provides the API reporting on the tags of the buffs and video streams
+package tag
+
+import (
+	"net/http"
+
+	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/buffassignment/api/apierror"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+)
+
+// NewListHandler returns a new instance of the list action of
+// the tag API using the given store instance.
+//
+// Every tag in use is listed, as there are only ever a few of them.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewListHandler(store model.TagStore) apiutils.Handler {
+	return &tagList{store}
+}
+
+// tagList implements the apiutils.Handler interface to provide the
+// list portion of the tag API
+type tagList struct {
+	store model.TagStore
+}
+
+// ServeCodec serves the API using the apiutils.Handler pattern
+// This allows the business logic to live here, and the encoding to live separate from it
+// This also makes testing easier, as there is a test codec that allows us to peek at the output
+// in a testing context.
+func (t *tagList) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
+	tags, err := t.store.ListTags(r.Context())
+	if err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+	c.Respond(r.Context(), w, http.StatusOK, types.NewTags(tags))
+}
@@ -0,0 +1,82 @@
+package tag_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/JoeReid/apiutils/testingcodec"
+	"github.com/JoeReid/buffassignment/api/tag"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/model/testmodel"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListTags(t *testing.T) {
+	var tests = []struct {
+		name               string
+		storeResponse      []model.TagCount
+		storeError         error
+		expectResponseCode int
+		expectResponseData interface{}
+	}{
+		{
+			name: "returns counts on happy path",
+			storeResponse: []model.TagCount{
+				{Tag: "football", Buffs: 2, VideoStreams: 1},
+				{Tag: "sponsor:acme", Buffs: 1},
+			},
+			expectResponseCode: http.StatusOK,
+			expectResponseData: []types.Tag{
+				{Tag: "football", Buffs: 2, VideoStreams: 1},
+				{Tag: "sponsor:acme", Buffs: 1},
+			},
+		},
+		{
+			name:               "returns empty list on no tags",
+			storeResponse:      []model.TagCount{},
+			expectResponseCode: http.StatusOK,
+			expectResponseData: []types.Tag{},
+		},
+		{
+			name:               "returns internal error on unexpected store error",
+			storeResponse:      []model.TagCount(nil),
+			storeError:         errors.New("the world exploded"),
+			expectResponseCode: http.StatusInternalServerError,
+			expectResponseData: types.Problem{
+				Type:   "about:blank",
+				Title:  http.StatusText(http.StatusInternalServerError),
+				Status: http.StatusInternalServerError,
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return the data configured in the test fixture
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("ListTags", mock.Anything).Return(tt.storeResponse, tt.storeError)
+
+			req, err := http.NewRequest("GET", "", nil)
+			require.NoError(t, err, "failed to build request for test")
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+
+			// Create the handler under test, and execute it
+			handler := tag.NewListHandler(testingStore)
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler returns the expected data
+			codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+
+			// assert that the handler responded only once
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			testingStore.AssertCalled(t, "ListTags", mock.Anything)
+		})
+	}
+}
@@ -1,20 +1,78 @@
 package types
 
-import "github.com/JoeReid/buffassignment/internal/model"
+import (
+	"time"
 
+	"github.com/JoeReid/buffassignment/internal/model"
+)
+
+// Buff is the v1 representation of a buff
+//
+// A quiz lists its correct answer apart from the incorrect ones. The other kinds
+// have no correct answer, so all of their answers are listed in Answers, until
+// a prediction is resolved and is listed as a quiz would be. The resolution is
+// ignored in a request, as it is only set by resolving the buff.
+//
+// The v1 format is frozen, so correct_answer and incorrect_answer are always
+// present, as they were before buffs had a kind.
 type Buff struct {
-	UUID             string   `json:"buff_id" yaml:"buff_id"`
-	VideoStreamUUID  string   `json:"stream_id" yaml:"stream_id"`
-	Question         string   `json:"question_text" yaml:"question_text"`
-	CorrectAnswer    string   `json:"correct_answer" yaml:"correct_answer"`
-	IncorrectAnswers []string `json:"incorrect_answer" yaml:"incorrect_answer"`
+	UUID             string           `json:"buff_id" yaml:"buff_id"`
+	VideoStreamUUID  string           `json:"stream_id" yaml:"stream_id"`
+	Kind             string           `json:"buff_kind,omitempty" yaml:"buff_kind,omitempty"`
+	Question         string           `json:"question_text" yaml:"question_text"`
+	CorrectAnswer    string           `json:"correct_answer" yaml:"correct_answer"`
+	IncorrectAnswers []string         `json:"incorrect_answer" yaml:"incorrect_answer"`
+	Answers          []string         `json:"answers,omitempty" yaml:"answers,omitempty"`
+	Schedule         *Schedule        `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+	Tags             []string         `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Resolution       *ResolutionState `json:"resolution,omitempty" yaml:"resolution,omitempty"`
+}
+
+// Schedule places a buff on the timeline of its stream
+// Both times are in milliseconds, the offset being measured from the start of the stream
+type Schedule struct {
+	StartOffsetMS int64 `json:"start_offset_ms" yaml:"start_offset_ms"`
+	DurationMS    int64 `json:"duration_ms" yaml:"duration_ms"`
+}
+
+// NewSchedule converts the schedule of a buff, returning nil for an unscheduled buff
+func NewSchedule(ms *model.Schedule) *Schedule {
+	if ms == nil {
+		return nil
+	}
+	return &Schedule{
+		StartOffsetMS: ms.Offset.Milliseconds(),
+		DurationMS:    ms.Duration.Milliseconds(),
+	}
+}
+
+// Model converts the schedule back into the model, returning nil if s is nil
+func (s *Schedule) Model() *model.Schedule {
+	if s == nil {
+		return nil
+	}
+	return &model.Schedule{
+		Offset:   time.Duration(s.StartOffsetMS) * time.Millisecond,
+		Duration: time.Duration(s.DurationMS) * time.Millisecond,
+	}
 }
 
 func NewBuff(mb model.Buff) Buff {
 	b := Buff{
 		UUID:            mb.ID.String(),
 		VideoStreamUUID: mb.Stream.String(),
+		Kind:            string(mb.Kind),
 		Question:        mb.Question,
+		Schedule:        NewSchedule(mb.Schedule),
+		Tags:            mb.Tags,
+		Resolution:      NewResolutionState(mb),
+	}
+
+	if !mb.Judged() {
+		for _, ans := range mb.Answers {
+			b.Answers = append(b.Answers, ans.Text)
+		}
+		return b
 	}
 
 	for _, ans := range mb.Answers {
@@ -35,3 +93,41 @@ func NewBuffs(mbs []model.Buff) []Buff {
 	}
 	return b
 }
+
+// BuffPatch is the request body used to partially update a Buff
+// Only the fields that are set in the request are changed
+type BuffPatch struct {
+	Kind             *string   `json:"buff_kind,omitempty" yaml:"buff_kind,omitempty"`
+	Question         *string   `json:"question_text,omitempty" yaml:"question_text,omitempty"`
+	CorrectAnswer    *string   `json:"correct_answer,omitempty" yaml:"correct_answer,omitempty"`
+	IncorrectAnswers *[]string `json:"incorrect_answer,omitempty" yaml:"incorrect_answer,omitempty"`
+	Answers          *[]string `json:"answers,omitempty" yaml:"answers,omitempty"`
+	Schedule         *Schedule `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+	Tags             *[]string `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+// Apply returns a copy of the given Buff with the fields set on the patch replaced
+func (p BuffPatch) Apply(b Buff) Buff {
+	if p.Kind != nil {
+		b.Kind = *p.Kind
+	}
+	if p.Question != nil {
+		b.Question = *p.Question
+	}
+	if p.CorrectAnswer != nil {
+		b.CorrectAnswer = *p.CorrectAnswer
+	}
+	if p.IncorrectAnswers != nil {
+		b.IncorrectAnswers = *p.IncorrectAnswers
+	}
+	if p.Answers != nil {
+		b.Answers = *p.Answers
+	}
+	if p.Schedule != nil {
+		b.Schedule = p.Schedule
+	}
+	if p.Tags != nil {
+		b.Tags = *p.Tags
+	}
+	return b
+}
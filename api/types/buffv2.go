@@ -0,0 +1,150 @@
+package types
+
+import (
+	"sort"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+)
+
+// BuffV2 is the shape of a buff in the v2 API
+//
+// Unlike Buff, every answer is kept along with its ID and whether it is correct,
+// so a buff with any number of correct answers can be represented.
+// It is used both for the authoring and the viewer routes, the latter leaving out
+// which answers are correct until the viewer has responded, as ViewerBuff does.
+// The answers of a buff that is not judged, such as a poll or an unresolved prediction, are never marked.
+type BuffV2 struct {
+	UUID            string           `json:"buff_id" yaml:"buff_id"`
+	VideoStreamUUID string           `json:"stream_id" yaml:"stream_id"`
+	Kind            string           `json:"buff_kind,omitempty" yaml:"buff_kind,omitempty"`
+	Question        string           `json:"question_text" yaml:"question_text"`
+	Answers         []AnswerV2       `json:"answers" yaml:"answers"`
+	Schedule        *Schedule        `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+	Tags            []string         `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Resolution      *ResolutionState `json:"resolution,omitempty" yaml:"resolution,omitempty"`
+	UserAnswerUUID  string           `json:"user_answer_id,omitempty" yaml:"user_answer_id,omitempty"`
+	Links           *BuffLinks       `json:"_links,omitempty" yaml:"_links,omitempty"`
+}
+
+// AnswerV2 is a single answer of a BuffV2
+//
+// In a request the answer_id is optional, an answer without one is a new answer.
+// A missing correct is read as false.
+type AnswerV2 struct {
+	UUID    string `json:"answer_id,omitempty" yaml:"answer_id,omitempty"`
+	Text    string `json:"text" yaml:"text"`
+	Correct *bool  `json:"correct,omitempty" yaml:"correct,omitempty"`
+}
+
+// Link is a reference to a related resource
+type Link struct {
+	Href string `json:"href" yaml:"href"`
+}
+
+// BuffLinks are the resources related to a BuffV2
+type BuffLinks struct {
+	Self        Link `json:"self" yaml:"self"`
+	Stream      Link `json:"stream" yaml:"stream"`
+	StreamBuffs Link `json:"stream_buffs" yaml:"stream_buffs"`
+	Results     Link `json:"results" yaml:"results"`
+}
+
+// NewBuffV2 converts the buff into the authoring shape, keeping the order of the answers
+func NewBuffV2(mb model.Buff, links *BuffLinks) BuffV2 {
+	b := BuffV2{
+		UUID:            mb.ID.String(),
+		VideoStreamUUID: mb.Stream.String(),
+		Kind:            string(mb.Kind),
+		Question:        mb.Question,
+		Answers:         make([]AnswerV2, 0, len(mb.Answers)),
+		Schedule:        NewSchedule(mb.Schedule),
+		Tags:            mb.Tags,
+		Resolution:      NewResolutionState(mb),
+		Links:           links,
+	}
+
+	for _, ans := range mb.Answers {
+		a := AnswerV2{UUID: ans.ID.String(), Text: ans.Text}
+		if mb.Judged() {
+			correct := ans.Correct
+			a.Correct = &correct
+		}
+		b.Answers = append(b.Answers, a)
+	}
+	return b
+}
+
+// NewViewerBuffV2 converts the buff into the viewer shape
+//
+// The answers are listed in the order of their IDs, and are only marked as
+// correct or not once the viewer has responded to the buff, or it has closed.
+func NewViewerBuffV2(mb model.Buff, v Viewing, links *BuffLinks) BuffV2 {
+	b := NewBuffV2(mb, links)
+
+	if resp := v.response(mb); resp != nil {
+		b.UserAnswerUUID = resp.Answer.String()
+	}
+	if !v.reveals(mb) {
+		for i := range b.Answers {
+			b.Answers[i].Correct = nil
+		}
+	}
+
+	sort.Slice(b.Answers, func(i, j int) bool {
+		return b.Answers[i].UUID < b.Answers[j].UUID
+	})
+	return b
+}
+
+// BuffV2Page is a page of v2 buffs returned by cursor pagination
+// NextCursor is empty on the last page
+type BuffV2Page struct {
+	Buffs      []BuffV2 `json:"buffs" yaml:"buffs"`
+	NextCursor string   `json:"next_cursor,omitempty" yaml:"next_cursor,omitempty"`
+}
+
+// NewBuffV2Page builds a page from the buffs the store returned,
+// in the same way as NewBuffPage, converting each of them with conv
+func NewBuffV2Page(mbs []model.Buff, count int, conv func(model.Buff) BuffV2) BuffV2Page {
+	var page BuffV2Page
+	if len(mbs) > count {
+		mbs = mbs[:count]
+		page.NextCursor = mbs[count-1].Cursor().String()
+	}
+
+	page.Buffs = make([]BuffV2, 0, len(mbs))
+	for _, mb := range mbs {
+		page.Buffs = append(page.Buffs, conv(mb))
+	}
+	return page
+}
+
+// BuffV2Patch is the request body used to partially update a BuffV2
+// Only the fields that are set in the request are changed, the answers and tags are replaced as a whole
+type BuffV2Patch struct {
+	Kind     *string     `json:"buff_kind,omitempty" yaml:"buff_kind,omitempty"`
+	Question *string     `json:"question_text,omitempty" yaml:"question_text,omitempty"`
+	Answers  *[]AnswerV2 `json:"answers,omitempty" yaml:"answers,omitempty"`
+	Schedule *Schedule   `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+	Tags     *[]string   `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+// Apply returns a copy of the given BuffV2 with the fields set on the patch replaced
+func (p BuffV2Patch) Apply(b BuffV2) BuffV2 {
+	if p.Kind != nil {
+		b.Kind = *p.Kind
+	}
+	if p.Question != nil {
+		b.Question = *p.Question
+	}
+	if p.Answers != nil {
+		b.Answers = *p.Answers
+	}
+	if p.Schedule != nil {
+		b.Schedule = p.Schedule
+	}
+	if p.Tags != nil {
+		b.Tags = *p.Tags
+	}
+	return b
+}
@@ -0,0 +1,44 @@
+package types
+
+import (
+	"time"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+)
+
+// Leaderboard is the top scores of a video stream, best first
+type Leaderboard struct {
+	VideoStreamUUID string  `json:"video_stream_id" yaml:"video_stream_id"`
+	Scores          []Score `json:"scores" yaml:"scores"`
+}
+
+// Score is the standing of a user on the leaderboard of a video stream
+// Users on the same points are ranked by who reached them first, at scored_at
+type Score struct {
+	Rank           int       `json:"rank" yaml:"rank"`
+	UserID         string    `json:"user_id" yaml:"user_id"`
+	Points         int       `json:"points" yaml:"points"`
+	CorrectAnswers int       `json:"correct_answers" yaml:"correct_answers"`
+	Responses      int       `json:"responses" yaml:"responses"`
+	ScoredAt       time.Time `json:"scored_at" yaml:"scored_at"`
+}
+
+func NewLeaderboard(stream model.VideoStreamID, mss []model.Score) Leaderboard {
+	l := Leaderboard{VideoStreamUUID: stream.String(), Scores: make([]Score, 0, len(mss))}
+
+	for _, ms := range mss {
+		l.Scores = append(l.Scores, NewScore(ms))
+	}
+	return l
+}
+
+func NewScore(ms model.Score) Score {
+	return Score{
+		Rank:           ms.Rank,
+		UserID:         ms.User.String(),
+		Points:         ms.Points,
+		CorrectAnswers: ms.Correct,
+		Responses:      ms.Responses,
+		ScoredAt:       ms.ScoredAt,
+	}
+}
@@ -0,0 +1,49 @@
+package types
+
+import "github.com/JoeReid/buffassignment/internal/model"
+
+// VideoStreamPage is a page of video streams returned by cursor pagination
+// NextCursor is empty on the last page
+type VideoStreamPage struct {
+	VideoStreams []VideoStream `json:"video_streams" yaml:"video_streams"`
+	NextCursor   string        `json:"next_cursor,omitempty" yaml:"next_cursor,omitempty"`
+}
+
+// NewVideoStreamPage builds a page from the streams the store returned
+//
+// The store should be asked for one more stream than the page holds, the
+// extra stream is only used to tell if there is a next page.
+func NewVideoStreamPage(mvs []model.VideoStream, count int) VideoStreamPage {
+	if len(mvs) <= count {
+		return VideoStreamPage{VideoStreams: NewVideoStreams(mvs)}
+	}
+
+	mvs = mvs[:count]
+	return VideoStreamPage{
+		VideoStreams: NewVideoStreams(mvs),
+		NextCursor:   mvs[count-1].Cursor().String(),
+	}
+}
+
+// BuffPage is a page of buffs returned by cursor pagination
+// NextCursor is empty on the last page
+type BuffPage struct {
+	Buffs      []Buff `json:"buffs" yaml:"buffs"`
+	NextCursor string `json:"next_cursor,omitempty" yaml:"next_cursor,omitempty"`
+}
+
+// NewBuffPage builds a page from the buffs the store returned
+//
+// The store should be asked for one more buff than the page holds, the
+// extra buff is only used to tell if there is a next page.
+func NewBuffPage(mbs []model.Buff, count int) BuffPage {
+	if len(mbs) <= count {
+		return BuffPage{Buffs: NewBuffs(mbs)}
+	}
+
+	mbs = mbs[:count]
+	return BuffPage{
+		Buffs:      NewBuffs(mbs),
+		NextCursor: mbs[count-1].Cursor().String(),
+	}
+}
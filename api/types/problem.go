@@ -0,0 +1,19 @@
+package types
+
+// Problem is the response body used for every error, following RFC 7807 (problem details)
+//
+// Type is a URI identifying the kind of problem, "about:blank" when the status
+// code says all there is to say. Title is a short summary of that kind of problem,
+// and Detail explains this occurrence of it.
+type Problem struct {
+	Type      string `json:"type" yaml:"type"`
+	Title     string `json:"title" yaml:"title"`
+	Status    int    `json:"status" yaml:"status"`
+	Detail    string `json:"detail,omitempty" yaml:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty" yaml:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty" yaml:"request_id,omitempty"`
+
+	// Errors lists every validation rule broken by the request
+	// Only set when the request was rejected by the validation rules
+	Errors []ValidationError `json:"errors,omitempty" yaml:"errors,omitempty"`
+}
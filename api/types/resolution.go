@@ -0,0 +1,63 @@
+package types
+
+import (
+	"time"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+)
+
+// The states of the ResolutionState of a prediction
+const (
+	Unresolved = "unresolved"
+	Resolved   = "resolved"
+)
+
+// ResolutionState is whether a prediction has been resolved, and to which answer
+// It is only set on the buffs that are predictions
+type ResolutionState struct {
+	State      string     `json:"state" yaml:"state"`
+	AnswerUUID string     `json:"answer_id,omitempty" yaml:"answer_id,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty" yaml:"resolved_at,omitempty"`
+}
+
+// NewResolutionState returns the state of the buff if it is a prediction, or nil if it isn't
+func NewResolutionState(mb model.Buff) *ResolutionState {
+	if mb.Kind != model.BuffPrediction {
+		return nil
+	}
+	if mb.Resolution == nil {
+		return &ResolutionState{State: Unresolved}
+	}
+
+	at := mb.Resolution.ResolvedAt
+	return &ResolutionState{
+		State:      Resolved,
+		AnswerUUID: mb.Resolution.Answer.String(),
+		ResolvedAt: &at,
+	}
+}
+
+// Resolution is the record of how a prediction was resolved
+//
+// Only the answer_id and resolved_by are read from a request, the rest is filled in
+// as the buff is resolved. The counts are of the responses scored by the resolution.
+type Resolution struct {
+	BuffUUID         string    `json:"buff_id" yaml:"buff_id"`
+	AnswerUUID       string    `json:"answer_id" yaml:"answer_id"`
+	ResolvedBy       string    `json:"resolved_by" yaml:"resolved_by"`
+	ResolvedAt       time.Time `json:"resolved_at" yaml:"resolved_at"`
+	Responses        int       `json:"total_responses" yaml:"total_responses"`
+	CorrectResponses int       `json:"correct_responses" yaml:"correct_responses"`
+}
+
+// NewResolution converts the resolution of a resolved buff
+func NewResolution(id model.BuffID, mr model.Resolution) Resolution {
+	return Resolution{
+		BuffUUID:         id.String(),
+		AnswerUUID:       mr.Answer.String(),
+		ResolvedBy:       mr.ResolvedBy,
+		ResolvedAt:       mr.ResolvedAt,
+		Responses:        mr.Responses,
+		CorrectResponses: mr.Correct,
+	}
+}
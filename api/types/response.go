@@ -0,0 +1,67 @@
+package types
+
+import (
+	"math"
+	"time"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+)
+
+// Response is a user's answer to a buff
+// The buff is taken from the URL, so it is ignored in the request body
+type Response struct {
+	BuffUUID   string    `json:"buff_id" yaml:"buff_id"`
+	UserID     string    `json:"user_id" yaml:"user_id"`
+	AnswerUUID string    `json:"answer_id" yaml:"answer_id"`
+	CreatedAt  time.Time `json:"response_created_at" yaml:"response_created_at"`
+}
+
+func NewResponse(mr model.Response) Response {
+	return Response{
+		BuffUUID:   mr.Buff.String(),
+		UserID:     mr.User.String(),
+		AnswerUUID: mr.Answer.String(),
+		CreatedAt:  mr.CreatedAt,
+	}
+}
+
+// Results is the tally of all the responses to a buff
+type Results struct {
+	BuffUUID string         `json:"buff_id" yaml:"buff_id"`
+	Total    int            `json:"total_responses" yaml:"total_responses"`
+	Answers  []AnswerResult `json:"answers" yaml:"answers"`
+}
+
+// AnswerResult is the number of responses given for a single answer,
+// and the percentage of all the responses to the buff that makes up
+type AnswerResult struct {
+	AnswerUUID string  `json:"answer_id" yaml:"answer_id"`
+	Text       string  `json:"answer_text" yaml:"answer_text"`
+	Responses  int     `json:"responses" yaml:"responses"`
+	Percentage float64 `json:"percentage" yaml:"percentage"`
+}
+
+// NewResults converts the results, working out the percentages to one decimal place
+// Every percentage is 0 when nobody has responded yet
+func NewResults(mr model.Results) Results {
+	r := Results{
+		BuffUUID: mr.Buff.String(),
+		Total:    mr.Total,
+		Answers:  make([]AnswerResult, 0, len(mr.Answers)),
+	}
+
+	for _, ans := range mr.Answers {
+		var pct float64
+		if mr.Total != 0 {
+			pct = math.Round(float64(ans.Responses)*1000/float64(mr.Total)) / 10
+		}
+
+		r.Answers = append(r.Answers, AnswerResult{
+			AnswerUUID: ans.Answer.String(),
+			Text:       ans.Text,
+			Responses:  ans.Responses,
+			Percentage: pct,
+		})
+	}
+	return r
+}
@@ -0,0 +1,78 @@
+package types
+
+import (
+	"sort"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+)
+
+// BuffMatch is a buff found by a search, in the shape it is written in
+//
+// Rank is how well the buff matched, and is only comparable with the other
+// matches of the same search.
+type BuffMatch struct {
+	Buff      Buff          `json:"buff" yaml:"buff"`
+	Rank      float64       `json:"rank" yaml:"rank"`
+	Highlight BuffHighlight `json:"highlight" yaml:"highlight"`
+}
+
+// BuffHighlight holds the text of a matched buff, with the matched terms wrapped in <mark> tags
+// Only the answers that matched are listed, in the order of their IDs.
+type BuffHighlight struct {
+	Question string            `json:"question_text" yaml:"question_text"`
+	Answers  []AnswerHighlight `json:"answers,omitempty" yaml:"answers,omitempty"`
+}
+
+// AnswerHighlight is the highlighted text of a single answer of a BuffHighlight
+type AnswerHighlight struct {
+	UUID string `json:"answer_id" yaml:"answer_id"`
+	Text string `json:"answer_text" yaml:"answer_text"`
+}
+
+// BuffMatchV2 is a buff found by a search, in the v2 authoring shape
+// It is otherwise the same as a BuffMatch.
+type BuffMatchV2 struct {
+	Buff      BuffV2        `json:"buff" yaml:"buff"`
+	Rank      float64       `json:"rank" yaml:"rank"`
+	Highlight BuffHighlight `json:"highlight" yaml:"highlight"`
+}
+
+// NewBuffMatches converts the matches, with the buffs in the shape they are written in
+func NewBuffMatches(mms []model.BuffMatch) []BuffMatch {
+	m := make([]BuffMatch, 0, len(mms))
+
+	for _, mm := range mms {
+		m = append(m, BuffMatch{
+			Buff:      NewBuff(mm.Buff),
+			Rank:      mm.Rank,
+			Highlight: newBuffHighlight(mm),
+		})
+	}
+	return m
+}
+
+// NewBuffMatchesV2 converts the matches, converting each of their buffs with conv
+func NewBuffMatchesV2(mms []model.BuffMatch, conv func(model.Buff) BuffV2) []BuffMatchV2 {
+	m := make([]BuffMatchV2, 0, len(mms))
+
+	for _, mm := range mms {
+		m = append(m, BuffMatchV2{
+			Buff:      conv(mm.Buff),
+			Rank:      mm.Rank,
+			Highlight: newBuffHighlight(mm),
+		})
+	}
+	return m
+}
+
+// newBuffHighlight returns the highlights of the match, with the answers in the order of their IDs
+func newBuffHighlight(mm model.BuffMatch) BuffHighlight {
+	h := BuffHighlight{Question: mm.QuestionHighlight}
+	for id, text := range mm.AnswerHighlights {
+		h.Answers = append(h.Answers, AnswerHighlight{UUID: id.String(), Text: text})
+	}
+	sort.Slice(h.Answers, func(i, j int) bool {
+		return h.Answers[i].UUID < h.Answers[j].UUID
+	})
+	return h
+}
@@ -0,0 +1,59 @@
+package types
+
+import "encoding/json"
+
+// SocketVersion is the version of the WebSocket protocol spoken by the server
+// A client asks for it when it subscribes, so the protocol can change without breaking it.
+const SocketVersion = 1
+
+// The types of the messages of the WebSocket protocol
+const (
+	// SocketSubscribe is sent by the client to start receiving the buffs of the stream
+	SocketSubscribe = "subscribe"
+	// SocketAnswer is sent by the client to respond to a buff
+	SocketAnswer = "answer"
+
+	// SocketBuff is sent by the server with a buff of the stream
+	SocketBuff = "buff"
+	// SocketAck is sent by the server once a message from the client has been handled
+	SocketAck = "ack"
+	// SocketResults is sent by the server with the latest results of a buff of the stream
+	SocketResults = "results"
+	// SocketError is sent by the server when a message from the client can't be handled
+	SocketError = "error"
+)
+
+// SocketMessage is a single message of the WebSocket protocol, in either direction
+//
+// The client may give its messages an ID, which is sent back as the Ref of the
+// ack or error replying to it. Buffs are sent with their cursor, which the client
+// can subscribe after when it reconnects.
+type SocketMessage struct {
+	Type   string          `json:"type"`
+	ID     string          `json:"id,omitempty"`
+	Ref    string          `json:"ref,omitempty"`
+	Cursor string          `json:"cursor,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// NewSocketMessage builds a message of the given type, holding data encoded as JSON
+func NewSocketMessage(typ string, data interface{}) (SocketMessage, error) {
+	m := SocketMessage{Type: typ}
+	if data == nil {
+		return m, nil
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return SocketMessage{}, err
+	}
+	m.Data = raw
+	return m, nil
+}
+
+// SocketSubscription is the data of a subscribe message
+// After is the cursor of the last buff the client was sent, if it is reconnecting
+type SocketSubscription struct {
+	Version int    `json:"version"`
+	After   string `json:"after,omitempty"`
+}
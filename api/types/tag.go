@@ -0,0 +1,19 @@
+package types
+
+import "github.com/JoeReid/buffassignment/internal/model"
+
+// Tag is a tag in use, along with the number of buffs and video streams that have it
+type Tag struct {
+	Tag          string `json:"tag" yaml:"tag"`
+	Buffs        int    `json:"buff_count" yaml:"buff_count"`
+	VideoStreams int    `json:"stream_count" yaml:"stream_count"`
+}
+
+func NewTags(mtcs []model.TagCount) []Tag {
+	t := make([]Tag, 0, len(mtcs))
+
+	for _, mtc := range mtcs {
+		t = append(t, Tag{Tag: mtc.Tag, Buffs: mtc.Buffs, VideoStreams: mtc.VideoStreams})
+	}
+	return t
+}
@@ -0,0 +1,25 @@
+package types
+
+import "github.com/JoeReid/buffassignment/internal/validation"
+
+// ValidationError is a single rule broken by a single field of the request
+type ValidationError struct {
+	Field   string `json:"field" yaml:"field"`
+	Rule    string `json:"rule" yaml:"rule"`
+	Message string `json:"message" yaml:"message"`
+}
+
+// NewValidationErrors lists every broken rule, so clients can fix them all at once
+// They are returned in the Errors of a Problem
+func NewValidationErrors(errs validation.Errors) []ValidationError {
+	v := make([]ValidationError, 0, len(errs))
+
+	for _, fe := range errs {
+		v = append(v, ValidationError{
+			Field:   fe.Field,
+			Rule:    fe.Rule,
+			Message: fe.Message,
+		})
+	}
+	return v
+}
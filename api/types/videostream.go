@@ -7,18 +7,28 @@ import (
 )
 
 type VideoStream struct {
-	UUID      string    `json:"stream_id" yaml:"stream_id"`
-	Title     string    `json:"stream_title" yaml:"stream_title"`
-	CreatedAt time.Time `json:"stream_created_at" yaml:"stream_created_at"`
-	UpdatedAt time.Time `json:"stream_updated_at" yaml:"stream_updated_at"`
+	UUID           string     `json:"stream_id" yaml:"stream_id"`
+	Title          string     `json:"stream_title" yaml:"stream_title"`
+	Tags           []string   `json:"tags,omitempty" yaml:"tags,omitempty"`
+	State          string     `json:"stream_state" yaml:"stream_state"`
+	ScheduledStart *time.Time `json:"stream_scheduled_start,omitempty" yaml:"stream_scheduled_start,omitempty"`
+	StartedAt      *time.Time `json:"stream_started_at,omitempty" yaml:"stream_started_at,omitempty"`
+	EndedAt        *time.Time `json:"stream_ended_at,omitempty" yaml:"stream_ended_at,omitempty"`
+	CreatedAt      time.Time  `json:"stream_created_at" yaml:"stream_created_at"`
+	UpdatedAt      time.Time  `json:"stream_updated_at" yaml:"stream_updated_at"`
 }
 
 func NewVideoStream(mvs model.VideoStream) VideoStream {
 	return VideoStream{
-		UUID:      mvs.ID.String(),
-		Title:     mvs.Title,
-		CreatedAt: mvs.CreatedAt,
-		UpdatedAt: mvs.UpdatedAt,
+		UUID:           mvs.ID.String(),
+		Title:          mvs.Title,
+		Tags:           mvs.Tags,
+		State:          string(mvs.State),
+		ScheduledStart: mvs.ScheduledStart,
+		StartedAt:      mvs.StartedAt,
+		EndedAt:        mvs.EndedAt,
+		CreatedAt:      mvs.CreatedAt,
+		UpdatedAt:      mvs.UpdatedAt,
 	}
 }
 
@@ -30,3 +40,25 @@ func NewVideoStreams(mvs []model.VideoStream) []VideoStream {
 	}
 	return vs
 }
+
+// VideoStreamPatch is the request body used to partially update a VideoStream
+// Only the fields that are set in the request are changed
+type VideoStreamPatch struct {
+	Title          *string    `json:"stream_title,omitempty" yaml:"stream_title,omitempty"`
+	ScheduledStart *time.Time `json:"stream_scheduled_start,omitempty" yaml:"stream_scheduled_start,omitempty"`
+	Tags           *[]string  `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+// Apply returns a copy of the given VideoStream with the fields set on the patch replaced
+func (p VideoStreamPatch) Apply(v VideoStream) VideoStream {
+	if p.Title != nil {
+		v.Title = *p.Title
+	}
+	if p.ScheduledStart != nil {
+		v.ScheduledStart = p.ScheduledStart
+	}
+	if p.Tags != nil {
+		v.Tags = *p.Tags
+	}
+	return v
+}
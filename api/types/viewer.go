@@ -0,0 +1,124 @@
+package types
+
+import (
+	"sort"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+)
+
+// ViewerBuff is the shape of a buff served to the viewers of a stream
+//
+// The answers are listed in the order of their IDs, so that their order says
+// nothing about which of them is correct. The answer the viewer chose is only set
+// once they have responded, and which answers are correct once they have responded
+// or the buff has closed.
+// Only a quiz, or a prediction once it is resolved, reveals its correct answers.
+type ViewerBuff struct {
+	UUID            string           `json:"buff_id" yaml:"buff_id"`
+	VideoStreamUUID string           `json:"stream_id" yaml:"stream_id"`
+	Kind            string           `json:"buff_kind,omitempty" yaml:"buff_kind,omitempty"`
+	Question        string           `json:"question_text" yaml:"question_text"`
+	Answers         []ViewerAnswer   `json:"answers" yaml:"answers"`
+	Schedule        *Schedule        `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+	Tags            []string         `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Resolution      *ResolutionState `json:"resolution,omitempty" yaml:"resolution,omitempty"`
+	UserAnswerUUID  string           `json:"user_answer_id,omitempty" yaml:"user_answer_id,omitempty"`
+}
+
+// ViewerAnswer is a single answer of a ViewerBuff
+// Correct is nil until the answer is revealed
+type ViewerAnswer struct {
+	UUID    string `json:"answer_id" yaml:"answer_id"`
+	Text    string `json:"answer_text" yaml:"answer_text"`
+	Correct *bool  `json:"correct,omitempty" yaml:"correct,omitempty"`
+}
+
+// Viewing is what decides which answers of the buffs are revealed to a viewer
+//
+// Responses holds the viewer's responses, keyed by the buff they were given to, and Closed
+// the buffs that have closed on the timeline of their stream, see model.Buff.ClosedBy.
+// The zero value reveals nothing.
+type Viewing struct {
+	Responses map[model.BuffID]model.Response
+	Closed    map[model.BuffID]bool
+}
+
+// response returns the viewer's response to the buff, or nil if they haven't responded
+func (v Viewing) response(mb model.Buff) *model.Response {
+	resp, ok := v.Responses[mb.ID]
+	if !ok {
+		return nil
+	}
+	return &resp
+}
+
+// reveals reports whether the correct answers of the buff are shown to the viewer
+func (v Viewing) reveals(mb model.Buff) bool {
+	return mb.Judged() && (v.response(mb) != nil || v.Closed[mb.ID])
+}
+
+// NewViewerBuff converts the buff, revealing the answers if the viewer has responded to it or it has closed
+func NewViewerBuff(mb model.Buff, v Viewing) ViewerBuff {
+	resp := v.response(mb)
+	reveal := v.reveals(mb)
+
+	b := ViewerBuff{
+		UUID:            mb.ID.String(),
+		VideoStreamUUID: mb.Stream.String(),
+		Kind:            string(mb.Kind),
+		Question:        mb.Question,
+		Answers:         make([]ViewerAnswer, 0, len(mb.Answers)),
+		Schedule:        NewSchedule(mb.Schedule),
+		Tags:            mb.Tags,
+		Resolution:      NewResolutionState(mb),
+	}
+	if resp != nil {
+		b.UserAnswerUUID = resp.Answer.String()
+	}
+
+	for _, ans := range mb.Answers {
+		va := ViewerAnswer{UUID: ans.ID.String(), Text: ans.Text}
+		if reveal {
+			correct := ans.Correct
+			va.Correct = &correct
+		}
+		b.Answers = append(b.Answers, va)
+	}
+
+	sort.Slice(b.Answers, func(i, j int) bool {
+		return b.Answers[i].UUID < b.Answers[j].UUID
+	})
+	return b
+}
+
+// NewViewerBuffs converts the buffs, revealing the answers of those the viewer has responded to
+// and those that have closed
+func NewViewerBuffs(mbs []model.Buff, v Viewing) []ViewerBuff {
+	b := make([]ViewerBuff, 0, len(mbs))
+
+	for _, mb := range mbs {
+		b = append(b, NewViewerBuff(mb, v))
+	}
+	return b
+}
+
+// ViewerBuffPage is a page of viewer buffs returned by cursor pagination
+// NextCursor is empty on the last page
+type ViewerBuffPage struct {
+	Buffs      []ViewerBuff `json:"buffs" yaml:"buffs"`
+	NextCursor string       `json:"next_cursor,omitempty" yaml:"next_cursor,omitempty"`
+}
+
+// NewViewerBuffPage builds a page from the buffs the store returned,
+// in the same way as NewBuffPage
+func NewViewerBuffPage(mbs []model.Buff, count int, v Viewing) ViewerBuffPage {
+	if len(mbs) <= count {
+		return ViewerBuffPage{Buffs: NewViewerBuffs(mbs, v)}
+	}
+
+	mbs = mbs[:count]
+	return ViewerBuffPage{
+		Buffs:      NewViewerBuffs(mbs, v),
+		NextCursor: mbs[count-1].Cursor().String(),
+	}
+}
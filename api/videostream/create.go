@@ -0,0 +1,81 @@
+package videostream
+
+import (
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/buffassignment/api/apierror"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/validation"
+	"github.com/google/uuid"
+)
+
+// NewCreateHandler returns a new instance of the create action of
+// the videostream API using the given store instance.
+//
+// The ID, state and timestamps of the new stream are set by the server,
+// so only the title and scheduled start are read from the request body.
+// Every new stream is scheduled, and is started with the transition handlers.
+//
+// The new stream is checked against the rules of the given validator before it is stored.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewCreateHandler(store model.VideoStreamStore, validator *validation.Validator) apiutils.Handler {
+	return &streamCreate{store, validator}
+}
+
+// streamCreate implements the apiutils.Handler interface to provide the
+// create portion of the videostream API
+type streamCreate struct {
+	store     model.VideoStreamStore
+	validator *validation.Validator
+}
+
+// ServeCodec serves the API using the apiutils.Handler pattern
+// This allows the business logic to live here, and the encoding to live separate from it
+// This also makes testing easier, as there is a test codec that allows us to peek at the output
+// in a testing context.
+func (s *streamCreate) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
+	var req types.VideoStream
+	if err := c.Read(r.Context(), r, &req); err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	stream := model.VideoStream{
+		ID:             model.VideoStreamID(uuid.New()),
+		Title:          req.Title,
+		Tags:           req.Tags,
+		State:          model.StreamScheduled,
+		ScheduledStart: utcTime(req.ScheduledStart),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	if err := s.validator.VideoStream(stream); err != nil {
+		apierror.RespondInvalid(c, w, r, err)
+		return
+	}
+
+	if err := s.store.CreateVideoStream(r.Context(), stream); err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+
+	w.Header().Set("Location", path.Join(r.URL.Path, stream.ID.String()))
+	c.Respond(r.Context(), w, http.StatusCreated, types.NewVideoStream(stream))
+}
+
+// utcTime returns the time in UTC, or nil if t is nil
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
@@ -0,0 +1,167 @@
+package videostream_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/JoeReid/apiutils/testingcodec"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/api/videostream"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/model/testmodel"
+	"github.com/JoeReid/buffassignment/internal/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateVideoStream(t *testing.T) {
+	scheduled := time.Date(2020, 6, 1, 12, 30, 0, 0, time.FixedZone("BST", 60*60))
+	started := time.Unix(0, 0)
+
+	var tests = []struct {
+		name                 string
+		requestBody          types.VideoStream
+		readError            error
+		storeError           error
+		expectResponseCode   int
+		expectResponseData   interface{}
+		expectStoreNotCalled bool
+	}{
+		{
+			name: "returns created on happy path, ignoring client ids and timestamps",
+			requestBody: types.VideoStream{
+				UUID:      "a client chosen id",
+				Title:     "a sepcial testing stream",
+				CreatedAt: time.Unix(0, 0),
+				UpdatedAt: time.Unix(0, 0),
+			},
+			expectResponseCode: http.StatusCreated,
+		},
+		{
+			name: "returns created with a scheduled start, ignoring the client's state",
+			requestBody: types.VideoStream{
+				Title:          "a sepcial testing stream",
+				State:          "live",
+				ScheduledStart: &scheduled,
+				StartedAt:      &started,
+			},
+			expectResponseCode: http.StatusCreated,
+		},
+		{
+			name:               "returns unprocessable entity on empty title",
+			requestBody:        types.VideoStream{Title: "  "},
+			expectResponseCode: http.StatusUnprocessableEntity,
+			expectResponseData: newInvalidProblem(
+				types.ValidationError{Field: "title", Rule: "required", Message: "must not be empty"},
+			),
+			expectStoreNotCalled: true,
+		},
+		{
+			name:                 "returns bad request on undecodable body",
+			readError:            errors.New("bad body"),
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, "bad body"),
+			expectStoreNotCalled: true,
+		},
+		{
+			name:               "returns internal error on unexpected store error",
+			requestBody:        types.VideoStream{Title: "a sepcial testing stream"},
+			storeError:         errors.New("the world exploded"),
+			expectResponseCode: http.StatusInternalServerError,
+			expectResponseData: newProblem(http.StatusInternalServerError, ""),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return the data configured in the test fixture
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("CreateVideoStream", mock.Anything, mock.Anything).Return(tt.storeError)
+
+			req, err := http.NewRequest("POST", "/v1/video_streams", nil)
+			require.NoError(t, err, "failed to build request for test")
+			w := httptest.NewRecorder()
+
+			// Problems point at the path of the request that caused them
+			if p, ok := tt.expectResponseData.(types.Problem); ok {
+				p.Instance = "/v1/video_streams"
+				tt.expectResponseData = p
+			}
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, w, mock.Anything, mock.Anything).Return()
+			codec.On("Read", mock.Anything, mock.Anything, mock.Anything).Return(tt.readError).Run(func(args mock.Arguments) {
+				*args.Get(2).(*types.VideoStream) = tt.requestBody
+			})
+
+			// Create the handler under test, and execute it
+			before := time.Now()
+			handler := videostream.NewCreateHandler(testingStore, newValidator(t))
+			handler.ServeCodec(codec, w, req)
+
+			// assert that the handler responded only once
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			if tt.expectStoreNotCalled {
+				// assert that no calls to the store were made
+				testingStore.AssertNotCalled(t, "CreateVideoStream", mock.Anything, mock.Anything)
+				codec.AssertCalled(t, "Respond", mock.Anything, w, tt.expectResponseCode, tt.expectResponseData)
+				return
+			}
+
+			// The IDs and timestamps are generated by the handler, so pull them back out of the store call
+			testingStore.AssertNumberOfCalls(t, "CreateVideoStream", 1)
+			created := testingStore.Calls[0].Arguments.Get(1).(model.VideoStream)
+
+			assert.Equal(t, tt.requestBody.Title, created.Title)
+			assert.NotEqual(t, tt.requestBody.UUID, created.ID.String())
+			assert.False(t, created.CreatedAt.Before(before), "the creation time should be set by the server")
+			assert.Equal(t, created.CreatedAt, created.UpdatedAt)
+			assert.Equal(t, model.StreamScheduled, created.State, "every new stream should be scheduled")
+			assert.Nil(t, created.StartedAt, "the start time should be set by the server")
+			if tt.requestBody.ScheduledStart != nil {
+				require.NotNil(t, created.ScheduledStart)
+				assert.True(t, tt.requestBody.ScheduledStart.Equal(*created.ScheduledStart))
+				assert.Equal(t, time.UTC, created.ScheduledStart.Location())
+			}
+
+			if tt.expectResponseData == nil {
+				tt.expectResponseData = types.NewVideoStream(created)
+				assert.Equal(t, "/v1/video_streams/"+created.ID.String(), w.Header().Get("Location"))
+			}
+			codec.AssertCalled(t, "Respond", mock.Anything, w, tt.expectResponseCode, tt.expectResponseData)
+		})
+	}
+}
+
+// newValidator returns a validator using the default rules
+func newValidator(t *testing.T) *validation.Validator {
+	v, err := validation.New()
+	require.NoError(t, err, "failed to build validator")
+	return v
+}
+
+// newProblem returns the problem a handler is expected to respond with
+// The test requests have no path and no request id
+func newProblem(status int, detail string) types.Problem {
+	return types.Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// newInvalidProblem returns the problem a handler is expected to respond with
+// when the request breaks the given validation rules
+func newInvalidProblem(errs ...types.ValidationError) types.Problem {
+	p := newProblem(http.StatusUnprocessableEntity, fmt.Sprintf("the request breaks %d validation rules", len(errs)))
+	p.Errors = errs
+	return p
+}
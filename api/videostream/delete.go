@@ -0,0 +1,45 @@
+package videostream
+
+import (
+	"net/http"
+
+	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/buffassignment/api/apierror"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+// NewDeleteHandler returns a new instance of the delete action of
+// the videostream API using the given store instance.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewDeleteHandler(store model.VideoStreamStore) apiutils.Handler {
+	return &streamDelete{store}
+}
+
+// streamDelete implements the apiutils.Handler interface to provide the
+// delete portion of the videostream API
+type streamDelete struct {
+	store model.VideoStreamStore
+}
+
+// ServeCodec serves the API using the apiutils.Handler pattern
+// This allows the business logic to live here, and the encoding to live separate from it
+// This also makes testing easier, as there is a test codec that allows us to peek at the output
+// in a testing context.
+func (s *streamDelete) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
+	vID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	// A stream that still has buffs is refused with a conflict
+	if err := s.store.DeleteVideoStream(r.Context(), model.VideoStreamID(vID)); err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+	c.Respond(r.Context(), w, http.StatusNoContent, nil)
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/buffassignment/api/apierror"
 	"github.com/JoeReid/buffassignment/api/types"
 	"github.com/JoeReid/buffassignment/internal/model"
 	"github.com/go-chi/chi"
@@ -32,17 +33,13 @@ type streamGet struct {
 func (s *streamGet) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
 	vID, err := uuid.Parse(chi.URLParam(r, "uuid"))
 	if err != nil {
-		c.Respond(r.Context(), w, http.StatusBadRequest, err)
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	stream, err := s.store.GetVideoStream(model.VideoStreamID(vID))
+	stream, err := s.store.GetVideoStream(r.Context(), model.VideoStreamID(vID))
 	if err != nil {
-		if err == model.ErrNotFound {
-			c.Respond(r.Context(), w, http.StatusNotFound, err)
-			return
-		}
-		c.Respond(r.Context(), w, http.StatusInternalServerError, err)
+		apierror.Respond(c, w, r, apierror.Status(err), err)
 		return
 	}
 	c.Respond(r.Context(), w, http.StatusOK, types.NewVideoStream(*stream))
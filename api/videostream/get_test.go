@@ -58,7 +58,7 @@ func TestGetVideoStreams(t *testing.T) {
 			},
 			storeResponse:      nil,
 			storeError:         model.ErrNotFound,
-			expectResponseData: model.ErrNotFound,
+			expectResponseData: newProblem(http.StatusNotFound, model.ErrNotFound.Error()),
 			expectResponseCode: http.StatusNotFound,
 		},
 		{
@@ -68,7 +68,7 @@ func TestGetVideoStreams(t *testing.T) {
 			},
 			storeResponse:        nil,
 			storeError:           nil,
-			expectResponseData:   errors.New("invalid UUID length: 16"),
+			expectResponseData:   newProblem(http.StatusBadRequest, "invalid UUID length: 16"),
 			expectResponseCode:   http.StatusBadRequest,
 			expectStoreNotCalled: true,
 		},
@@ -79,7 +79,7 @@ func TestGetVideoStreams(t *testing.T) {
 			},
 			storeResponse:      nil,
 			storeError:         errors.New("the world exploded"),
-			expectResponseData: errors.New("the world exploded"),
+			expectResponseData: newProblem(http.StatusInternalServerError, ""),
 			expectResponseCode: http.StatusInternalServerError,
 		},
 	}
@@ -89,7 +89,7 @@ func TestGetVideoStreams(t *testing.T) {
 
 			// Setup the mock store object to return the data configured in the test fixture
 			testingStore := testmodel.NewModelMock()
-			testingStore.On("GetVideoStream", mock.Anything).Return(tt.storeResponse, tt.storeError)
+			testingStore.On("GetVideoStream", mock.Anything, mock.Anything).Return(tt.storeResponse, tt.storeError)
 
 			// Build the request to the spec of the test fixture
 			rctx := chi.NewRouteContext()
@@ -117,10 +117,10 @@ func TestGetVideoStreams(t *testing.T) {
 			// If the handler needs to use the store, assert it made the right call
 			if tt.expectStoreNotCalled {
 				// assert that no calls to the store were made
-				testingStore.AssertNotCalled(t, "GetVideoStream", mock.Anything)
+				testingStore.AssertNotCalled(t, "GetVideoStream", mock.Anything, mock.Anything)
 			} else {
 				// assert that the store was called with the correct uuid
-				testingStore.AssertCalled(t, "GetVideoStream", model.VideoStreamID(sentinelUUID))
+				testingStore.AssertCalled(t, "GetVideoStream", mock.Anything, model.VideoStreamID(sentinelUUID))
 			}
 		})
 	}
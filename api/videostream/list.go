@@ -1,16 +1,53 @@
 package videostream
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/buffassignment/api/apierror"
+	"github.com/JoeReid/buffassignment/api/paginate"
 	"github.com/JoeReid/buffassignment/api/types"
 	"github.com/JoeReid/buffassignment/internal/model"
 )
 
+// The URL params filtering and sorting the listed streams
+const (
+	// StateKey holds a state the listed streams can be in
+	// It can be given more than once, to list the streams in any of the states
+	StateKey = "state"
+	// TitleContainsKey holds text the titles of the listed streams contain, ignoring case
+	TitleContainsKey = "title_contains"
+	// CreatedAfterKey and CreatedBeforeKey hold RFC 3339 times the listed streams were created after or before
+	CreatedAfterKey  = "created_after"
+	CreatedBeforeKey = "created_before"
+	// UpdatedSinceKey holds an RFC 3339 time the listed streams were last updated at or after
+	UpdatedSinceKey = "updated_since"
+	// TagKey holds a tag the listed streams have
+	// It can be given more than once, to list the streams having every one of the tags
+	TagKey = "tag"
+	// SortKey holds a comma separated list of the fields to sort the streams by,
+	// each prefixed with a - to sort by it in descending order
+	SortKey = "sort"
+)
+
+var (
+	// ErrSortWithCursor is returned when a request sets sort along with a cursor
+	// The cursor only pages through the streams in the order they were created
+	ErrSortWithCursor = errors.New("video stream list error: sort cannot be used with a cursor")
+	// ErrInvalidCreatedRange is returned when created_after is not before created_before
+	ErrInvalidCreatedRange = errors.New("video stream list error: created_after must be before created_before")
+)
+
 // NewListHandler returns a new instance of the list action of
 // the videostream API using the given store instance.
 //
+// The streams can be filtered by their state, title, tags and creation and update
+// times, and sorted by the fields in model.VideoStreamSortFields.
+//
 // The store is provided as an argument for easy dependency injection in tests
 // I.E: using the testing mock store rather than a full DB for API testing
 func NewListHandler(store model.VideoStreamStore) apiutils.Handler {
@@ -28,20 +65,141 @@ type streamList struct {
 // This also makes testing easier, as there is a test codec that allows us to peek at the output
 // in a testing context.
 func (s *streamList) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
+	query, err := streamQuery(r)
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	after, count, ok, err := paginate.Cursor(r, apiutils.DefaultCount(10), apiutils.MaxCount(10))
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+	if ok {
+		if len(query.Sort) != 0 {
+			apierror.Respond(c, w, r, http.StatusBadRequest, ErrSortWithCursor)
+			return
+		}
+
+		// Ask for one extra stream to find out if there is a next page
+		streams, err := s.store.ListVideoStreamAfter(r.Context(), query, after, count+1)
+		if err != nil {
+			apierror.Respond(c, w, r, apierror.Status(err), err)
+			return
+		}
+		c.Respond(r.Context(), w, http.StatusOK, types.NewVideoStreamPage(streams, count))
+		return
+	}
+
 	count, skip, err := apiutils.Paginate(r, apiutils.DefaultCount(10), apiutils.MaxCount(10))
 	if err != nil {
-		c.Respond(r.Context(), w, http.StatusBadRequest, err)
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	streams, err := s.store.ListVideoStream(count*skip, count)
+	streams, err := s.store.ListVideoStream(r.Context(), query, count*skip, count)
 	if err != nil {
-		if err == model.ErrNotFound {
+		if errors.Is(err, model.ErrNotFound) {
 			c.Respond(r.Context(), w, http.StatusOK, []types.VideoStream{})
 			return
 		}
-		c.Respond(r.Context(), w, http.StatusInternalServerError, err)
+		apierror.Respond(c, w, r, apierror.Status(err), err)
 		return
 	}
 	c.Respond(r.Context(), w, http.StatusOK, types.NewVideoStreams(streams))
 }
+
+// streamQuery reads the filters and sort of the list from the request
+func streamQuery(r *http.Request) (model.VideoStreamQuery, error) {
+	values := r.URL.Query()
+	query := model.VideoStreamQuery{TitleContains: values.Get(TitleContainsKey)}
+
+	for _, value := range values[StateKey] {
+		state := model.StreamState(value)
+		if !state.Valid() {
+			return model.VideoStreamQuery{}, fmt.Errorf("unknown video stream state %q", value)
+		}
+		query.States = append(query.States, state)
+	}
+
+	for _, tag := range values[TagKey] {
+		if tag != "" {
+			query.Tags = append(query.Tags, tag)
+		}
+	}
+
+	var err error
+	if query.CreatedAfter, err = queryTime(values.Get(CreatedAfterKey), CreatedAfterKey); err != nil {
+		return model.VideoStreamQuery{}, err
+	}
+	if query.CreatedBefore, err = queryTime(values.Get(CreatedBeforeKey), CreatedBeforeKey); err != nil {
+		return model.VideoStreamQuery{}, err
+	}
+	if query.UpdatedSince, err = queryTime(values.Get(UpdatedSinceKey), UpdatedSinceKey); err != nil {
+		return model.VideoStreamQuery{}, err
+	}
+	if query.CreatedAfter != nil && query.CreatedBefore != nil && !query.CreatedAfter.Before(*query.CreatedBefore) {
+		return model.VideoStreamQuery{}, ErrInvalidCreatedRange
+	}
+
+	if query.Sort, err = querySort(values.Get(SortKey)); err != nil {
+		return model.VideoStreamQuery{}, err
+	}
+	return query, nil
+}
+
+// queryTime parses the RFC 3339 time held by the param named key
+// An empty value returns a nil time, as the param is not set
+func queryTime(value, key string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC 3339 time, not %q", key, value)
+	}
+	t = t.UTC()
+	return &t, nil
+}
+
+// querySort parses the comma separated fields of the sort param
+// Only the fields in model.VideoStreamSortFields are accepted, each at most once.
+func querySort(value string) ([]model.VideoStreamSort, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	fields := strings.Split(value, ",")
+	sorts := make([]model.VideoStreamSort, 0, len(fields))
+	seen := make(map[model.VideoStreamSortField]bool, len(fields))
+
+	for _, f := range fields {
+		var s model.VideoStreamSort
+		if strings.HasPrefix(f, "-") {
+			s.Descending = true
+			f = f[1:]
+		}
+
+		s.Field = model.VideoStreamSortField(f)
+		if !s.Field.Valid() {
+			return nil, fmt.Errorf("can't sort video streams by %q, must be one of %s", f, sortFieldList())
+		}
+		if seen[s.Field] {
+			return nil, fmt.Errorf("can't sort video streams by %q more than once", f)
+		}
+		seen[s.Field] = true
+		sorts = append(sorts, s)
+	}
+	return sorts, nil
+}
+
+// sortFieldList lists the fields streams can be sorted by, for error messages
+func sortFieldList() string {
+	fields := make([]string, 0, len(model.VideoStreamSortFields))
+	for _, f := range model.VideoStreamSortFields {
+		fields = append(fields, string(f))
+	}
+	return strings.Join(fields, ", ")
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/JoeReid/apiutils/testingcodec"
+	"github.com/JoeReid/buffassignment/api/paginate"
 	"github.com/JoeReid/buffassignment/api/types"
 	"github.com/JoeReid/buffassignment/api/videostream"
 	"github.com/JoeReid/buffassignment/internal/model"
@@ -79,7 +80,7 @@ func TestListVideoStreams(t *testing.T) {
 			requestURLValues:     map[string]string{"count": "11"},
 			expectStoreNotCalled: true,
 			expectResponseCode:   http.StatusBadRequest,
-			expectResponseData:   errors.New("paginate error: count 11 must be < 10"),
+			expectResponseData:   newProblem(http.StatusBadRequest, "paginate error: count 11 must be < 10"),
 		},
 		{
 			name:             "custom pagination values correctly computed",
@@ -106,7 +107,7 @@ func TestListVideoStreams(t *testing.T) {
 			expectOffset:       0,
 			expectLimit:        10,
 			expectResponseCode: http.StatusInternalServerError,
-			expectResponseData: errors.New("the world exploded"),
+			expectResponseData: newProblem(http.StatusInternalServerError, ""),
 		},
 	}
 	for _, tt := range tests {
@@ -114,7 +115,7 @@ func TestListVideoStreams(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Setup the mock store object to return the data configured in the test fixture
 			testingStore := testmodel.NewModelMock()
-			testingStore.On("ListVideoStream", mock.Anything, mock.Anything, mock.Anything).Return(tt.storeResponse, tt.storeError)
+			testingStore.On("ListVideoStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(tt.storeResponse, tt.storeError)
 
 			// Build the request to the spec of the test fixture
 			req, err := http.NewRequest("GET", "", nil)
@@ -142,10 +143,305 @@ func TestListVideoStreams(t *testing.T) {
 			// If the handler needs to use the store, assert it made the right call
 			if tt.expectStoreNotCalled {
 				// assert that no calls to the store were made
-				testingStore.AssertNotCalled(t, "ListVideoStream", mock.Anything, mock.Anything)
+				testingStore.AssertNotCalled(t, "ListVideoStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 			} else {
 				// assert that the store was called with the correct uuid
-				testingStore.AssertCalled(t, "ListVideoStream", tt.expectOffset, tt.expectLimit)
+				testingStore.AssertCalled(t, "ListVideoStream", mock.Anything, model.VideoStreamQuery{}, tt.expectOffset, tt.expectLimit)
+			}
+		})
+	}
+}
+
+func TestListVideoStreamsCursor(t *testing.T) {
+	// Cursors hold UTC times without a monotonic clock reading
+	sentinelTime := time.Now().UTC().Round(0)
+
+	streams := make([]model.VideoStream, 0, 3)
+	for i := 0; i < 3; i++ {
+		streams = append(streams, model.VideoStream{
+			ID:        model.VideoStreamID(uuid.New()),
+			Title:     "a stream",
+			CreatedAt: sentinelTime,
+			UpdatedAt: sentinelTime,
+		})
+	}
+	after := streams[0].Cursor()
+
+	var tests = []struct {
+		name                 string
+		requestURLValues     map[string]string
+		storeResponse        []model.VideoStream
+		storeError           error
+		expectAfter          *model.Cursor
+		expectLimit          int
+		expectResponseCode   int
+		expectResponseData   interface{}
+		expectStoreNotCalled bool
+	}{
+		{
+			name:               "empty cursor returns the first page with the next cursor",
+			requestURLValues:   map[string]string{"cursor": "", "count": "2"},
+			storeResponse:      streams,
+			expectAfter:        nil,
+			expectLimit:        3,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.VideoStreamPage{
+				VideoStreams: types.NewVideoStreams(streams[:2]),
+				NextCursor:   streams[1].Cursor().String(),
+			},
+		},
+		{
+			name:               "last page has no next cursor",
+			requestURLValues:   map[string]string{"cursor": after.String()},
+			storeResponse:      streams[1:],
+			expectAfter:        &after,
+			expectLimit:        11,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.VideoStreamPage{
+				VideoStreams: types.NewVideoStreams(streams[1:]),
+			},
+		},
+		{
+			name:               "empty page has an empty list",
+			requestURLValues:   map[string]string{"cursor": after.String()},
+			storeResponse:      []model.VideoStream{},
+			expectAfter:        &after,
+			expectLimit:        11,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.VideoStreamPage{
+				VideoStreams: []types.VideoStream{},
+			},
+		},
+		{
+			name:                 "returns error on invalid cursor",
+			requestURLValues:     map[string]string{"cursor": "not a cursor"},
+			expectStoreNotCalled: true,
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, model.ErrInvalidCursor.Error()),
+		},
+		{
+			name:                 "returns error on skip with cursor",
+			requestURLValues:     map[string]string{"cursor": "", "skip": "2"},
+			expectStoreNotCalled: true,
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, paginate.ErrSkipWithCursor.Error()),
+		},
+		{
+			name:                 "returns error on requested data size > 10",
+			requestURLValues:     map[string]string{"cursor": "", "count": "11"},
+			expectStoreNotCalled: true,
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, "paginate error: count 11 must be < 10"),
+		},
+		{
+			name:               "returns internal error on unexpected store error",
+			requestURLValues:   map[string]string{"cursor": ""},
+			storeResponse:      nil,
+			storeError:         errors.New("the world exploded"),
+			expectAfter:        nil,
+			expectLimit:        11,
+			expectResponseCode: http.StatusInternalServerError,
+			expectResponseData: newProblem(http.StatusInternalServerError, ""),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return the data configured in the test fixture
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("ListVideoStreamAfter", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(tt.storeResponse, tt.storeError)
+
+			// Build the request to the spec of the test fixture
+			req, err := http.NewRequest("GET", "", nil)
+			require.NoError(t, err, "failed to build request for test")
+			vals := url.Values{}
+			for k, v := range tt.requestURLValues {
+				vals.Add(k, v)
+			}
+			req.URL.RawQuery = vals.Encode()
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+
+			// Create the handler under test, and execute it
+			handler := videostream.NewListHandler(testingStore)
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler returns the expected data
+			codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+
+			// assert that the handler responded only once
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			// The offset pagination must never be used alongside a cursor
+			testingStore.AssertNotCalled(t, "ListVideoStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+			if tt.expectStoreNotCalled {
+				testingStore.AssertNotCalled(t, "ListVideoStreamAfter", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				testingStore.AssertCalled(t, "ListVideoStreamAfter", mock.Anything, model.VideoStreamQuery{}, tt.expectAfter, tt.expectLimit)
+			}
+		})
+	}
+}
+
+func TestListVideoStreamsQuery(t *testing.T) {
+	live := model.VideoStream{ID: model.VideoStreamID(uuid.New()), Title: "live", State: model.StreamLive}
+	june := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	july := time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		name                 string
+		requestURLValues     url.Values
+		expectQuery          model.VideoStreamQuery
+		expectCursor         bool
+		expectResponseCode   int
+		expectResponseData   interface{}
+		expectStoreNotCalled bool
+	}{
+		{
+			name:               "one state",
+			requestURLValues:   url.Values{"state": {"live"}},
+			expectQuery:        model.VideoStreamQuery{States: []model.StreamState{model.StreamLive}},
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.NewVideoStreams([]model.VideoStream{live}),
+		},
+		{
+			name:               "several states",
+			requestURLValues:   url.Values{"state": {"live", "ended"}},
+			expectQuery:        model.VideoStreamQuery{States: []model.StreamState{model.StreamLive, model.StreamEnded}},
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.NewVideoStreams([]model.VideoStream{live}),
+		},
+		{
+			name:               "with a cursor",
+			requestURLValues:   url.Values{"state": {"live"}, "cursor": {""}},
+			expectQuery:        model.VideoStreamQuery{States: []model.StreamState{model.StreamLive}},
+			expectCursor:       true,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.NewVideoStreamPage([]model.VideoStream{live}, 10),
+		},
+		{
+			name:                 "returns bad request on unknown state",
+			requestURLValues:     url.Values{"state": {"live", "paused"}},
+			expectStoreNotCalled: true,
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, `unknown video stream state "paused"`),
+		},
+		{
+			name:               "several tags",
+			requestURLValues:   url.Values{"tag": {"football", "final", ""}},
+			expectQuery:        model.VideoStreamQuery{Tags: []string{"football", "final"}},
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.NewVideoStreams([]model.VideoStream{live}),
+		},
+		{
+			name: "filters",
+			requestURLValues: url.Values{
+				"title_contains": {"show"},
+				"created_after":  {"2020-06-01T12:00:00Z"},
+				"created_before": {"2020-07-01T14:00:00+02:00"},
+				"updated_since":  {"2020-06-01T12:00:00Z"},
+			},
+			expectQuery: model.VideoStreamQuery{
+				TitleContains: "show",
+				CreatedAfter:  &june,
+				CreatedBefore: &july,
+				UpdatedSince:  &june,
+			},
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.NewVideoStreams([]model.VideoStream{live}),
+		},
+		{
+			name:               "filters with a cursor",
+			requestURLValues:   url.Values{"title_contains": {"show"}, "cursor": {""}},
+			expectQuery:        model.VideoStreamQuery{TitleContains: "show"},
+			expectCursor:       true,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.NewVideoStreamPage([]model.VideoStream{live}, 10),
+		},
+		{
+			name:             "sort",
+			requestURLValues: url.Values{"sort": {"-created,title"}},
+			expectQuery: model.VideoStreamQuery{Sort: []model.VideoStreamSort{
+				{Field: model.SortStreamCreated, Descending: true},
+				{Field: model.SortStreamTitle},
+			}},
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.NewVideoStreams([]model.VideoStream{live}),
+		},
+		{
+			name:                 "returns bad request on an invalid time",
+			requestURLValues:     url.Values{"created_after": {"yesterday"}},
+			expectStoreNotCalled: true,
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, `created_after must be an RFC 3339 time, not "yesterday"`),
+		},
+		{
+			name:                 "returns bad request on an empty creation range",
+			requestURLValues:     url.Values{"created_after": {"2020-07-01T12:00:00Z"}, "created_before": {"2020-06-01T12:00:00Z"}},
+			expectStoreNotCalled: true,
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, videostream.ErrInvalidCreatedRange.Error()),
+		},
+		{
+			name:                 "returns bad request on an unknown sort field",
+			requestURLValues:     url.Values{"sort": {"created,id"}},
+			expectStoreNotCalled: true,
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, `can't sort video streams by "id", must be one of created, updated, title`),
+		},
+		{
+			name:                 "returns bad request on a repeated sort field",
+			requestURLValues:     url.Values{"sort": {"title,-title"}},
+			expectStoreNotCalled: true,
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, `can't sort video streams by "title" more than once`),
+		},
+		{
+			name:                 "returns bad request on a sort with a cursor",
+			requestURLValues:     url.Values{"sort": {"title"}, "cursor": {""}},
+			expectStoreNotCalled: true,
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, videostream.ErrSortWithCursor.Error()),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return the data configured in the test fixture
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("ListVideoStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]model.VideoStream{live}, nil)
+			testingStore.On("ListVideoStreamAfter", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]model.VideoStream{live}, nil)
+
+			// Build the request to the spec of the test fixture
+			req, err := http.NewRequest("GET", "", nil)
+			require.NoError(t, err, "failed to build request for test")
+			req.URL.RawQuery = tt.requestURLValues.Encode()
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+
+			// Create the handler under test, and execute it
+			handler := videostream.NewListHandler(testingStore)
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler returns the expected data
+			codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+
+			// assert that the handler responded only once
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			switch {
+			case tt.expectStoreNotCalled:
+				testingStore.AssertNotCalled(t, "ListVideoStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				testingStore.AssertNotCalled(t, "ListVideoStreamAfter", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			case tt.expectCursor:
+				testingStore.AssertCalled(t, "ListVideoStreamAfter", mock.Anything, tt.expectQuery, mock.Anything, mock.Anything)
+			default:
+				testingStore.AssertCalled(t, "ListVideoStream", mock.Anything, tt.expectQuery, mock.Anything, mock.Anything)
 			}
 		})
 	}
@@ -0,0 +1,57 @@
+package videostream
+
+import (
+	"net/http"
+
+	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/buffassignment/api/apierror"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+// NewTransitionHandler returns a new instance of a transition action of
+// the videostream API using the given store instance.
+//
+// Each action moves the stream into the given state, such as model.StreamLive
+// to start it. A transition the stream's current state doesn't allow is
+// refused with a conflict, and the stream is left as it was.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewTransitionHandler(store model.VideoStreamStore, to model.StreamState) apiutils.Handler {
+	return &streamTransition{store, to}
+}
+
+// streamTransition implements the apiutils.Handler interface to provide the
+// transition portion of the videostream API
+type streamTransition struct {
+	store model.VideoStreamStore
+	to    model.StreamState
+}
+
+// ServeCodec serves the API using the apiutils.Handler pattern
+// This allows the business logic to live here, and the encoding to live separate from it
+// This also makes testing easier, as there is a test codec that allows us to peek at the output
+// in a testing context.
+func (s *streamTransition) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
+	vID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := s.store.TransitionVideoStream(r.Context(), model.VideoStreamID(vID), s.to); err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+
+	// Read the stream back, so the response has the times set by the store
+	updated, err := s.store.GetVideoStream(r.Context(), model.VideoStreamID(vID))
+	if err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+	c.Respond(r.Context(), w, http.StatusOK, types.NewVideoStream(*updated))
+}
@@ -0,0 +1,124 @@
+package videostream_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/JoeReid/apiutils/testingcodec"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/api/videostream"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/model/testmodel"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransitionVideoStream(t *testing.T) {
+	sentinelUUID := uuid.New()
+	sentinelTime := time.Now().UTC()
+
+	started := &model.VideoStream{
+		ID:        model.VideoStreamID(sentinelUUID),
+		Title:     "testing stream title",
+		State:     model.StreamLive,
+		StartedAt: &sentinelTime,
+		CreatedAt: sentinelTime.Add(-time.Hour),
+		UpdatedAt: sentinelTime,
+	}
+	notAllowed := &model.StreamTransitionError{Stream: model.VideoStreamID(sentinelUUID), From: model.StreamEnded, To: model.StreamLive}
+
+	var tests = []struct {
+		name                 string
+		requestParams        map[string]string
+		transitionError      error
+		getResponse          *model.VideoStream
+		getError             error
+		expectResponseCode   int
+		expectResponseData   interface{}
+		expectStoreNotCalled bool
+	}{
+		{
+			name:               "returns the stream in its new state on happy path",
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			getResponse:        started,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.NewVideoStream(*started),
+		},
+		{
+			name:               "returns conflict on a transition the stream's state doesn't allow",
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			transitionError:    notAllowed,
+			expectResponseCode: http.StatusConflict,
+			expectResponseData: newProblem(http.StatusConflict, notAllowed.Error()),
+		},
+		{
+			name:               "returns not found on store not found error",
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			transitionError:    model.ErrNotFound,
+			expectResponseCode: http.StatusNotFound,
+			expectResponseData: newProblem(http.StatusNotFound, model.ErrNotFound.Error()),
+		},
+		{
+			name:                 "returns bad request on missformated uuid",
+			requestParams:        map[string]string{"uuid": "not_a_valid_uuid"},
+			expectResponseCode:   http.StatusBadRequest,
+			expectResponseData:   newProblem(http.StatusBadRequest, "invalid UUID length: 16"),
+			expectStoreNotCalled: true,
+		},
+		{
+			name:               "returns internal error when the stream can't be read back",
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			getError:           errors.New("the world exploded"),
+			expectResponseCode: http.StatusInternalServerError,
+			expectResponseData: newProblem(http.StatusInternalServerError, ""),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return the data configured in the test fixture
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("TransitionVideoStream", mock.Anything, mock.Anything, mock.Anything).Return(tt.transitionError)
+			testingStore.On("GetVideoStream", mock.Anything, mock.Anything).Return(tt.getResponse, tt.getError)
+
+			// Build the request to the spec of the test fixture
+			rctx := chi.NewRouteContext()
+			for k, v := range tt.requestParams {
+				rctx.URLParams.Add(k, v)
+			}
+			req, err := http.NewRequest("POST", "", nil)
+			require.NoError(t, err, "failed to build request for test")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+
+			// Create the handler under test, and execute it
+			handler := videostream.NewTransitionHandler(testingStore, model.StreamLive)
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler returns the expected data
+			codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+
+			// assert that the handler responded only once
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			if tt.expectStoreNotCalled {
+				testingStore.AssertNotCalled(t, "TransitionVideoStream", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			testingStore.AssertCalled(t, "TransitionVideoStream", mock.Anything, model.VideoStreamID(sentinelUUID), model.StreamLive)
+
+			// The stream is only read back once it has been moved
+			if tt.transitionError != nil {
+				testingStore.AssertNotCalled(t, "GetVideoStream", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
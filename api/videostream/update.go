@@ -0,0 +1,133 @@
+package videostream
+
+import (
+	"net/http"
+
+	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/buffassignment/api/apierror"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/validation"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+// NewUpdateHandler returns a new instance of the update action of
+// the videostream API using the given store instance.
+//
+// The state and timestamps of the stream are managed by the server,
+// so only the title and scheduled start are read from the request body.
+//
+// The new state of the stream is checked against the rules of the given validator before it is stored.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewUpdateHandler(store model.VideoStreamStore, validator *validation.Validator) apiutils.Handler {
+	return &streamUpdate{store, validator}
+}
+
+// NewPatchHandler returns a new instance of the patch action of
+// the videostream API using the given store instance.
+//
+// The patch only replaces the fields that are set in the request.
+//
+// The new state of the stream is checked against the rules of the given validator before it is stored.
+//
+// The store is provided as an argument for easy dependency injection in tests
+// I.E: using the testing mock store rather than a full DB for API testing
+func NewPatchHandler(store model.VideoStreamStore, validator *validation.Validator) apiutils.Handler {
+	return &streamPatch{store, validator}
+}
+
+// streamUpdate implements the apiutils.Handler interface to provide the
+// update portion of the videostream API
+type streamUpdate struct {
+	store     model.VideoStreamStore
+	validator *validation.Validator
+}
+
+// ServeCodec serves the API using the apiutils.Handler pattern
+// This allows the business logic to live here, and the encoding to live separate from it
+// This also makes testing easier, as there is a test codec that allows us to peek at the output
+// in a testing context.
+func (s *streamUpdate) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
+	vID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var req types.VideoStream
+	if err := c.Read(r.Context(), r, &req); err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	updateStream(s.store, s.validator, c, w, r, model.VideoStreamID(vID), req)
+}
+
+// streamPatch implements the apiutils.Handler interface to provide the
+// patch portion of the videostream API
+type streamPatch struct {
+	store     model.VideoStreamStore
+	validator *validation.Validator
+}
+
+// ServeCodec serves the API using the apiutils.Handler pattern
+// This allows the business logic to live here, and the encoding to live separate from it
+// This also makes testing easier, as there is a test codec that allows us to peek at the output
+// in a testing context.
+func (s *streamPatch) ServeCodec(c apiutils.Codec, w http.ResponseWriter, r *http.Request) {
+	vID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var req types.VideoStreamPatch
+	if err := c.Read(r.Context(), r, &req); err != nil {
+		apierror.Respond(c, w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	existing, err := s.store.GetVideoStream(r.Context(), model.VideoStreamID(vID))
+	if err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+
+	// Apply the patch to the current state, and then treat it as a full update
+	updateStream(s.store, s.validator, c, w, r, existing.ID, req.Apply(types.NewVideoStream(*existing)))
+}
+
+// updateStream holds the logic shared between the update and patch handlers,
+// once they have worked out the full new state of the stream
+func updateStream(
+	store model.VideoStreamStore,
+	validator *validation.Validator,
+	c apiutils.Codec,
+	w http.ResponseWriter,
+	r *http.Request,
+	id model.VideoStreamID,
+	req types.VideoStream,
+) {
+	stream := model.VideoStream{ID: id, Title: req.Title, Tags: req.Tags, ScheduledStart: utcTime(req.ScheduledStart)}
+
+	if err := validator.VideoStream(stream); err != nil {
+		apierror.RespondInvalid(c, w, r, err)
+		return
+	}
+
+	if err := store.UpdateVideoStream(r.Context(), id, stream); err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+
+	// Read the stream back, so the response has the timestamps set by the store
+	updated, err := store.GetVideoStream(r.Context(), id)
+	if err != nil {
+		apierror.Respond(c, w, r, apierror.Status(err), err)
+		return
+	}
+	c.Respond(r.Context(), w, http.StatusOK, types.NewVideoStream(*updated))
+}
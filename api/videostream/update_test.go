@@ -0,0 +1,244 @@
+package videostream_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/JoeReid/apiutils"
+	"github.com/JoeReid/apiutils/testingcodec"
+	"github.com/JoeReid/buffassignment/api/types"
+	"github.com/JoeReid/buffassignment/api/videostream"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/model/testmodel"
+	"github.com/JoeReid/buffassignment/internal/validation"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateVideoStream(t *testing.T) {
+	sentinelUUID := uuid.New()
+	sentinelTime := time.Now()
+
+	existing := &model.VideoStream{
+		ID:        model.VideoStreamID(sentinelUUID),
+		Title:     "a sepcial testing stream",
+		CreatedAt: sentinelTime,
+		UpdatedAt: sentinelTime,
+	}
+	updated := &model.VideoStream{
+		ID:        model.VideoStreamID(sentinelUUID),
+		Title:     "a renamed testing stream",
+		CreatedAt: sentinelTime,
+		UpdatedAt: sentinelTime.Add(time.Minute),
+	}
+	title := "a renamed testing stream"
+	scheduled := sentinelTime.Add(time.Hour).UTC()
+	scheduledExisting := &model.VideoStream{
+		ID:             model.VideoStreamID(sentinelUUID),
+		Title:          "a sepcial testing stream",
+		State:          model.StreamScheduled,
+		ScheduledStart: &scheduled,
+		CreatedAt:      sentinelTime,
+		UpdatedAt:      sentinelTime,
+	}
+	taggedExisting := &model.VideoStream{
+		ID:        model.VideoStreamID(sentinelUUID),
+		Title:     "a sepcial testing stream",
+		Tags:      []string{"football", "sport"},
+		CreatedAt: sentinelTime,
+		UpdatedAt: sentinelTime,
+	}
+
+	var tests = []struct {
+		name               string
+		handler            func(model.VideoStreamStore, *validation.Validator) apiutils.Handler
+		requestParams      map[string]string
+		requestBody        interface{}
+		getResponse        *model.VideoStream
+		getError           error
+		updateError        error
+		expectResponseCode int
+		expectResponseData interface{}
+		expectUpdate       *model.VideoStream
+	}{
+		{
+			name:               "update renames the stream",
+			handler:            videostream.NewUpdateHandler,
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			requestBody:        types.VideoStream{Title: title},
+			getResponse:        updated,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.NewVideoStream(*updated),
+			expectUpdate:       &model.VideoStream{ID: model.VideoStreamID(sentinelUUID), Title: title},
+		},
+		{
+			name:               "patch renames the stream",
+			handler:            videostream.NewPatchHandler,
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			requestBody:        types.VideoStreamPatch{Title: &title},
+			getResponse:        updated,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.NewVideoStream(*updated),
+			expectUpdate:       &model.VideoStream{ID: model.VideoStreamID(sentinelUUID), Title: title},
+		},
+		{
+			name:               "empty patch keeps the title",
+			handler:            videostream.NewPatchHandler,
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			requestBody:        types.VideoStreamPatch{},
+			getResponse:        existing,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.NewVideoStream(*existing),
+			expectUpdate:       &model.VideoStream{ID: model.VideoStreamID(sentinelUUID), Title: existing.Title},
+		},
+		{
+			name:               "update reschedules the stream",
+			handler:            videostream.NewUpdateHandler,
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			requestBody:        types.VideoStream{Title: title, ScheduledStart: &scheduled, State: "live"},
+			getResponse:        updated,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.NewVideoStream(*updated),
+			expectUpdate:       &model.VideoStream{ID: model.VideoStreamID(sentinelUUID), Title: title, ScheduledStart: &scheduled},
+		},
+		{
+			name:               "patch keeps the scheduled start",
+			handler:            videostream.NewPatchHandler,
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			requestBody:        types.VideoStreamPatch{Title: &title},
+			getResponse:        scheduledExisting,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.NewVideoStream(*scheduledExisting),
+			expectUpdate:       &model.VideoStream{ID: model.VideoStreamID(sentinelUUID), Title: title, ScheduledStart: &scheduled},
+		},
+		{
+			name:               "patch replaces the tags",
+			handler:            videostream.NewPatchHandler,
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			requestBody:        types.VideoStreamPatch{Tags: &[]string{"final"}},
+			getResponse:        taggedExisting,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.NewVideoStream(*taggedExisting),
+			expectUpdate:       &model.VideoStream{ID: model.VideoStreamID(sentinelUUID), Title: taggedExisting.Title, Tags: []string{"final"}},
+		},
+		{
+			name:               "patch keeps the tags",
+			handler:            videostream.NewPatchHandler,
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			requestBody:        types.VideoStreamPatch{Title: &title},
+			getResponse:        taggedExisting,
+			expectResponseCode: http.StatusOK,
+			expectResponseData: types.NewVideoStream(*taggedExisting),
+			expectUpdate:       &model.VideoStream{ID: model.VideoStreamID(sentinelUUID), Title: title, Tags: taggedExisting.Tags},
+		},
+		{
+			name:               "update returns unprocessable entity on an invalid tag",
+			handler:            videostream.NewUpdateHandler,
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			requestBody:        types.VideoStream{Title: title, Tags: []string{"Football"}},
+			expectResponseCode: http.StatusUnprocessableEntity,
+			expectResponseData: newInvalidProblem(types.ValidationError{
+				Field: "tags[0]", Rule: "tag_format",
+				Message: "must be 1 to 64 lower case letters, digits, '_', ':' or '-', starting with a letter or digit",
+			}),
+		},
+		{
+			name:               "update returns unprocessable entity on empty title",
+			handler:            videostream.NewUpdateHandler,
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			requestBody:        types.VideoStream{},
+			expectResponseCode: http.StatusUnprocessableEntity,
+			expectResponseData: newInvalidProblem(
+				types.ValidationError{Field: "title", Rule: "required", Message: "must not be empty"},
+			),
+		},
+		{
+			name:               "update returns bad request on missformated uuid",
+			handler:            videostream.NewUpdateHandler,
+			requestParams:      map[string]string{"uuid": "not_a_valid_uuid"},
+			expectResponseCode: http.StatusBadRequest,
+			expectResponseData: newProblem(http.StatusBadRequest, "invalid UUID length: 16"),
+		},
+		{
+			name:               "update returns not found on store not found error",
+			handler:            videostream.NewUpdateHandler,
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			requestBody:        types.VideoStream{Title: title},
+			updateError:        model.ErrNotFound,
+			expectResponseCode: http.StatusNotFound,
+			expectResponseData: newProblem(http.StatusNotFound, model.ErrNotFound.Error()),
+			expectUpdate:       &model.VideoStream{ID: model.VideoStreamID(sentinelUUID), Title: title},
+		},
+		{
+			name:               "patch returns not found on store not found error",
+			handler:            videostream.NewPatchHandler,
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			requestBody:        types.VideoStreamPatch{Title: &title},
+			getError:           model.ErrNotFound,
+			expectResponseCode: http.StatusNotFound,
+			expectResponseData: newProblem(http.StatusNotFound, model.ErrNotFound.Error()),
+		},
+		{
+			name:               "update returns internal error on unexpected store error",
+			handler:            videostream.NewUpdateHandler,
+			requestParams:      map[string]string{"uuid": sentinelUUID.String()},
+			requestBody:        types.VideoStream{Title: title},
+			updateError:        errors.New("the world exploded"),
+			expectResponseCode: http.StatusInternalServerError,
+			expectResponseData: newProblem(http.StatusInternalServerError, ""),
+			expectUpdate:       &model.VideoStream{ID: model.VideoStreamID(sentinelUUID), Title: title},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup the mock store object to return the data configured in the test fixture
+			testingStore := testmodel.NewModelMock()
+			testingStore.On("GetVideoStream", mock.Anything, mock.Anything).Return(tt.getResponse, tt.getError)
+			testingStore.On("UpdateVideoStream", mock.Anything, mock.Anything, mock.Anything).Return(tt.updateError)
+
+			// Build the request to the spec of the test fixture
+			rctx := chi.NewRouteContext()
+			for k, v := range tt.requestParams {
+				rctx.URLParams.Add(k, v)
+			}
+			req, err := http.NewRequest("PUT", "", nil)
+			require.NoError(t, err, "failed to build request for test")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			// Use the testing codec to assert handler behaviour
+			codec := testingcodec.New()
+			codec.On("Respond", mock.Anything, nil, mock.Anything, mock.Anything).Return()
+			codec.On("Read", mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+				switch body := args.Get(2).(type) {
+				case *types.VideoStream:
+					*body = tt.requestBody.(types.VideoStream)
+				case *types.VideoStreamPatch:
+					*body = tt.requestBody.(types.VideoStreamPatch)
+				}
+			})
+
+			// Create the handler under test, and execute it
+			handler := tt.handler(testingStore, newValidator(t))
+			handler.ServeCodec(codec, nil, req)
+
+			// assert that the handler returns the expected data
+			codec.AssertCalled(t, "Respond", mock.Anything, nil, tt.expectResponseCode, tt.expectResponseData)
+
+			// assert that the handler responded only once
+			codec.AssertNumberOfCalls(t, "Respond", 1)
+
+			// If the handler needs to update the store, assert it made the right call
+			if tt.expectUpdate == nil {
+				testingStore.AssertNotCalled(t, "UpdateVideoStream", mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				testingStore.AssertCalled(t, "UpdateVideoStream", mock.Anything, model.VideoStreamID(sentinelUUID), *tt.expectUpdate)
+			}
+		})
+	}
+}
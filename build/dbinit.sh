@@ -12,6 +12,10 @@ done
 echo >&2 "running migrations"
 tern migrate -m migrations
 
+# Score the responses the migrations left unscored, with the configured points
+echo >&2 "running db score"
+score
+
 # Run the db seed application
 echo >&2 "running db seed"
 seed
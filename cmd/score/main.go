@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/JoeReid/apiutils/tracer"
+	"github.com/JoeReid/buffassignment/internal/config"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/model/postgres"
+	_ "github.com/lib/pq"
+	"github.com/opentracing/opentracing-go"
+)
+
+func main() {
+	if err := tracer.InitTracer("Buff DB score tool"); err != nil {
+		tracer.UntracedLogf("failed to configure tracer: %e", err)
+		os.Exit(1)
+	}
+	defer tracer.Close() // try to flush the traces before we exit
+
+	os.Exit(score())
+}
+
+// score gives the responses left unscored by the migrations the points
+// the service is configured with, so must be run with the same environment
+func score() (exitcode int) {
+	sp, ctx := opentracing.StartSpanFromContext(context.Background(), "score postgres database")
+	defer sp.Finish()
+
+	tracer.Log(sp, "read db config from environment")
+	dc, err := config.DBConfig()
+	if err != nil {
+		tracer.SetError(sp, err)
+		return 1
+	}
+
+	tracer.Log(sp, "read scoring config from environment")
+	scc, err := config.ScoringConfig()
+	if err != nil {
+		tracer.SetError(sp, err)
+		return 1
+	}
+
+	tracer.Log(sp, "build a new postgres store")
+	store, err := postgres.NewStore(
+		postgres.SetDBUser(dc.DBUser),
+		postgres.SetDBPassword(dc.DBPassword),
+		postgres.SetDBHostname(dc.DBHost),
+		postgres.SetDBPort(dc.DBPort),
+		postgres.SetDBName(dc.DBName),
+		postgres.SetConnectTimeout(dc.DBConnectTimeout),
+		postgres.WithScoring(model.Scoring{Points: scc.Points, SpeedBonus: scc.SpeedBonus}),
+	)
+	if err != nil {
+		tracer.Log(sp, "failed to configure postgres store")
+		tracer.SetError(sp, err)
+		return 1
+	}
+
+	tracer.Log(sp, "score the responses that haven't been scored")
+	n, err := store.ScoreResponses(ctx)
+	if err != nil {
+		tracer.Log(sp, "failed to score responses")
+		tracer.SetError(sp, err)
+		return 1
+	}
+	tracer.Log(sp, fmt.Sprintf("scored %d responses", n))
+	return 0
+}
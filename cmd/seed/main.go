@@ -1,16 +1,13 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"os"
-	"time"
 
 	"github.com/JoeReid/apiutils/tracer"
 	"github.com/JoeReid/buffassignment/internal/config"
-	"github.com/JoeReid/buffassignment/internal/model"
 	"github.com/JoeReid/buffassignment/internal/model/postgres"
-	"github.com/brianvoe/gofakeit/v5"
-	"github.com/google/uuid"
+	"github.com/JoeReid/buffassignment/internal/seed"
 	_ "github.com/lib/pq"
 	"github.com/opentracing/opentracing-go"
 )
@@ -26,8 +23,7 @@ func main() {
 }
 
 func populate() (exitcode int) {
-	gofakeit.Seed(0)
-	sp := opentracing.StartSpan("seed postgres database")
+	sp, ctx := opentracing.StartSpanFromContext(context.Background(), "seed postgres database")
 	defer sp.Finish()
 
 	tracer.Log(sp, "read db config from environment")
@@ -52,75 +48,11 @@ func populate() (exitcode int) {
 		return 1
 	}
 
-	// Populate DB with 100 streams, each with 10 buffs
-	for i := 0; i < 100; i++ {
-		u, err := uuid.Parse(gofakeit.UUID())
-		if err != nil {
-			tracer.Log(sp, "failed to create new uuid")
-			tracer.SetError(sp, err)
-			return 1
-		}
-		vID := model.VideoStreamID(u)
-
-		// start sometime in the last week, updated between then and now
-		week := time.Hour * 24 * 7
-		now := time.Now()
-		startdate := gofakeit.DateRange(now.Add(-week), now)
-		updated := gofakeit.DateRange(startdate, now)
-
-		tracer.Log(sp, "create video stream entry")
-		if err := store.CreateVideoStream(model.VideoStream{
-			ID:        vID,
-			Title:     fmt.Sprintf("%s %s stream", gofakeit.Adverb(), gofakeit.Adjective()),
-			CreatedAt: startdate,
-			UpdatedAt: updated,
-		}); err != nil {
-			tracer.Log(sp, "failed to create video stream")
-			tracer.SetError(sp, err)
-			return 1
-		}
-
-		// create buffs
-		for j := 0; j < 10; j++ {
-			u, err := uuid.Parse(gofakeit.UUID())
-			if err != nil {
-				tracer.Log(sp, "failed to create new uuid")
-				tracer.SetError(sp, err)
-				return 1
-			}
-			bID := model.BuffID(u)
-
-			ans := []model.Answer{}
-
-			// Create answers
-			for k := 0; k < 5; k++ {
-				u, err := uuid.Parse(gofakeit.UUID())
-				if err != nil {
-					tracer.Log(sp, "failed to create new uuid")
-					tracer.SetError(sp, err)
-					return 1
-				}
-				aID := model.AnswerID(u)
-
-				ans = append(ans, model.Answer{
-					ID:      aID,
-					Text:    gofakeit.Noun(),
-					Correct: k == 0,
-				})
-			}
-
-			tracer.Log(sp, "create buff entry")
-			if err := store.CreateBuff(model.Buff{
-				ID:       bID,
-				Stream:   vID,
-				Question: gofakeit.Question(),
-				Answers:  ans,
-			}); err != nil {
-				tracer.Log(sp, "failed to create buff")
-				tracer.SetError(sp, err)
-				return 1
-			}
-		}
+	tracer.Log(sp, "populate the store with generated data")
+	if err := seed.Populate(ctx, store); err != nil {
+		tracer.Log(sp, "failed to populate store")
+		tracer.SetError(sp, err)
+		return 1
 	}
 	return 0
 }
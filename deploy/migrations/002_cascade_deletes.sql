@@ -0,0 +1,21 @@
+alter table questions
+  drop constraint questions_stream_fkey,
+  add constraint questions_stream_fkey
+    foreign key (stream) references video_streams(id) on delete cascade;
+
+alter table answers
+  drop constraint answers_question_fkey,
+  add constraint answers_question_fkey
+    foreign key (question) references questions(id) on delete cascade;
+
+---- create above / drop below ----
+
+alter table answers
+  drop constraint answers_question_fkey,
+  add constraint answers_question_fkey
+    foreign key (question) references questions(id);
+
+alter table questions
+  drop constraint questions_stream_fkey,
+  add constraint questions_stream_fkey
+    foreign key (stream) references video_streams(id);
@@ -0,0 +1,36 @@
+-- Existing buffs didn't record when they were created,
+-- so they are all given the time of the migration
+alter table questions
+  add column created timestamp not null default (now() at time zone 'utc');
+
+alter table questions
+  alter column created drop default;
+
+-- Existing answers keep the order the API has always shown them in,
+-- with the correct answer first
+alter table answers
+  add column position integer not null default 0;
+
+update answers set position = ordered.position
+from (
+  select id, row_number() over (partition by question order by correct desc, id) - 1 as position
+  from answers
+) as ordered
+where answers.id = ordered.id;
+
+alter table answers
+  alter column position drop default;
+
+create index questions_created_id_idx on questions (created, id);
+create index questions_stream_created_id_idx on questions (stream, created, id);
+
+---- create above / drop below ----
+
+drop index questions_stream_created_id_idx;
+drop index questions_created_id_idx;
+
+alter table answers
+  drop column position;
+
+alter table questions
+  drop column created;
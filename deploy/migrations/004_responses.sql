@@ -0,0 +1,23 @@
+-- Lets a response check that its answer belongs to its buff
+alter table answers
+  add constraint answers_question_id_key unique (question, id);
+
+-- A user can respond to each buff once, so the pair is the key.
+-- Responses go with the buff, or the answer, they refer to.
+create table responses(
+  question uuid not null REFERENCES questions(id) on delete cascade,
+  user_id varchar not null,
+  answer uuid not null,
+  created timestamp not null,
+  PRIMARY KEY (question, user_id),
+  foreign key (question, answer) references answers(question, id) on delete cascade
+);
+
+create index responses_question_answer_idx on responses (question, answer);
+
+---- create above / drop below ----
+
+drop table responses;
+
+alter table answers
+  drop constraint answers_question_id_key;
@@ -0,0 +1,18 @@
+-- Tells every listening replica about each new buff, once it is committed.
+-- The payload is just the buff's ID, as a whole buff may not fit in a notification.
+create function notify_buff_created() returns trigger as $$
+begin
+  perform pg_notify('buff_created', NEW.id::text);
+  return NEW;
+end;
+$$ language plpgsql;
+
+create trigger questions_notify_created
+  after insert on questions
+  for each row execute procedure notify_buff_created();
+
+---- create above / drop below ----
+
+drop trigger questions_notify_created on questions;
+
+drop function notify_buff_created();
@@ -0,0 +1,18 @@
+-- Tells every listening replica about each new response, once it is committed.
+-- The payload is the ID of the buff responded to, whose results have changed.
+create function notify_response_created() returns trigger as $$
+begin
+  perform pg_notify('response_created', NEW.question::text);
+  return NEW;
+end;
+$$ language plpgsql;
+
+create trigger responses_notify_created
+  after insert on responses
+  for each row execute procedure notify_response_created();
+
+---- create above / drop below ----
+
+drop trigger responses_notify_created on responses;
+
+drop function notify_response_created();
@@ -0,0 +1,21 @@
+-- Buffs can be placed on the timeline of their stream, as an offset from the start
+-- of the stream and a duration, both in milliseconds. Existing buffs are left unscheduled.
+alter table questions
+  add column start_offset_ms bigint,
+  add column duration_ms bigint,
+  add constraint questions_schedule_check check (
+    (start_offset_ms is null and duration_ms is null) or
+    (start_offset_ms >= 0 and duration_ms > 0)
+  );
+
+create index questions_stream_start_offset_idx on questions (stream, start_offset_ms)
+  where start_offset_ms is not null;
+
+---- create above / drop below ----
+
+drop index questions_stream_start_offset_idx;
+
+alter table questions
+  drop constraint questions_schedule_check,
+  drop column duration_ms,
+  drop column start_offset_ms;
@@ -0,0 +1,28 @@
+-- Video streams move through a lifecycle of states, and record when they were
+-- scheduled to start and when they actually started and ended.
+-- Existing streams have always had their buffs delivered, so they are treated as
+-- live, and as having started when they were created.
+alter table video_streams
+  add column state varchar not null default 'live',
+  add column scheduled_start timestamp,
+  add column started timestamp,
+  add column ended timestamp,
+  add constraint video_streams_state_check check (state in ('scheduled', 'live', 'ended', 'archived'));
+
+update video_streams set started = created;
+
+alter table video_streams
+  alter column state drop default;
+
+create index video_streams_state_created_id_idx on video_streams (state, created, id);
+
+---- create above / drop below ----
+
+drop index video_streams_state_created_id_idx;
+
+alter table video_streams
+  drop constraint video_streams_state_check,
+  drop column ended,
+  drop column started,
+  drop column scheduled_start,
+  drop column state;
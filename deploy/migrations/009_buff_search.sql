@@ -0,0 +1,9 @@
+-- Buffs are searched by the words of their question and answers. The expressions
+-- must match those used by the store's search for the indexes to be used.
+create index questions_text_search_idx on questions using gin (to_tsvector('english', text));
+create index answers_text_search_idx on answers using gin (to_tsvector('english', text));
+
+---- create above / drop below ----
+
+drop index answers_text_search_idx;
+drop index questions_text_search_idx;
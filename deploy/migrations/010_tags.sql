@@ -0,0 +1,30 @@
+-- Buffs and video streams can be tagged. Each tag is stored once, and is linked
+-- to every question and stream that has it.
+create table tags(
+  name varchar primary key
+);
+
+create table question_tags(
+  question uuid not null references questions(id) on delete cascade,
+  tag varchar not null references tags(name),
+  primary key (question, tag)
+);
+
+create table video_stream_tags(
+  stream uuid not null references video_streams(id) on delete cascade,
+  tag varchar not null references tags(name),
+  primary key (stream, tag)
+);
+
+-- The primary keys find the tags of a row, these find the rows with a tag
+create index question_tags_tag_idx on question_tags (tag);
+create index video_stream_tags_tag_idx on video_stream_tags (tag);
+
+---- create above / drop below ----
+
+drop index video_stream_tags_tag_idx;
+drop index question_tags_tag_idx;
+
+drop table video_stream_tags;
+drop table question_tags;
+drop table tags;
@@ -0,0 +1,14 @@
+-- Buffs are a quiz, a poll or a prediction, which decides how their answers are judged.
+-- Every existing buff was written as a quiz, with a correct answer.
+alter table questions
+  add column kind varchar not null default 'quiz',
+  add constraint questions_kind_check check (kind in ('quiz', 'poll', 'prediction'));
+
+alter table questions
+  alter column kind drop default;
+
+---- create above / drop below ----
+
+alter table questions
+  drop constraint questions_kind_check,
+  drop column kind;
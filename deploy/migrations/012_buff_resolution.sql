@@ -0,0 +1,16 @@
+-- A prediction is resolved once its result is known. The row records the winning answer,
+-- who resolved it and when, and how many of the responses it scored were correct.
+-- It is written once, and goes with the buff, or the answer, it refers to.
+create table resolutions(
+  question uuid primary key references questions(id) on delete cascade,
+  answer uuid not null,
+  resolved_by varchar not null,
+  resolved timestamp not null,
+  responses integer not null,
+  correct integer not null,
+  foreign key (question, answer) references answers(question, id) on delete cascade
+);
+
+---- create above / drop below ----
+
+drop table resolutions;
@@ -0,0 +1,122 @@
+-- Each response records the points it earned when it was scored. The responses given
+-- before now are left without any, to be scored with the configured points by cmd/score,
+-- which is run after the migrations. Every new response is scored as it is given.
+alter table responses
+  add column points integer;
+
+-- The leaderboard of each stream, holding a score for every user that has responded to its buffs.
+-- User IDs are compared byte by byte, as they are by the service, to break the last ties.
+create table scores(
+  stream uuid not null references video_streams(id) on delete cascade,
+  user_id varchar collate "C" not null,
+  points integer not null,
+  correct integer not null,
+  responses integer not null,
+  scored timestamp not null,
+  primary key (stream, user_id)
+);
+
+create index scores_rank_idx on scores (stream, points desc, scored, user_id);
+
+-- Finds the responses of a user when their score is refreshed
+create index responses_user_id_idx on responses (user_id);
+
+insert into scores (stream, user_id, points, correct, responses, scored)
+  select questions.stream, responses.user_id, coalesce(sum(responses.points), 0),
+    count(*) filter (where responses.points > 0), count(*),
+    coalesce(max(responses.created) filter (where responses.points > 0), min(responses.created))
+  from responses join questions on questions.id = responses.question
+  group by questions.stream, responses.user_id;
+
+-- Works the score of a user on a stream out again from their own responses, so that the
+-- leaderboard is kept up to date without counting every response to the stream.
+-- The score is locked first, so that responses given at the same time are all counted.
+-- A stream that is being deleted takes its leaderboard with it, so is left alone.
+create function refresh_score(s uuid, u varchar) returns void as $$
+declare
+  total record;
+begin
+  perform 1 from video_streams where id = s;
+  if not found then
+    return;
+  end if;
+
+  insert into scores (stream, user_id, points, correct, responses, scored)
+    values (s, u, 0, 0, 0, now()) on conflict do nothing;
+  perform 1 from scores where stream = s and user_id = u for update;
+
+  select coalesce(sum(responses.points), 0) as points,
+    count(*) filter (where responses.points > 0) as correct,
+    count(*) as responses,
+    coalesce(max(responses.created) filter (where responses.points > 0), min(responses.created)) as scored
+  into total
+  from responses join questions on questions.id = responses.question
+  where questions.stream = s and responses.user_id = u;
+
+  if total.responses = 0 then
+    delete from scores where stream = s and user_id = u;
+  else
+    update scores set points = total.points, correct = total.correct,
+      responses = total.responses, scored = total.scored
+    where stream = s and user_id = u;
+  end if;
+end;
+$$ language plpgsql;
+
+-- Refreshes the score of the user of each response given, rescored, or removed.
+create function score_response() returns trigger as $$
+declare
+  resp record;
+  s uuid;
+begin
+  if TG_OP = 'DELETE' then
+    resp := OLD;
+  else
+    resp := NEW;
+  end if;
+
+  select stream into s from questions where id = resp.question;
+  if found then
+    perform refresh_score(s, resp.user_id);
+  end if;
+  return null;
+end;
+$$ language plpgsql;
+
+create trigger responses_score
+  after insert or delete on responses
+  for each row execute procedure score_response();
+
+create trigger responses_rescore
+  after update of points on responses
+  for each row when (OLD.points is distinct from NEW.points)
+  execute procedure score_response();
+
+-- The responses to a buff are removed before the buff, rather than along with it,
+-- so that the stream of each can still be found when the scores are refreshed.
+create function unscore_question() returns trigger as $$
+begin
+  delete from responses where question = OLD.id;
+  return OLD;
+end;
+$$ language plpgsql;
+
+create trigger questions_unscore
+  before delete on questions
+  for each row execute procedure unscore_question();
+
+---- create above / drop below ----
+
+drop trigger questions_unscore on questions;
+drop trigger responses_rescore on responses;
+drop trigger responses_score on responses;
+
+drop function unscore_question();
+drop function score_response();
+drop function refresh_score(uuid, varchar);
+
+drop index responses_user_id_idx;
+drop table scores;
+
+alter table responses
+  drop column points;
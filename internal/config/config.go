@@ -43,3 +43,89 @@ func DBConfig() (Database, error) {
 	err := envconfig.Process("", &config)
 	return config, err
 }
+
+// Validation defines all the config options for the domain validation rules
+// These options can be fetched from the environment
+type Validation struct {
+	BuffMinAnswers        int  `envconfig:"BUFF_MIN_ANSWERS" default:"2"`
+	BuffMaxAnswers        int  `envconfig:"BUFF_MAX_ANSWERS" default:"10"`
+	BuffMaxQuestionLength int  `envconfig:"BUFF_MAX_QUESTION_LENGTH" default:"280"`
+	BuffExactlyOneCorrect bool `envconfig:"BUFF_EXACTLY_ONE_CORRECT" default:"true"`
+	StreamMaxTitleLength  int  `envconfig:"STREAM_MAX_TITLE_LENGTH" default:"200"`
+}
+
+// ValidationConfig returns a new built Validation config struct build from the
+// application's environment
+func ValidationConfig() (Validation, error) {
+	var config Validation
+
+	err := envconfig.Process("", &config)
+	return config, err
+}
+
+// Store defines the config options for choosing the storage sub-component
+// These options can be fetched from the environment
+type Store struct {
+	// Backend is either "postgres" or "memory"
+	Backend string `envconfig:"STORE_BACKEND" default:"postgres"`
+
+	// Seed populates the memory backend with the same generated data
+	// as the seed tool. It has no effect on the postgres backend.
+	Seed bool `envconfig:"STORE_SEED" default:"false"`
+}
+
+// StoreConfig returns a new built Store config struct build from the
+// application's environment
+func StoreConfig() (Store, error) {
+	var config Store
+
+	err := envconfig.Process("", &config)
+	return config, err
+}
+
+// Scoring defines the config options for the stream leaderboards
+// These options can be fetched from the environment
+type Scoring struct {
+	// Points is how many points a correct response earns
+	Points int `envconfig:"SCORE_POINTS" default:"100"`
+
+	// SpeedBonus is the most a correct response to a scheduled buff earns on top of
+	// its points, when given the moment the buff opens. Nothing more is earned by default.
+	SpeedBonus int `envconfig:"SCORE_SPEED_BONUS" default:"0"`
+}
+
+// ScoringConfig returns a new built Scoring config struct build from the
+// application's environment
+func ScoringConfig() (Scoring, error) {
+	var config Scoring
+
+	err := envconfig.Process("", &config)
+	return config, err
+}
+
+// Live defines the config options for the live buff endpoints
+// These options can be fetched from the environment
+type Live struct {
+	// Heartbeat is how often an idle connection is sent a comment, to keep it open
+	Heartbeat time.Duration `envconfig:"LIVE_HEARTBEAT" default:"3s"`
+
+	// MaxLifetime is how long a connection is kept before the client is asked to reconnect
+	// It must be shorter than SERVE_WRITE_TIMEOUT, which would otherwise cut the connection off
+	MaxLifetime time.Duration `envconfig:"LIVE_MAX_LIFETIME" default:"9s"`
+
+	// SubscriberBuffer is how many buffs a connection can fall behind before it is dropped
+	SubscriberBuffer int `envconfig:"LIVE_SUBSCRIBER_BUFFER" default:"16"`
+
+	// PingInterval is how often WebSocket clients are pinged
+	// A client that doesn't answer for twice as long is disconnected.
+	PingInterval time.Duration `envconfig:"LIVE_PING_INTERVAL" default:"20s"`
+}
+
+// LiveConfig returns a new built Live config struct build from the
+// application's environment
+func LiveConfig() (Live, error) {
+	var config Live
+
+	err := envconfig.Process("", &config)
+	return config, err
+}
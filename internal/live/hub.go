@@ -0,0 +1,175 @@
+// Package live fans buffs, and the results of their responses,
+// out to the viewers of a video stream as they change
+package live
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+)
+
+// Publisher is anything buffs, and their results, can be published to
+type Publisher interface {
+	Publish(model.Buff)
+	PublishResults(model.VideoStreamID, model.Results)
+}
+
+var _ Publisher = &Hub{}
+
+// Hub is an in-process publish/subscribe hub of buffs, keyed by the stream they belong to
+//
+// Publishing never blocks. A subscriber that falls a whole buffer behind is dropped,
+// closing its channel, and is expected to catch up from the store.
+//
+// Results are never dropped. Each new tally of a buff replaces the one before it,
+// so a slow subscriber is only sent the latest results of each buff.
+type Hub struct {
+	buffer int
+
+	mu   sync.Mutex
+	subs map[model.VideoStreamID]map[*Subscription]struct{}
+}
+
+// HubOption configures a Hub
+type HubOption func(*Hub) error
+
+// NewHub returns a new Hub, with no subscribers
+func NewHub(options ...HubOption) (*Hub, error) {
+	const defaultBuffer = 16
+
+	h := &Hub{
+		buffer: defaultBuffer,
+		subs:   make(map[model.VideoStreamID]map[*Subscription]struct{}),
+	}
+
+	for _, opt := range options {
+		if err := opt(h); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// SubscriberBuffer sets how many buffs a subscriber can fall behind before it is dropped
+func SubscriberBuffer(n int) HubOption {
+	return func(h *Hub) error {
+		if n < 1 {
+			return errors.New("subscriber buffer must be at least 1")
+		}
+		h.buffer = n
+		return nil
+	}
+}
+
+// Subscribe returns a new subscription to the buffs published for the stream
+// The subscription must be closed once it is no longer used
+func (h *Hub) Subscribe(stream model.VideoStreamID) *Subscription {
+	sub := &Subscription{
+		hub:          h,
+		stream:       stream,
+		buffs:        make(chan model.Buff, h.buffer),
+		results:      make(map[model.BuffID]model.Results),
+		resultsReady: make(chan struct{}, 1),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subs[stream] == nil {
+		h.subs[stream] = make(map[*Subscription]struct{})
+	}
+	h.subs[stream][sub] = struct{}{}
+	return sub
+}
+
+// Publish sends the buff to every subscriber of its stream
+func (h *Hub) Publish(b model.Buff) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subs[b.Stream] {
+		select {
+		case sub.buffs <- b:
+		default:
+			// Too far behind, drop it rather than hold up everyone else
+			h.remove(sub)
+		}
+	}
+}
+
+// PublishResults sends the results of a buff to every subscriber of its stream
+func (h *Hub) PublishResults(stream model.VideoStreamID, res model.Results) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subs[stream] {
+		sub.mu.Lock()
+		sub.results[res.Buff] = res
+		sub.mu.Unlock()
+
+		// A signal already waiting covers these results too
+		select {
+		case sub.resultsReady <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// remove closes the subscription, if it hasn't been removed already
+// The caller must hold the lock
+func (h *Hub) remove(sub *Subscription) {
+	if _, ok := h.subs[sub.stream][sub]; !ok {
+		return
+	}
+
+	delete(h.subs[sub.stream], sub)
+	if len(h.subs[sub.stream]) == 0 {
+		delete(h.subs, sub.stream)
+	}
+	close(sub.buffs)
+}
+
+// Subscription is a subscriber to the buffs of a single stream
+type Subscription struct {
+	hub    *Hub
+	stream model.VideoStreamID
+	buffs  chan model.Buff
+
+	mu           sync.Mutex
+	results      map[model.BuffID]model.Results
+	resultsReady chan struct{}
+}
+
+// Buffs returns the channel the buffs are sent on
+// It is closed when the subscription is closed, or dropped for falling behind
+func (s *Subscription) Buffs() <-chan model.Buff {
+	return s.buffs
+}
+
+// ResultsReady returns a channel that is signalled when there are new results to take
+func (s *Subscription) ResultsReady() <-chan struct{} {
+	return s.resultsReady
+}
+
+// TakeResults returns the latest results of every buff published since they were last taken,
+// in no particular order
+func (s *Subscription) TakeResults() []model.Results {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := make([]model.Results, 0, len(s.results))
+	for id, res := range s.results {
+		results = append(results, res)
+		delete(s.results, id)
+	}
+	return results
+}
+
+// Close stops the subscription, it is safe to call more than once
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	s.hub.remove(s)
+}
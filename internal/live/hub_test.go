@@ -0,0 +1,183 @@
+package live_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/JoeReid/buffassignment/internal/live"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newBuff returns a buff for the given stream, the hub doesn't look at anything else
+func newBuff(stream model.VideoStreamID) model.Buff {
+	return model.Buff{ID: model.BuffID(uuid.New()), Stream: stream}
+}
+
+// receive returns every buff waiting on the subscription, and whether it is still open
+func receive(sub *live.Subscription) ([]model.Buff, bool) {
+	var buffs []model.Buff
+	for {
+		select {
+		case b, ok := <-sub.Buffs():
+			if !ok {
+				return buffs, false
+			}
+			buffs = append(buffs, b)
+		default:
+			return buffs, true
+		}
+	}
+}
+
+func TestHubPublish(t *testing.T) {
+	hub, err := live.NewHub()
+	require.NoError(t, err, "failed to create hub")
+
+	stream := model.VideoStreamID(uuid.New())
+	other := model.VideoStreamID(uuid.New())
+
+	first := hub.Subscribe(stream)
+	defer first.Close()
+	second := hub.Subscribe(stream)
+	defer second.Close()
+	elsewhere := hub.Subscribe(other)
+	defer elsewhere.Close()
+
+	b := newBuff(stream)
+	hub.Publish(b)
+
+	// Every subscriber of the stream gets the buff, and no one else
+	for _, sub := range []*live.Subscription{first, second} {
+		buffs, open := receive(sub)
+		assert.True(t, open, "the subscription should still be open")
+		assert.Equal(t, []model.Buff{b}, buffs)
+	}
+
+	buffs, open := receive(elsewhere)
+	assert.True(t, open, "the subscription should still be open")
+	assert.Empty(t, buffs)
+}
+
+func TestHubDropsSlowSubscribers(t *testing.T) {
+	hub, err := live.NewHub(live.SubscriberBuffer(2))
+	require.NoError(t, err, "failed to create hub")
+
+	stream := model.VideoStreamID(uuid.New())
+	slow := hub.Subscribe(stream)
+	defer slow.Close()
+
+	published := []model.Buff{newBuff(stream), newBuff(stream), newBuff(stream)}
+	for _, b := range published {
+		hub.Publish(b)
+	}
+
+	// The buffered buffs are still delivered before the channel is closed
+	buffs, open := receive(slow)
+	assert.False(t, open, "the slow subscription should have been dropped")
+	assert.Equal(t, published[:2], buffs)
+
+	// Closing a dropped subscription is harmless
+	slow.Close()
+
+	// A new subscriber is unaffected
+	fresh := hub.Subscribe(stream)
+	defer fresh.Close()
+
+	hub.Publish(published[2])
+	buffs, open = receive(fresh)
+	assert.True(t, open, "the new subscription should be open")
+	assert.Equal(t, published[2:], buffs)
+}
+
+func TestHubClose(t *testing.T) {
+	hub, err := live.NewHub()
+	require.NoError(t, err, "failed to create hub")
+
+	stream := model.VideoStreamID(uuid.New())
+	sub := hub.Subscribe(stream)
+	sub.Close()
+	sub.Close()
+
+	// Publishing to a stream without subscribers is fine too
+	hub.Publish(newBuff(stream))
+
+	_, open := receive(sub)
+	assert.False(t, open, "the subscription should be closed")
+}
+
+func TestHubPublishResults(t *testing.T) {
+	hub, err := live.NewHub(live.SubscriberBuffer(1))
+	require.NoError(t, err, "failed to create hub")
+
+	stream := model.VideoStreamID(uuid.New())
+	sub := hub.Subscribe(stream)
+	defer sub.Close()
+	elsewhere := hub.Subscribe(model.VideoStreamID(uuid.New()))
+	defer elsewhere.Close()
+
+	first := model.BuffID(uuid.New())
+	second := model.BuffID(uuid.New())
+
+	// Only the latest results of each buff are kept, however many are published
+	hub.PublishResults(stream, model.Results{Buff: first, Total: 1})
+	hub.PublishResults(stream, model.Results{Buff: first, Total: 2})
+	hub.PublishResults(stream, model.Results{Buff: second, Total: 1})
+
+	select {
+	case <-sub.ResultsReady():
+	default:
+		t.Fatal("the subscriber should have been told about the results")
+	}
+	assert.ElementsMatch(t, []model.Results{{Buff: first, Total: 2}, {Buff: second, Total: 1}}, sub.TakeResults())
+	assert.Empty(t, sub.TakeResults(), "the results should only be taken once")
+
+	select {
+	case <-elsewhere.ResultsReady():
+		t.Fatal("the subscriber of another stream should not be told about the results")
+	default:
+	}
+
+	// Results never count towards the buffer of buffs
+	hub.Publish(newBuff(stream))
+	buffs, open := receive(sub)
+	assert.True(t, open, "the subscription should still be open")
+	assert.Len(t, buffs, 1)
+}
+
+func TestHubConcurrent(t *testing.T) {
+	hub, err := live.NewHub(live.SubscriberBuffer(100))
+	require.NoError(t, err, "failed to create hub")
+
+	stream := model.VideoStreamID(uuid.New())
+	sub := hub.Subscribe(stream)
+	defer sub.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// Subscribers come and go while the buffs are published
+			other := hub.Subscribe(stream)
+			defer other.Close()
+
+			for j := 0; j < 10; j++ {
+				hub.Publish(newBuff(stream))
+			}
+		}()
+	}
+	wg.Wait()
+
+	buffs, open := receive(sub)
+	assert.True(t, open, "the subscription should still be open")
+	assert.Len(t, buffs, 100)
+}
+
+func TestHubOptions(t *testing.T) {
+	_, err := live.NewHub(live.SubscriberBuffer(0))
+	assert.Error(t, err)
+}
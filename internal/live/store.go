@@ -0,0 +1,86 @@
+package live
+
+import (
+	"context"
+
+	"github.com/JoeReid/apiutils/tracer"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/opentracing/opentracing-go"
+)
+
+// NewStore returns the store with every buff created through it, and the results
+// of every response given through it, published to p
+//
+// Buffs are published once, as they are created, whatever their schedule. A scheduled
+// buff is not published again as it opens, clients place it on the timeline themselves.
+//
+// It is meant for stores that can't tell others about their writes, such as the
+// memory store. The postgres store publishes with ListenBuffs and ListenResults
+// instead, so that the writes made through every replica are seen.
+func NewStore(store model.Store, p Publisher) model.Store {
+	return &notifyingStore{store, p}
+}
+
+// notifyingStore decorates a model.Store, publishing the buffs it creates
+// and the results of the responses it is given
+type notifyingStore struct {
+	model.Store
+	publisher Publisher
+}
+
+// CreateBuff creates the buff in the underlying store, and publishes it once it is stored
+//
+// The buff is read back before it is published, so that subscribers are sent the buff
+// as the store keeps it, the same as it is served everywhere else. The buff has been
+// stored even if it can't be read back, so that doesn't fail the call, it is just not published,
+// and the error is recorded on the span instead.
+func (s *notifyingStore) CreateBuff(ctx context.Context, b model.Buff) error {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Live:Create Buff")
+	defer sp.Finish()
+
+	if err := s.Store.CreateBuff(ctx, b); err != nil {
+		return err
+	}
+
+	stored, err := s.Store.GetBuff(ctx, b.ID)
+	if err != nil {
+		tracer.Log(sp, "failed to read back the created buff, it is not published")
+		tracer.SetError(sp, err)
+		return nil
+	}
+
+	s.publisher.Publish(*stored)
+	return nil
+}
+
+// CreateResponse creates the response in the underlying store, and publishes
+// the new results of its buff once it is stored
+//
+// The response has been stored even if the results can't be read back,
+// so that doesn't fail the call, the results are just not published, and the error is
+// recorded on the span instead.
+func (s *notifyingStore) CreateResponse(ctx context.Context, r model.Response) error {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Live:Create Response")
+	defer sp.Finish()
+
+	if err := s.Store.CreateResponse(ctx, r); err != nil {
+		return err
+	}
+
+	b, err := s.Store.GetBuff(ctx, r.Buff)
+	if err != nil {
+		tracer.Log(sp, "failed to read back the buff responded to, its results are not published")
+		tracer.SetError(sp, err)
+		return nil
+	}
+
+	res, err := s.Store.GetResults(ctx, r.Buff)
+	if err != nil {
+		tracer.Log(sp, "failed to read back the results, they are not published")
+		tracer.SetError(sp, err)
+		return nil
+	}
+
+	s.publisher.PublishResults(b.Stream, *res)
+	return nil
+}
@@ -0,0 +1,159 @@
+package live_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/JoeReid/buffassignment/internal/live"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/model/memory"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorePublishesCreatedBuffs(t *testing.T) {
+	hub, err := live.NewHub()
+	require.NoError(t, err, "failed to create hub")
+
+	mem, err := memory.NewStore()
+	require.NoError(t, err, "failed to create store")
+	store := live.NewStore(mem, hub)
+
+	v := model.VideoStream{
+		ID:        model.VideoStreamID(uuid.New()),
+		Title:     "a stream",
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+	require.NoError(t, store.CreateVideoStream(context.Background(), v), "failed to create video stream")
+
+	sub := hub.Subscribe(v.ID)
+	defer sub.Close()
+
+	b := model.Buff{
+		ID:       model.BuffID(uuid.New()),
+		Stream:   v.ID,
+		Question: "What is the meaning of life, the universe, and everything?",
+		Answers: []model.Answer{
+			{ID: model.AnswerID(uuid.New()), Text: "42", Correct: true},
+			{ID: model.AnswerID(uuid.New()), Text: "43", Correct: false},
+		},
+		Tags:      []string{"sport", "football"},
+		CreatedAt: time.Now().UTC(),
+	}
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	// A buff that isn't stored isn't published
+	err = store.CreateBuff(context.Background(), b)
+	require.True(t, errors.Is(err, model.ErrConflict), "expected ErrConflict, got %v", err)
+
+	// Everything else goes straight to the underlying store
+	got, err := store.GetBuff(context.Background(), b.ID)
+	require.NoError(t, err, "failed to get buff")
+	assert.Equal(t, b.Question, got.Question)
+
+	// The buff is published as it was stored, as a quiz with its tags in order
+	assert.Equal(t, model.BuffQuiz, got.Kind)
+	assert.Equal(t, []string{"football", "sport"}, got.Tags)
+
+	buffs, open := receive(sub)
+	assert.True(t, open, "the subscription should still be open")
+	assert.Equal(t, []model.Buff{*got}, buffs)
+}
+
+func TestStorePublishesScheduledBuffsOnce(t *testing.T) {
+	hub, err := live.NewHub()
+	require.NoError(t, err, "failed to create hub")
+
+	mem, err := memory.NewStore()
+	require.NoError(t, err, "failed to create store")
+	store := live.NewStore(mem, hub)
+
+	v := model.VideoStream{
+		ID:        model.VideoStreamID(uuid.New()),
+		Title:     "a stream",
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+	require.NoError(t, store.CreateVideoStream(context.Background(), v), "failed to create video stream")
+	require.NoError(t, store.TransitionVideoStream(context.Background(), v.ID, model.StreamLive), "failed to start video stream")
+
+	sub := hub.Subscribe(v.ID)
+	defer sub.Close()
+
+	// The buff opens shortly after it is created
+	b := model.Buff{
+		ID:       model.BuffID(uuid.New()),
+		Stream:   v.ID,
+		Question: "What is the meaning of life, the universe, and everything?",
+		Answers: []model.Answer{
+			{ID: model.AnswerID(uuid.New()), Text: "42", Correct: true},
+			{ID: model.AnswerID(uuid.New()), Text: "43", Correct: false},
+		},
+		Schedule:  &model.Schedule{Offset: 50 * time.Millisecond, Duration: time.Minute},
+		CreatedAt: time.Now().UTC(),
+	}
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	// It is published once, as it is created, with the schedule telling clients when it opens
+	buffs, open := receive(sub)
+	assert.True(t, open, "the subscription should still be open")
+	require.Len(t, buffs, 1)
+	assert.Equal(t, b.Schedule, buffs[0].Schedule)
+
+	// And isn't published again as it opens
+	time.Sleep(100 * time.Millisecond)
+	buffs, open = receive(sub)
+	assert.True(t, open, "the subscription should still be open")
+	assert.Empty(t, buffs, "the buff should not be published again once it opens")
+}
+
+func TestStorePublishesResults(t *testing.T) {
+	hub, err := live.NewHub()
+	require.NoError(t, err, "failed to create hub")
+
+	mem, err := memory.NewStore()
+	require.NoError(t, err, "failed to create store")
+	store := live.NewStore(mem, hub)
+
+	v := model.VideoStream{
+		ID:        model.VideoStreamID(uuid.New()),
+		Title:     "a stream",
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+	require.NoError(t, store.CreateVideoStream(context.Background(), v), "failed to create video stream")
+	require.NoError(t, store.TransitionVideoStream(context.Background(), v.ID, model.StreamLive), "failed to start video stream")
+
+	b := model.Buff{
+		ID:       model.BuffID(uuid.New()),
+		Stream:   v.ID,
+		Question: "What is the meaning of life, the universe, and everything?",
+		Answers: []model.Answer{
+			{ID: model.AnswerID(uuid.New()), Text: "42", Correct: true},
+			{ID: model.AnswerID(uuid.New()), Text: "43", Correct: false},
+		},
+		CreatedAt: time.Now().UTC(),
+	}
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	sub := hub.Subscribe(v.ID)
+	defer sub.Close()
+
+	r := model.Response{Buff: b.ID, User: "user-42", Answer: b.Answers[0].ID, CreatedAt: time.Now().UTC()}
+	require.NoError(t, store.CreateResponse(context.Background(), r), "failed to create response")
+
+	// A response that isn't stored doesn't publish anything
+	err = store.CreateResponse(context.Background(), r)
+	require.True(t, errors.Is(err, model.ErrConflict), "expected ErrConflict, got %v", err)
+
+	<-sub.ResultsReady()
+	results := sub.TakeResults()
+	require.Len(t, results, 1)
+	assert.Equal(t, b.ID, results[0].Buff)
+	assert.Equal(t, 1, results[0].Total)
+	assert.Empty(t, sub.TakeResults(), "the conflicting response should not publish results")
+}
@@ -1,6 +1,12 @@
 package model
 
-import "github.com/google/uuid"
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // BuffStore defines all the actions needed to implement a buff storage layer
 // This could be implemented by:
@@ -10,14 +16,60 @@ import "github.com/google/uuid"
 //
 // Genericising the storage actions in this way makes the code considerably
 // easier to re-factor with respect to storage sub-systems, should they need to change
+//
+// Every action takes a context, which implementations should use to cancel
+// in-flight work and to parent any tracing spans they create
+//
+// The After variants of the list actions return the items following the
+// given Cursor, or the first page if it is nil. The lists only hold the
+// buffs matching the BuffQuery.
+//
+// UpdateBuff returns a *BuffResolvedError for a buff that has been resolved,
+// as the responses have been scored against its answers.
+//
+// ResolveBuff settles a prediction with Buff.Resolve, as a single atomic change, and
+// returns the buff as resolved. The Responses and Correct of the Resolution are counted
+// by the store from the responses it holds, rather than taken from the caller.
+//
+// ListBuffActiveForStream returns every buff of the stream that is active at the
+// given offset into the stream, see Schedule.ActiveAt. Buffs without a schedule
+// are never active.
+//
+// SearchBuffs returns the buffs with a question or answer matching the query,
+// best match first. How the text is matched is up to the store, so long as the
+// buffs matching in their question rank above those only matching in an answer.
+// A blank query matches nothing.
 type BuffStore interface {
-	GetBuff(BuffID) (*Buff, error)
-	ListBuff(offset, limit int) ([]Buff, error)
-	ListBuffForStream(stream VideoStreamID, offset, limit int) ([]Buff, error)
+	GetBuff(context.Context, BuffID) (*Buff, error)
+	ListBuff(ctx context.Context, query BuffQuery, offset, limit int) ([]Buff, error)
+	ListBuffAfter(ctx context.Context, query BuffQuery, after *Cursor, limit int) ([]Buff, error)
+	ListBuffForStream(ctx context.Context, stream VideoStreamID, query BuffQuery, offset, limit int) ([]Buff, error)
+	ListBuffForStreamAfter(ctx context.Context, stream VideoStreamID, query BuffQuery, after *Cursor, limit int) ([]Buff, error)
+	ListBuffActiveForStream(ctx context.Context, stream VideoStreamID, query BuffQuery, at time.Duration) ([]Buff, error)
+	SearchBuffs(ctx context.Context, query string, offset, limit int) ([]BuffMatch, error)
+
+	CreateBuff(context.Context, Buff) error
+	UpdateBuff(context.Context, BuffID, Buff) error
+	ResolveBuff(ctx context.Context, id BuffID, res Resolution) (*Buff, error)
+	DeleteBuff(context.Context, BuffID) error
+}
+
+// BuffQuery selects the buffs to list
+// The zero value selects every buff
+type BuffQuery struct {
+	// Tags lists the tags a buff must all have, any buff if it is empty
+	Tags []string
+
+	// StreamStates lists the states the stream of a buff can be in, any state if it is empty
+	StreamStates []StreamState
+}
 
-	CreateBuff(Buff) error
-	UpdateBuff(BuffID, Buff) error
-	DeleteBuff(BuffID) error
+// Matches reports whether the buff is selected by the query, given the state of its stream
+func (q BuffQuery) Matches(b Buff, state StreamState) bool {
+	if len(q.StreamStates) != 0 && !hasState(q.StreamStates, state) {
+		return false
+	}
+	return HasTags(b.Tags, q.Tags)
 }
 
 // BuffID is a uuid.UUID type
@@ -41,11 +93,206 @@ func ParseBuffID(s string) (BuffID, error) {
 //
 // This is how we can think about a Buff in the application
 // (separate from the database or API encoding representations)
+//
+// Buffs are listed in the order they were created, and their answers
+// are kept in the order they were given
+//
+// A buff with a Schedule is shown at a set point of its stream,
+// while one without is not placed on the stream's timeline at all
+//
+// Tags are a set, and are always listed in order.
+//
+// The Kind of a buff decides how its answers are judged, a buff without
+// a kind is a BuffQuiz. A BuffPrediction has a Resolution once it is resolved,
+// which is only ever set by Resolve.
 type Buff struct {
-	ID       BuffID
-	Stream   VideoStreamID
-	Question string
-	Answers  []Answer
+	ID         BuffID
+	Stream     VideoStreamID
+	Kind       BuffKind
+	Question   string
+	Answers    []Answer
+	Schedule   *Schedule
+	Resolution *Resolution
+	Tags       []string
+	CreatedAt  time.Time
+}
+
+// Judged reports whether the answers of the buff are marked as correct or not
+// A quiz is always judged, and a prediction once it is resolved. No answer of a poll is ever correct.
+func (b Buff) Judged() bool {
+	return b.Kind == "" || b.Kind == BuffQuiz || b.Resolution != nil
+}
+
+// Resolve settles a BuffPrediction, marking the answer of the resolution as the correct one
+//
+// Resolving a buff again to the same answer keeps the first resolution, so it can be safely
+// retried. A buff that isn't a prediction returns a *BuffKindError, one resolved to another
+// answer returns a *BuffResolvedError, and an answer that isn't one of the buff's wraps
+// ErrInvalidReference. The buff is left untouched on any error.
+func (b *Buff) Resolve(res Resolution) error {
+	if b.Kind != BuffPrediction {
+		return &BuffKindError{Buff: b.ID, Kind: b.Kind}
+	}
+	if b.Resolution != nil {
+		if b.Resolution.Answer != res.Answer {
+			return &BuffResolvedError{Buff: b.ID, Answer: b.Resolution.Answer}
+		}
+		return nil
+	}
+
+	for i := range b.Answers {
+		if b.Answers[i].ID == res.Answer {
+			b.Answers[i].Correct = true
+			b.Resolution = &res
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: answer %s is not an answer to buff %s", ErrInvalidReference, res.Answer, b.ID)
+}
+
+// Resolution records how a BuffPrediction was settled, and who settled it
+//
+// Responses and Correct count the responses that were scored when it was resolved,
+// and how many of them gave the winning answer. No more responses are accepted once
+// a buff is resolved, so they are its final tally.
+type Resolution struct {
+	Answer     AnswerID
+	ResolvedBy string
+	ResolvedAt time.Time
+	Responses  int
+	Correct    int
+}
+
+// BuffKindError should be returned when a buff is asked to do something its kind can't,
+// such as resolving a buff that isn't a BuffPrediction
+//
+// It is a kind of ErrConflict, so errors.Is(err, ErrConflict) is true for it
+type BuffKindError struct {
+	Buff BuffID
+	Kind BuffKind
+}
+
+// Error implements the error interface
+func (e *BuffKindError) Error() string {
+	return fmt.Sprintf("buff %s is a %s, only a prediction can be resolved", e.Buff, e.Kind)
+}
+
+// Is reports whether the error is an ErrConflict
+func (e *BuffKindError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// BuffResolvedError should be returned when a resolved buff is asked to change,
+// whether by being resolved to another answer, updated, or responded to
+//
+// It is a kind of ErrConflict, so errors.Is(err, ErrConflict) is true for it
+type BuffResolvedError struct {
+	Buff   BuffID
+	Answer AnswerID
+}
+
+// Error implements the error interface
+func (e *BuffResolvedError) Error() string {
+	return fmt.Sprintf("buff %s has already been resolved to answer %s", e.Buff, e.Answer)
+}
+
+// Is reports whether the error is an ErrConflict
+func (e *BuffResolvedError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// BuffClosedError should be returned when a buff is responded to after it has closed,
+// see Buff.ClosedBy, as its correct answers are shown to every viewer by then
+//
+// It is a kind of ErrConflict, so errors.Is(err, ErrConflict) is true for it
+type BuffClosedError struct {
+	Buff BuffID
+}
+
+// Error implements the error interface
+func (e *BuffClosedError) Error() string {
+	return fmt.Sprintf("buff %s has closed, and takes no more responses", e.Buff)
+}
+
+// Is reports whether the error is an ErrConflict
+func (e *BuffClosedError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// BuffKind is the kind of question a Buff asks
+type BuffKind string
+
+// The kinds a Buff can be
+const (
+	// BuffQuiz asks a question with an answer known to be correct when it is written
+	BuffQuiz BuffKind = "quiz"
+	// BuffPoll asks for an opinion, so none of its answers are correct
+	BuffPoll BuffKind = "poll"
+	// BuffPrediction asks about something yet to happen, so its correct answer
+	// isn't known when it is written
+	BuffPrediction BuffKind = "prediction"
+)
+
+// BuffKinds lists every BuffKind
+var BuffKinds = []BuffKind{BuffQuiz, BuffPoll, BuffPrediction}
+
+// Valid reports whether the kind is one of the BuffKinds
+func (k BuffKind) Valid() bool {
+	for _, kind := range BuffKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// The markers wrapped around the matched terms of the highlights of a BuffMatch
+const (
+	HighlightStart = "<mark>"
+	HighlightStop  = "</mark>"
+)
+
+// BuffMatch is a buff found by BuffStore.SearchBuffs
+//
+// Rank is how well the buff matched, and is only comparable with the ranks of the
+// same search. A buff matching in its question is listed above those only matching
+// in an answer, whatever their ranks. The highlights hold the text of the question, and of each answer
+// that matched, with the matched terms wrapped in HighlightStart and HighlightStop. The rest of
+// the text is HTML escaped, so that the markers are the only markup in the highlights.
+type BuffMatch struct {
+	Buff              Buff
+	Rank              float64
+	QuestionHighlight string
+	AnswerHighlights  map[AnswerID]string
+}
+
+// Schedule places a Buff on the timeline of its video stream
+//
+// Offset is measured from the start of the stream, so that the buff is
+// shown at the same point of a recording as it was during the live stream.
+type Schedule struct {
+	Offset   time.Duration
+	Duration time.Duration
+}
+
+// End returns the offset into the stream at which the buff closes
+func (s Schedule) End() time.Duration {
+	return s.Offset + s.Duration
+}
+
+// ActiveAt reports whether the buff is open at the given offset into the stream
+// A buff opens at its Offset, and is closed from its End onwards
+func (s Schedule) ActiveAt(at time.Duration) bool {
+	return s.Offset <= at && at < s.End()
+}
+
+// ClosedBy reports whether the buff has closed by the given time, on a stream that started at started
+// A buff without a schedule never closes, and nor does a buff of a stream that hasn't started.
+func (b Buff) ClosedBy(started *time.Time, at time.Time) bool {
+	if b.Schedule == nil || started == nil {
+		return false
+	}
+	return !at.Before(started.Add(b.Schedule.End()))
 }
 
 // Answer defines the abstract representation of the Answer type in the data model
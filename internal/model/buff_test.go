@@ -1,7 +1,9 @@
 package model_test
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/JoeReid/buffassignment/internal/model"
 	"github.com/google/uuid"
@@ -46,3 +48,140 @@ func TestAnswerIDString(t *testing.T) {
 	_, err := uuid.Parse(id.String())
 	require.NoError(t, err, "failed to parse UUID")
 }
+
+func TestScheduleActiveAt(t *testing.T) {
+	s := model.Schedule{Offset: time.Minute, Duration: 30 * time.Second}
+	assert.Equal(t, 90*time.Second, s.End())
+
+	var tests = []struct {
+		name   string
+		at     time.Duration
+		expect bool
+	}{
+		{name: "before the buff opens", at: 59 * time.Second, expect: false},
+		{name: "as the buff opens", at: time.Minute, expect: true},
+		{name: "while the buff is open", at: 75 * time.Second, expect: true},
+		{name: "as the buff closes", at: 90 * time.Second, expect: false},
+		{name: "after the buff closes", at: time.Hour, expect: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, s.ActiveAt(tt.at))
+		})
+	}
+}
+
+func TestBuffClosedBy(t *testing.T) {
+	started := time.Now()
+	scheduled := model.Buff{Schedule: &model.Schedule{Offset: time.Minute, Duration: 30 * time.Second}}
+
+	var tests = []struct {
+		name    string
+		buff    model.Buff
+		started *time.Time
+		at      time.Duration
+		expect  bool
+	}{
+		{name: "while the buff is open", buff: scheduled, started: &started, at: 75 * time.Second, expect: false},
+		{name: "as the buff closes", buff: scheduled, started: &started, at: 90 * time.Second, expect: true},
+		{name: "after the buff closes", buff: scheduled, started: &started, at: time.Hour, expect: true},
+		{name: "stream not started", buff: scheduled, at: time.Hour, expect: false},
+		{name: "without a schedule", buff: model.Buff{}, started: &started, at: time.Hour, expect: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, tt.buff.ClosedBy(tt.started, started.Add(tt.at)))
+		})
+	}
+}
+
+func TestBuffQueryMatches(t *testing.T) {
+	live := []model.StreamState{model.StreamLive}
+
+	assert.True(t, model.BuffQuery{}.Matches(model.Buff{}, model.StreamEnded))
+	assert.True(t, model.BuffQuery{StreamStates: live}.Matches(model.Buff{}, model.StreamLive))
+	assert.False(t, model.BuffQuery{StreamStates: live}.Matches(model.Buff{}, model.StreamScheduled))
+	assert.False(t, model.BuffQuery{StreamStates: live}.Matches(model.Buff{}, model.StreamEnded))
+	assert.False(t, model.BuffQuery{StreamStates: live, Tags: []string{"trivia"}}.Matches(model.Buff{}, model.StreamLive))
+}
+
+func TestBuffKindValid(t *testing.T) {
+	for _, kind := range model.BuffKinds {
+		assert.True(t, kind.Valid(), "%q should be valid", kind)
+	}
+	assert.False(t, model.BuffKind("").Valid(), "an empty kind should not be valid")
+	assert.False(t, model.BuffKind("survey").Valid(), "an unknown kind should not be valid")
+}
+
+func TestBuffJudged(t *testing.T) {
+	var tests = []struct {
+		kind   model.BuffKind
+		expect bool
+	}{
+		{kind: "", expect: true},
+		{kind: model.BuffQuiz, expect: true},
+		{kind: model.BuffPoll, expect: false},
+		{kind: model.BuffPrediction, expect: false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(string(tt.kind), func(t *testing.T) {
+			assert.Equal(t, tt.expect, model.Buff{Kind: tt.kind}.Judged())
+		})
+	}
+
+	resolved := model.Buff{Kind: model.BuffPrediction, Resolution: &model.Resolution{}}
+	assert.True(t, resolved.Judged(), "a resolved prediction is judged")
+}
+
+func TestBuffResolve(t *testing.T) {
+	b := model.Buff{
+		ID:   model.BuffID(uuid.New()),
+		Kind: model.BuffPrediction,
+		Answers: []model.Answer{
+			{ID: model.AnswerID(uuid.New()), Text: "yes"},
+			{ID: model.AnswerID(uuid.New()), Text: "no"},
+		},
+	}
+
+	// An answer of another buff leaves the buff unresolved
+	err := b.Resolve(model.Resolution{Answer: model.AnswerID(uuid.New())})
+	assert.True(t, errors.Is(err, model.ErrInvalidReference))
+	assert.Nil(t, b.Resolution)
+
+	first := model.Resolution{Answer: b.Answers[1].ID, ResolvedBy: "alice", ResolvedAt: time.Now().UTC()}
+	require.NoError(t, b.Resolve(first))
+	assert.Equal(t, &first, b.Resolution)
+	assert.False(t, b.Answers[0].Correct)
+	assert.True(t, b.Answers[1].Correct)
+
+	// Resolving to the same answer again keeps the first resolution
+	require.NoError(t, b.Resolve(model.Resolution{Answer: b.Answers[1].ID, ResolvedBy: "bob"}))
+	assert.Equal(t, &first, b.Resolution)
+
+	// Another answer is refused, and the buff is left as it was
+	err = b.Resolve(model.Resolution{Answer: b.Answers[0].ID, ResolvedBy: "bob"})
+	assert.Equal(t, &model.BuffResolvedError{Buff: b.ID, Answer: b.Answers[1].ID}, err)
+	assert.Equal(t, &first, b.Resolution)
+	assert.False(t, b.Answers[0].Correct)
+
+	quiz := model.Buff{ID: model.BuffID(uuid.New()), Kind: model.BuffQuiz, Answers: b.Answers}
+	err = quiz.Resolve(model.Resolution{Answer: b.Answers[0].ID})
+	assert.Equal(t, &model.BuffKindError{Buff: quiz.ID, Kind: model.BuffQuiz}, err)
+	assert.Nil(t, quiz.Resolution)
+}
+
+func TestBuffErrorsAreConflicts(t *testing.T) {
+	id := model.BuffID(uuid.New())
+
+	for _, err := range []error{
+		&model.BuffKindError{Buff: id, Kind: model.BuffPoll},
+		&model.BuffResolvedError{Buff: id, Answer: model.AnswerID(uuid.New())},
+	} {
+		assert.True(t, errors.Is(err, model.ErrConflict))
+		assert.False(t, errors.Is(err, model.ErrNotFound))
+	}
+}
@@ -0,0 +1,75 @@
+package model
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidCursor is returned by ParseCursor when the given string
+// was not built by Cursor.String
+var ErrInvalidCursor = errors.New("the cursor is not valid")
+
+// Cursor marks a position in a list ordered by creation time, oldest first,
+// with the id breaking any ties
+//
+// Listing the items after a cursor (keyset pagination) stays fast however
+// deep into the list it is, and doesn't skip or repeat items when others
+// are created or deleted between pages.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// Cursor returns the position of the VideoStream in the list of streams
+func (v VideoStream) Cursor() Cursor {
+	return Cursor{CreatedAt: v.CreatedAt, ID: uuid.UUID(v.ID)}
+}
+
+// Cursor returns the position of the Buff in the lists of buffs
+func (b Buff) Cursor() Cursor {
+	return Cursor{CreatedAt: b.CreatedAt, ID: uuid.UUID(b.ID)}
+}
+
+// String returns an opaque representation of the cursor, safe to use in a URL
+// It can be turned back into a Cursor with ParseCursor
+func (c Cursor) String() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "," + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseCursor will return a new Cursor parsed from it's string representation
+// The string is expected in the format returned by Cursor.String
+func ParseCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), ",", 2)
+	if len(parts) != 2 {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	created, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	id, err := uuid.Parse(parts[1])
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return Cursor{CreatedAt: created, ID: id}, nil
+}
+
+// Less reports whether the position of c comes before the position of o
+func (c Cursor) Less(o Cursor) bool {
+	if !c.CreatedAt.Equal(o.CreatedAt) {
+		return c.CreatedAt.Before(o.CreatedAt)
+	}
+	return strings.Compare(c.ID.String(), o.ID.String()) < 0
+}
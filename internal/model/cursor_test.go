@@ -0,0 +1,78 @@
+package model_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	c := model.Cursor{
+		CreatedAt: time.Date(2020, 6, 1, 12, 30, 0, 123456000, time.UTC),
+		ID:        uuid.New(),
+	}
+
+	parsed, err := model.ParseCursor(c.String())
+	require.NoError(t, err, "failed to parse cursor")
+
+	assert.True(t, c.CreatedAt.Equal(parsed.CreatedAt), "created at: expected %s, got %s", c.CreatedAt, parsed.CreatedAt)
+	assert.Equal(t, c.ID, parsed.ID)
+}
+
+func TestParseCursorInvalid(t *testing.T) {
+	var tests = []struct {
+		name   string
+		cursor string
+	}{
+		{name: "empty", cursor: ""},
+		{name: "not base64", cursor: "not a cursor!"},
+		{name: "no separator", cursor: "bm8gc2VwYXJhdG9y"},
+		{name: "bad time", cursor: "bm90IGEgdGltZSwwMDAwMDAwMC0wMDAwLTAwMDAtMDAwMC0wMDAwMDAwMDAwMDA"},
+		{name: "bad id", cursor: "MjAyMC0wNi0wMVQxMjozMDowMFosbm90IGFuIGlk"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := model.ParseCursor(tt.cursor)
+			assert.Equal(t, model.ErrInvalidCursor, err)
+		})
+	}
+}
+
+func TestCursorLess(t *testing.T) {
+	now := time.Now()
+	low := uuid.MustParse("00000000-0000-4000-8000-000000000000")
+	high := uuid.MustParse("ffffffff-0000-4000-8000-000000000000")
+
+	var tests = []struct {
+		name   string
+		a, b   model.Cursor
+		expect bool
+	}{
+		{name: "older", a: model.Cursor{now.Add(-time.Second), high}, b: model.Cursor{now, low}, expect: true},
+		{name: "newer", a: model.Cursor{now, low}, b: model.Cursor{now.Add(-time.Second), high}, expect: false},
+		{name: "tie on time, lower id", a: model.Cursor{now, low}, b: model.Cursor{now, high}, expect: true},
+		{name: "tie on time, higher id", a: model.Cursor{now, high}, b: model.Cursor{now, low}, expect: false},
+		{name: "equal", a: model.Cursor{now, low}, b: model.Cursor{now, low}, expect: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, tt.a.Less(tt.b))
+		})
+	}
+}
+
+func TestVideoStreamAndBuffCursor(t *testing.T) {
+	now := time.Now()
+
+	v := model.VideoStream{ID: model.VideoStreamID(uuid.New()), CreatedAt: now}
+	assert.Equal(t, model.Cursor{CreatedAt: now, ID: uuid.UUID(v.ID)}, v.Cursor())
+
+	b := model.Buff{ID: model.BuffID(uuid.New()), CreatedAt: now}
+	assert.Equal(t, model.Cursor{CreatedAt: now, ID: uuid.UUID(b.ID)}, b.Cursor())
+}
@@ -0,0 +1,130 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// LeaderboardStore defines all the actions needed to implement a leaderboard storage layer
+// This could be implemented by:
+//   - A relational database (for production)
+//   - A mock implementation (for testing)
+//   - An RPC backend (for unforeseen future developments)
+//
+// Genericising the storage actions in this way makes the code considerably
+// easier to re-factor with respect to storage sub-systems, should they need to change
+//
+// Every action takes a context, which implementations should use to cancel
+// in-flight work and to parent any tracing spans they create
+//
+// The scores are kept up to date as responses are given and scored, rather than
+// worked out from every response when they are read, as a stream can have
+// hundreds of thousands of users taking part.
+//
+// ListLeaderboard returns the top scores of the stream, best first, ranked from 1.
+// A top of 0 lists every score. GetScore returns the score of a single user, ranked
+// against every other user of the stream.
+//
+// Both return ErrNotFound if the stream doesn't exist, and GetScore if the user
+// hasn't responded to any of the stream's buffs. A stream nobody has responded
+// to has an empty leaderboard.
+type LeaderboardStore interface {
+	ListLeaderboard(ctx context.Context, stream VideoStreamID, top int) ([]Score, error)
+	GetScore(ctx context.Context, stream VideoStreamID, user UserID) (*Score, error)
+}
+
+// Score is the standing of a user on the leaderboard of a VideoStream
+//
+// Every response the user has given to the buffs of the stream counts towards it,
+// Correct of them earning the points set by the Scoring of the store. Responses to a
+// poll, or to a prediction that is yet to be resolved, are not correct.
+//
+// ScoredAt is when the user gave the response that last earned them points, or
+// their first response if none have. Users on the same points are ranked by it,
+// so that the one who reached them first is placed above.
+type Score struct {
+	User      UserID
+	Rank      int
+	Points    int
+	Correct   int
+	Responses int
+	ScoredAt  time.Time
+}
+
+// RanksAbove reports whether the score is placed above the other on a leaderboard
+// Users with more points rank higher, then the earliest ScoredAt, and then the lowest
+// user ID, so that no two users share a rank.
+func (s Score) RanksAbove(o Score) bool {
+	if s.Points != o.Points {
+		return s.Points > o.Points
+	}
+	if !s.ScoredAt.Equal(o.ScoredAt) {
+		return s.ScoredAt.Before(o.ScoredAt)
+	}
+	return s.User < o.User
+}
+
+// Add counts a response towards the score, given at the given time and earning the given points
+func (s *Score) Add(points int, at time.Time) {
+	s.Responses++
+
+	switch {
+	case points > 0:
+		s.Points += points
+		s.Correct++
+
+		// The first points earned replace the time of the first response
+		if s.Correct == 1 || at.After(s.ScoredAt) {
+			s.ScoredAt = at
+		}
+	case s.Correct == 0:
+		if s.Responses == 1 || at.Before(s.ScoredAt) {
+			s.ScoredAt = at
+		}
+	}
+}
+
+// Scoring sets how many points a correct response to a buff earns
+//
+// Every correct response earns Points. One to a buff with a Schedule, on a stream that has
+// started, earns up to SpeedBonus more, all of it the moment the buff opens and falling
+// evenly to nothing by the time it closes. A response that isn't correct earns nothing.
+type Scoring struct {
+	Points     int
+	SpeedBonus int
+}
+
+// DefaultScoring is used by the stores when they aren't given a Scoring of their own
+var DefaultScoring = Scoring{Points: 100}
+
+// Score returns the points the response to the buff earns, on a stream that started at the
+// given time, or nil if it hasn't
+//
+// Times are counted in whole milliseconds, as the schedule of a buff is stored in them.
+func (s Scoring) Score(b Buff, started *time.Time, resp Response) int {
+	correct := false
+	for _, ans := range b.Answers {
+		if ans.ID == resp.Answer {
+			correct = ans.Correct
+		}
+	}
+	if !correct {
+		return 0
+	}
+
+	if s.SpeedBonus == 0 || b.Schedule == nil || b.Schedule.Duration.Milliseconds() <= 0 || started == nil {
+		return s.Points
+	}
+
+	// Responses given before the buff opens, as the stream is catching up, are as fast as can be
+	elapsed := resp.CreatedAt.Sub(*started).Milliseconds() - b.Schedule.Offset.Milliseconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	duration := b.Schedule.Duration.Milliseconds()
+	if elapsed >= duration {
+		return s.Points
+	}
+	return s.Points + int(int64(s.SpeedBonus)*(duration-elapsed)/duration)
+}
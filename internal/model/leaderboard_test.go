@@ -0,0 +1,231 @@
+package model_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScoreRanksAbove(t *testing.T) {
+	now := time.Now()
+
+	var tests = []struct {
+		name   string
+		score  model.Score
+		other  model.Score
+		expect bool
+	}{
+		{
+			name:   "more points",
+			score:  model.Score{User: "bob", Points: 200, ScoredAt: now.Add(time.Minute)},
+			other:  model.Score{User: "alice", Points: 100, ScoredAt: now},
+			expect: true,
+		},
+		{
+			name:   "fewer points",
+			score:  model.Score{User: "alice", Points: 100, ScoredAt: now},
+			other:  model.Score{User: "bob", Points: 200, ScoredAt: now.Add(time.Minute)},
+			expect: false,
+		},
+		{
+			name:   "same points scored first",
+			score:  model.Score{User: "bob", Points: 100, ScoredAt: now},
+			other:  model.Score{User: "alice", Points: 100, ScoredAt: now.Add(time.Second)},
+			expect: true,
+		},
+		{
+			name:   "same points scored later",
+			score:  model.Score{User: "alice", Points: 100, ScoredAt: now.Add(time.Second)},
+			other:  model.Score{User: "bob", Points: 100, ScoredAt: now},
+			expect: false,
+		},
+		{
+			name:   "same points at the same time",
+			score:  model.Score{User: "alice", Points: 100, ScoredAt: now},
+			other:  model.Score{User: "bob", Points: 100, ScoredAt: now},
+			expect: true,
+		},
+		{
+			name:   "the same score",
+			score:  model.Score{User: "alice", Points: 100, ScoredAt: now},
+			other:  model.Score{User: "alice", Points: 100, ScoredAt: now},
+			expect: false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, tt.score.RanksAbove(tt.other))
+		})
+	}
+}
+
+func TestScoreAdd(t *testing.T) {
+	now := time.Now()
+
+	type response struct {
+		points int
+		at     time.Time
+	}
+
+	var tests = []struct {
+		name      string
+		responses []response
+		expect    model.Score
+	}{
+		{
+			name:      "nothing scored takes the first response",
+			responses: []response{{0, now.Add(time.Second)}, {0, now}, {0, now.Add(time.Minute)}},
+			expect:    model.Score{Responses: 3, ScoredAt: now},
+		},
+		{
+			name:      "points take the last scoring response",
+			responses: []response{{100, now}, {0, now.Add(time.Minute)}, {150, now.Add(time.Second)}},
+			expect:    model.Score{Points: 250, Correct: 2, Responses: 3, ScoredAt: now.Add(time.Second)},
+		},
+		{
+			name:      "points replace the first response",
+			responses: []response{{0, now}, {100, now.Add(time.Second)}, {0, now.Add(-time.Second)}},
+			expect:    model.Score{Points: 100, Correct: 1, Responses: 3, ScoredAt: now.Add(time.Second)},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var s model.Score
+			for _, resp := range tt.responses {
+				s.Add(resp.points, resp.at)
+			}
+			assert.Equal(t, tt.expect, s)
+		})
+	}
+}
+
+func TestScoringScore(t *testing.T) {
+	started := time.Now()
+	correct, incorrect := model.AnswerID(uuid.New()), model.AnswerID(uuid.New())
+
+	newBuff := func(schedule *model.Schedule) model.Buff {
+		return model.Buff{
+			Answers: []model.Answer{
+				{ID: incorrect, Text: "43"},
+				{ID: correct, Text: "42", Correct: true},
+			},
+			Schedule: schedule,
+		}
+	}
+	scheduled := newBuff(&model.Schedule{Offset: time.Minute, Duration: 10 * time.Second})
+	bonus := model.Scoring{Points: 100, SpeedBonus: 50}
+
+	var tests = []struct {
+		name    string
+		scoring model.Scoring
+		buff    model.Buff
+		started *time.Time
+		answer  model.AnswerID
+		after   time.Duration
+		expect  int
+	}{
+		{
+			name:    "correct",
+			scoring: model.DefaultScoring,
+			buff:    newBuff(nil),
+			answer:  correct,
+			expect:  100,
+		},
+		{
+			name:    "incorrect",
+			scoring: model.DefaultScoring,
+			buff:    newBuff(nil),
+			answer:  incorrect,
+			expect:  0,
+		},
+		{
+			name:    "unknown answer",
+			scoring: model.DefaultScoring,
+			buff:    newBuff(nil),
+			answer:  model.AnswerID(uuid.New()),
+			expect:  0,
+		},
+		{
+			name:    "no bonus without a schedule",
+			scoring: bonus,
+			buff:    newBuff(nil),
+			started: &started,
+			answer:  correct,
+			expect:  100,
+		},
+		{
+			name:    "no bonus before the stream starts",
+			scoring: bonus,
+			buff:    scheduled,
+			answer:  correct,
+			after:   time.Minute,
+			expect:  100,
+		},
+		{
+			name:    "full bonus as the buff opens",
+			scoring: bonus,
+			buff:    scheduled,
+			started: &started,
+			answer:  correct,
+			after:   time.Minute,
+			expect:  150,
+		},
+		{
+			name:    "full bonus before the buff opens",
+			scoring: bonus,
+			buff:    scheduled,
+			started: &started,
+			answer:  correct,
+			after:   30 * time.Second,
+			expect:  150,
+		},
+		{
+			name:    "bonus falls while the buff is open",
+			scoring: bonus,
+			buff:    scheduled,
+			started: &started,
+			answer:  correct,
+			after:   time.Minute + 4*time.Second,
+			expect:  130,
+		},
+		{
+			name:    "bonus is rounded down",
+			scoring: bonus,
+			buff:    scheduled,
+			started: &started,
+			answer:  correct,
+			after:   time.Minute + 9999*time.Millisecond,
+			expect:  100,
+		},
+		{
+			name:    "no bonus once the buff closes",
+			scoring: bonus,
+			buff:    scheduled,
+			started: &started,
+			answer:  correct,
+			after:   2 * time.Minute,
+			expect:  100,
+		},
+		{
+			name:    "no bonus when incorrect",
+			scoring: bonus,
+			buff:    scheduled,
+			started: &started,
+			answer:  incorrect,
+			after:   time.Minute,
+			expect:  0,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			resp := model.Response{Answer: tt.answer, CreatedAt: started.Add(tt.after)}
+			assert.Equal(t, tt.expect, tt.scoring.Score(tt.buff, tt.started, resp))
+		})
+	}
+}
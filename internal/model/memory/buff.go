@@ -0,0 +1,453 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/google/uuid"
+	"github.com/opentracing/opentracing-go"
+)
+
+// GetBuff returns a model.Buff by it's id
+func (s *Store) GetBuff(ctx context.Context, id model.BuffID) (*model.Buff, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Memory:Get Buff")
+	defer sp.Finish()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored, ok := s.buffs[id]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+
+	b := copyBuff(stored)
+	return &b, nil
+}
+
+// ListBuff returns a slice of model.Buff using offset and limit semantics
+// Only the buffs matching the query are returned, ordered by creation time, oldest first
+func (s *Store) ListBuff(ctx context.Context, query model.BuffQuery, offset, limit int) ([]model.Buff, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Memory:List Buff")
+	defer sp.Finish()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.listBuff(func(b model.Buff) bool { return s.matches(query, b) }, offset, limit), nil
+}
+
+// ListBuffForStream returns a slice of model.Buff using offset and limit semantics
+// Where all the returned buffs are ascociated with the given model.VideoStreamID
+// Only the buffs matching the query are returned, ordered by creation time, oldest first
+func (s *Store) ListBuffForStream(ctx context.Context, stream model.VideoStreamID, query model.BuffQuery, offset, limit int) ([]model.Buff, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Memory:List Buff For Stream")
+	defer sp.Finish()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.listBuff(func(b model.Buff) bool { return b.Stream == stream && s.matches(query, b) }, offset, limit), nil
+}
+
+// ListBuffAfter returns a slice of model.Buff using keyset semantics
+// The buffs matching the query after the cursor are returned, or the first buffs if it is nil
+func (s *Store) ListBuffAfter(ctx context.Context, query model.BuffQuery, after *model.Cursor, limit int) ([]model.Buff, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Memory:List Buff After")
+	defer sp.Finish()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.listBuff(func(b model.Buff) bool { return isAfter(b, after) && s.matches(query, b) }, 0, limit), nil
+}
+
+// ListBuffForStreamAfter returns a slice of model.Buff using keyset semantics
+// Where all the returned buffs are ascociated with the given model.VideoStreamID
+// The buffs matching the query after the cursor are returned, or the first buffs if it is nil
+func (s *Store) ListBuffForStreamAfter(
+	ctx context.Context,
+	stream model.VideoStreamID,
+	query model.BuffQuery,
+	after *model.Cursor,
+	limit int,
+) ([]model.Buff, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Memory:List Buff For Stream After")
+	defer sp.Finish()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.listBuff(func(b model.Buff) bool {
+		return b.Stream == stream && isAfter(b, after) && s.matches(query, b)
+	}, 0, limit), nil
+}
+
+// ListBuffActiveForStream returns a slice of model.Buff
+// Where all the returned buffs are ascociated with the given model.VideoStreamID,
+// and are active at the given offset into the stream
+// Only the buffs matching the query are returned, ordered by creation time, oldest first
+func (s *Store) ListBuffActiveForStream(
+	ctx context.Context,
+	stream model.VideoStreamID,
+	query model.BuffQuery,
+	at time.Duration,
+) ([]model.Buff, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Memory:List Buff Active For Stream")
+	defer sp.Finish()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.listBuff(func(b model.Buff) bool {
+		return b.Stream == stream && b.Schedule != nil && b.Schedule.ActiveAt(at) && s.matches(query, b)
+	}, 0, 0), nil
+}
+
+// matches reports whether the buff is selected by the query, given the state of its stream
+// The caller must hold the lock.
+func (s *Store) matches(query model.BuffQuery, b model.Buff) bool {
+	return query.Matches(b, s.streams[b.Stream].State)
+}
+
+// The weights of a match in the question and in an answer, the same as the
+// default weights postgres gives the A and B labels of a search
+const (
+	questionMatchWeight = 1.0
+	answerMatchWeight   = 0.4
+)
+
+// SearchBuffs returns a slice of model.BuffMatch using offset and limit semantics
+//
+// The query is matched naively, as a substring of the question and answers, ignoring case.
+// The buffs matching in their question come first, however many answers the others match.
+// Each group is ordered by rank, where a match in the question adds more than a match in an
+// answer, and buffs with the same rank are ordered by creation time, oldest first.
+func (s *Store) SearchBuffs(ctx context.Context, query string, offset, limit int) ([]model.BuffMatch, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Memory:Search Buffs")
+	defer sp.Finish()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return []model.BuffMatch{}, nil
+	}
+	re := regexp.MustCompile("(?i)" + regexp.QuoteMeta(query))
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matches := make([]model.BuffMatch, 0)
+	inQuestion := make(map[model.BuffID]bool)
+	for _, b := range s.buffs {
+		question := re.FindAllStringIndex(b.Question, -1)
+		m := model.BuffMatch{
+			Rank:              float64(len(question)) * questionMatchWeight,
+			QuestionHighlight: highlight(b.Question, question),
+			AnswerHighlights:  make(map[model.AnswerID]string),
+		}
+
+		for _, a := range b.Answers {
+			if answer := re.FindAllStringIndex(a.Text, -1); len(answer) != 0 {
+				m.Rank += float64(len(answer)) * answerMatchWeight
+				m.AnswerHighlights[a.ID] = highlight(a.Text, answer)
+			}
+		}
+
+		if m.Rank != 0 {
+			m.Buff = copyBuff(b)
+			matches = append(matches, m)
+			inQuestion[b.ID] = len(question) != 0
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if inQuestion[a.Buff.ID] != inQuestion[b.Buff.ID] {
+			return inQuestion[a.Buff.ID]
+		}
+		if a.Rank != b.Rank {
+			return a.Rank > b.Rank
+		}
+		if !a.Buff.CreatedAt.Equal(b.Buff.CreatedAt) {
+			return a.Buff.CreatedAt.Before(b.Buff.CreatedAt)
+		}
+		return lessUUID(uuid.UUID(a.Buff.ID), uuid.UUID(b.Buff.ID))
+	})
+
+	start, end := paginate(len(matches), offset, limit)
+	return matches[start:end], nil
+}
+
+// highlight wraps the matched parts of the text in the highlight markers
+// The matches are the index pairs returned by regexp.FindAllStringIndex.
+// The text itself is HTML escaped, so that only the markers are markup.
+func highlight(text string, matches [][]int) string {
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		sb.WriteString(html.EscapeString(text[last:m[0]]))
+		sb.WriteString(model.HighlightStart)
+		sb.WriteString(html.EscapeString(text[m[0]:m[1]]))
+		sb.WriteString(model.HighlightStop)
+		last = m[1]
+	}
+	sb.WriteString(html.EscapeString(text[last:]))
+	return sb.String()
+}
+
+// isAfter reports whether the buff is listed after the cursor
+// Every buff is after a nil cursor
+func isAfter(b model.Buff, after *model.Cursor) bool {
+	return after == nil || after.Less(b.Cursor())
+}
+
+// listBuff returns a copy of the page of buffs that match the filter
+// The caller must hold at least a read lock
+func (s *Store) listBuff(filter func(model.Buff) bool, offset, limit int) []model.Buff {
+	buffs := make([]model.Buff, 0)
+	for _, b := range s.buffs {
+		if filter(b) {
+			buffs = append(buffs, b)
+		}
+	}
+	sortBuffs(buffs)
+
+	start, end := paginate(len(buffs), offset, limit)
+
+	rtn := make([]model.Buff, 0, end-start)
+	for _, b := range buffs[start:end] {
+		rtn = append(rtn, copyBuff(b))
+	}
+	return rtn
+}
+
+// CreateBuff adds a new buff object into the memory store
+// A buff without a kind is stored as model.BuffQuiz, and it is always stored unresolved
+//
+// The buff is validated first, returning a validation.Errors if it breaks any rules
+func (s *Store) CreateBuff(ctx context.Context, buff model.Buff) error {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Memory:Create Buff")
+	defer sp.Finish()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := s.validator.Buff(buff); err != nil {
+		return err
+	}
+
+	if buff.Kind == "" {
+		buff.Kind = model.BuffQuiz
+	}
+	buff.Resolution = nil
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.buffs[buff.ID]; ok {
+		return fmt.Errorf("%w: buff %s already exists", model.ErrConflict, buff.ID)
+	}
+	if _, ok := s.streams[buff.Stream]; !ok {
+		return fmt.Errorf("%w: video stream %s does not exist", model.ErrInvalidReference, buff.Stream)
+	}
+	if err := s.checkAnswerIDs(buff.ID, buff.Answers); err != nil {
+		return err
+	}
+
+	s.buffs[buff.ID] = copyBuff(buff)
+	for _, ans := range buff.Answers {
+		s.answers[ans.ID] = buff.ID
+	}
+	return nil
+}
+
+// UpdateBuff replaces the Buff with ID model.BuffID with the given object
+//
+// The kind, question text, answers, schedule and tags are replaced, a buff without
+// a kind becoming a model.BuffQuiz. The stream a buff belongs to and its creation
+// time cannot be changed, and a resolved buff cannot be updated at all.
+//
+// The buff is validated first, returning a validation.Errors if it breaks any rules
+func (s *Store) UpdateBuff(ctx context.Context, id model.BuffID, buff model.Buff) error {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Memory:Update Buff")
+	defer sp.Finish()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := s.validator.Buff(buff); err != nil {
+		return err
+	}
+
+	if buff.Kind == "" {
+		buff.Kind = model.BuffQuiz
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.buffs[id]
+	if !ok {
+		return model.ErrNotFound
+	}
+	if stored.Resolution != nil {
+		return &model.BuffResolvedError{Buff: id, Answer: stored.Resolution.Answer}
+	}
+	if err := s.checkAnswerIDs(id, buff.Answers); err != nil {
+		return err
+	}
+
+	for _, ans := range stored.Answers {
+		delete(s.answers, ans.ID)
+	}
+	for _, ans := range buff.Answers {
+		s.answers[ans.ID] = id
+	}
+
+	// Responses to answers that have been removed go with them, but their users are
+	// still rescored, as they may have nothing left on the stream's leaderboard
+	users := responders(s.responses[id])
+	for user, resp := range s.responses[id] {
+		if s.answers[resp.Answer] != id {
+			delete(s.responses[id], user)
+		}
+	}
+
+	updated := copyBuff(buff)
+	stored.Kind = updated.Kind
+	stored.Question = updated.Question
+	stored.Answers = updated.Answers
+	stored.Schedule = updated.Schedule
+	stored.Tags = updated.Tags
+	s.buffs[id] = stored
+
+	// The answers that are correct, and the schedule, may have changed
+	s.rescoreBuff(id, users)
+	return nil
+}
+
+// ResolveBuff settles the prediction with ID model.BuffID, counting the responses it has been given
+// The resolution is validated first, returning a validation.Errors if it breaks any rules
+func (s *Store) ResolveBuff(ctx context.Context, id model.BuffID, res model.Resolution) (*model.Buff, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Memory:Resolve Buff")
+	defer sp.Finish()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.validator.Resolution(res); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.buffs[id]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+
+	res.Responses, res.Correct = len(s.responses[id]), 0
+	for _, resp := range s.responses[id] {
+		if resp.Answer == res.Answer {
+			res.Correct++
+		}
+	}
+
+	if err := stored.Resolve(res); err != nil {
+		return nil, err
+	}
+	s.buffs[id] = stored
+	s.rescoreBuff(id, responders(s.responses[id]))
+
+	b := copyBuff(stored)
+	return &b, nil
+}
+
+// DeleteBuff deletes the Buff with ID model.BuffID, along with all of its answers and responses
+func (s *Store) DeleteBuff(ctx context.Context, id model.BuffID) error {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Memory:Delete Buff")
+	defer sp.Finish()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.buffs[id]; !ok {
+		return model.ErrNotFound
+	}
+
+	s.deleteBuff(id)
+	return nil
+}
+
+// deleteBuff removes the buff, its answers and its responses,
+// and takes the responses off the leaderboard of its stream
+// The caller must hold the write lock
+func (s *Store) deleteBuff(id model.BuffID) {
+	stream := s.buffs[id].Stream
+	users := responders(s.responses[id])
+
+	for _, ans := range s.buffs[id].Answers {
+		delete(s.answers, ans.ID)
+	}
+	delete(s.responses, id)
+	delete(s.points, id)
+	delete(s.buffs, id)
+
+	for _, user := range users {
+		s.refreshScore(stream, user)
+	}
+}
+
+// checkAnswerIDs returns an error if any of the answers are already
+// stored against a buff other than the given one
+// The caller must hold at least a read lock
+func (s *Store) checkAnswerIDs(id model.BuffID, answers []model.Answer) error {
+	for _, ans := range answers {
+		if owner, ok := s.answers[ans.ID]; ok && owner != id {
+			return fmt.Errorf("%w: answer %s already exists", model.ErrConflict, ans.ID)
+		}
+	}
+	return nil
+}
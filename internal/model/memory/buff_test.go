@@ -0,0 +1,82 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetBuffReturnsCopy(t *testing.T) {
+	store, stream := newStoreWithStream(t)
+
+	b := newBuff(stream)
+	b.Schedule = &model.Schedule{Offset: time.Minute, Duration: time.Minute}
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	// Changing the given or returned buff must not change the stored one
+	b.Answers[0].Text = "changed"
+	b.Schedule.Offset = time.Hour
+	got, err := store.GetBuff(context.Background(), b.ID)
+	require.NoError(t, err, "failed to get buff")
+	assert.Equal(t, "42", got.Answers[0].Text)
+	assert.Equal(t, time.Minute, got.Schedule.Offset)
+
+	got.Answers[0].Text = "changed"
+	got.Schedule.Offset = time.Hour
+	got, err = store.GetBuff(context.Background(), b.ID)
+	require.NoError(t, err, "failed to get buff")
+	assert.Equal(t, "42", got.Answers[0].Text)
+	assert.Equal(t, time.Minute, got.Schedule.Offset)
+}
+
+func TestListBuffOrder(t *testing.T) {
+	store, stream := newStoreWithStream(t)
+
+	// Created newest first, so the insertion order differs from the list order
+	now := time.Now()
+	buffs := make([]model.Buff, 0)
+	for i := 0; i < 5; i++ {
+		b := newBuff(stream)
+		b.CreatedAt = now.Add(time.Duration(-i) * time.Minute)
+		require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+		buffs = append([]model.Buff{b}, buffs...)
+	}
+
+	// Updates must not move a buff
+	update := buffs[0]
+	update.CreatedAt = now.Add(time.Hour)
+	require.NoError(t, store.UpdateBuff(context.Background(), update.ID, update), "failed to update buff")
+
+	all, err := store.ListBuff(context.Background(), model.BuffQuery{}, 0, 0)
+	require.NoError(t, err, "failed to list buffs")
+	assert.Equal(t, buffs, all, "buffs should be listed oldest first")
+
+	page, err := store.ListBuffForStream(context.Background(), stream, model.BuffQuery{}, 1, 2)
+	require.NoError(t, err, "failed to list buffs for stream")
+	assert.Equal(t, buffs[1:3], page, "pages should hold whole buffs")
+}
+
+func TestAnswerIDsAreUnique(t *testing.T) {
+	store, stream := newStoreWithStream(t)
+
+	b := newBuff(stream)
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	reused := newBuff(stream)
+	reused.Answers[1].ID = b.Answers[1].ID
+	assert.Error(t, store.CreateBuff(context.Background(), reused), "answer ids must be unique across buffs")
+
+	other := newBuff(stream)
+	require.NoError(t, store.CreateBuff(context.Background(), other), "failed to create buff")
+
+	other.Answers[0].ID = b.Answers[0].ID
+	assert.Error(t, store.UpdateBuff(context.Background(), other.ID, other), "answer ids must be unique across buffs")
+
+	other.Answers[0].ID = model.AnswerID(uuid.New())
+	assert.NoError(t, store.UpdateBuff(context.Background(), other.ID, other))
+}
@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/opentracing/opentracing-go"
+)
+
+// ListLeaderboard returns the top scores of the stream, best first
+// A top of 0 lists every score, matching the postgres store
+func (s *Store) ListLeaderboard(ctx context.Context, stream model.VideoStreamID, top int) ([]model.Score, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Memory:List Leaderboard")
+	defer sp.Finish()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.streams[stream]; !ok {
+		return nil, model.ErrNotFound
+	}
+
+	scores := make([]model.Score, 0, len(s.scores[stream]))
+	for _, score := range s.scores[stream] {
+		scores = append(scores, score)
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		return scores[i].RanksAbove(scores[j])
+	})
+
+	_, end := paginate(len(scores), 0, top)
+	scores = scores[:end]
+	for i := range scores {
+		scores[i].Rank = i + 1
+	}
+	return scores, nil
+}
+
+// GetScore returns the score of the user on the stream, ranked against every other user
+func (s *Store) GetScore(ctx context.Context, stream model.VideoStreamID, user model.UserID) (*model.Score, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Memory:Get Score")
+	defer sp.Finish()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	score, ok := s.scores[stream][user]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+
+	score.Rank = 1
+	for _, other := range s.scores[stream] {
+		if other.RanksAbove(score) {
+			score.Rank++
+		}
+	}
+	return &score, nil
+}
+
+// scoreResponse works out the points the response to the buff earns,
+// and refreshes the score of its user on the buff's stream
+// The caller must hold the write lock
+func (s *Store) scoreResponse(b model.Buff, resp model.Response) {
+	if s.points[b.ID] == nil {
+		s.points[b.ID] = make(map[model.UserID]int)
+	}
+	s.points[b.ID][resp.User] = s.scoring.Score(b, s.streams[b.Stream].StartedAt, resp)
+	s.refreshScore(b.Stream, resp.User)
+}
+
+// rescoreBuff works out the points of every response to the buff again,
+// and refreshes the scores of the given users on the buff's stream
+// The caller must hold the write lock
+func (s *Store) rescoreBuff(id model.BuffID, users []model.UserID) {
+	b := s.buffs[id]
+	started := s.streams[b.Stream].StartedAt
+
+	points := make(map[model.UserID]int, len(s.responses[id]))
+	for user, resp := range s.responses[id] {
+		points[user] = s.scoring.Score(b, started, resp)
+	}
+	s.points[id] = points
+
+	for _, user := range users {
+		s.refreshScore(b.Stream, user)
+	}
+}
+
+// refreshScore works out the score of the user from their responses to the buffs of the stream
+// A user without any responses left is taken off the leaderboard
+// The caller must hold the write lock
+func (s *Store) refreshScore(stream model.VideoStreamID, user model.UserID) {
+	score := model.Score{User: user}
+	for id, b := range s.buffs {
+		if b.Stream != stream {
+			continue
+		}
+		if resp, ok := s.responses[id][user]; ok {
+			score.Add(s.points[id][user], resp.CreatedAt)
+		}
+	}
+
+	if score.Responses == 0 {
+		delete(s.scores[stream], user)
+		return
+	}
+
+	if s.scores[stream] == nil {
+		s.scores[stream] = make(map[model.UserID]model.Score)
+	}
+	s.scores[stream][user] = score
+}
+
+// responders returns the users that gave the responses
+func responders(responses map[model.UserID]model.Response) []model.UserID {
+	users := make([]model.UserID, 0, len(responses))
+	for user := range responses {
+		users = append(users, user)
+	}
+	return users
+}
@@ -0,0 +1,49 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/model/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeaderboardSpeedBonus(t *testing.T) {
+	store, stream := newStoreWithStream(t, memory.WithScoring(model.Scoring{Points: 100, SpeedBonus: 50}))
+	require.NoError(t, store.TransitionVideoStream(context.Background(), stream, model.StreamLive), "failed to start stream")
+
+	v, err := store.GetVideoStream(context.Background(), stream)
+	require.NoError(t, err, "failed to get video stream")
+
+	b := newBuff(stream)
+	b.Schedule = &model.Schedule{Offset: time.Minute, Duration: 10 * time.Second}
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	// The bonus falls evenly while the buff is open, and is all but gone as it closes
+	for user, after := range map[model.UserID]time.Duration{
+		"alice": time.Minute + 2*time.Second,
+		"bob":   time.Minute + 8*time.Second,
+		"carol": time.Minute + 10*time.Second - 100*time.Millisecond,
+	} {
+		require.NoError(t, store.CreateResponse(context.Background(), model.Response{
+			Buff:      b.ID,
+			User:      user,
+			Answer:    b.Answers[0].ID,
+			CreatedAt: v.StartedAt.Add(after),
+		}), "failed to create response")
+	}
+
+	scores, err := store.ListLeaderboard(context.Background(), stream, 0)
+	require.NoError(t, err, "failed to list leaderboard")
+	require.Len(t, scores, 3)
+
+	assert.Equal(t, model.UserID("alice"), scores[0].User)
+	assert.Equal(t, 140, scores[0].Points)
+	assert.Equal(t, model.UserID("bob"), scores[1].User)
+	assert.Equal(t, 110, scores[1].Points)
+	assert.Equal(t, model.UserID("carol"), scores[2].User)
+	assert.Equal(t, 100, scores[2].Points)
+}
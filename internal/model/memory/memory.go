@@ -0,0 +1,223 @@
+// Package memory provides an in-memory implementation of the model.Store interface
+//
+// It is intended for local development and for fast tests, where running a
+// real database is not worth the effort. Nothing is persisted, so all the data
+// is lost when the process exits.
+package memory
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/validation"
+	"github.com/google/uuid"
+)
+
+var _ model.Store = &Store{}
+
+// Store is an in-memory implementation of the model.Store interface
+//
+// It is safe for concurrent use, and mirrors the behaviour of the postgres
+// store as closely as possible. This includes the ordering of lists,
+// the pagination semantics, and the errors returned.
+type Store struct {
+	mu sync.RWMutex
+
+	streams map[model.VideoStreamID]model.VideoStream
+	buffs   map[model.BuffID]model.Buff
+
+	// answers maps every stored answer to the buff it belongs to,
+	// as answer IDs must be unique across all buffs
+	answers map[model.AnswerID]model.BuffID
+
+	// responses holds the responses to each buff, keyed by the user that gave them
+	responses map[model.BuffID]map[model.UserID]model.Response
+
+	// points holds the points each response earned, keyed in the same way as responses
+	points map[model.BuffID]map[model.UserID]int
+
+	// scores holds the leaderboard of each stream, keyed by the user each score is for
+	scores map[model.VideoStreamID]map[model.UserID]model.Score
+
+	// Behaviour options
+	streamDeletePolicy model.StreamDeletePolicy
+	validator          *validation.Validator
+	scoring            model.Scoring
+}
+
+type StoreOption func(*Store) error
+
+// NewStore returns a new, empty, Store object built with the given options
+func NewStore(options ...StoreOption) (*Store, error) {
+	s := &Store{
+		streams:   make(map[model.VideoStreamID]model.VideoStream),
+		buffs:     make(map[model.BuffID]model.Buff),
+		answers:   make(map[model.AnswerID]model.BuffID),
+		responses: make(map[model.BuffID]map[model.UserID]model.Response),
+		points:    make(map[model.BuffID]map[model.UserID]int),
+		scores:    make(map[model.VideoStreamID]map[model.UserID]model.Score),
+		scoring:   model.DefaultScoring,
+	}
+
+	for _, opt := range options {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.validator == nil {
+		v, err := validation.New()
+		if err != nil {
+			return nil, err
+		}
+		s.validator = v
+	}
+	return s, nil
+}
+
+// WithStreamDeletePolicy is a function option for NewStore that sets
+// what happens to the buffs of a stream when the stream is deleted.
+// The default is model.CascadeBuffs.
+func WithStreamDeletePolicy(policy model.StreamDeletePolicy) StoreOption {
+	return func(s *Store) error {
+		switch policy {
+		case model.CascadeBuffs, model.RestrictBuffs:
+			s.streamDeletePolicy = policy
+			return nil
+		default:
+			return fmt.Errorf("unknown stream delete policy %d", policy)
+		}
+	}
+}
+
+// WithValidator is a function option for NewStore that sets the rules
+// buffs and video streams are checked against before they are written.
+// If not set, the default validation.Validator rules are used.
+func WithValidator(v *validation.Validator) StoreOption {
+	return func(s *Store) error {
+		if v == nil {
+			return errors.New("cannot set a nil validator")
+		}
+
+		s.validator = v
+		return nil
+	}
+}
+
+// WithScoring is a function option for NewStore that sets how many points
+// a correct response earns on the leaderboards of the streams.
+// If not set, model.DefaultScoring is used.
+func WithScoring(scoring model.Scoring) StoreOption {
+	return func(s *Store) error {
+		if scoring.Points <= 0 {
+			return fmt.Errorf("cannot score a correct response %d points", scoring.Points)
+		}
+		if scoring.SpeedBonus < 0 {
+			return fmt.Errorf("cannot set a speed bonus of %d points", scoring.SpeedBonus)
+		}
+
+		s.scoring = scoring
+		return nil
+	}
+}
+
+// paginate returns the bounds of the page of n items selected by offset and limit
+// A zero offset or limit is treated as unset, matching the postgres store
+func paginate(n, offset, limit int) (start, end int) {
+	start, end = offset, n
+	if start > n {
+		start = n
+	}
+	if limit != 0 && start+limit < end {
+		end = start + limit
+	}
+	return start, end
+}
+
+// lessUUID orders uuids in the same way as postgres does
+func lessUUID(a, b uuid.UUID) bool {
+	return bytes.Compare(a[:], b[:]) < 0
+}
+
+// sortVideoStreams orders streams by each of the sorts in turn, then by
+// creation time, using the id to break ties
+func sortVideoStreams(vids []model.VideoStream, sorts ...model.VideoStreamSort) {
+	sort.Slice(vids, func(i, j int) bool {
+		for _, s := range sorts {
+			if c := s.Compare(vids[i], vids[j]); c != 0 {
+				return c < 0
+			}
+		}
+		if !vids[i].CreatedAt.Equal(vids[j].CreatedAt) {
+			return vids[i].CreatedAt.Before(vids[j].CreatedAt)
+		}
+		return lessUUID(uuid.UUID(vids[i].ID), uuid.UUID(vids[j].ID))
+	})
+}
+
+// sortBuffs orders buffs by creation time, using the id to break ties
+func sortBuffs(buffs []model.Buff) {
+	sort.Slice(buffs, func(i, j int) bool {
+		if !buffs[i].CreatedAt.Equal(buffs[j].CreatedAt) {
+			return buffs[i].CreatedAt.Before(buffs[j].CreatedAt)
+		}
+		return lessUUID(uuid.UUID(buffs[i].ID), uuid.UUID(buffs[j].ID))
+	})
+}
+
+// copyBuff returns a copy of the buff that shares no memory with the original
+// This stops callers from changing the stored data through the answers slice, the schedule,
+// the resolution, or the tags
+func copyBuff(b model.Buff) model.Buff {
+	answers := make([]model.Answer, len(b.Answers))
+	copy(answers, b.Answers)
+	b.Answers = answers
+	b.Tags = copyTags(b.Tags)
+
+	if b.Schedule != nil {
+		schedule := *b.Schedule
+		b.Schedule = &schedule
+	}
+	if b.Resolution != nil {
+		resolution := *b.Resolution
+		b.Resolution = &resolution
+	}
+	return b
+}
+
+// copyVideoStream returns a copy of the stream that shares no memory with the original
+// This stops callers from changing the stored data through the times it points to, or the tags
+func copyVideoStream(v model.VideoStream) model.VideoStream {
+	v.Tags = copyTags(v.Tags)
+	v.ScheduledStart = copyTime(v.ScheduledStart)
+	v.StartedAt = copyTime(v.StartedAt)
+	v.EndedAt = copyTime(v.EndedAt)
+	return v
+}
+
+// copyTags returns a sorted copy of the tags, or nil if there are none
+// Sorting them as they are copied keeps them in the same order as the postgres store lists them
+func copyTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	c := make([]string, len(tags))
+	copy(c, tags)
+	sort.Strings(c)
+	return c
+}
+
+// copyTime returns a pointer to a copy of the time, or nil if t is nil
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
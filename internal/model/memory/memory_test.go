@@ -0,0 +1,97 @@
+package memory_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/model/memory"
+	"github.com/JoeReid/buffassignment/internal/model/storetest"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.RunConformance(t, func(t *testing.T) model.Store {
+		store, err := memory.NewStore()
+		require.NoError(t, err, "failed to create store")
+		return store
+	})
+}
+
+// newVideoStream returns a valid video stream created at the given time
+func newVideoStream(created time.Time) model.VideoStream {
+	return model.VideoStream{
+		ID:        model.VideoStreamID(uuid.New()),
+		Title:     "a stream",
+		CreatedAt: created,
+		UpdatedAt: created,
+	}
+}
+
+// newBuff returns a valid buff for the given stream
+func newBuff(stream model.VideoStreamID) model.Buff {
+	return model.Buff{
+		ID:       model.BuffID(uuid.New()),
+		Stream:   stream,
+		Kind:     model.BuffQuiz,
+		Question: "what's the answer to life, the universe, and everything?",
+		Answers: []model.Answer{
+			{ID: model.AnswerID(uuid.New()), Text: "42", Correct: true},
+			{ID: model.AnswerID(uuid.New()), Text: "43", Correct: false},
+		},
+	}
+}
+
+// newStoreWithStream returns a new store holding a single video stream
+func newStoreWithStream(t *testing.T, options ...memory.StoreOption) (*memory.Store, model.VideoStreamID) {
+	store, err := memory.NewStore(options...)
+	require.NoError(t, err, "failed to create store")
+
+	v := newVideoStream(time.Now())
+	require.NoError(t, store.CreateVideoStream(context.Background(), v), "failed to create video stream")
+	return store, v.ID
+}
+
+func TestCancelledContext(t *testing.T) {
+	store, stream := newStoreWithStream(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := store.ListVideoStream(ctx, model.VideoStreamQuery{}, 0, 0)
+	assert.Equal(t, context.Canceled, err)
+
+	err = store.CreateBuff(ctx, newBuff(stream))
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	store, stream := newStoreWithStream(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			b := newBuff(stream)
+			b.Question = fmt.Sprintf("question %d", i)
+			assert.NoError(t, store.CreateBuff(context.Background(), b))
+
+			_, err := store.ListBuffForStream(context.Background(), stream, model.BuffQuery{}, 0, 0)
+			assert.NoError(t, err)
+
+			assert.NoError(t, store.DeleteBuff(context.Background(), b.ID))
+		}(i)
+	}
+	wg.Wait()
+
+	all, err := store.ListBuff(context.Background(), model.BuffQuery{}, 0, 0)
+	require.NoError(t, err, "failed to list buffs")
+	assert.Empty(t, all)
+}
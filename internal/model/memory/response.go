@@ -0,0 +1,118 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/opentracing/opentracing-go"
+)
+
+// CreateResponse adds a new response to a buff into the memory store
+// The response is validated first, returning a validation.Errors if it breaks any rules
+//
+// A buff that has been resolved takes no more responses, so that its resolution stays final,
+// and a buff only takes responses while its stream is live, up until the buff closes.
+// The response is scored as it is stored, adding its points to the leaderboard of the buff's stream.
+func (s *Store) CreateResponse(ctx context.Context, resp model.Response) error {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Memory:Create Response")
+	defer sp.Finish()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := s.validator.Response(resp); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buffs[resp.Buff]
+	if !ok {
+		return model.ErrNotFound
+	}
+	if b.Resolution != nil {
+		return &model.BuffResolvedError{Buff: b.ID, Answer: b.Resolution.Answer}
+	}
+	stream := s.streams[b.Stream]
+	if stream.State != model.StreamLive {
+		return &model.StreamNotLiveError{Stream: b.Stream, State: stream.State}
+	}
+	if b.ClosedBy(stream.StartedAt, resp.CreatedAt) {
+		return &model.BuffClosedError{Buff: b.ID}
+	}
+	if s.answers[resp.Answer] != resp.Buff {
+		return fmt.Errorf("%w: answer %s is not an answer to buff %s", model.ErrInvalidReference, resp.Answer, resp.Buff)
+	}
+	if _, ok := s.responses[resp.Buff][resp.User]; ok {
+		return fmt.Errorf("%w: user %s has already responded to buff %s", model.ErrConflict, resp.User, resp.Buff)
+	}
+
+	if s.responses[resp.Buff] == nil {
+		s.responses[resp.Buff] = make(map[model.UserID]model.Response)
+	}
+	s.responses[resp.Buff][resp.User] = resp
+	s.scoreResponse(b, resp)
+	return nil
+}
+
+// ListResponseForUser returns the responses the user has given to any of the given buffs
+func (s *Store) ListResponseForUser(ctx context.Context, user model.UserID, buffs []model.BuffID) ([]model.Response, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Memory:List Response For User")
+	defer sp.Finish()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rtn := make([]model.Response, 0)
+	for _, id := range buffs {
+		if resp, ok := s.responses[id][user]; ok {
+			rtn = append(rtn, resp)
+		}
+	}
+	return rtn, nil
+}
+
+// GetResults returns the tally of the responses to the buff with the given id
+// Every answer is included, even those nobody has given
+func (s *Store) GetResults(ctx context.Context, id model.BuffID) (*model.Results, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Memory:Get Results")
+	defer sp.Finish()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	b, ok := s.buffs[id]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+
+	counts := make(map[model.AnswerID]int, len(b.Answers))
+	for _, resp := range s.responses[id] {
+		counts[resp.Answer]++
+	}
+
+	res := &model.Results{
+		Buff:    id,
+		Total:   len(s.responses[id]),
+		Answers: make([]model.AnswerResult, 0, len(b.Answers)),
+	}
+	for _, ans := range b.Answers {
+		res.Answers = append(res.Answers, model.AnswerResult{
+			Answer:    ans.ID,
+			Text:      ans.Text,
+			Responses: counts[ans.ID],
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,56 @@
+package memory_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrentResponses(t *testing.T) {
+	store, stream := newStoreWithStream(t)
+	require.NoError(t, store.TransitionVideoStream(context.Background(), stream, model.StreamLive), "failed to start stream")
+
+	b := newBuff(stream)
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	// Every user responds twice at the same time, only one of which should be stored
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		conflicts int
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			err := store.CreateResponse(context.Background(), model.Response{
+				Buff:      b.ID,
+				User:      model.UserID(fmt.Sprintf("user-%d", i/2)),
+				Answer:    b.Answers[i%len(b.Answers)].ID,
+				CreatedAt: time.Now(),
+			})
+			if errors.Is(err, model.ErrConflict) {
+				mu.Lock()
+				conflicts++
+				mu.Unlock()
+				return
+			}
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 10, conflicts)
+
+	res, err := store.GetResults(context.Background(), b.ID)
+	require.NoError(t, err, "failed to get results")
+	assert.Equal(t, 10, res.Total)
+}
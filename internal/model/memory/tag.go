@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/opentracing/opentracing-go"
+)
+
+// ListTags returns a slice of model.TagCount for every tag on a buff or video stream
+// The tags are ordered by the tag
+func (s *Store) ListTags(ctx context.Context) ([]model.TagCount, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Memory:List Tags")
+	defer sp.Finish()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]*model.TagCount)
+	count := func(tag string) *model.TagCount {
+		c, ok := counts[tag]
+		if !ok {
+			c = &model.TagCount{Tag: tag}
+			counts[tag] = c
+		}
+		return c
+	}
+
+	for _, b := range s.buffs {
+		for _, tag := range b.Tags {
+			count(tag).Buffs++
+		}
+	}
+	for _, v := range s.streams {
+		for _, tag := range v.Tags {
+			count(tag).VideoStreams++
+		}
+	}
+
+	rtn := make([]model.TagCount, 0, len(counts))
+	for _, c := range counts {
+		rtn = append(rtn, *c)
+	}
+	sort.Slice(rtn, func(i, j int) bool {
+		return rtn[i].Tag < rtn[j].Tag
+	})
+	return rtn, nil
+}
@@ -0,0 +1,210 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/opentracing/opentracing-go"
+)
+
+// GetVideoStream returns a model.VideoStream by it's id
+func (s *Store) GetVideoStream(ctx context.Context, id model.VideoStreamID) (*model.VideoStream, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Memory:Get Video Stream")
+	defer sp.Finish()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	vid, ok := s.streams[id]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+
+	vid = copyVideoStream(vid)
+	return &vid, nil
+}
+
+// ListVideoStream returns a slice of model.VideoStream using offset and limit semantics
+// Only the streams matching the query are returned, ordered by its sort and then by creation time
+func (s *Store) ListVideoStream(ctx context.Context, query model.VideoStreamQuery, offset, limit int) ([]model.VideoStream, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Memory:List Video Stream")
+	defer sp.Finish()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	vids := make([]model.VideoStream, 0, len(s.streams))
+	for _, vid := range s.streams {
+		if query.Matches(vid) {
+			vids = append(vids, copyVideoStream(vid))
+		}
+	}
+	sortVideoStreams(vids, query.Sort...)
+
+	start, end := paginate(len(vids), offset, limit)
+	return vids[start:end], nil
+}
+
+// ListVideoStreamAfter returns a slice of model.VideoStream using keyset semantics
+// The streams matching the query after the cursor are returned, or the first streams if it is nil
+func (s *Store) ListVideoStreamAfter(ctx context.Context, query model.VideoStreamQuery, after *model.Cursor, limit int) ([]model.VideoStream, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Memory:List Video Stream After")
+	defer sp.Finish()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	vids := make([]model.VideoStream, 0, len(s.streams))
+	for _, vid := range s.streams {
+		if (after == nil || after.Less(vid.Cursor())) && query.Matches(vid) {
+			vids = append(vids, copyVideoStream(vid))
+		}
+	}
+	sortVideoStreams(vids)
+
+	start, end := paginate(len(vids), 0, limit)
+	return vids[start:end], nil
+}
+
+// CreateVideoStream adds a new VideoStream object into the memory store
+// A stream without a state is stored as model.StreamScheduled
+//
+// The stream is validated first, returning a validation.Errors if it breaks any rules
+func (s *Store) CreateVideoStream(ctx context.Context, vid model.VideoStream) error {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Memory:Create Video Stream")
+	defer sp.Finish()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := s.validator.VideoStream(vid); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.streams[vid.ID]; ok {
+		return fmt.Errorf("%w: video stream %s already exists", model.ErrConflict, vid.ID)
+	}
+
+	if vid.State == "" {
+		vid.State = model.StreamScheduled
+	}
+
+	s.streams[vid.ID] = copyVideoStream(vid)
+	return nil
+}
+
+// UpdateVideoStream replaces the VideoStream with ID model.VideoStreamID with the given object
+//
+// Only the title, tags and scheduled start can be changed, the updated timestamp is set
+// by the store and the creation timestamp is left untouched. The state, and the times
+// the stream started and ended, are only changed by TransitionVideoStream.
+//
+// The stream is validated first, returning a validation.Errors if it breaks any rules
+func (s *Store) UpdateVideoStream(ctx context.Context, id model.VideoStreamID, vid model.VideoStream) error {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Memory:Update Video Stream")
+	defer sp.Finish()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := s.validator.VideoStream(vid); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.streams[id]
+	if !ok {
+		return model.ErrNotFound
+	}
+
+	existing.Title = vid.Title
+	existing.Tags = copyTags(vid.Tags)
+	existing.ScheduledStart = copyTime(vid.ScheduledStart)
+	existing.UpdatedAt = time.Now().UTC()
+	s.streams[id] = existing
+	return nil
+}
+
+// TransitionVideoStream moves the VideoStream with ID model.VideoStreamID into the given state
+// A transition the stream's current state doesn't allow returns a *model.StreamTransitionError
+func (s *Store) TransitionVideoStream(ctx context.Context, id model.VideoStreamID, to model.StreamState) error {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Memory:Transition Video Stream")
+	defer sp.Finish()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.streams[id]
+	if !ok {
+		return model.ErrNotFound
+	}
+
+	if err := existing.Transition(to, time.Now().UTC()); err != nil {
+		return err
+	}
+	s.streams[id] = existing
+	return nil
+}
+
+// DeleteVideoStream deletes the VideoStream with ID model.VideoStreamID
+//
+// What happens to the buffs of the stream depends on the model.StreamDeletePolicy
+// the store was built with.
+func (s *Store) DeleteVideoStream(ctx context.Context, id model.VideoStreamID) error {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Memory:Delete Video Stream")
+	defer sp.Finish()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.streams[id]; !ok {
+		return model.ErrNotFound
+	}
+
+	buffs := make([]model.BuffID, 0)
+	for bID, b := range s.buffs {
+		if b.Stream == id {
+			buffs = append(buffs, bID)
+		}
+	}
+
+	if s.streamDeletePolicy == model.RestrictBuffs && len(buffs) != 0 {
+		return &model.StreamHasBuffsError{Stream: id, Buffs: len(buffs)}
+	}
+
+	for _, bID := range buffs {
+		s.deleteBuff(bID)
+	}
+	delete(s.scores, id)
+	delete(s.streams, id)
+	return nil
+}
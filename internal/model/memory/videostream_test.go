@@ -0,0 +1,41 @@
+package memory_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/model/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteVideoStreamRestrictBuffs(t *testing.T) {
+	store, stream := newStoreWithStream(t, memory.WithStreamDeletePolicy(model.RestrictBuffs))
+
+	b := newBuff(stream)
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	err := store.DeleteVideoStream(context.Background(), stream)
+	var hasBuffs *model.StreamHasBuffsError
+	require.True(t, errors.As(err, &hasBuffs), "the delete should be refused")
+	assert.Equal(t, 1, hasBuffs.Buffs)
+
+	require.NoError(t, store.DeleteBuff(context.Background(), b.ID), "failed to delete buff")
+	assert.NoError(t, store.DeleteVideoStream(context.Background(), stream))
+}
+
+func TestStoreOptions(t *testing.T) {
+	_, err := memory.NewStore(memory.WithStreamDeletePolicy(model.StreamDeletePolicy(42)))
+	assert.Error(t, err)
+
+	_, err = memory.NewStore(memory.WithValidator(nil))
+	assert.Error(t, err)
+
+	_, err = memory.NewStore(memory.WithScoring(model.Scoring{Points: 0}))
+	assert.Error(t, err)
+
+	_, err = memory.NewStore(memory.WithScoring(model.Scoring{Points: 100, SpeedBonus: -1}))
+	assert.Error(t, err)
+}
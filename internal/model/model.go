@@ -2,9 +2,52 @@ package model
 
 import "errors"
 
-// ErrNotFound should be returned by store implementations when they
-// couldn't find the requested data
-var ErrNotFound = errors.New("the requested data was not found in the store")
+// The errors store implementations should return, so that callers can tell
+// what went wrong without knowing which store they are using.
+// Implementations may wrap them with more detail, so they should be
+// checked for with errors.Is.
+var (
+	// ErrNotFound should be returned by store implementations when they
+	// couldn't find the requested data
+	ErrNotFound = errors.New("the requested data was not found in the store")
+
+	// ErrConflict should be returned by store implementations when a write
+	// clashes with the data already stored, such as an ID that is already in use
+	ErrConflict = errors.New("the data conflicts with the data in the store")
+
+	// ErrInvalidReference should be returned by store implementations when a write
+	// refers to data that isn't stored, such as a buff for an unknown video stream
+	ErrInvalidReference = errors.New("the data refers to data that is not in the store")
+
+	// ErrUnavailable should be returned by store implementations when they
+	// can't reach the underlying storage, and the request may succeed if retried
+	ErrUnavailable = errors.New("the store is unavailable")
+)
+
+// StoreError ties one of the model errors to the error of the underlying storage that caused it
+//
+// errors.Is matches the model error, while errors.As and errors.Unwrap still give
+// access to the original error for logging. Only the Kind is fit to show to clients,
+// as the original error can hold the internals of the storage.
+type StoreError struct {
+	Kind error
+	Err  error
+}
+
+// Error implements the error interface
+func (e *StoreError) Error() string {
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+// Is reports whether the target is the model error this error is a kind of
+func (e *StoreError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Unwrap returns the error of the underlying storage
+func (e *StoreError) Unwrap() error {
+	return e.Err
+}
 
 // Store defines all the actions needed to implement the full storage layer
 // This could be implemented by:
@@ -17,4 +60,7 @@ var ErrNotFound = errors.New("the requested data was not found in the store")
 type Store interface {
 	VideoStreamStore
 	BuffStore
+	ResponseStore
+	LeaderboardStore
+	TagStore
 }
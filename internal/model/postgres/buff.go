@@ -1,7 +1,9 @@
 package postgres
 
 import (
-	"errors"
+	"context"
+	"database/sql"
+	"time"
 
 	"github.com/JoeReid/apiutils/tracer"
 	"github.com/JoeReid/buffassignment/internal/model"
@@ -12,9 +14,35 @@ import (
 
 // question is the DB representation of the structure
 type question struct {
-	ID     uuid.UUID
-	Stream uuid.UUID
-	Text   string
+	ID      uuid.UUID
+	Stream  uuid.UUID
+	Kind    string
+	Text    string
+	Created time.Time
+
+	// The schedule is stored in milliseconds, and is null for unscheduled buffs
+	StartOffsetMS sql.NullInt64
+	DurationMS    sql.NullInt64
+}
+
+// scheduleColumns returns the values of the schedule columns for the buff
+func scheduleColumns(s *model.Schedule) (sql.NullInt64, sql.NullInt64) {
+	if s == nil {
+		return sql.NullInt64{}, sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: s.Offset.Milliseconds(), Valid: true},
+		sql.NullInt64{Int64: s.Duration.Milliseconds(), Valid: true}
+}
+
+// schedule returns the model.Schedule stored for the question, or nil if it has none
+func (q question) schedule() *model.Schedule {
+	if !q.StartOffsetMS.Valid || !q.DurationMS.Valid {
+		return nil
+	}
+	return &model.Schedule{
+		Offset:   time.Duration(q.StartOffsetMS.Int64) * time.Millisecond,
+		Duration: time.Duration(q.DurationMS.Int64) * time.Millisecond,
+	}
 }
 
 // answer is the DB representation of the structure
@@ -26,215 +54,459 @@ type answer struct {
 }
 
 // GetBuff returns a model.Buff by it's id
-func (s *Store) GetBuff(id model.BuffID) (*model.Buff, error) {
-	// TODO: replace with context method
-	sp := opentracing.GlobalTracer().StartSpan("Postgres:Get Buff")
+func (s *Store) GetBuff(ctx context.Context, id model.BuffID) (*model.Buff, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Postgres:Get Buff")
 	defer sp.Finish()
 
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
 	q, v, err := psql.Select(buffFields...).From(questionTable).Join(
 		answerTable+" ON questions.id = answers.question",
-	).Where("questions.id = ?", uuid.UUID(id)).Limit(1).ToSql()
+	).Where("questions.id = ?", uuid.UUID(id)).OrderBy("answers.position").ToSql()
 	if err != nil {
 		tracer.Log(sp, "failed to build sql query")
 		tracer.SetError(sp, err)
 		return nil, err
 	}
 
-	res, err := s.db.Queryx(q, v...)
+	buffs, err := s.queryBuffs(ctx, sp, q, v)
 	if err != nil {
-		tracer.Log(sp, "failed to run query")
-		tracer.SetError(sp, err)
 		return nil, err
 	}
 
-	mdlBuff := model.Buff{Answers: make([]model.Answer, 0)}
-	for res.Next() {
-		ques := question{}
-		ans := answer{}
+	// Every buff has answers, so no rows means there is no buff
+	if len(buffs) == 0 {
+		return nil, model.ErrNotFound
+	}
+	return &buffs[0], nil
+}
 
-		if err := res.Scan(
-			&ques.ID, &ques.Stream, &ques.Text,
-			&ans.ID, &ans.Question, &ans.Text, &ans.Correct,
-		); err != nil {
-			tracer.Log(sp, "failed to scan results")
-			tracer.SetError(sp, err)
-			return nil, err
+// ListBuff returns a slice of model.Buff using offset and limit semantics
+// Only the buffs matching the query are returned, ordered by creation time, oldest first
+func (s *Store) ListBuff(ctx context.Context, query model.BuffQuery, offset, limit int) ([]model.Buff, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Postgres:List Buff")
+	defer sp.Finish()
+
+	page := whereBuffQuery(sq.Select("id").From(questionTable), query)
+
+	return s.listBuffs(ctx, sp, page, nil, offset, limit)
+}
+
+// ListBuffAfter returns a slice of model.Buff using keyset semantics
+// The buffs matching the query after the cursor are returned, or the first buffs if it is nil
+func (s *Store) ListBuffAfter(ctx context.Context, query model.BuffQuery, after *model.Cursor, limit int) ([]model.Buff, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Postgres:List Buff After")
+	defer sp.Finish()
+
+	page := whereBuffQuery(sq.Select("id").From(questionTable), query)
+
+	return s.listBuffs(ctx, sp, page, after, 0, limit)
+}
+
+// ListBuffForStream returns a slice of model.Buff using offset and limit semantics
+// Where all the returned buffs are ascociated with the given model.VideoStreamID
+// Only the buffs matching the query are returned, ordered by creation time, oldest first
+func (s *Store) ListBuffForStream(ctx context.Context, stream model.VideoStreamID, query model.BuffQuery, offset, limit int) ([]model.Buff, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Postgres:List Buff For Stream")
+	defer sp.Finish()
+
+	page := whereBuffQuery(sq.Select("id").From(questionTable).Where("stream = ?", uuid.UUID(stream)), query)
+
+	return s.listBuffs(ctx, sp, page, nil, offset, limit)
+}
+
+// ListBuffForStreamAfter returns a slice of model.Buff using keyset semantics
+// Where all the returned buffs are ascociated with the given model.VideoStreamID
+// The buffs matching the query after the cursor are returned, or the first buffs if it is nil
+func (s *Store) ListBuffForStreamAfter(
+	ctx context.Context,
+	stream model.VideoStreamID,
+	query model.BuffQuery,
+	after *model.Cursor,
+	limit int,
+) ([]model.Buff, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Postgres:List Buff For Stream After")
+	defer sp.Finish()
+
+	page := whereBuffQuery(sq.Select("id").From(questionTable).Where("stream = ?", uuid.UUID(stream)), query)
+
+	return s.listBuffs(ctx, sp, page, after, 0, limit)
+}
+
+// ListBuffActiveForStream returns every buff of the stream that is active at the given offset into it
+// Only the buffs matching the query are returned, ordered by creation time, oldest first
+func (s *Store) ListBuffActiveForStream(
+	ctx context.Context,
+	stream model.VideoStreamID,
+	query model.BuffQuery,
+	at time.Duration,
+) ([]model.Buff, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Postgres:List Buff Active For Stream")
+	defer sp.Finish()
+
+	ms := at.Milliseconds()
+	page := whereBuffQuery(sq.Select("id").From(questionTable).Where(
+		"stream = ? AND start_offset_ms <= ? AND start_offset_ms + duration_ms > ?", uuid.UUID(stream), ms, ms,
+	), query)
+
+	return s.listBuffs(ctx, sp, page, nil, 0, 0)
+}
+
+// whereBuffQuery restricts the page query to the questions matching the query
+func whereBuffQuery(page sq.SelectBuilder, query model.BuffQuery) sq.SelectBuilder {
+	if len(query.StreamStates) != 0 {
+		states := make([]string, 0, len(query.StreamStates))
+		for _, state := range query.StreamStates {
+			states = append(states, string(state))
 		}
 
-		mdlBuff.ID = model.BuffID(ques.ID)
-		mdlBuff.Stream = model.VideoStreamID(ques.Stream)
-		mdlBuff.Question = ques.Text
-		mdlBuff.Answers = append(mdlBuff.Answers, model.Answer{
-			ID:      model.AnswerID(ans.ID),
-			Text:    ans.Text,
-			Correct: ans.Correct,
-		})
+		streams := sq.Select("id").From(videoStreamTable).Where(sq.Eq{"state": states})
+		page = page.Where(sq.Expr("stream IN (?)", streams))
 	}
+	return questionTags.whereHasTags(page, "id", query.Tags)
+}
 
-	return &mdlBuff, nil
+// searchVector returns the tsvector of the text column, as indexed by deploy/migrations/009_buff_search.sql
+// The indexes are only used by queries with the same expression, including the text search config
+func searchVector(column string) string {
+	return "to_tsvector('english', " + column + ")"
 }
 
-// ListBuff returns a slice of model.Buff using offset and limit semantics
-func (s *Store) ListBuff(offset, limit int) ([]model.Buff, error) {
-	// TODO: replace with context method
-	sp := opentracing.GlobalTracer().StartSpan("Postgres:List Buff")
+// searchHeadline returns the text of the column with every term matching the query highlighted
+// The text is HTML escaped first, so that only the markers are markup.
+func searchHeadline(column string) string {
+	return "ts_headline('english', " + escapeHTML(column) + ", query.query, " +
+		"'StartSel=" + model.HighlightStart + ", StopSel=" + model.HighlightStop + ", HighlightAll=true')"
+}
+
+// escapeHTML returns the text of the column escaped in the same way as html.EscapeString
+// The ampersands are replaced first, so the entities of the other replacements are kept.
+func escapeHTML(column string) string {
+	return "replace(replace(replace(replace(replace(" + column + ", '&', '&amp;'), " +
+		"'''', '&#39;'), '<', '&lt;'), '>', '&gt;'), '\"', '&#34;')"
+}
+
+// SearchBuffs returns a slice of model.BuffMatch using offset and limit semantics
+//
+// The query is parsed as plain text, and a buff matches if its question or any of its answers
+// contains every word of it, after stemming. The buffs matching in their question come first,
+// as ts_rank alone can put many matching answers above a single matching question. Each group
+// is ordered by rank, and buffs with the same rank by creation time, oldest first.
+func (s *Store) SearchBuffs(ctx context.Context, query string, offset, limit int) ([]model.BuffMatch, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Postgres:Search Buffs")
 	defer sp.Finish()
 
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
-	qb := psql.Select(buffFields...).From(questionTable).Join(
-		answerTable + " ON questions.id = answers.question",
+	// The questions and answers are matched separately, so that each can use its index
+	page := psql.Select(
+		"questions.id",
+		"ts_rank(setweight("+searchVector("questions.text")+", 'A') || "+
+			"setweight("+searchVector("coalesce(answer_text.text, '')")+", 'B'), query.query) AS rank",
+		searchHeadline("questions.text")+" AS highlight",
+	).From(questionTable).Join(
+		"(SELECT id FROM questions WHERE "+searchVector("text")+" @@ plainto_tsquery('english', ?) "+
+			"UNION SELECT question FROM answers WHERE "+searchVector("text")+" @@ plainto_tsquery('english', ?)"+
+			") AS matched ON matched.id = questions.id",
+		query, query,
+	).Join(
+		"plainto_tsquery('english', ?) AS query ON true", query,
+	).LeftJoin(
+		"LATERAL (SELECT string_agg(text, ' ' ORDER BY position) AS text FROM answers WHERE answers.question = questions.id) AS answer_text ON true",
+	).OrderBy(
+		searchVector("questions.text")+" @@ query.query DESC", "rank DESC", "questions.created", "questions.id",
 	)
 
 	if offset != 0 {
-		qb = qb.Offset(uint64(offset))
+		page = page.Offset(uint64(offset))
 	}
 	if limit != 0 {
-		qb = qb.Limit(uint64(limit))
+		page = page.Limit(uint64(limit))
+	}
+
+	q, v, err := page.ToSql()
+	if err != nil {
+		tracer.Log(sp, "failed to build sql query")
+		tracer.SetError(sp, err)
+		return nil, err
+	}
+
+	rows := []struct {
+		ID        uuid.UUID
+		Rank      float64
+		Highlight string
+	}{}
+	if err := s.db.SelectContext(ctx, &rows, q, v...); err != nil {
+		tracer.Log(sp, "failed to run query")
+		tracer.SetError(sp, err)
+		return nil, translateError(err)
+	}
+	if len(rows) == 0 {
+		return []model.BuffMatch{}, nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
 	}
 
-	q, v, err := qb.ToSql()
+	q, v, err = psql.Select(buffFields...).From(questionTable).Join(
+		answerTable+" ON questions.id = answers.question",
+	).Where(sq.Eq{"questions.id": ids}).OrderBy("questions.id", "answers.position").ToSql()
 	if err != nil {
 		tracer.Log(sp, "failed to build sql query")
 		tracer.SetError(sp, err)
 		return nil, err
 	}
 
-	res, err := s.db.Queryx(q, v...)
+	buffs, err := s.queryBuffs(ctx, sp, q, v)
 	if err != nil {
 		return nil, err
 	}
 
-	mdlBuffs := make(map[uuid.UUID]model.Buff)
+	highlights, err := s.searchAnswerHighlights(ctx, sp, query, ids)
+	if err != nil {
+		return nil, err
+	}
 
-	for res.Next() {
-		ques := question{}
-		ans := answer{}
+	byID := make(map[uuid.UUID]model.Buff, len(buffs))
+	for _, b := range buffs {
+		byID[uuid.UUID(b.ID)] = b
+	}
 
-		if err := res.Scan(
-			&ques.ID, &ques.Stream, &ques.Text,
-			&ans.ID, &ans.Question, &ans.Text, &ans.Correct,
-		); err != nil {
-			return nil, err
+	// The buffs are returned in the order of the ranked page, skipping any deleted in between the queries
+	rtn := make([]model.BuffMatch, 0, len(rows))
+	for _, row := range rows {
+		b, ok := byID[row.ID]
+		if !ok {
+			continue
 		}
 
-		// Create blank object if not exist
-		mdlBuff, ok := mdlBuffs[ques.ID]
-		if !ok {
-			mdlBuff = model.Buff{Answers: make([]model.Answer, 0)}
+		m := model.BuffMatch{
+			Buff:              b,
+			Rank:              row.Rank,
+			QuestionHighlight: row.Highlight,
+			AnswerHighlights:  make(map[model.AnswerID]string),
+		}
+		for _, a := range b.Answers {
+			if h, ok := highlights[a.ID]; ok {
+				m.AnswerHighlights[a.ID] = h
+			}
 		}
+		rtn = append(rtn, m)
+	}
+	return rtn, nil
+}
 
-		mdlBuff.ID = model.BuffID(ques.ID)
-		mdlBuff.Stream = model.VideoStreamID(ques.Stream)
-		mdlBuff.Question = ques.Text
-		mdlBuff.Answers = append(mdlBuff.Answers, model.Answer{
-			ID:      model.AnswerID(ans.ID),
-			Text:    ans.Text,
-			Correct: ans.Correct,
-		})
-		mdlBuffs[ques.ID] = mdlBuff
+// searchAnswerHighlights returns the highlighted text of the answers of the questions that match the query
+func (s *Store) searchAnswerHighlights(
+	ctx context.Context,
+	sp opentracing.Span,
+	query string,
+	questions []uuid.UUID,
+) (map[model.AnswerID]string, error) {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	q, v, err := psql.Select("answers.id", searchHeadline("answers.text")+" AS highlight").From(answerTable).Join(
+		"plainto_tsquery('english', ?) AS query ON true", query,
+	).Where(sq.Eq{"answers.question": questions}).Where(
+		searchVector("answers.text") + " @@ query.query",
+	).ToSql()
+	if err != nil {
+		tracer.Log(sp, "failed to build sql query")
+		tracer.SetError(sp, err)
+		return nil, err
 	}
 
-	rtn := make([]model.Buff, 0, len(mdlBuffs))
-	for _, v := range mdlBuffs {
-		rtn = append(rtn, v)
+	rows := []struct {
+		ID        uuid.UUID
+		Highlight string
+	}{}
+	if err := s.db.SelectContext(ctx, &rows, q, v...); err != nil {
+		tracer.Log(sp, "failed to run query")
+		tracer.SetError(sp, err)
+		return nil, translateError(err)
+	}
+
+	rtn := make(map[model.AnswerID]string, len(rows))
+	for _, row := range rows {
+		rtn[model.AnswerID(row.ID)] = row.Highlight
 	}
 	return rtn, nil
 }
 
-// ListBuffForStream returns a slice of model.Buff using offset and limit semantics
-// Where all the returned buffs are ascociated with the given model.VideoStreamID
-func (s *Store) ListBuffForStream(stream model.VideoStreamID, offset, limit int) ([]model.Buff, error) {
-	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
-
-	qb := psql.Select(buffFields...).From(questionTable).Join(
-		answerTable+" ON questions.id = answers.question",
-	).Where("questions.stream = ?", uuid.UUID(stream))
+// listBuffs returns the buffs selected by the page query, after the cursor, offset and limit are applied
+//
+// The page query selects the ids of the questions to return. The cursor, offset and limit are applied
+// to it, rather than to the join with the answers, so that they count whole buffs.
+func (s *Store) listBuffs(
+	ctx context.Context,
+	sp opentracing.Span,
+	page sq.SelectBuilder,
+	after *model.Cursor,
+	offset, limit int,
+) ([]model.Buff, error) {
+	// The id breaks ties, so that pages are stable even when
+	// several buffs share a creation time
+	page = page.OrderBy("created", "id")
+
+	// The row comparison matches the order, and can use the (created, id) indexes
+	if after != nil {
+		page = page.Where("(created, id) > (?, ?)", after.CreatedAt.UTC(), after.ID)
+	}
 
 	if offset != 0 {
-		qb = qb.Offset(uint64(offset))
+		page = page.Offset(uint64(offset))
 	}
 	if limit != 0 {
-		qb = qb.Limit(uint64(limit))
+		page = page.Limit(uint64(limit))
 	}
 
-	q, v, err := qb.ToSql()
+	pq, pv, err := page.ToSql()
 	if err != nil {
+		tracer.Log(sp, "failed to build sql query")
+		tracer.SetError(sp, err)
 		return nil, err
 	}
 
-	res, err := s.db.Queryx(q, v...)
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	q, v, err := psql.Select(buffFields...).From(questionTable).Join(
+		answerTable+" ON questions.id = answers.question",
+	).Where(
+		sq.Expr("questions.id IN ("+pq+")", pv...),
+	).OrderBy("questions.created", "questions.id", "answers.position").ToSql()
 	if err != nil {
+		tracer.Log(sp, "failed to build sql query")
+		tracer.SetError(sp, err)
 		return nil, err
 	}
 
-	mdlBuffs := make(map[uuid.UUID]model.Buff)
+	return s.queryBuffs(ctx, sp, q, v)
+}
+
+// queryBuffs runs a query selecting the buffFields, and collects the rows into buffs
+// The rows for each buff must be next to each other, and the order of the rows is kept
+func (s *Store) queryBuffs(ctx context.Context, sp opentracing.Span, q string, v []interface{}) ([]model.Buff, error) {
+	res, err := s.db.QueryxContext(ctx, q, v...)
+	if err != nil {
+		tracer.Log(sp, "failed to run query")
+		tracer.SetError(sp, err)
+		return nil, translateError(err)
+	}
+	defer res.Close()
 
+	rtn := make([]model.Buff, 0)
 	for res.Next() {
 		ques := question{}
 		ans := answer{}
 
 		if err := res.Scan(
-			&ques.ID, &ques.Stream, &ques.Text,
+			&ques.ID, &ques.Stream, &ques.Kind, &ques.Text, &ques.Created,
+			&ques.StartOffsetMS, &ques.DurationMS,
 			&ans.ID, &ans.Question, &ans.Text, &ans.Correct,
 		); err != nil {
-			return nil, err
+			tracer.Log(sp, "failed to scan results")
+			tracer.SetError(sp, err)
+			return nil, translateError(err)
 		}
 
-		// Create blank object if not exist
-		mdlBuff, ok := mdlBuffs[ques.ID]
-		if !ok {
-			mdlBuff = model.Buff{Answers: make([]model.Answer, 0)}
+		// Start a new buff whenever the question changes
+		if len(rtn) == 0 || rtn[len(rtn)-1].ID != model.BuffID(ques.ID) {
+			rtn = append(rtn, model.Buff{
+				ID:        model.BuffID(ques.ID),
+				Stream:    model.VideoStreamID(ques.Stream),
+				Kind:      model.BuffKind(ques.Kind),
+				Question:  ques.Text,
+				CreatedAt: ques.Created,
+				Answers:   make([]model.Answer, 0),
+				Schedule:  ques.schedule(),
+			})
 		}
 
-		mdlBuff.ID = model.BuffID(ques.ID)
-		mdlBuff.Stream = model.VideoStreamID(ques.Stream)
-		mdlBuff.Question = ques.Text
+		mdlBuff := &rtn[len(rtn)-1]
 		mdlBuff.Answers = append(mdlBuff.Answers, model.Answer{
 			ID:      model.AnswerID(ans.ID),
 			Text:    ans.Text,
 			Correct: ans.Correct,
 		})
-		mdlBuffs[ques.ID] = mdlBuff
 	}
 
-	rtn := make([]model.Buff, 0, len(mdlBuffs))
-	for _, v := range mdlBuffs {
-		rtn = append(rtn, v)
+	if err := res.Err(); err != nil {
+		tracer.Log(sp, "failed to read results")
+		tracer.SetError(sp, err)
+		return nil, translateError(err)
+	}
+
+	ids := make([]uuid.UUID, 0, len(rtn))
+	for _, b := range rtn {
+		ids = append(ids, uuid.UUID(b.ID))
+	}
+
+	tags, err := questionTags.read(ctx, s.db, ids)
+	if err != nil {
+		tracer.Log(sp, "failed to read tags")
+		tracer.SetError(sp, err)
+		return nil, err
+	}
+	resolutions, err := readResolutions(ctx, s.db, ids)
+	if err != nil {
+		tracer.Log(sp, "failed to read resolutions")
+		tracer.SetError(sp, err)
+		return nil, err
+	}
+
+	for i := range rtn {
+		rtn[i].Tags = tags[uuid.UUID(rtn[i].ID)]
+		rtn[i].Resolution = resolutions[uuid.UUID(rtn[i].ID)]
 	}
 	return rtn, nil
 }
 
 // CreateBuff adds a new buff object into the postgres store
-func (s *Store) CreateBuff(buff model.Buff) error {
+// A buff without a kind is stored as model.BuffQuiz, and it is always stored unresolved
+//
+// The buff is validated first, returning a validation.Errors if it breaks any rules
+func (s *Store) CreateBuff(ctx context.Context, buff model.Buff) error {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Postgres:Create Buff")
+	defer sp.Finish()
+
+	if err := s.validator.Buff(buff); err != nil {
+		return err
+	}
+
+	if buff.Kind == "" {
+		buff.Kind = model.BuffQuiz
+	}
+
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
+	offset, duration := scheduleColumns(buff.Schedule)
+
 	q, v, err := psql.Insert(questionTable).Columns(questionFields...).Values(
-		uuid.UUID(buff.ID), uuid.UUID(buff.Stream), buff.Question,
+		uuid.UUID(buff.ID), uuid.UUID(buff.Stream), string(buff.Kind), buff.Question, buff.CreatedAt, offset, duration,
 	).ToSql()
 	if err != nil {
 		return err
 	}
 
-	tx, err := s.db.Begin()
+	tx, err := s.db.BeginTxx(ctx, nil)
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 
-	_, err = tx.Exec(q, v...)
+	_, err = tx.ExecContext(ctx, q, v...)
 	if err != nil {
 		// No need to check the error here,
 		// just make a best attempt to clean up the transaction
 		// nolint:errcheck
 		defer tx.Rollback()
-		return err
+		return translateError(err)
 	}
 
-	for _, ans := range buff.Answers {
+	for i, ans := range buff.Answers {
 		q2, v2, err := psql.Insert(answerTable).Columns(answerFields...).Values(
-			uuid.UUID(ans.ID), uuid.UUID(buff.ID), ans.Text, ans.Correct,
+			uuid.UUID(ans.ID), uuid.UUID(buff.ID), ans.Text, ans.Correct, i,
 		).ToSql()
 		if err != nil {
 			// No need to check the error here,
@@ -244,27 +516,242 @@ func (s *Store) CreateBuff(buff model.Buff) error {
 			return err
 		}
 
-		_, err = tx.Exec(q2, v2...)
+		_, err = tx.ExecContext(ctx, q2, v2...)
 		if err != nil {
 			// No need to check the error here,
 			// just make a best attempt to clean up the transaction
 			// nolint:errcheck
 			defer tx.Rollback()
-			return err
+			return translateError(err)
 		}
 	}
 
-	return tx.Commit()
+	if err := questionTags.write(ctx, tx, uuid.UUID(buff.ID), buff.Tags); err != nil {
+		// No need to check the error here,
+		// just make a best attempt to clean up the transaction
+		// nolint:errcheck
+		defer tx.Rollback()
+		return err
+	}
+
+	return translateError(tx.Commit())
 }
 
 // UpdateBuff replaces the Buff with ID model.BuffID with the given object
-// This method is not yet implemented
-func (s *Store) UpdateBuff(model.BuffID, model.Buff) error {
-	return errors.New("not implemented")
+//
+// The kind, question text, schedule and tags are replaced, and the stored answers are reconciled with
+// those on the given buff: new answer IDs are inserted, existing ones are
+// updated, and any that are no longer present are removed.
+// This all happens in a single transaction, along with scoring the remaining responses again.
+// A resolved buff cannot be updated at all.
+//
+// The buff is validated first, returning a validation.Errors if it breaks any rules
+func (s *Store) UpdateBuff(ctx context.Context, id model.BuffID, buff model.Buff) error {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Postgres:Update Buff")
+	defer sp.Finish()
+
+	if err := s.validator.Buff(buff); err != nil {
+		tracer.Log(sp, "buff failed validation")
+		tracer.SetError(sp, err)
+		return err
+	}
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		tracer.Log(sp, "failed to begin transaction")
+		tracer.SetError(sp, err)
+		return translateError(err)
+	}
+	// Rollback is a no-op once the transaction is committed,
+	// so this is just a best attempt to clean up on the error paths
+	// nolint:errcheck
+	defer tx.Rollback()
+
+	// A buff without a kind is a quiz, as it is when created
+	if buff.Kind == "" {
+		buff.Kind = model.BuffQuiz
+	}
+
+	offset, duration := scheduleColumns(buff.Schedule)
+
+	q, v, err := psql.Update(questionTable).Set("kind", string(buff.Kind)).Set("text", buff.Question).
+		Set("start_offset_ms", offset).Set("duration_ms", duration).Where(
+		"id = ?", uuid.UUID(id),
+	).ToSql()
+	if err != nil {
+		tracer.Log(sp, "failed to build sql query")
+		tracer.SetError(sp, err)
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, q, v...)
+	if err != nil {
+		tracer.Log(sp, "failed to update question")
+		tracer.SetError(sp, err)
+		return translateError(err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		tracer.Log(sp, "failed to read affected rows")
+		tracer.SetError(sp, err)
+		return translateError(err)
+	}
+	if n == 0 {
+		return model.ErrNotFound
+	}
+
+	// The update has locked the question, so it can't be resolved until this is committed
+	resolutions, err := readResolutions(ctx, tx, []uuid.UUID{uuid.UUID(id)})
+	if err != nil {
+		tracer.Log(sp, "failed to read resolution")
+		tracer.SetError(sp, err)
+		return err
+	}
+	if r, ok := resolutions[uuid.UUID(id)]; ok {
+		return &model.BuffResolvedError{Buff: id, Answer: r.Answer}
+	}
+
+	// Find the answers that are currently stored, so we can work out
+	// which of the given answers are new, and which have been removed
+	q, v, err = psql.Select("id").From(answerTable).Where("question = ?", uuid.UUID(id)).ToSql()
+	if err != nil {
+		tracer.Log(sp, "failed to build sql query")
+		tracer.SetError(sp, err)
+		return err
+	}
+
+	existingIDs := make([]uuid.UUID, 0)
+	if err := tx.SelectContext(ctx, &existingIDs, q, v...); err != nil {
+		tracer.Log(sp, "failed to select existing answers")
+		tracer.SetError(sp, err)
+		return translateError(err)
+	}
+
+	existing := make(map[uuid.UUID]bool, len(existingIDs))
+	for _, ansID := range existingIDs {
+		existing[ansID] = true
+	}
+
+	for i, ans := range buff.Answers {
+		ansID := uuid.UUID(ans.ID)
+
+		if existing[ansID] {
+			q, v, err = psql.Update(answerTable).SetMap(map[string]interface{}{
+				"text":     ans.Text,
+				"correct":  ans.Correct,
+				"position": i,
+			}).Where("id = ?", ansID).ToSql()
+		} else {
+			q, v, err = psql.Insert(answerTable).Columns(answerFields...).Values(
+				ansID, uuid.UUID(id), ans.Text, ans.Correct, i,
+			).ToSql()
+		}
+		if err != nil {
+			tracer.Log(sp, "failed to build sql query")
+			tracer.SetError(sp, err)
+			return translateError(err)
+		}
+
+		if _, err := tx.ExecContext(ctx, q, v...); err != nil {
+			tracer.Log(sp, "failed to write answer")
+			tracer.SetError(sp, err)
+			return translateError(err)
+		}
+
+		// Anything left in the map once we are done has been removed from the buff
+		delete(existing, ansID)
+	}
+
+	for ansID := range existing {
+		q, v, err = psql.Delete(answerTable).Where("id = ?", ansID).ToSql()
+		if err != nil {
+			tracer.Log(sp, "failed to build sql query")
+			tracer.SetError(sp, err)
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, q, v...); err != nil {
+			tracer.Log(sp, "failed to remove answer")
+			tracer.SetError(sp, err)
+			return err
+		}
+	}
+
+	if err := questionTags.write(ctx, tx, uuid.UUID(id), buff.Tags); err != nil {
+		tracer.Log(sp, "failed to write tags")
+		tracer.SetError(sp, err)
+		return err
+	}
+
+	// The answers that are correct, and the schedule, may have changed
+	if err := s.rescoreResponses(ctx, tx, uuid.UUID(id)); err != nil {
+		tracer.Log(sp, "failed to rescore responses")
+		tracer.SetError(sp, err)
+		return err
+	}
+
+	return translateError(tx.Commit())
 }
 
-// DeleteBuff deleted the Buff with ID model.BuffID
-// This method is not yet implemented
-func (s *Store) DeleteBuff(model.BuffID) error {
-	return errors.New("not implemented")
+// DeleteBuff deletes the Buff with ID model.BuffID, along with all of its answers
+// Its responses are removed by the database when the answers are
+func (s *Store) DeleteBuff(ctx context.Context, id model.BuffID) error {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Postgres:Delete Buff")
+	defer sp.Finish()
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		tracer.Log(sp, "failed to begin transaction")
+		tracer.SetError(sp, err)
+		return translateError(err)
+	}
+	// Rollback is a no-op once the transaction is committed,
+	// so this is just a best attempt to clean up on the error paths
+	// nolint:errcheck
+	defer tx.Rollback()
+
+	// The answers must go first, as they reference the question
+	q, v, err := psql.Delete(answerTable).Where("question = ?", uuid.UUID(id)).ToSql()
+	if err != nil {
+		tracer.Log(sp, "failed to build sql query")
+		tracer.SetError(sp, err)
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, q, v...); err != nil {
+		tracer.Log(sp, "failed to delete answers")
+		tracer.SetError(sp, err)
+		return err
+	}
+
+	q, v, err = psql.Delete(questionTable).Where("id = ?", uuid.UUID(id)).ToSql()
+	if err != nil {
+		tracer.Log(sp, "failed to build sql query")
+		tracer.SetError(sp, err)
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, q, v...)
+	if err != nil {
+		tracer.Log(sp, "failed to delete question")
+		tracer.SetError(sp, err)
+		return translateError(err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		tracer.Log(sp, "failed to read affected rows")
+		tracer.SetError(sp, err)
+		return translateError(err)
+	}
+	if n == 0 {
+		return model.ErrNotFound
+	}
+
+	return translateError(tx.Commit())
 }
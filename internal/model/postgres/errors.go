@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/lib/pq"
+)
+
+// translateError maps the errors returned by the database driver onto the model errors
+// Any other errors, including nil and errors that have already been translated, are returned unchanged
+func translateError(err error) error {
+	var translated *model.StoreError
+	if err == nil || errors.As(err, &translated) {
+		return err
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.ErrNotFound
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code.Name() == "unique_violation":
+			return &model.StoreError{Kind: model.ErrConflict, Err: err}
+		case pqErr.Code.Name() == "foreign_key_violation":
+			return &model.StoreError{Kind: model.ErrInvalidReference, Err: err}
+
+		// connection_exception, insufficient_resources and operator_intervention
+		// (e.g. the server shutting down) can all be retried
+		case pqErr.Code.Class() == "08", pqErr.Code.Class() == "53", pqErr.Code.Class() == "57":
+			if pqErr.Code.Name() == "query_canceled" {
+				return err
+			}
+			return &model.StoreError{Kind: model.ErrUnavailable, Err: err}
+		}
+		return err
+	}
+
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.As(err, &netErr) {
+		return &model.StoreError{Kind: model.ErrUnavailable, Err: err}
+	}
+	return err
+}
@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslateError(t *testing.T) {
+	var tests = []struct {
+		name   string
+		err    error
+		expect error
+	}{
+		{name: "nil", err: nil, expect: nil},
+		{name: "no rows", err: sql.ErrNoRows, expect: model.ErrNotFound},
+		{name: "unique violation", err: &pq.Error{Code: "23505"}, expect: model.ErrConflict},
+		{name: "foreign key violation", err: &pq.Error{Code: "23503"}, expect: model.ErrInvalidReference},
+		{name: "connection failure", err: &pq.Error{Code: "08006"}, expect: model.ErrUnavailable},
+		{name: "too many connections", err: &pq.Error{Code: "53300"}, expect: model.ErrUnavailable},
+		{name: "server shutting down", err: &pq.Error{Code: "57P01"}, expect: model.ErrUnavailable},
+		{name: "bad connection", err: driver.ErrBadConn, expect: model.ErrUnavailable},
+		{name: "network error", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, expect: model.ErrUnavailable},
+		{name: "wrapped unique violation", err: fmt.Errorf("insert: %w", &pq.Error{Code: "23505"}), expect: model.ErrConflict},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := translateError(tt.err)
+			if tt.expect == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.True(t, errors.Is(err, tt.expect), "expected %v, got %v", tt.expect, err)
+
+			// The original error should still be reachable
+			assert.True(t, errors.Is(err, tt.err) || tt.expect == model.ErrNotFound, "the original error should be wrapped")
+		})
+	}
+}
+
+func TestTranslateErrorUnchanged(t *testing.T) {
+	var tests = []struct {
+		name string
+		err  error
+	}{
+		{name: "unknown error", err: errors.New("the world exploded")},
+		{name: "cancelled", err: context.Canceled},
+		{name: "query cancelled", err: &pq.Error{Code: "57014"}},
+		{name: "other constraint", err: &pq.Error{Code: "23502"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.err, translateError(tt.err))
+		})
+	}
+}
+
+func TestTranslateErrorTwice(t *testing.T) {
+	once := translateError(&pq.Error{Code: "23505", Message: "duplicate key"})
+	assert.Equal(t, once, translateError(once), "translating an error again should not change it")
+}
@@ -0,0 +1,11 @@
+package postgres
+
+import "context"
+
+// Truncate removes all the data from the store
+// It is only exported for use in tests, giving each test an empty database
+func (s *Store) Truncate(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, "TRUNCATE "+scoreTable+", "+resolutionTable+", "+responseTable+", "+answerTable+", "+
+		questionTagTable+", "+videoStreamTagTable+", "+tagTable+", "+questionTable+", "+videoStreamTable)
+	return err
+}
@@ -0,0 +1,204 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/JoeReid/apiutils/tracer"
+	"github.com/JoeReid/buffassignment/internal/model"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/opentracing/opentracing-go"
+)
+
+// score is the DB representation of the structure
+type score struct {
+	UserID    string `db:"user_id"`
+	Points    int
+	Correct   int
+	Responses int
+	Scored    time.Time
+}
+
+// model returns the score as a model.Score, with the given rank
+func (s score) model(rank int) model.Score {
+	return model.Score{
+		User:      model.UserID(s.UserID),
+		Rank:      rank,
+		Points:    s.Points,
+		Correct:   s.Correct,
+		Responses: s.Responses,
+		ScoredAt:  s.Scored,
+	}
+}
+
+// ListLeaderboard returns the top scores of the stream, best first
+//
+// The scores are kept by triggers on the responses, see deploy/migrations/013_leaderboard.sql,
+// so the leaderboard is read straight from its index.
+func (s *Store) ListLeaderboard(ctx context.Context, stream model.VideoStreamID, top int) ([]model.Score, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Postgres:List Leaderboard")
+	defer sp.Finish()
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	q, v, err := psql.Select("id").From(videoStreamTable).Where("id = ?", uuid.UUID(stream)).ToSql()
+	if err != nil {
+		tracer.Log(sp, "failed to build sql query")
+		tracer.SetError(sp, err)
+		return nil, err
+	}
+
+	var id uuid.UUID
+	if err := s.db.GetContext(ctx, &id, q, v...); err != nil {
+		// No rows is translated to model.ErrNotFound
+		return nil, translateError(err)
+	}
+
+	qb := psql.Select(scoreFields...).From(scoreTable).Where("stream = ?", uuid.UUID(stream)).OrderBy(
+		"points DESC", "scored", "user_id",
+	)
+	if top != 0 {
+		qb = qb.Limit(uint64(top))
+	}
+
+	q, v, err = qb.ToSql()
+	if err != nil {
+		tracer.Log(sp, "failed to build sql query")
+		tracer.SetError(sp, err)
+		return nil, err
+	}
+
+	scores := make([]score, 0)
+	if err := s.db.SelectContext(ctx, &scores, q, v...); err != nil {
+		tracer.Log(sp, "failed to select scores")
+		tracer.SetError(sp, err)
+		return nil, translateError(err)
+	}
+
+	rtn := make([]model.Score, 0, len(scores))
+	for i, sc := range scores {
+		rtn = append(rtn, sc.model(i+1))
+	}
+	return rtn, nil
+}
+
+// GetScore returns the score of the user on the stream, ranked against every other user
+// The rank is found by counting the scores placed above it, which the index keeps together.
+func (s *Store) GetScore(ctx context.Context, stream model.VideoStreamID, user model.UserID) (*model.Score, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Postgres:Get Score")
+	defer sp.Finish()
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	q, v, err := psql.Select(scoreFields...).From(scoreTable).Where(
+		"stream = ? AND user_id = ?", uuid.UUID(stream), user.String(),
+	).ToSql()
+	if err != nil {
+		tracer.Log(sp, "failed to build sql query")
+		tracer.SetError(sp, err)
+		return nil, err
+	}
+
+	var sc score
+	if err := s.db.GetContext(ctx, &sc, q, v...); err != nil {
+		// No rows is translated to model.ErrNotFound
+		return nil, translateError(err)
+	}
+
+	q, v, err = psql.Select("count(*)").From(scoreTable).Where("stream = ?", uuid.UUID(stream)).Where(
+		"(points > ? OR (points = ? AND (scored, user_id) < (?::timestamp, ?::varchar)))",
+		sc.Points, sc.Points, sc.Scored, sc.UserID,
+	).ToSql()
+	if err != nil {
+		tracer.Log(sp, "failed to build sql query")
+		tracer.SetError(sp, err)
+		return nil, err
+	}
+
+	var above int
+	if err := s.db.GetContext(ctx, &above, q, v...); err != nil {
+		tracer.Log(sp, "failed to rank score")
+		tracer.SetError(sp, err)
+		return nil, translateError(err)
+	}
+
+	rtn := sc.model(above + 1)
+	return &rtn, nil
+}
+
+// pointsColumn returns the sql working out the points a response earns, in the same way as model.Scoring.Score
+//
+// The answer, question and video stream of the response must be joined, and created is
+// the sql for the time the response was given, taking the given args.
+func (s *Store) pointsColumn(created string, args ...interface{}) (string, []interface{}) {
+	points := "CASE WHEN NOT answers.correct THEN 0" +
+		" WHEN ?::integer = 0 OR questions.duration_ms IS NULL OR video_streams.started IS NULL THEN ?::integer" +
+		" ELSE ?::integer + ?::integer * greatest(0, questions.duration_ms - greatest(0," +
+		" floor(extract(epoch FROM " + created + " - video_streams.started) * 1000)::bigint - questions.start_offset_ms" +
+		")) / questions.duration_ms END"
+
+	v := []interface{}{s.scoring.SpeedBonus, s.scoring.Points, s.scoring.Points, s.scoring.SpeedBonus}
+	return points, append(v, args...)
+}
+
+// ScoreResponses works out the points of every response that hasn't been scored, returning how many were
+//
+// The responses given before the leaderboards were added are left unscored by
+// deploy/migrations/013_leaderboard.sql, so that they earn the points the store is configured
+// with. Every other response is scored as it is given. The scores of their users are refreshed
+// by the database, and running it again scores nothing more.
+func (s *Store) ScoreResponses(ctx context.Context) (int, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Postgres:Score Responses")
+	defer sp.Finish()
+
+	n, err := s.scoreResponses(ctx, s.db, sq.Eq{"points": nil})
+	if err != nil {
+		tracer.Log(sp, "failed to score responses")
+		tracer.SetError(sp, err)
+		return 0, err
+	}
+	return n, nil
+}
+
+// rescoreResponses works out the points of every response to the question again
+// The scores of the users whose points change are refreshed by the database.
+func (s *Store) rescoreResponses(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) error {
+	_, err := s.scoreResponses(ctx, tx, sq.Eq{"question": id})
+	return err
+}
+
+// scoreResponses works out the points of the responses matching where, returning how many were scored
+func (s *Store) scoreResponses(ctx context.Context, db sqlx.ExecerContext, where sq.Sqlizer) (int, error) {
+	points, args := s.pointsColumn("responses.created")
+
+	// The inner select uses the default placeholders, they are numbered when
+	// the whole statement is built
+	sub, subArgs, err := sq.Select().Column(points, args...).From(answerTable).Join(
+		questionTable + " ON questions.id = answers.question",
+	).Join(
+		videoStreamTable + " ON video_streams.id = questions.stream",
+	).Where("answers.id = responses.answer AND answers.question = responses.question").ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	q, v, err := psql.Update(responseTable).Set("points", sq.Expr("("+sub+")", subArgs...)).Where(where).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := db.ExecContext(ctx, q, v...)
+	if err != nil {
+		return 0, translateError(err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return int(n), nil
+}
@@ -0,0 +1,162 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/JoeReid/apiutils/tracer"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/opentracing/opentracing-go"
+)
+
+const (
+	// buffCreatedChannel is notified with the ID of every new buff by the
+	// trigger added in deploy/migrations/005_buff_notify.sql
+	buffCreatedChannel = "buff_created"
+
+	// responseCreatedChannel is notified with the ID of the buff of every new response
+	// by the trigger added in deploy/migrations/006_response_notify.sql
+	responseCreatedChannel = "response_created"
+
+	// listenerPingInterval is how often an idle listener checks its connection
+	listenerPingInterval = time.Minute
+
+	// publishTimeout bounds looking up what has been notified
+	publishTimeout = 10 * time.Second
+)
+
+// Listener passes on the changes made in the database,
+// through any replica of the service, as they are committed
+type Listener struct {
+	listener *pq.Listener
+	done     chan struct{}
+}
+
+// ListenBuffs starts listening for the buffs created in the database,
+// calling publish with each of them
+//
+// The trigger only notifies as a buff is inserted, so a scheduled buff is published
+// once as it is created, and not again as it opens.
+//
+// The listener reconnects by itself if the connection is lost, but any buffs
+// created while it is disconnected are missed. It must be closed once it is no
+// longer needed.
+func (s *Store) ListenBuffs(publish func(model.Buff)) (*Listener, error) {
+	return s.listen(buffCreatedChannel, func(id string) {
+		s.publishBuff(id, publish)
+	})
+}
+
+// ListenResults starts listening for the responses given in the database,
+// calling publish with the new results of their buff, and the stream it belongs to
+//
+// It reconnects in the same way as ListenBuffs, and must be closed in the same way.
+func (s *Store) ListenResults(publish func(model.VideoStreamID, model.Results)) (*Listener, error) {
+	return s.listen(responseCreatedChannel, func(id string) {
+		s.publishResults(id, publish)
+	})
+}
+
+// listen starts a listener calling handle with the payload of every notification on the channel
+func (s *Store) listen(channel string, handle func(payload string)) (*Listener, error) {
+	l := pq.NewListener(s.connectionString(), time.Second, time.Minute, nil)
+	if err := l.Listen(channel); err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	sl := &Listener{listener: l, done: make(chan struct{})}
+	go sl.run(handle)
+	return sl, nil
+}
+
+// Close stops the listener, waiting for the notification being handled, if any
+func (sl *Listener) Close() error {
+	err := sl.listener.Close()
+	<-sl.done
+	return err
+}
+
+// run handles the notifications until the listener is closed
+func (sl *Listener) run(handle func(payload string)) {
+	defer close(sl.done)
+
+	ping := time.NewTicker(listenerPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case n, ok := <-sl.listener.Notify:
+			if !ok {
+				return
+			}
+
+			// A nil notification means the connection was re-established
+			if n != nil {
+				handle(n.Extra)
+			}
+
+		case <-ping.C:
+			// A failed ping makes the listener reconnect, so the error isn't needed
+			go sl.listener.Ping()
+		}
+	}
+}
+
+// publishBuff looks up the buff with the notified id, and publishes it
+func (s *Store) publishBuff(id string, publish func(model.Buff)) {
+	sp := opentracing.StartSpan("Postgres:Publish Buff")
+	defer sp.Finish()
+
+	ctx, cancel := context.WithTimeout(opentracing.ContextWithSpan(context.Background(), sp), publishTimeout)
+	defer cancel()
+
+	bID, err := uuid.Parse(id)
+	if err != nil {
+		tracer.Log(sp, "notification is not a buff id")
+		tracer.SetError(sp, err)
+		return
+	}
+
+	// The buff may have been deleted again already, which leaves nothing to publish
+	b, err := s.GetBuff(ctx, model.BuffID(bID))
+	if err != nil {
+		tracer.Log(sp, "failed to get the notified buff")
+		tracer.SetError(sp, err)
+		return
+	}
+	publish(*b)
+}
+
+// publishResults looks up the results of the buff with the notified id, and publishes them
+func (s *Store) publishResults(id string, publish func(model.VideoStreamID, model.Results)) {
+	sp := opentracing.StartSpan("Postgres:Publish Results")
+	defer sp.Finish()
+
+	ctx, cancel := context.WithTimeout(opentracing.ContextWithSpan(context.Background(), sp), publishTimeout)
+	defer cancel()
+
+	bID, err := uuid.Parse(id)
+	if err != nil {
+		tracer.Log(sp, "notification is not a buff id")
+		tracer.SetError(sp, err)
+		return
+	}
+
+	b, err := s.GetBuff(ctx, model.BuffID(bID))
+	if err != nil {
+		tracer.Log(sp, "failed to get the notified buff")
+		tracer.SetError(sp, err)
+		return
+	}
+
+	res, err := s.GetResults(ctx, b.ID)
+	if err != nil {
+		tracer.Log(sp, "failed to get the results of the notified buff")
+		tracer.SetError(sp, err)
+		return
+	}
+	publish(b.Stream, *res)
+}
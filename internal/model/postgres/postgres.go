@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/validation"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -25,21 +26,40 @@ type Store struct {
 	maxConLifetime time.Duration
 	connectTimeout time.Duration
 
+	// Behaviour options
+	streamDeletePolicy model.StreamDeletePolicy
+	validator          *validation.Validator
+	scoring            model.Scoring
+
 	db *sqlx.DB
 }
 
 var (
 	videoStreamTable  = "video_streams"
-	videoStreamFields = []string{"id", "title", "created", "updated"}
+	videoStreamFields = []string{"id", "title", "state", "scheduled_start", "started", "ended", "created", "updated"}
 
 	questionTable  = "questions"
-	questionFields = []string{"id", "stream", "text"}
+	questionFields = []string{"id", "stream", "kind", "text", "created", "start_offset_ms", "duration_ms"}
 
 	answerTable  = "answers"
-	answerFields = []string{"id", "question", "text", "correct"}
+	answerFields = []string{"id", "question", "text", "correct", "position"}
+
+	responseTable  = "responses"
+	responseFields = []string{"question", "user_id", "answer", "created"}
+
+	resolutionTable  = "resolutions"
+	resolutionFields = []string{"question", "answer", "resolved_by", "resolved", "responses", "correct"}
+
+	scoreTable  = "scores"
+	scoreFields = []string{"user_id", "points", "correct", "responses", "scored"}
+
+	tagTable            = "tags"
+	questionTagTable    = "question_tags"
+	videoStreamTagTable = "video_stream_tags"
 
 	buffFields = []string{
-		"questions.id", "questions.stream", "questions.text",
+		"questions.id", "questions.stream", "questions.kind", "questions.text", "questions.created",
+		"questions.start_offset_ms", "questions.duration_ms",
 		"answers.id", "answers.question", "answers.text", "answers.correct",
 	}
 )
@@ -62,6 +82,7 @@ func NewStore(options ...StoreOption) (*Store, error) {
 		maxIdleCons:    defaultMaxIdleCons,
 		maxConLifetime: defaultMaxConLifetime,
 		connectTimeout: defaultConnectTimeout,
+		scoring:        model.DefaultScoring,
 	}
 
 	for _, opt := range options {
@@ -70,6 +91,14 @@ func NewStore(options ...StoreOption) (*Store, error) {
 		}
 	}
 
+	if s.validator == nil {
+		v, err := validation.New()
+		if err != nil {
+			return nil, err
+		}
+		s.validator = v
+	}
+
 	switch {
 	case s.user == "":
 		return nil, errors.New("required config not set: user")
@@ -81,8 +110,7 @@ func NewStore(options ...StoreOption) (*Store, error) {
 		return nil, errors.New("required config not set: hostname")
 	default:
 
-		connectionString := fmt.Sprintf("user=%s dbname=%s sslmode=disable password=%s host=%s port=%d", s.user, s.database, s.password, s.host, s.port)
-		db, err := sqlx.Open("postgres", connectionString)
+		db, err := sqlx.Open("postgres", s.connectionString())
 		if err != nil {
 			return nil, err
 		}
@@ -108,6 +136,11 @@ func NewStore(options ...StoreOption) (*Store, error) {
 	}
 }
 
+// connectionString returns the options used to connect to the database
+func (s *Store) connectionString() string {
+	return fmt.Sprintf("user=%s dbname=%s sslmode=disable password=%s host=%s port=%d", s.user, s.database, s.password, s.host, s.port)
+}
+
 // SetDBUser sets the user used in the db connection
 func SetDBUser(user string) StoreOption {
 	return func(p *Store) error {
@@ -198,3 +231,49 @@ func WithMaxConLifetime(duration time.Duration) StoreOption {
 		return nil
 	}
 }
+
+// WithStreamDeletePolicy is a function option for NewStore that sets
+// what happens to the buffs of a stream when the stream is deleted.
+// The default is model.CascadeBuffs.
+func WithStreamDeletePolicy(policy model.StreamDeletePolicy) StoreOption {
+	return func(p *Store) error {
+		switch policy {
+		case model.CascadeBuffs, model.RestrictBuffs:
+			p.streamDeletePolicy = policy
+			return nil
+		default:
+			return fmt.Errorf("unknown stream delete policy %d", policy)
+		}
+	}
+}
+
+// WithValidator is a function option for NewStore that sets the rules
+// buffs and video streams are checked against before they are written.
+// If not set, the default validation.Validator rules are used.
+func WithValidator(v *validation.Validator) StoreOption {
+	return func(p *Store) error {
+		if v == nil {
+			return errors.New("cannot set a nil validator")
+		}
+
+		p.validator = v
+		return nil
+	}
+}
+
+// WithScoring is a function option for NewStore that sets how many points
+// a correct response earns on the leaderboards of the streams.
+// If not set, model.DefaultScoring is used.
+func WithScoring(scoring model.Scoring) StoreOption {
+	return func(p *Store) error {
+		if scoring.Points <= 0 {
+			return fmt.Errorf("cannot score a correct response %d points", scoring.Points)
+		}
+		if scoring.SpeedBonus < 0 {
+			return fmt.Errorf("cannot set a speed bonus of %d points", scoring.SpeedBonus)
+		}
+
+		p.scoring = scoring
+		return nil
+	}
+}
@@ -0,0 +1,315 @@
+package postgres_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/JoeReid/buffassignment/internal/config"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/model/postgres"
+	"github.com/JoeReid/buffassignment/internal/model/storetest"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newEmptyStore connects to the testing database, removing any data already in it
+func newEmptyStore(t *testing.T, options ...postgres.StoreOption) *postgres.Store {
+	dc, err := config.DBConfig()
+	require.NoError(t, err, "failed to configure DB connection")
+
+	options = append([]postgres.StoreOption{
+		postgres.SetDBUser(dc.DBUser),
+		postgres.SetDBPassword(dc.DBPassword),
+		postgres.SetDBHostname(dc.DBHost),
+		postgres.SetDBPort(dc.DBPort),
+		postgres.SetDBName(dc.DBName),
+		postgres.SetConnectTimeout(dc.DBConnectTimeout),
+		postgres.WithMaxConLifetime(time.Minute),
+		postgres.WithMaxOpenCons(1),
+		postgres.WithMaxIdleCons(1),
+	}, options...)
+
+	store, err := postgres.NewStore(options...)
+	require.NoError(t, err, "failed to create store")
+
+	require.NoError(t, store.Truncate(context.Background()), "failed to empty the database")
+	return store
+}
+
+func TestConformance(t *testing.T) {
+	storetest.RunConformance(t, func(t *testing.T) model.Store {
+		return newEmptyStore(t)
+	})
+}
+
+func TestDeleteVideoStreamRestrictBuffs(t *testing.T) {
+	store := newEmptyStore(t, postgres.WithStreamDeletePolicy(model.RestrictBuffs))
+
+	v := model.VideoStream{
+		ID:        model.VideoStreamID(uuid.New()),
+		Title:     "a stream",
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+	require.NoError(t, store.CreateVideoStream(context.Background(), v), "failed to create video stream")
+
+	b := model.Buff{
+		ID:       model.BuffID(uuid.New()),
+		Stream:   v.ID,
+		Question: "What is the meaning of life, the universe, and everything?",
+		Answers: []model.Answer{
+			{ID: model.AnswerID(uuid.New()), Text: "42", Correct: true},
+			{ID: model.AnswerID(uuid.New()), Text: "43", Correct: false},
+		},
+	}
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	// The stream still has a buff, so it must not be removed
+	err := store.DeleteVideoStream(context.Background(), v.ID)
+
+	var hasBuffs *model.StreamHasBuffsError
+	require.True(t, errors.As(err, &hasBuffs), "the error should be a StreamHasBuffsError")
+	assert.Equal(t, 1, hasBuffs.Buffs)
+
+	_, err = store.GetVideoStream(context.Background(), v.ID)
+	assert.NoError(t, err, "the stream should still exist")
+
+	// Once the buff is gone, the stream can be removed
+	require.NoError(t, store.DeleteBuff(context.Background(), b.ID), "failed to delete buff")
+	assert.NoError(t, store.DeleteVideoStream(context.Background(), v.ID))
+}
+
+func TestLeaderboardSpeedBonus(t *testing.T) {
+	store := newEmptyStore(t, postgres.WithScoring(model.Scoring{Points: 100, SpeedBonus: 50}))
+
+	v := model.VideoStream{
+		ID:        model.VideoStreamID(uuid.New()),
+		Title:     "a stream",
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+	require.NoError(t, store.CreateVideoStream(context.Background(), v), "failed to create video stream")
+	require.NoError(t, store.TransitionVideoStream(context.Background(), v.ID, model.StreamLive), "failed to start stream")
+
+	started, err := store.GetVideoStream(context.Background(), v.ID)
+	require.NoError(t, err, "failed to get video stream")
+
+	b := model.Buff{
+		ID:       model.BuffID(uuid.New()),
+		Stream:   v.ID,
+		Question: "What is the meaning of life, the universe, and everything?",
+		Answers: []model.Answer{
+			{ID: model.AnswerID(uuid.New()), Text: "42", Correct: true},
+			{ID: model.AnswerID(uuid.New()), Text: "43", Correct: false},
+		},
+		Schedule:  &model.Schedule{Offset: time.Minute, Duration: 10 * time.Second},
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	// The bonus falls evenly while the buff is open, and is all but gone as it closes,
+	// working out the same as model.Scoring.Score does
+	for user, after := range map[model.UserID]time.Duration{
+		"alice": time.Minute + 2*time.Second,
+		"bob":   time.Minute + 8*time.Second,
+		"carol": time.Minute + 10*time.Second - 100*time.Millisecond,
+	} {
+		require.NoError(t, store.CreateResponse(context.Background(), model.Response{
+			Buff:      b.ID,
+			User:      user,
+			Answer:    b.Answers[0].ID,
+			CreatedAt: started.StartedAt.Add(after),
+		}), "failed to create response")
+	}
+
+	scores, err := store.ListLeaderboard(context.Background(), v.ID, 0)
+	require.NoError(t, err, "failed to list leaderboard")
+	require.Len(t, scores, 3)
+
+	assert.Equal(t, model.UserID("alice"), scores[0].User)
+	assert.Equal(t, 140, scores[0].Points)
+	assert.Equal(t, model.UserID("bob"), scores[1].User)
+	assert.Equal(t, 110, scores[1].Points)
+	assert.Equal(t, model.UserID("carol"), scores[2].User)
+	assert.Equal(t, 100, scores[2].Points)
+}
+
+func TestScoreResponses(t *testing.T) {
+	store := newEmptyStore(t, postgres.WithScoring(model.Scoring{Points: 50}))
+
+	v := model.VideoStream{
+		ID:        model.VideoStreamID(uuid.New()),
+		Title:     "a stream",
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+	require.NoError(t, store.CreateVideoStream(context.Background(), v), "failed to create video stream")
+	require.NoError(t, store.TransitionVideoStream(context.Background(), v.ID, model.StreamLive), "failed to start stream")
+
+	b := model.Buff{
+		ID:       model.BuffID(uuid.New()),
+		Stream:   v.ID,
+		Question: "What is the meaning of life, the universe, and everything?",
+		Answers: []model.Answer{
+			{ID: model.AnswerID(uuid.New()), Text: "42", Correct: true},
+			{ID: model.AnswerID(uuid.New()), Text: "43", Correct: false},
+		},
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	for user, answer := range map[model.UserID]int{"alice": 0, "bob": 1} {
+		require.NoError(t, store.CreateResponse(context.Background(), model.Response{
+			Buff:      b.ID,
+			User:      user,
+			Answer:    b.Answers[answer].ID,
+			CreatedAt: time.Now().UTC(),
+		}), "failed to create response")
+	}
+
+	// Every response is scored as it is given, so there is nothing to score
+	n, err := store.ScoreResponses(context.Background())
+	require.NoError(t, err, "failed to score responses")
+	assert.Equal(t, 0, n)
+
+	// Leave the responses unscored, as the migration adding the leaderboards does
+	dc, err := config.DBConfig()
+	require.NoError(t, err, "failed to configure DB connection")
+	db, err := sql.Open("postgres", fmt.Sprintf(
+		"user=%s dbname=%s sslmode=disable password=%s host=%s port=%d", dc.DBUser, dc.DBName, dc.DBPassword, dc.DBHost, dc.DBPort,
+	))
+	require.NoError(t, err, "failed to connect to the database")
+	defer db.Close()
+	_, err = db.ExecContext(context.Background(), "UPDATE responses SET points = NULL")
+	require.NoError(t, err, "failed to unscore responses")
+
+	n, err = store.ScoreResponses(context.Background())
+	require.NoError(t, err, "failed to score responses")
+	assert.Equal(t, 2, n)
+
+	scores, err := store.ListLeaderboard(context.Background(), v.ID, 0)
+	require.NoError(t, err, "failed to list leaderboard")
+	require.Len(t, scores, 2)
+	assert.Equal(t, model.UserID("alice"), scores[0].User)
+	assert.Equal(t, 50, scores[0].Points)
+	assert.Equal(t, model.UserID("bob"), scores[1].User)
+	assert.Equal(t, 0, scores[1].Points)
+
+	// Scoring again scores nothing more
+	n, err = store.ScoreResponses(context.Background())
+	require.NoError(t, err, "failed to score responses")
+	assert.Equal(t, 0, n)
+}
+
+func TestStoreOptions(t *testing.T) {
+	_, err := postgres.NewStore(postgres.WithMaxOpenCons(0))
+	assert.Error(t, err)
+
+	_, err = postgres.NewStore(postgres.WithStreamDeletePolicy(model.StreamDeletePolicy(42)))
+	assert.Error(t, err)
+
+	_, err = postgres.NewStore(postgres.WithValidator(nil))
+	assert.Error(t, err)
+
+	_, err = postgres.NewStore(postgres.WithScoring(model.Scoring{Points: 0}))
+	assert.Error(t, err)
+
+	_, err = postgres.NewStore(postgres.WithScoring(model.Scoring{Points: 100, SpeedBonus: -1}))
+	assert.Error(t, err)
+
+	_, err = postgres.NewStore()
+	assert.Error(t, err, "the connection details are required")
+}
+
+func TestListenBuffs(t *testing.T) {
+	store := newEmptyStore(t)
+
+	published := make(chan model.Buff, 1)
+	listener, err := store.ListenBuffs(func(b model.Buff) { published <- b })
+	require.NoError(t, err, "failed to listen for buffs")
+	defer listener.Close()
+
+	v := model.VideoStream{
+		ID:        model.VideoStreamID(uuid.New()),
+		Title:     "a stream",
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+	require.NoError(t, store.CreateVideoStream(context.Background(), v), "failed to create video stream")
+
+	b := model.Buff{
+		ID:       model.BuffID(uuid.New()),
+		Stream:   v.ID,
+		Question: "What is the meaning of life, the universe, and everything?",
+		Answers: []model.Answer{
+			{ID: model.AnswerID(uuid.New()), Text: "42", Correct: true},
+			{ID: model.AnswerID(uuid.New()), Text: "43", Correct: false},
+		},
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	// The buff is published with its answers, as they are committed along with it
+	select {
+	case got := <-published:
+		assert.Equal(t, b.ID, got.ID)
+		assert.Equal(t, b.Answers, got.Answers)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the buff was not published")
+	}
+}
+
+func TestListenResults(t *testing.T) {
+	store := newEmptyStore(t)
+
+	type published struct {
+		stream  model.VideoStreamID
+		results model.Results
+	}
+	results := make(chan published, 1)
+	listener, err := store.ListenResults(func(stream model.VideoStreamID, res model.Results) {
+		results <- published{stream, res}
+	})
+	require.NoError(t, err, "failed to listen for results")
+	defer listener.Close()
+
+	v := model.VideoStream{
+		ID:        model.VideoStreamID(uuid.New()),
+		Title:     "a stream",
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+	require.NoError(t, store.CreateVideoStream(context.Background(), v), "failed to create video stream")
+	require.NoError(t, store.TransitionVideoStream(context.Background(), v.ID, model.StreamLive), "failed to start video stream")
+
+	b := model.Buff{
+		ID:       model.BuffID(uuid.New()),
+		Stream:   v.ID,
+		Question: "What is the meaning of life, the universe, and everything?",
+		Answers: []model.Answer{
+			{ID: model.AnswerID(uuid.New()), Text: "42", Correct: true},
+			{ID: model.AnswerID(uuid.New()), Text: "43", Correct: false},
+		},
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	r := model.Response{Buff: b.ID, User: "user-42", Answer: b.Answers[0].ID, CreatedAt: time.Now().UTC()}
+	require.NoError(t, store.CreateResponse(context.Background(), r), "failed to create response")
+
+	// The results are published with the response counted, as it is committed before the notification
+	select {
+	case got := <-results:
+		assert.Equal(t, v.ID, got.stream)
+		assert.Equal(t, b.ID, got.results.Buff)
+		assert.Equal(t, 1, got.results.Total)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the results were not published")
+	}
+}
@@ -0,0 +1,193 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/JoeReid/apiutils/tracer"
+	"github.com/JoeReid/buffassignment/internal/model"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/opentracing/opentracing-go"
+)
+
+// resolution is the DB representation of the structure
+type resolution struct {
+	Question   uuid.UUID
+	Answer     uuid.UUID
+	ResolvedBy string `db:"resolved_by"`
+	Resolved   time.Time
+	Responses  int
+	Correct    int
+}
+
+// readResolutions returns the resolutions of any of the questions that have been resolved, keyed by the question
+func readResolutions(ctx context.Context, db sqlx.QueryerContext, ids []uuid.UUID) (map[uuid.UUID]*model.Resolution, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	q, v, err := psql.Select(resolutionFields...).From(resolutionTable).Where(sq.Eq{"question": ids}).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]resolution, 0)
+	if err := sqlx.SelectContext(ctx, db, &rows, q, v...); err != nil {
+		return nil, translateError(err)
+	}
+
+	rtn := make(map[uuid.UUID]*model.Resolution, len(rows))
+	for _, row := range rows {
+		rtn[row.Question] = &model.Resolution{
+			Answer:     model.AnswerID(row.Answer),
+			ResolvedBy: row.ResolvedBy,
+			ResolvedAt: row.Resolved,
+			Responses:  row.Responses,
+			Correct:    row.Correct,
+		}
+	}
+	return rtn, nil
+}
+
+// ResolveBuff settles the prediction with ID model.BuffID, counting the responses it has been given
+// The resolution is validated first, returning a validation.Errors if it breaks any rules
+//
+// The winning answer is marked as correct, the responses scored, and the resolution recorded, in a single transaction.
+// The question is locked while it is resolved, so it can't be updated, responded to or resolved at the same time.
+func (s *Store) ResolveBuff(ctx context.Context, id model.BuffID, res model.Resolution) (*model.Buff, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Postgres:Resolve Buff")
+	defer sp.Finish()
+
+	if err := s.validator.Resolution(res); err != nil {
+		tracer.Log(sp, "resolution failed validation")
+		tracer.SetError(sp, err)
+		return nil, err
+	}
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		tracer.Log(sp, "failed to begin transaction")
+		tracer.SetError(sp, err)
+		return nil, translateError(err)
+	}
+	// Rollback is a no-op once the transaction is committed,
+	// so this is just a best attempt to clean up on the error paths
+	// nolint:errcheck
+	defer tx.Rollback()
+
+	q, v, err := psql.Select("kind").From(questionTable).Where("id = ?", uuid.UUID(id)).Suffix("FOR UPDATE").ToSql()
+	if err != nil {
+		tracer.Log(sp, "failed to build sql query")
+		tracer.SetError(sp, err)
+		return nil, err
+	}
+
+	var kind string
+	if err := tx.GetContext(ctx, &kind, q, v...); err != nil {
+		// No rows is translated to model.ErrNotFound
+		return nil, translateError(err)
+	}
+	if model.BuffKind(kind) != model.BuffPrediction {
+		return nil, &model.BuffKindError{Buff: id, Kind: model.BuffKind(kind)}
+	}
+
+	existing, err := readResolutions(ctx, tx, []uuid.UUID{uuid.UUID(id)})
+	if err != nil {
+		tracer.Log(sp, "failed to read resolution")
+		tracer.SetError(sp, err)
+		return nil, err
+	}
+
+	// Resolving again to the same answer keeps the first resolution
+	// Nothing has been written, so the transaction is rolled back to release
+	// the lock and its connection before the buff is read again.
+	if r, ok := existing[uuid.UUID(id)]; ok {
+		if r.Answer != res.Answer {
+			return nil, &model.BuffResolvedError{Buff: id, Answer: r.Answer}
+		}
+		if err := tx.Rollback(); err != nil {
+			tracer.Log(sp, "failed to roll back transaction")
+			tracer.SetError(sp, err)
+			return nil, translateError(err)
+		}
+		return s.GetBuff(ctx, id)
+	}
+
+	q, v, err = psql.Update(answerTable).Set("correct", true).Where(
+		"id = ? AND question = ?", uuid.UUID(res.Answer), uuid.UUID(id),
+	).ToSql()
+	if err != nil {
+		tracer.Log(sp, "failed to build sql query")
+		tracer.SetError(sp, err)
+		return nil, err
+	}
+
+	ans, err := tx.ExecContext(ctx, q, v...)
+	if err != nil {
+		tracer.Log(sp, "failed to mark answer")
+		tracer.SetError(sp, err)
+		return nil, translateError(err)
+	}
+
+	n, err := ans.RowsAffected()
+	if err != nil {
+		tracer.Log(sp, "failed to read affected rows")
+		tracer.SetError(sp, err)
+		return nil, translateError(err)
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("%w: answer %s is not an answer to buff %s", model.ErrInvalidReference, res.Answer, id)
+	}
+
+	if err := s.rescoreResponses(ctx, tx, uuid.UUID(id)); err != nil {
+		tracer.Log(sp, "failed to rescore responses")
+		tracer.SetError(sp, err)
+		return nil, err
+	}
+
+	q, v, err = psql.Select("count(*) AS responses").Column(
+		"count(*) FILTER (WHERE answer = ?) AS correct", uuid.UUID(res.Answer),
+	).From(
+		responseTable,
+	).Where("question = ?", uuid.UUID(id)).ToSql()
+	if err != nil {
+		tracer.Log(sp, "failed to build sql query")
+		tracer.SetError(sp, err)
+		return nil, err
+	}
+
+	if err := tx.QueryRowxContext(ctx, q, v...).Scan(&res.Responses, &res.Correct); err != nil {
+		tracer.Log(sp, "failed to count responses")
+		tracer.SetError(sp, err)
+		return nil, translateError(err)
+	}
+
+	q, v, err = psql.Insert(resolutionTable).Columns(resolutionFields...).Values(
+		uuid.UUID(id), uuid.UUID(res.Answer), res.ResolvedBy, res.ResolvedAt, res.Responses, res.Correct,
+	).ToSql()
+	if err != nil {
+		tracer.Log(sp, "failed to build sql query")
+		tracer.SetError(sp, err)
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, q, v...); err != nil {
+		tracer.Log(sp, "failed to insert resolution")
+		tracer.SetError(sp, err)
+		return nil, translateError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		tracer.Log(sp, "failed to commit transaction")
+		tracer.SetError(sp, err)
+		return nil, translateError(err)
+	}
+	return s.GetBuff(ctx, id)
+}
@@ -0,0 +1,256 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/JoeReid/apiutils/tracer"
+	"github.com/JoeReid/buffassignment/internal/model"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/opentracing/opentracing-go"
+)
+
+// response is the DB representation of the structure
+type response struct {
+	Question uuid.UUID
+	UserID   string `db:"user_id"`
+	Answer   uuid.UUID
+	Created  time.Time
+}
+
+// answerResult is the DB representation of the structure
+type answerResult struct {
+	ID        uuid.UUID
+	Text      string
+	Responses int
+}
+
+// closedCondition is true when the question has closed by the timestamp parameter, matching
+// model.Buff.ClosedBy, it needs the questions and video_streams tables joined
+const closedCondition = "questions.duration_ms IS NOT NULL AND video_streams.started IS NOT NULL AND " +
+	"?::timestamp >= video_streams.started + (questions.start_offset_ms + questions.duration_ms) * interval '1 millisecond'"
+
+// CreateResponse adds a new response to a buff into the postgres store
+// The response is validated first, returning a validation.Errors if it breaks any rules
+//
+// The response is only inserted if the answer belongs to the buff, the buff hasn't been
+// resolved, its stream is live, and it hasn't closed yet. The primary key stops a user
+// from responding to the same buff twice.
+//
+// The points the response earns are worked out as it is inserted, and the database
+// adds them to the leaderboard of the buff's stream.
+// The question is share locked while the response is inserted, so the buff can't be
+// resolved until it has been scored, nor scored after the buff has been resolved.
+func (s *Store) CreateResponse(ctx context.Context, resp model.Response) error {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Postgres:Create Response")
+	defer sp.Finish()
+
+	if err := s.validator.Response(resp); err != nil {
+		tracer.Log(sp, "response failed validation")
+		tracer.SetError(sp, err)
+		return err
+	}
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		tracer.Log(sp, "failed to begin transaction")
+		tracer.SetError(sp, err)
+		return translateError(err)
+	}
+	// Rollback is a no-op once the transaction is committed,
+	// so this is just a best attempt to clean up on the error paths
+	// nolint:errcheck
+	defer tx.Rollback()
+
+	// The share lock conflicts with the lock ResolveBuff takes, so the question can't be
+	// resolved between checking for a resolution and inserting the response
+	q, v, err := psql.Select("id").From(questionTable).Where("id = ?", uuid.UUID(resp.Buff)).Suffix("FOR SHARE").ToSql()
+	if err != nil {
+		tracer.Log(sp, "failed to build sql query")
+		tracer.SetError(sp, err)
+		return err
+	}
+
+	var locked uuid.UUID
+	if err := tx.GetContext(ctx, &locked, q, v...); err != nil {
+		// No rows is translated to model.ErrNotFound
+		return translateError(err)
+	}
+
+	points, args := s.pointsColumn("?::timestamp", resp.CreatedAt)
+
+	// The inner select uses the default placeholders, they are numbered when
+	// the whole statement is built. The casts are needed as postgres can't
+	// infer the types of parameters in the select list.
+	answer := sq.Select("answers.question").Column("?::varchar", resp.User.String()).Column("answers.id").Column(
+		"?::timestamp", resp.CreatedAt,
+	).Column(points, args...).From(answerTable).Join(
+		questionTable+" ON questions.id = answers.question",
+	).Join(
+		videoStreamTable+" ON video_streams.id = questions.stream",
+	).Where("answers.id = ? AND answers.question = ?", uuid.UUID(resp.Answer), uuid.UUID(resp.Buff)).Where(
+		"NOT EXISTS (SELECT 1 FROM "+resolutionTable+" WHERE question = ?)", uuid.UUID(resp.Buff),
+	).Where("video_streams.state = ?", string(model.StreamLive)).Where("NOT ("+closedCondition+")", resp.CreatedAt)
+
+	q, v, err = psql.Insert(responseTable).Columns(responseFields...).Columns("points").Select(answer).ToSql()
+	if err != nil {
+		tracer.Log(sp, "failed to build sql query")
+		tracer.SetError(sp, err)
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, q, v...)
+	if err != nil {
+		tracer.Log(sp, "failed to insert response")
+		tracer.SetError(sp, err)
+		return translateError(err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		tracer.Log(sp, "failed to read affected rows")
+		tracer.SetError(sp, err)
+		return translateError(err)
+	}
+	if n != 0 {
+		if err := tx.Commit(); err != nil {
+			tracer.Log(sp, "failed to commit transaction")
+			tracer.SetError(sp, err)
+			return translateError(err)
+		}
+		return nil
+	}
+
+	// Nothing was inserted, either because there is no such buff, because it has been
+	// resolved, because its stream isn't live, because it has closed, or because the answer
+	// belongs to another buff
+	q, v, err = psql.Select("questions.id", "questions.stream", "video_streams.state").Column(
+		"("+closedCondition+") AS closed", resp.CreatedAt,
+	).From(questionTable).Join(
+		videoStreamTable+" ON video_streams.id = questions.stream",
+	).Where("questions.id = ?", uuid.UUID(resp.Buff)).ToSql()
+	if err != nil {
+		tracer.Log(sp, "failed to build sql query")
+		tracer.SetError(sp, err)
+		return err
+	}
+
+	var found struct {
+		ID     uuid.UUID
+		Stream uuid.UUID
+		State  string
+		Closed bool
+	}
+	if err := tx.GetContext(ctx, &found, q, v...); err != nil {
+		// No rows is translated to model.ErrNotFound
+		return translateError(err)
+	}
+
+	resolutions, err := readResolutions(ctx, tx, []uuid.UUID{found.ID})
+	if err != nil {
+		tracer.Log(sp, "failed to read resolution")
+		tracer.SetError(sp, err)
+		return err
+	}
+	if r, ok := resolutions[found.ID]; ok {
+		return &model.BuffResolvedError{Buff: resp.Buff, Answer: r.Answer}
+	}
+	if state := model.StreamState(found.State); state != model.StreamLive {
+		return &model.StreamNotLiveError{Stream: model.VideoStreamID(found.Stream), State: state}
+	}
+	if found.Closed {
+		return &model.BuffClosedError{Buff: resp.Buff}
+	}
+	return fmt.Errorf("%w: answer %s is not an answer to buff %s", model.ErrInvalidReference, resp.Answer, resp.Buff)
+}
+
+// ListResponseForUser returns the responses the user has given to any of the given buffs
+func (s *Store) ListResponseForUser(ctx context.Context, user model.UserID, buffs []model.BuffID) ([]model.Response, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Postgres:List Response For User")
+	defer sp.Finish()
+
+	rtn := make([]model.Response, 0)
+	if len(buffs) == 0 {
+		return rtn, nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(buffs))
+	for _, id := range buffs {
+		ids = append(ids, uuid.UUID(id))
+	}
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	q, v, err := psql.Select(responseFields...).From(responseTable).Where(
+		sq.Eq{"user_id": user.String(), "question": ids},
+	).ToSql()
+	if err != nil {
+		tracer.Log(sp, "failed to build sql query")
+		tracer.SetError(sp, err)
+		return nil, err
+	}
+
+	responses := make([]response, 0)
+	if err := s.db.SelectContext(ctx, &responses, q, v...); err != nil {
+		tracer.Log(sp, "failed to select responses")
+		tracer.SetError(sp, err)
+		return nil, translateError(err)
+	}
+
+	for _, resp := range responses {
+		rtn = append(rtn, model.Response{
+			Buff:      model.BuffID(resp.Question),
+			User:      model.UserID(resp.UserID),
+			Answer:    model.AnswerID(resp.Answer),
+			CreatedAt: resp.Created,
+		})
+	}
+	return rtn, nil
+}
+
+// GetResults returns the tally of the responses to the buff with the given id
+// Every answer is included, even those nobody has given
+func (s *Store) GetResults(ctx context.Context, id model.BuffID) (*model.Results, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Postgres:Get Results")
+	defer sp.Finish()
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	q, v, err := psql.Select("answers.id", "answers.text", "count(responses.answer) AS responses").From(answerTable).LeftJoin(
+		responseTable+" ON responses.question = answers.question AND responses.answer = answers.id",
+	).Where("answers.question = ?", uuid.UUID(id)).GroupBy(
+		"answers.id", "answers.text", "answers.position",
+	).OrderBy("answers.position").ToSql()
+	if err != nil {
+		tracer.Log(sp, "failed to build sql query")
+		tracer.SetError(sp, err)
+		return nil, err
+	}
+
+	answers := make([]answerResult, 0)
+	if err := s.db.SelectContext(ctx, &answers, q, v...); err != nil {
+		tracer.Log(sp, "failed to select results")
+		tracer.SetError(sp, err)
+		return nil, translateError(err)
+	}
+
+	// Every buff has answers, so no rows means there is no buff
+	if len(answers) == 0 {
+		return nil, model.ErrNotFound
+	}
+
+	res := &model.Results{Buff: id, Answers: make([]model.AnswerResult, 0, len(answers))}
+	for _, ans := range answers {
+		res.Total += ans.Responses
+		res.Answers = append(res.Answers, model.AnswerResult{
+			Answer:    model.AnswerID(ans.ID),
+			Text:      ans.Text,
+			Responses: ans.Responses,
+		})
+	}
+	return res, nil
+}
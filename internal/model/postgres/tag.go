@@ -0,0 +1,136 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/JoeReid/apiutils/tracer"
+	"github.com/JoeReid/buffassignment/internal/model"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/opentracing/opentracing-go"
+)
+
+// tagLink describes a table linking tags to the rows of another table
+type tagLink struct {
+	table  string
+	column string
+}
+
+var (
+	questionTags    = tagLink{table: questionTagTable, column: "question"}
+	videoStreamTags = tagLink{table: videoStreamTagTable, column: "stream"}
+)
+
+// whereHasTags restricts the select to the rows, identified by the id column, that have every one of the tags
+func (l tagLink) whereHasTags(qb sq.SelectBuilder, id string, tags []string) sq.SelectBuilder {
+	if len(tags) == 0 {
+		return qb
+	}
+
+	unique := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		unique[tag] = true
+	}
+
+	// Each tag is only linked to a row once, so counting the matching links tells if every tag is there
+	sub := sq.Select(l.column).From(l.table).Where(sq.Eq{"tag": tags}).GroupBy(l.column).Having(
+		"count(*) = ?", len(unique),
+	)
+	return qb.Where(sq.Expr(id+" IN (?)", sub))
+}
+
+// write replaces the tags linked to the row with the given tags, adding any tags that are new
+func (l tagLink) write(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, tags []string) error {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	q, v, err := psql.Delete(l.table).Where(sq.Eq{l.column: id}).ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, q, v...); err != nil {
+		return translateError(err)
+	}
+
+	if len(tags) == 0 {
+		return nil
+	}
+
+	newTags := psql.Insert(tagTable).Columns("name").Suffix("ON CONFLICT DO NOTHING")
+	links := psql.Insert(l.table).Columns(l.column, "tag")
+	for _, tag := range tags {
+		newTags = newTags.Values(tag)
+		links = links.Values(id, tag)
+	}
+
+	for _, qb := range []sq.InsertBuilder{newTags, links} {
+		q, v, err := qb.ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, q, v...); err != nil {
+			return translateError(err)
+		}
+	}
+	return nil
+}
+
+// read returns the tags linked to each of the rows, in order, keyed by the id of the row
+func (l tagLink) read(ctx context.Context, db sqlx.QueryerContext, ids []uuid.UUID) (map[uuid.UUID][]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	q, v, err := psql.Select(l.column+" AS id", "tag").From(l.table).Where(sq.Eq{l.column: ids}).OrderBy("tag").ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []struct {
+		ID  uuid.UUID
+		Tag string
+	}{}
+	if err := sqlx.SelectContext(ctx, db, &rows, q, v...); err != nil {
+		return nil, translateError(err)
+	}
+
+	rtn := make(map[uuid.UUID][]string)
+	for _, row := range rows {
+		rtn[row.ID] = append(rtn[row.ID], row.Tag)
+	}
+	return rtn, nil
+}
+
+// ListTags returns a slice of model.TagCount for every tag on a buff or video stream
+// The tags are ordered by the tag
+func (s *Store) ListTags(ctx context.Context) ([]model.TagCount, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Postgres:List Tags")
+	defer sp.Finish()
+
+	// Tags are never deleted, so those no longer linked to anything are left out
+	q := `SELECT tag, buffs, video_streams FROM (
+		SELECT name AS tag,
+			(SELECT count(*) FROM ` + questionTagTable + ` WHERE tag = tags.name) AS buffs,
+			(SELECT count(*) FROM ` + videoStreamTagTable + ` WHERE tag = tags.name) AS video_streams
+		FROM ` + tagTable + `
+	) AS counts WHERE buffs + video_streams > 0 ORDER BY tag`
+
+	rows := []struct {
+		Tag          string
+		Buffs        int
+		VideoStreams int `db:"video_streams"`
+	}{}
+	if err := s.db.SelectContext(ctx, &rows, q); err != nil {
+		tracer.Log(sp, "failed to run query")
+		tracer.SetError(sp, err)
+		return nil, translateError(err)
+	}
+
+	rtn := make([]model.TagCount, 0, len(rows))
+	for _, row := range rows {
+		rtn = append(rtn, model.TagCount{Tag: row.Tag, Buffs: row.Buffs, VideoStreams: row.VideoStreams})
+	}
+	return rtn, nil
+}
@@ -1,24 +1,59 @@
 package postgres
 
 import (
-	"errors"
+	"context"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/JoeReid/apiutils/tracer"
 	"github.com/JoeReid/buffassignment/internal/model"
 	sq "github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
+	"github.com/opentracing/opentracing-go"
 )
 
 // videoStream is the DB representation of the structure
 type videoStream struct {
-	ID      uuid.UUID
-	Title   string
-	Created time.Time
-	Updated time.Time
+	ID             uuid.UUID
+	Title          string
+	State          string
+	ScheduledStart *time.Time `db:"scheduled_start"`
+	Started        *time.Time
+	Ended          *time.Time
+	Created        time.Time
+	Updated        time.Time
+}
+
+// model converts the row into a model.VideoStream
+func (v videoStream) model() model.VideoStream {
+	return model.VideoStream{
+		ID:             model.VideoStreamID(v.ID),
+		Title:          v.Title,
+		State:          model.StreamState(v.State),
+		ScheduledStart: v.ScheduledStart,
+		StartedAt:      v.Started,
+		EndedAt:        v.Ended,
+		CreatedAt:      v.Created,
+		UpdatedAt:      v.Updated,
+	}
+}
+
+// utcTime returns the time in UTC, or nil if t is nil
+// Timestamp columns don't hold a time zone, so times are always written in UTC
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
 }
 
 // GetVideoStream returns a model.VideoStream by it's id
-func (s *Store) GetVideoStream(id model.VideoStreamID) (*model.VideoStream, error) {
+func (s *Store) GetVideoStream(ctx context.Context, id model.VideoStreamID) (*model.VideoStream, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Postgres:Get Video Stream")
+	defer sp.Finish()
+
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
 	q, v, err := psql.Select(videoStreamFields...).From(videoStreamTable).Where("id = ?", uuid.UUID(id)).Limit(1).ToSql()
@@ -27,23 +62,37 @@ func (s *Store) GetVideoStream(id model.VideoStreamID) (*model.VideoStream, erro
 	}
 
 	vid := videoStream{}
-	if err := s.db.Get(&vid, q, v...); err != nil {
+	if err := s.db.GetContext(ctx, &vid, q, v...); err != nil {
+		// No rows is translated to model.ErrNotFound
+		return nil, translateError(err)
+	}
+
+	tags, err := videoStreamTags.read(ctx, s.db, []uuid.UUID{vid.ID})
+	if err != nil {
 		return nil, err
 	}
 
-	return &model.VideoStream{
-		ID:        model.VideoStreamID(vid.ID),
-		Title:     vid.Title,
-		CreatedAt: vid.Created,
-		UpdatedAt: vid.Updated,
-	}, nil
+	mdlVid := vid.model()
+	mdlVid.Tags = tags[vid.ID]
+	return &mdlVid, nil
 }
 
 // ListVideoStream returns a slice of model.VideoStream using offset and limit semantics
-func (s *Store) ListVideoStream(offset, limit int) ([]model.VideoStream, error) {
+// Only the streams matching the query are returned, ordered by its sort and then by creation time
+func (s *Store) ListVideoStream(ctx context.Context, query model.VideoStreamQuery, offset, limit int) ([]model.VideoStream, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Postgres:List Video Stream")
+	defer sp.Finish()
+
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
-	qb := psql.Select(videoStreamFields...).From(videoStreamTable)
+	order, err := orderVideoStreamQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	// The id breaks ties, so that pages are stable even when
+	// several streams share a creation time
+	qb := whereVideoStreamQuery(psql.Select(videoStreamFields...).From(videoStreamTable), query).OrderBy(order...)
 
 	if offset != 0 {
 		qb = qb.Offset(uint64(offset))
@@ -56,46 +105,348 @@ func (s *Store) ListVideoStream(offset, limit int) ([]model.VideoStream, error)
 	if err != nil {
 		return nil, err
 	}
+	return s.selectVideoStreams(ctx, q, v)
+}
+
+// ListVideoStreamAfter returns a slice of model.VideoStream using keyset semantics
+// The streams matching the query after the cursor are returned, or the first streams if it is nil
+func (s *Store) ListVideoStreamAfter(ctx context.Context, query model.VideoStreamQuery, after *model.Cursor, limit int) ([]model.VideoStream, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Postgres:List Video Stream After")
+	defer sp.Finish()
 
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	qb := whereVideoStreamQuery(psql.Select(videoStreamFields...).From(videoStreamTable), query).OrderBy("created", "id")
+
+	// The row comparison matches the order, and can use the (created, id) index
+	if after != nil {
+		qb = qb.Where("(created, id) > (?, ?)", after.CreatedAt.UTC(), after.ID)
+	}
+	if limit != 0 {
+		qb = qb.Limit(uint64(limit))
+	}
+
+	q, v, err := qb.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	return s.selectVideoStreams(ctx, q, v)
+}
+
+// whereVideoStreamQuery restricts the select to the streams matching the query
+func whereVideoStreamQuery(qb sq.SelectBuilder, query model.VideoStreamQuery) sq.SelectBuilder {
+	if len(query.States) != 0 {
+		states := make([]string, 0, len(query.States))
+		for _, state := range query.States {
+			states = append(states, string(state))
+		}
+		// squirrel expands a slice into an IN list
+		qb = qb.Where(sq.Eq{"state": states})
+	}
+	if query.TitleContains != "" {
+		qb = qb.Where(sq.ILike{"title": "%" + escapeLike(query.TitleContains) + "%"})
+	}
+	if query.CreatedAfter != nil {
+		qb = qb.Where(sq.Gt{"created": query.CreatedAfter.UTC()})
+	}
+	if query.CreatedBefore != nil {
+		qb = qb.Where(sq.Lt{"created": query.CreatedBefore.UTC()})
+	}
+	if query.UpdatedSince != nil {
+		qb = qb.Where(sq.GtOrEq{"updated": query.UpdatedSince.UTC()})
+	}
+	return videoStreamTags.whereHasTags(qb, "id", query.Tags)
+}
+
+// videoStreamSortColumns maps the fields streams can be sorted by onto their columns
+// Only the columns listed here are ever written into the ORDER BY of a query
+//
+// Titles are compared byte by byte, as the memory store does, rather than
+// by the collation of the database.
+var videoStreamSortColumns = map[model.VideoStreamSortField]string{
+	model.SortStreamCreated: "created",
+	model.SortStreamUpdated: "updated",
+	model.SortStreamTitle:   `title COLLATE "C"`,
+}
+
+// orderVideoStreamQuery returns the ORDER BY of the query, ending with the
+// creation time and id so that the order is always stable
+func orderVideoStreamQuery(query model.VideoStreamQuery) ([]string, error) {
+	order := make([]string, 0, len(query.Sort)+2)
+	for _, s := range query.Sort {
+		column, ok := videoStreamSortColumns[s.Field]
+		if !ok {
+			return nil, fmt.Errorf("can't sort video streams by %q", s.Field)
+		}
+		if s.Descending {
+			column += " DESC"
+		}
+		order = append(order, column)
+	}
+	return append(order, "created", "id"), nil
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern, using the default escape character
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// escapeLike returns the pattern matching s literally
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// selectVideoStreams runs a query selecting the videoStreamFields, and converts the rows to the model
+func (s *Store) selectVideoStreams(ctx context.Context, q string, v []interface{}) ([]model.VideoStream, error) {
 	vids := make([]videoStream, 0)
-	if err := s.db.Select(&vids, q, v...); err != nil {
+	if err := s.db.SelectContext(ctx, &vids, q, v...); err != nil {
+		return nil, translateError(err)
+	}
+
+	ids := make([]uuid.UUID, 0, len(vids))
+	for _, vid := range vids {
+		ids = append(ids, vid.ID)
+	}
+
+	tags, err := videoStreamTags.read(ctx, s.db, ids)
+	if err != nil {
 		return nil, err
 	}
 
 	mdlVids := make([]model.VideoStream, 0, len(vids))
 	for _, vid := range vids {
-		mdlVids = append(mdlVids, model.VideoStream{
-			ID:        model.VideoStreamID(vid.ID),
-			Title:     vid.Title,
-			CreatedAt: vid.Created,
-			UpdatedAt: vid.Updated,
-		})
+		mdlVid := vid.model()
+		mdlVid.Tags = tags[vid.ID]
+		mdlVids = append(mdlVids, mdlVid)
 	}
 	return mdlVids, nil
 }
 
 // CreateVideoStream adds a new VideoStream object into the postgres store
-func (s *Store) CreateVideoStream(vid model.VideoStream) error {
+// A stream without a state is stored as model.StreamScheduled
+//
+// The stream is validated first, returning a validation.Errors if it breaks any rules
+func (s *Store) CreateVideoStream(ctx context.Context, vid model.VideoStream) error {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Postgres:Create Video Stream")
+	defer sp.Finish()
+
+	if err := s.validator.VideoStream(vid); err != nil {
+		return err
+	}
+
+	if vid.State == "" {
+		vid.State = model.StreamScheduled
+	}
+
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
 	q, v, err := psql.Insert(videoStreamTable).Columns(videoStreamFields...).Values(
-		uuid.UUID(vid.ID), vid.Title, vid.CreatedAt, vid.UpdatedAt,
+		uuid.UUID(vid.ID), vid.Title, string(vid.State),
+		utcTime(vid.ScheduledStart), utcTime(vid.StartedAt), utcTime(vid.EndedAt),
+		vid.CreatedAt, vid.UpdatedAt,
 	).ToSql()
 	if err != nil {
 		return err
 	}
-	_, err = s.db.Exec(q, v...)
-	return err
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return translateError(err)
+	}
+	// Rollback is a no-op once the transaction is committed,
+	// so this is just a best attempt to clean up on the error paths
+	// nolint:errcheck
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, q, v...); err != nil {
+		return translateError(err)
+	}
+	if err := videoStreamTags.write(ctx, tx, uuid.UUID(vid.ID), vid.Tags); err != nil {
+		return err
+	}
+	return translateError(tx.Commit())
 }
 
 // UpdateVideoStream replaces the VideoStream with ID model.VideoStreamID with the given object
-// This method is not yet implemented
-func (s *Store) UpdateVideoStream(model.VideoStreamID, model.VideoStream) error {
-	return errors.New("not implemented")
+//
+// Only the title, scheduled start and tags can be changed, the updated timestamp is set
+// by the store and the creation timestamp is left untouched. The state, and the times
+// the stream started and ended, are only changed by TransitionVideoStream.
+//
+// The stream is validated first, returning a validation.Errors if it breaks any rules
+func (s *Store) UpdateVideoStream(ctx context.Context, id model.VideoStreamID, vid model.VideoStream) error {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Postgres:Update Video Stream")
+	defer sp.Finish()
+
+	if err := s.validator.VideoStream(vid); err != nil {
+		return err
+	}
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	q, v, err := psql.Update(videoStreamTable).SetMap(map[string]interface{}{
+		"title":           vid.Title,
+		"scheduled_start": utcTime(vid.ScheduledStart),
+		"updated":         time.Now().UTC(),
+	}).Where("id = ?", uuid.UUID(id)).ToSql()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return translateError(err)
+	}
+	// Rollback is a no-op once the transaction is committed,
+	// so this is just a best attempt to clean up on the error paths
+	// nolint:errcheck
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, q, v...)
+	if err != nil {
+		return translateError(err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return translateError(err)
+	}
+	if n == 0 {
+		return model.ErrNotFound
+	}
+
+	if err := videoStreamTags.write(ctx, tx, uuid.UUID(id), vid.Tags); err != nil {
+		return err
+	}
+	return translateError(tx.Commit())
+}
+
+// TransitionVideoStream moves the VideoStream with ID model.VideoStreamID into the given state
+//
+// The stream row is locked while the transition is checked against its current state,
+// so concurrent transitions of the same stream are applied one after the other.
+// A transition the stream's current state doesn't allow returns a *model.StreamTransitionError
+func (s *Store) TransitionVideoStream(ctx context.Context, id model.VideoStreamID, to model.StreamState) error {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Postgres:Transition Video Stream")
+	defer sp.Finish()
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		tracer.Log(sp, "failed to begin transaction")
+		tracer.SetError(sp, err)
+		return translateError(err)
+	}
+	// Rollback is a no-op once the transaction is committed,
+	// so this is just a best attempt to clean up on the error paths
+	// nolint:errcheck
+	defer tx.Rollback()
+
+	q, v, err := psql.Select(videoStreamFields...).From(videoStreamTable).Where(
+		"id = ?", uuid.UUID(id),
+	).Suffix("FOR UPDATE").ToSql()
+	if err != nil {
+		return err
+	}
+
+	row := videoStream{}
+	if err := tx.GetContext(ctx, &row, q, v...); err != nil {
+		// No rows is translated to model.ErrNotFound
+		return translateError(err)
+	}
+
+	vid := row.model()
+	if err := vid.Transition(to, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	q, v, err = psql.Update(videoStreamTable).SetMap(map[string]interface{}{
+		"state":   string(vid.State),
+		"started": vid.StartedAt,
+		"ended":   vid.EndedAt,
+		"updated": vid.UpdatedAt,
+	}).Where("id = ?", uuid.UUID(id)).ToSql()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, q, v...); err != nil {
+		tracer.Log(sp, "failed to update video stream")
+		tracer.SetError(sp, err)
+		return translateError(err)
+	}
+	return translateError(tx.Commit())
 }
 
-// DeleteVideoStream deleted the VideoStream with ID model.VideoStreamID
-// This method is not yet implemented
-func (s *Store) DeleteVideoStream(model.VideoStreamID) error {
-	return errors.New("not implemented")
+// DeleteVideoStream deletes the VideoStream with ID model.VideoStreamID
+//
+// What happens to the buffs of the stream depends on the model.StreamDeletePolicy
+// the store was built with. Either way, the stream and its buffs are handled
+// in a single transaction.
+func (s *Store) DeleteVideoStream(ctx context.Context, id model.VideoStreamID) error {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Postgres:Delete Video Stream")
+	defer sp.Finish()
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return translateError(err)
+	}
+	// Rollback is a no-op once the transaction is committed,
+	// so this is just a best attempt to clean up on the error paths
+	// nolint:errcheck
+	defer tx.Rollback()
+
+	// Lock the stream row first. This conflicts with the lock taken when
+	// a new question references the stream, so no buffs can sneak in between
+	// the checks below and the delete.
+	q, v, err := psql.Select("id").From(videoStreamTable).Where(
+		"id = ?", uuid.UUID(id),
+	).Suffix("FOR UPDATE").ToSql()
+	if err != nil {
+		return err
+	}
+
+	var locked uuid.UUID
+	if err := tx.GetContext(ctx, &locked, q, v...); err != nil {
+		// No rows is translated to model.ErrNotFound
+		return translateError(err)
+	}
+
+	if s.streamDeletePolicy == model.RestrictBuffs {
+		q, v, err := psql.Select("count(*)").From(questionTable).Where("stream = ?", uuid.UUID(id)).ToSql()
+		if err != nil {
+			return err
+		}
+
+		var buffs int
+		if err := tx.GetContext(ctx, &buffs, q, v...); err != nil {
+			return translateError(err)
+		}
+		if buffs != 0 {
+			return &model.StreamHasBuffsError{Stream: id, Buffs: buffs}
+		}
+	}
+
+	// Remove everything that references the stream, deepest first
+	deletes := []sq.DeleteBuilder{
+		psql.Delete(answerTable).Where(
+			"question IN (SELECT id FROM "+questionTable+" WHERE stream = ?)", uuid.UUID(id),
+		),
+		psql.Delete(questionTable).Where("stream = ?", uuid.UUID(id)),
+		psql.Delete(videoStreamTable).Where("id = ?", uuid.UUID(id)),
+	}
+
+	for _, d := range deletes {
+		q, v, err := d.ToSql()
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, q, v...); err != nil {
+			return err
+		}
+	}
+
+	return translateError(tx.Commit())
 }
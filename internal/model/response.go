@@ -0,0 +1,71 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// ResponseStore defines all the actions needed to implement a response storage layer
+// This could be implemented by:
+//   - A relational database (for production)
+//   - A mock implementation (for testing)
+//   - An RPC backend (for unforeseen future developments)
+//
+// Genericising the storage actions in this way makes the code considerably
+// easier to re-factor with respect to storage sub-systems, should they need to change
+//
+// Every action takes a context, which implementations should use to cancel
+// in-flight work and to parent any tracing spans they create
+//
+// CreateResponse returns ErrNotFound if the buff doesn't exist, ErrInvalidReference
+// if the answer isn't one of the buff's answers, and ErrConflict if the user has
+// already responded to the buff. A buff that has been resolved takes no more
+// responses, returning a *BuffResolvedError, and a buff only takes responses while
+// its stream is live, returning a *StreamNotLiveError otherwise. Once a scheduled
+// buff has closed it takes no more responses either, returning a *BuffClosedError.
+//
+// ListResponseForUser returns the responses the user has given to any of the
+// given buffs, in no particular order. Buffs the user hasn't responded to,
+// or that don't exist, are left out rather than returning an error.
+type ResponseStore interface {
+	CreateResponse(context.Context, Response) error
+	ListResponseForUser(ctx context.Context, user UserID, buffs []BuffID) ([]Response, error)
+	GetResults(context.Context, BuffID) (*Results, error)
+}
+
+// UserID identifies the user that responded to a buff
+//
+// Users are managed outside of this service, so the ID is kept exactly as
+// it is given to us rather than parsed into a uuid.UUID
+type UserID string
+
+// String returns the UserID as a plain string
+func (u UserID) String() string {
+	return string(u)
+}
+
+// Response defines the abstract representation of a user's answer to a Buff
+//
+// A user can respond to each buff at most once. Responses are removed
+// along with the buff, or the answer, they refer to.
+type Response struct {
+	Buff      BuffID
+	User      UserID
+	Answer    AnswerID
+	CreatedAt time.Time
+}
+
+// Results is the tally of all the responses to a Buff
+type Results struct {
+	Buff    BuffID
+	Total   int
+	Answers []AnswerResult
+}
+
+// AnswerResult is the number of responses given for a single Answer
+// The answers of a Results are kept in the order of the buff's answers
+type AnswerResult struct {
+	Answer    AnswerID
+	Text      string
+	Responses int
+}
@@ -0,0 +1,13 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserIDString(t *testing.T) {
+	id := model.UserID("user-42")
+	assert.Equal(t, "user-42", id.String())
+}
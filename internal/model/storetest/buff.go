@@ -0,0 +1,640 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testGetBuff(t *testing.T, store model.Store) {
+	vids := createVideoStreams(t, store, time.Now())
+
+	b := newBuff(vids[0].ID, "what's the answer to life, the universe, and everything?")
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	got, err := store.GetBuff(context.Background(), b.ID)
+	require.NoError(t, err, "failed to get buff")
+	assertBuffEqual(t, b, *got)
+}
+
+func testGetBuffNotFound(t *testing.T, store model.Store) {
+	_, err := store.GetBuff(context.Background(), model.BuffID(uuid.New()))
+	assert.Equal(t, model.ErrNotFound, err)
+}
+
+func testListBuff(t *testing.T, store model.Store) {
+	empty, err := store.ListBuff(context.Background(), model.BuffQuery{}, 0, 0)
+	require.NoError(t, err, "failed to list buffs")
+	assert.NotNil(t, empty, "an empty list should not be nil")
+	assert.Empty(t, empty)
+
+	vids := createVideoStreams(t, store, time.Now().Add(-time.Hour), time.Now())
+
+	// Created out of order and across streams, with two buffs sharing
+	// a creation time so the id is needed to break the tie
+	now := time.Now()
+	buffs := append(
+		createBuffs(t, store, vids[0].ID, now, now.Add(-2*time.Hour)),
+		createBuffs(t, store, vids[1].ID, now.Add(-time.Hour), now)...,
+	)
+	sortBuffs(buffs)
+
+	all, err := store.ListBuff(context.Background(), model.BuffQuery{}, 0, 0)
+	require.NoError(t, err, "failed to list buffs")
+	assertBuffsEqual(t, buffs, all)
+}
+
+func testListBuffPagination(t *testing.T, store model.Store) {
+	vids := createVideoStreams(t, store, time.Now())
+
+	now := time.Now()
+	buffs := createBuffs(t, store, vids[0].ID,
+		now.Add(-5*time.Minute), now.Add(-4*time.Minute), now.Add(-3*time.Minute),
+		now.Add(-2*time.Minute), now.Add(-time.Minute),
+	)
+
+	var tests = []struct {
+		name   string
+		offset int
+		limit  int
+		expect []model.Buff
+	}{
+		{name: "no offset or limit", expect: buffs},
+		{name: "limit only", limit: 2, expect: buffs[:2]},
+		{name: "offset only", offset: 3, expect: buffs[3:]},
+		{name: "offset and limit", offset: 1, limit: 3, expect: buffs[1:4]},
+		{name: "limit past the end", offset: 3, limit: 10, expect: buffs[3:]},
+		{name: "offset at the end", offset: 5, limit: 2, expect: []model.Buff{}},
+		{name: "offset past the end", offset: 10, limit: 2, expect: []model.Buff{}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Every buff has several answers, so the pages must count buffs not answers
+			page, err := store.ListBuff(context.Background(), model.BuffQuery{}, tt.offset, tt.limit)
+			require.NoError(t, err, "failed to list buffs")
+			assertBuffsEqual(t, tt.expect, page)
+		})
+	}
+}
+
+func testListBuffAfter(t *testing.T, store model.Store) {
+	empty, err := store.ListBuffAfter(context.Background(), model.BuffQuery{}, nil, 2)
+	require.NoError(t, err, "failed to list buffs")
+	assert.NotNil(t, empty, "an empty list should not be nil")
+	assert.Empty(t, empty)
+
+	vids := createVideoStreams(t, store, time.Now().Add(-time.Hour), time.Now())
+
+	// Two buffs share a creation time, so the id is needed to break the tie
+	now := time.Now()
+	buffs := append(
+		createBuffs(t, store, vids[0].ID, now.Add(-4*time.Minute), now.Add(-2*time.Minute)),
+		createBuffs(t, store, vids[1].ID, now.Add(-3*time.Minute), now.Add(-2*time.Minute))...,
+	)
+	sortBuffs(buffs)
+
+	first, err := store.ListBuffAfter(context.Background(), model.BuffQuery{}, nil, 2)
+	require.NoError(t, err, "failed to list buffs")
+	assertBuffsEqual(t, buffs[:2], first)
+
+	// Changes before the cursor must not move the following pages
+	require.NoError(t, store.DeleteBuff(context.Background(), buffs[0].ID), "failed to delete buff")
+	createBuffs(t, store, vids[0].ID, now.Add(-time.Hour))
+
+	after := first[1].Cursor()
+	second, err := store.ListBuffAfter(context.Background(), model.BuffQuery{}, &after, 2)
+	require.NoError(t, err, "failed to list buffs")
+	assertBuffsEqual(t, buffs[2:], second)
+
+	after = second[1].Cursor()
+	end, err := store.ListBuffAfter(context.Background(), model.BuffQuery{}, &after, 2)
+	require.NoError(t, err, "failed to list buffs")
+	assert.Empty(t, end)
+}
+
+func testListBuffForStream(t *testing.T, store model.Store) {
+	vids := createVideoStreams(t, store, time.Now().Add(-time.Hour), time.Now())
+
+	now := time.Now()
+	buffs := createBuffs(t, store, vids[0].ID, now, now.Add(-time.Hour), now)
+	createBuffs(t, store, vids[1].ID, now.Add(-2*time.Hour))
+
+	forStream, err := store.ListBuffForStream(context.Background(), vids[0].ID, model.BuffQuery{}, 0, 0)
+	require.NoError(t, err, "failed to list buffs for stream")
+	assertBuffsEqual(t, buffs, forStream)
+
+	unknown, err := store.ListBuffForStream(context.Background(), model.VideoStreamID(uuid.New()), model.BuffQuery{}, 0, 0)
+	require.NoError(t, err, "failed to list buffs for stream")
+	assert.NotNil(t, unknown, "an empty list should not be nil")
+	assert.Empty(t, unknown)
+}
+
+func testListBuffForStreamPagination(t *testing.T, store model.Store) {
+	vids := createVideoStreams(t, store, time.Now().Add(-time.Hour), time.Now())
+
+	now := time.Now()
+	buffs := createBuffs(t, store, vids[0].ID,
+		now.Add(-4*time.Minute), now.Add(-3*time.Minute), now.Add(-2*time.Minute), now.Add(-time.Minute),
+	)
+
+	// Interleaved with the buffs of another stream, which must not use up the page
+	createBuffs(t, store, vids[1].ID,
+		now.Add(-5*time.Minute), now.Add(-3*time.Minute), now.Add(-90*time.Second),
+	)
+
+	// Walking the pages should visit every buff exactly once
+	seen := make([]model.Buff, 0)
+	for offset := 0; offset < len(buffs)+2; offset += 2 {
+		page, err := store.ListBuffForStream(context.Background(), vids[0].ID, model.BuffQuery{}, offset, 2)
+		require.NoError(t, err, "failed to list buffs for stream")
+		assert.LessOrEqual(t, len(page), 2, "a page should hold at most limit buffs")
+		seen = append(seen, page...)
+	}
+	assertBuffsEqual(t, buffs, seen)
+}
+
+func testListBuffForStreamAfter(t *testing.T, store model.Store) {
+	vids := createVideoStreams(t, store, time.Now().Add(-time.Hour), time.Now())
+
+	now := time.Now()
+	buffs := createBuffs(t, store, vids[0].ID,
+		now.Add(-4*time.Minute), now.Add(-3*time.Minute), now.Add(-3*time.Minute), now.Add(-time.Minute),
+	)
+
+	// Interleaved with the buffs of another stream, which must not use up the page
+	createBuffs(t, store, vids[1].ID, now.Add(-5*time.Minute), now.Add(-3*time.Minute), now.Add(-2*time.Minute))
+
+	// Walking the pages should visit every buff exactly once
+	seen := make([]model.Buff, 0)
+	var after *model.Cursor
+	for {
+		page, err := store.ListBuffForStreamAfter(context.Background(), vids[0].ID, model.BuffQuery{}, after, 3)
+		require.NoError(t, err, "failed to list buffs for stream")
+		require.LessOrEqual(t, len(page), 3, "a page should hold at most limit buffs")
+		if len(page) == 0 {
+			break
+		}
+
+		seen = append(seen, page...)
+		c := page[len(page)-1].Cursor()
+		after = &c
+	}
+	assertBuffsEqual(t, buffs, seen)
+
+	unknown, err := store.ListBuffForStreamAfter(context.Background(), model.VideoStreamID(uuid.New()), model.BuffQuery{}, nil, 3)
+	require.NoError(t, err, "failed to list buffs for stream")
+	assert.NotNil(t, unknown, "an empty list should not be nil")
+	assert.Empty(t, unknown)
+}
+
+func testCreateBuffInvalid(t *testing.T, store model.Store) {
+	vids := createVideoStreams(t, store, time.Now())
+
+	b := newBuff(vids[0].ID, "")
+	b.Answers = b.Answers[:1]
+	assertInvalid(t, store.CreateBuff(context.Background(), b))
+
+	_, err := store.GetBuff(context.Background(), b.ID)
+	assert.Equal(t, model.ErrNotFound, err, "an invalid buff should not be stored")
+}
+
+func testCreateBuffUnknownStream(t *testing.T, store model.Store) {
+	b := newBuff(model.VideoStreamID(uuid.New()), "where is the stream?")
+	err := store.CreateBuff(context.Background(), b)
+	assert.True(t, errors.Is(err, model.ErrInvalidReference), "the stream must exist, got %v", err)
+
+	_, err = store.GetBuff(context.Background(), b.ID)
+	assert.Equal(t, model.ErrNotFound, err, "the buff should not be stored")
+}
+
+func testCreateBuffAnswerOrder(t *testing.T, store model.Store) {
+	vids := createVideoStreams(t, store, time.Now())
+
+	// The correct answer is given last, and the ids are not in order,
+	// so neither can be used to sort the answers
+	b := newBuff(vids[0].ID, "which of these is the answer?")
+	b.Answers = []model.Answer{
+		{ID: model.AnswerID(uuid.MustParse("ffffffff-0000-4000-8000-000000000000")), Text: "c", Correct: false},
+		{ID: model.AnswerID(uuid.MustParse("00000000-0000-4000-8000-000000000000")), Text: "a", Correct: false},
+		{ID: model.AnswerID(uuid.MustParse("88888888-0000-4000-8000-000000000000")), Text: "b", Correct: true},
+	}
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	got, err := store.GetBuff(context.Background(), b.ID)
+	require.NoError(t, err, "failed to get buff")
+	assertBuffEqual(t, b, *got)
+
+	all, err := store.ListBuff(context.Background(), model.BuffQuery{}, 0, 0)
+	require.NoError(t, err, "failed to list buffs")
+	assertBuffsEqual(t, []model.Buff{b}, all)
+}
+
+func testCreateBuffDuplicate(t *testing.T, store model.Store) {
+	vids := createVideoStreams(t, store, time.Now())
+
+	b := newBuff(vids[0].ID, "what's the answer?")
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	dup := newBuff(vids[0].ID, "what's the question?")
+	dup.ID = b.ID
+	err := store.CreateBuff(context.Background(), dup)
+	assert.True(t, errors.Is(err, model.ErrConflict), "ids must be unique, got %v", err)
+
+	reused := newBuff(vids[0].ID, "what's the question?")
+	reused.Answers[1].ID = b.Answers[1].ID
+	err = store.CreateBuff(context.Background(), reused)
+	assert.True(t, errors.Is(err, model.ErrConflict), "answer ids must be unique, got %v", err)
+
+	got, err := store.GetBuff(context.Background(), b.ID)
+	require.NoError(t, err, "failed to get buff")
+	assertBuffEqual(t, b, *got)
+
+	_, err = store.GetBuff(context.Background(), reused.ID)
+	assert.Equal(t, model.ErrNotFound, err, "the buff should not be stored")
+}
+
+func testUpdateBuff(t *testing.T, store model.Store) {
+	vids := createVideoStreams(t, store, time.Now().Add(-time.Hour), time.Now())
+
+	b := newBuff(vids[0].ID, "what's the answer to life?")
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	other := newBuff(vids[0].ID, "what's the answer to everything?")
+	require.NoError(t, store.CreateBuff(context.Background(), other), "failed to create buff")
+
+	// Change the text of one answer, keep another, remove the last, and add a new one.
+	// The stream and creation time are owned by the buff, so the changes should be ignored
+	update := model.Buff{
+		ID:        b.ID,
+		Stream:    vids[1].ID,
+		Question:  "what's the answer to life, the universe, and everything?",
+		CreatedAt: b.CreatedAt.Add(time.Hour),
+		Answers: []model.Answer{
+			{ID: b.Answers[0].ID, Text: "forty two", Correct: true},
+			b.Answers[1],
+			{ID: model.AnswerID(uuid.New()), Text: "45", Correct: false},
+		},
+	}
+	require.NoError(t, store.UpdateBuff(context.Background(), b.ID, update), "failed to update buff")
+
+	// The update has no kind, so the buff is stored as a quiz
+	expect := update
+	expect.Stream = b.Stream
+	expect.Kind = model.BuffQuiz
+	expect.CreatedAt = b.CreatedAt
+
+	got, err := store.GetBuff(context.Background(), b.ID)
+	require.NoError(t, err, "failed to get buff")
+	assertBuffEqual(t, expect, *got)
+
+	// The other buff must be untouched
+	got, err = store.GetBuff(context.Background(), other.ID)
+	require.NoError(t, err, "failed to get buff")
+	assertBuffEqual(t, other, *got)
+
+	// The change is visible to the lists as well
+	expectList := []model.Buff{expect, other}
+	sortBuffs(expectList)
+
+	forStream, err := store.ListBuffForStream(context.Background(), vids[0].ID, model.BuffQuery{}, 0, 0)
+	require.NoError(t, err, "failed to list buffs for stream")
+	assertBuffsEqual(t, expectList, forStream)
+
+	invalid := update
+	invalid.Answers = nil
+	assertInvalid(t, store.UpdateBuff(context.Background(), b.ID, invalid))
+}
+
+func testBuffKind(t *testing.T, store model.Store) {
+	vids := createVideoStreams(t, store, time.Now())
+
+	quiz := newBuff(vids[0].ID, "what's the answer?")
+	quiz.Kind = ""
+	require.NoError(t, store.CreateBuff(context.Background(), quiz), "failed to create buff")
+
+	got, err := store.GetBuff(context.Background(), quiz.ID)
+	require.NoError(t, err, "failed to get buff")
+	assert.Equal(t, model.BuffQuiz, got.Kind, "a buff without a kind should be stored as a quiz")
+
+	poll := newBuff(vids[0].ID, "who is the best?")
+	poll.Kind = model.BuffPoll
+	poll.Answers[0].Correct = false
+	require.NoError(t, store.CreateBuff(context.Background(), poll), "failed to create buff")
+
+	got, err = store.GetBuff(context.Background(), poll.ID)
+	require.NoError(t, err, "failed to get buff")
+	assertBuffEqual(t, poll, *got)
+
+	// The kind can be changed, so long as the answers suit the new kind
+	prediction := poll
+	prediction.Kind = model.BuffPrediction
+	require.NoError(t, store.UpdateBuff(context.Background(), poll.ID, prediction), "failed to update buff")
+
+	got, err = store.GetBuff(context.Background(), poll.ID)
+	require.NoError(t, err, "failed to get buff")
+	assertBuffEqual(t, prediction, *got)
+
+	invalid := newBuff(vids[0].ID, "who is the best?")
+	invalid.Kind = model.BuffPoll
+	assertInvalid(t, store.CreateBuff(context.Background(), invalid))
+}
+
+func testUpdateBuffAnswerOrder(t *testing.T, store model.Store) {
+	vids := createVideoStreams(t, store, time.Now())
+
+	b := newBuff(vids[0].ID, "which of these is the answer?")
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	// Reverse the answers, and put a new one in the middle
+	update := b
+	update.Answers = []model.Answer{
+		b.Answers[2],
+		{ID: model.AnswerID(uuid.New()), Text: "new", Correct: false},
+		b.Answers[1],
+		b.Answers[0],
+	}
+	require.NoError(t, store.UpdateBuff(context.Background(), b.ID, update), "failed to update buff")
+
+	got, err := store.GetBuff(context.Background(), b.ID)
+	require.NoError(t, err, "failed to get buff")
+	assertBuffEqual(t, update, *got)
+}
+
+func testUpdateBuffNotFound(t *testing.T, store model.Store) {
+	vids := createVideoStreams(t, store, time.Now())
+
+	b := newBuff(vids[0].ID, "where is the buff?")
+	err := store.UpdateBuff(context.Background(), b.ID, b)
+	assert.Equal(t, model.ErrNotFound, err)
+}
+
+func testBuffSchedule(t *testing.T, store model.Store) {
+	vids := createVideoStreams(t, store, time.Now())
+
+	b := newBuff(vids[0].ID, "when is this shown?")
+	b.Schedule = &model.Schedule{Offset: 90 * time.Second, Duration: 15 * time.Second}
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	got, err := store.GetBuff(context.Background(), b.ID)
+	require.NoError(t, err, "failed to get buff")
+	assertBuffEqual(t, b, *got)
+
+	// Moving the buff changes its schedule
+	b.Schedule = &model.Schedule{Offset: 2 * time.Minute, Duration: 30 * time.Second}
+	require.NoError(t, store.UpdateBuff(context.Background(), b.ID, b), "failed to update buff")
+
+	got, err = store.GetBuff(context.Background(), b.ID)
+	require.NoError(t, err, "failed to get buff")
+	assertBuffEqual(t, b, *got)
+
+	// Updating without a schedule takes the buff off the timeline
+	b.Schedule = nil
+	require.NoError(t, store.UpdateBuff(context.Background(), b.ID, b), "failed to update buff")
+
+	got, err = store.GetBuff(context.Background(), b.ID)
+	require.NoError(t, err, "failed to get buff")
+	assertBuffEqual(t, b, *got)
+
+	invalid := newBuff(vids[0].ID, "when is this shown?")
+	invalid.Schedule = &model.Schedule{Offset: -time.Second, Duration: time.Second}
+	assertInvalid(t, store.CreateBuff(context.Background(), invalid))
+
+	invalid.Schedule = &model.Schedule{Offset: time.Second, Duration: 0}
+	assertInvalid(t, store.CreateBuff(context.Background(), invalid))
+}
+
+func testListBuffActiveForStream(t *testing.T, store model.Store) {
+	vids := createVideoStreams(t, store, time.Now().Add(-time.Hour), time.Now())
+
+	schedule := func(b model.Buff, offset, duration time.Duration) model.Buff {
+		b.Schedule = &model.Schedule{Offset: offset, Duration: duration}
+		return b
+	}
+
+	buffs := createBuffs(t, store, vids[0].ID, time.Now().Add(-3*time.Minute), time.Now().Add(-2*time.Minute), time.Now().Add(-time.Minute))
+	buffs[0] = schedule(buffs[0], 0, time.Minute)
+	buffs[1] = schedule(buffs[1], 30*time.Second, time.Minute)
+	for _, b := range buffs[:2] {
+		require.NoError(t, store.UpdateBuff(context.Background(), b.ID, b), "failed to update buff")
+	}
+
+	// A buff of another stream, scheduled at the same time, must not be listed
+	other := schedule(newBuff(vids[1].ID, "is this another stream?"), 0, time.Hour)
+	require.NoError(t, store.CreateBuff(context.Background(), other), "failed to create buff")
+
+	tests := []struct {
+		name   string
+		at     time.Duration
+		expect []model.Buff
+	}{
+		{name: "start of the stream", at: 0, expect: buffs[:1]},
+		{name: "overlapping", at: 45 * time.Second, expect: buffs[:2]},
+		{name: "closed at its end", at: time.Minute, expect: buffs[1:2]},
+		{name: "after every buff", at: time.Hour, expect: []model.Buff{}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.ListBuffActiveForStream(context.Background(), vids[0].ID, model.BuffQuery{}, tt.at)
+			require.NoError(t, err, "failed to list active buffs")
+			assertBuffsEqual(t, tt.expect, got)
+		})
+	}
+}
+
+func testListBuffByStreamState(t *testing.T, store model.Store) {
+	vids := createVideoStreams(t, store, time.Now().Add(-time.Hour), time.Now())
+	require.NoError(t, store.TransitionVideoStream(context.Background(), vids[1].ID, model.StreamLive), "failed to start video stream")
+
+	now := time.Now()
+	scheduled := createBuffs(t, store, vids[0].ID, now.Add(-2*time.Minute))
+	live := createBuffs(t, store, vids[1].ID, now.Add(-time.Minute))
+	for _, b := range []*model.Buff{&scheduled[0], &live[0]} {
+		b.Schedule = &model.Schedule{Offset: 0, Duration: time.Minute}
+		require.NoError(t, store.UpdateBuff(context.Background(), b.ID, *b), "failed to update buff")
+	}
+
+	tests := []struct {
+		name         string
+		states       []model.StreamState
+		expect       []model.Buff
+		expectStream []model.Buff
+	}{
+		{name: "any state", states: nil, expect: append(scheduled, live...), expectStream: live},
+		{name: "live", states: []model.StreamState{model.StreamLive}, expect: live, expectStream: live},
+		{name: "not live", states: []model.StreamState{model.StreamScheduled, model.StreamEnded}, expect: scheduled, expectStream: []model.Buff{}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			query := model.BuffQuery{StreamStates: tt.states}
+
+			all, err := store.ListBuff(context.Background(), query, 0, 0)
+			require.NoError(t, err, "failed to list buffs")
+			assertBuffsEqual(t, tt.expect, all)
+
+			after, err := store.ListBuffAfter(context.Background(), query, nil, 10)
+			require.NoError(t, err, "failed to list buffs after")
+			assertBuffsEqual(t, tt.expect, after)
+
+			forStream, err := store.ListBuffForStream(context.Background(), vids[1].ID, query, 0, 0)
+			require.NoError(t, err, "failed to list buffs for stream")
+			assertBuffsEqual(t, tt.expectStream, forStream)
+
+			forStreamAfter, err := store.ListBuffForStreamAfter(context.Background(), vids[1].ID, query, nil, 10)
+			require.NoError(t, err, "failed to list buffs for stream after")
+			assertBuffsEqual(t, tt.expectStream, forStreamAfter)
+
+			active, err := store.ListBuffActiveForStream(context.Background(), vids[1].ID, query, 0)
+			require.NoError(t, err, "failed to list active buffs")
+			assertBuffsEqual(t, tt.expectStream, active)
+		})
+	}
+}
+
+func testSearchBuffs(t *testing.T, store model.Store) {
+	vids := createVideoStreams(t, store, time.Now())
+
+	// The questions and answers are plain single words, which every store matches in the same way
+	withText := func(question string, answers ...string) model.Buff {
+		b := newBuff(vids[0].ID, question)
+		for i, text := range answers {
+			b.Answers[i].Text = text
+		}
+		return b
+	}
+
+	buffs := []model.Buff{
+		withText("Who will score first?", "Home", "Away", "Nobody"),
+		withText("Which team wins at home?", "Yes", "No", "Draw"),
+		withText("Who is the man of the match?", "The striker", "The keeper", "The referee"),
+	}
+	for i := range buffs {
+		buffs[i].CreatedAt = time.Now().UTC().Add(time.Duration(i-len(buffs)) * time.Minute).Truncate(time.Microsecond)
+		require.NoError(t, store.CreateBuff(context.Background(), buffs[i]), "failed to create buff")
+	}
+
+	mark := func(s string) string {
+		return model.HighlightStart + s + model.HighlightStop
+	}
+
+	t.Run("question ranks above answer", func(t *testing.T) {
+		got, err := store.SearchBuffs(context.Background(), "home", 0, 0)
+		require.NoError(t, err, "failed to search buffs")
+		require.Len(t, got, 2)
+
+		assertBuffEqual(t, buffs[1], got[0].Buff)
+		assert.Equal(t, "Which team wins at "+mark("home")+"?", got[0].QuestionHighlight)
+		assert.Empty(t, got[0].AnswerHighlights)
+
+		assertBuffEqual(t, buffs[0], got[1].Buff)
+		assert.Equal(t, buffs[0].Question, got[1].QuestionHighlight)
+		assert.Equal(t, map[model.AnswerID]string{buffs[0].Answers[0].ID: mark("Home")}, got[1].AnswerHighlights)
+
+		assert.Greater(t, got[0].Rank, got[1].Rank)
+	})
+
+	t.Run("question ranks above several answers", func(t *testing.T) {
+		// Every answer of the older buff matches, against the question alone of the newer one
+		answers := withText("Who takes the penalty?", "Keeper", "Home keeper", "Away keeper")
+		answers.CreatedAt = time.Now().UTC().Add(-time.Minute).Truncate(time.Microsecond)
+		question := withText("Who is the best keeper?", "Home", "Away", "Neither")
+		question.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+		for _, b := range []model.Buff{answers, question} {
+			require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+		}
+		defer func() {
+			require.NoError(t, store.DeleteBuff(context.Background(), answers.ID), "failed to delete buff")
+			require.NoError(t, store.DeleteBuff(context.Background(), question.ID), "failed to delete buff")
+		}()
+
+		got, err := store.SearchBuffs(context.Background(), "keeper", 0, 0)
+		require.NoError(t, err, "failed to search buffs")
+		require.Len(t, got, 3)
+
+		assertBuffEqual(t, question, got[0].Buff)
+		assertBuffEqual(t, answers, got[1].Buff)
+		assertBuffEqual(t, buffs[2], got[2].Buff)
+		assert.Greater(t, got[1].Rank, got[2].Rank)
+	})
+
+	t.Run("escapes the text around the highlights", func(t *testing.T) {
+		// The highlights are HTML, so any markup written into the buff must not survive as markup
+		b := withText(`Who wins the derby? <b>Bold</b> & 'quoted' "text"`, "Yes", "No", "Draw")
+		require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+		defer func() {
+			require.NoError(t, store.DeleteBuff(context.Background(), b.ID), "failed to delete buff")
+		}()
+
+		got, err := store.SearchBuffs(context.Background(), "derby", 0, 0)
+		require.NoError(t, err, "failed to search buffs")
+		require.Len(t, got, 1)
+
+		assertBuffEqual(t, b, got[0].Buff)
+		assert.Equal(t, "Who wins the "+mark("derby")+"? &lt;b&gt;Bold&lt;/b&gt; &amp; &#39;quoted&#39; &#34;text&#34;", got[0].QuestionHighlight)
+	})
+
+	t.Run("ignores case", func(t *testing.T) {
+		got, err := store.SearchBuffs(context.Background(), "STRIKER", 0, 0)
+		require.NoError(t, err, "failed to search buffs")
+		require.Len(t, got, 1)
+
+		assertBuffEqual(t, buffs[2], got[0].Buff)
+		assert.Equal(t, map[model.AnswerID]string{buffs[2].Answers[0].ID: "The " + mark("striker")}, got[0].AnswerHighlights)
+	})
+
+	t.Run("paginated", func(t *testing.T) {
+		got, err := store.SearchBuffs(context.Background(), "home", 1, 1)
+		require.NoError(t, err, "failed to search buffs")
+		require.Len(t, got, 1)
+		assertBuffEqual(t, buffs[0], got[0].Buff)
+	})
+
+	t.Run("no matches", func(t *testing.T) {
+		got, err := store.SearchBuffs(context.Background(), "corner", 0, 0)
+		require.NoError(t, err, "failed to search buffs")
+		assert.Equal(t, []model.BuffMatch{}, got)
+	})
+
+	t.Run("blank query", func(t *testing.T) {
+		got, err := store.SearchBuffs(context.Background(), " ", 0, 0)
+		require.NoError(t, err, "failed to search buffs")
+		assert.Equal(t, []model.BuffMatch{}, got)
+	})
+}
+
+func testDeleteBuff(t *testing.T, store model.Store) {
+	vids := createVideoStreams(t, store, time.Now())
+
+	deleted := newBuff(vids[0].ID, "will this be deleted?")
+	require.NoError(t, store.CreateBuff(context.Background(), deleted), "failed to create buff")
+
+	kept := newBuff(vids[0].ID, "will this be kept?")
+	require.NoError(t, store.CreateBuff(context.Background(), kept), "failed to create buff")
+
+	require.NoError(t, store.DeleteBuff(context.Background(), deleted.ID), "failed to delete buff")
+
+	_, err := store.GetBuff(context.Background(), deleted.ID)
+	assert.Equal(t, model.ErrNotFound, err)
+
+	all, err := store.ListBuff(context.Background(), model.BuffQuery{}, 0, 0)
+	require.NoError(t, err, "failed to list buffs")
+	assertBuffsEqual(t, []model.Buff{kept}, all)
+
+	// The answers should have gone too, leaving their ids free to use again
+	assert.NoError(t, store.CreateBuff(context.Background(), deleted))
+}
+
+func testDeleteBuffNotFound(t *testing.T, store model.Store) {
+	err := store.DeleteBuff(context.Background(), model.BuffID(uuid.New()))
+	assert.Equal(t, model.ErrNotFound, err)
+}
@@ -0,0 +1,231 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// respondAt stores a response to the answer of the buff, given at the given time
+func respondAt(t *testing.T, store model.Store, b model.Buff, user string, answer int, at time.Time) {
+	t.Helper()
+
+	resp := newResponse(b, user, answer)
+	resp.CreatedAt = at.UTC().Truncate(time.Microsecond)
+	require.NoError(t, store.CreateResponse(context.Background(), resp), "failed to create response")
+}
+
+// newScore returns the score of the user, scored at the given time
+func newScore(user string, rank, points, correct, responses int, scored time.Time) model.Score {
+	return model.Score{
+		User:      model.UserID(user),
+		Rank:      rank,
+		Points:    points,
+		Correct:   correct,
+		Responses: responses,
+		ScoredAt:  scored.UTC().Truncate(time.Microsecond),
+	}
+}
+
+// createLeaderboard stores a stream with responses from four users, and another stream
+// with responses of its own, returning the first stream and its expected leaderboard
+//
+// Alice has the most points, while Carol and Bob are tied and ranked by who reached their
+// points first. Dave has none, and is ranked last.
+func createLeaderboard(t *testing.T, store model.Store) (model.VideoStream, []model.Score) {
+	vids := createLiveVideoStreams(t, store, time.Now(), time.Now())
+	buffs := createBuffs(t, store, vids[0].ID, time.Now(), time.Now())
+	other := createBuffs(t, store, vids[1].ID, time.Now())
+
+	start := time.Now().Add(-time.Hour)
+	respondAt(t, store, buffs[0], "dave", 1, start)
+	respondAt(t, store, buffs[0], "alice", 0, start.Add(time.Second))
+	respondAt(t, store, buffs[1], "carol", 0, start.Add(time.Second))
+	respondAt(t, store, buffs[0], "bob", 0, start.Add(2*time.Second))
+	respondAt(t, store, buffs[1], "alice", 0, start.Add(3*time.Second))
+	respondAt(t, store, buffs[1], "bob", 2, start.Add(4*time.Second))
+
+	// Responses to the buffs of other streams are not counted
+	respondAt(t, store, other[0], "dave", 0, start)
+	respondAt(t, store, other[0], "erin", 0, start)
+
+	return vids[0], []model.Score{
+		newScore("alice", 1, 200, 2, 2, start.Add(3*time.Second)),
+		newScore("carol", 2, 100, 1, 1, start.Add(time.Second)),
+		newScore("bob", 3, 100, 1, 2, start.Add(2*time.Second)),
+		newScore("dave", 4, 0, 0, 1, start),
+	}
+}
+
+// assertScoreEqual compares scores, using time.Time.Equal for the time they were scored
+func assertScoreEqual(t *testing.T, expect, actual model.Score) {
+	t.Helper()
+
+	assert.True(t, expect.ScoredAt.Equal(actual.ScoredAt), "scored at: expected %s, got %s", expect.ScoredAt, actual.ScoredAt)
+
+	expect.ScoredAt, actual.ScoredAt = time.Time{}, time.Time{}
+	assert.Equal(t, expect, actual, "score")
+}
+
+// assertScoresEqual compares leaderboards, including their order
+func assertScoresEqual(t *testing.T, expect, actual []model.Score) {
+	t.Helper()
+
+	require.Len(t, actual, len(expect))
+	for i := range expect {
+		assertScoreEqual(t, expect[i], actual[i])
+	}
+}
+
+// assertLeaderboard checks the stream's full leaderboard
+func assertLeaderboard(t *testing.T, store model.Store, stream model.VideoStreamID, expect ...model.Score) {
+	t.Helper()
+
+	got, err := store.ListLeaderboard(context.Background(), stream, 0)
+	require.NoError(t, err, "failed to list leaderboard")
+	assertScoresEqual(t, expect, got)
+}
+
+func testListLeaderboard(t *testing.T, store model.Store) {
+	vid, expect := createLeaderboard(t, store)
+
+	var tests = []struct {
+		name   string
+		top    int
+		expect []model.Score
+	}{
+		{name: "every score", top: 0, expect: expect},
+		{name: "top scores", top: 2, expect: expect[:2]},
+		{name: "more than there are", top: 10, expect: expect},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.ListLeaderboard(context.Background(), vid.ID, tt.top)
+			require.NoError(t, err, "failed to list leaderboard")
+			assertScoresEqual(t, tt.expect, got)
+		})
+	}
+}
+
+func testListLeaderboardEmpty(t *testing.T, store model.Store) {
+	vids := createLiveVideoStreams(t, store, time.Now())
+	createBuffs(t, store, vids[0].ID, time.Now())
+
+	got, err := store.ListLeaderboard(context.Background(), vids[0].ID, 0)
+	require.NoError(t, err, "failed to list leaderboard")
+	assert.Empty(t, got)
+}
+
+func testListLeaderboardNotFound(t *testing.T, store model.Store) {
+	_, err := store.ListLeaderboard(context.Background(), model.VideoStreamID(uuid.New()), 0)
+	assert.Equal(t, model.ErrNotFound, err)
+}
+
+func testGetScore(t *testing.T, store model.Store) {
+	vid, expect := createLeaderboard(t, store)
+
+	for _, e := range expect {
+		e := e
+		t.Run(e.User.String(), func(t *testing.T) {
+			got, err := store.GetScore(context.Background(), vid.ID, e.User)
+			require.NoError(t, err, "failed to get score")
+			assertScoreEqual(t, e, *got)
+		})
+	}
+}
+
+func testGetScoreNotFound(t *testing.T, store model.Store) {
+	vid, _ := createLeaderboard(t, store)
+
+	var tests = []struct {
+		name   string
+		stream model.VideoStreamID
+		user   model.UserID
+	}{
+		{name: "user without responses", stream: vid.ID, user: "frank"},
+		{name: "user of another stream", stream: vid.ID, user: "erin"},
+		{name: "unknown stream", stream: model.VideoStreamID(uuid.New()), user: "alice"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := store.GetScore(context.Background(), tt.stream, tt.user)
+			assert.Equal(t, model.ErrNotFound, err)
+		})
+	}
+}
+
+func testLeaderboardResolveBuff(t *testing.T, store model.Store) {
+	vids := createLiveVideoStreams(t, store, time.Now())
+
+	b := newPrediction(vids[0].ID, "will there be a goal?")
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	start := time.Now().Add(-time.Hour)
+	respondAt(t, store, b, "alice", 0, start)
+	respondAt(t, store, b, "bob", 1, start.Add(time.Second))
+
+	// Nobody is correct until the prediction is resolved
+	assertLeaderboard(t, store, vids[0].ID,
+		newScore("alice", 1, 0, 0, 1, start),
+		newScore("bob", 2, 0, 0, 1, start.Add(time.Second)),
+	)
+
+	_, err := store.ResolveBuff(context.Background(), b.ID, newResolution(b, 1))
+	require.NoError(t, err, "failed to resolve buff")
+
+	assertLeaderboard(t, store, vids[0].ID,
+		newScore("bob", 1, 100, 1, 1, start.Add(time.Second)),
+		newScore("alice", 2, 0, 0, 1, start),
+	)
+}
+
+func testLeaderboardUpdateBuff(t *testing.T, store model.Store) {
+	vids := createLiveVideoStreams(t, store, time.Now())
+	buffs := createBuffs(t, store, vids[0].ID, time.Now())
+	b := buffs[0]
+
+	start := time.Now().Add(-time.Hour)
+	respondAt(t, store, b, "alice", 0, start)
+	respondAt(t, store, b, "bob", 1, start.Add(time.Second))
+	respondAt(t, store, b, "carol", 2, start.Add(2*time.Second))
+
+	// Correcting the buff moves the points to the users that gave the new correct answer,
+	// and removing an answer takes its users off the leaderboard along with their responses
+	b.Answers = []model.Answer{b.Answers[0], b.Answers[1]}
+	b.Answers[0].Correct, b.Answers[1].Correct = false, true
+	require.NoError(t, store.UpdateBuff(context.Background(), b.ID, b), "failed to update buff")
+
+	assertLeaderboard(t, store, vids[0].ID,
+		newScore("bob", 1, 100, 1, 1, start.Add(time.Second)),
+		newScore("alice", 2, 0, 0, 1, start),
+	)
+}
+
+func testLeaderboardDeleteBuff(t *testing.T, store model.Store) {
+	vids := createLiveVideoStreams(t, store, time.Now())
+	buffs := createBuffs(t, store, vids[0].ID, time.Now(), time.Now())
+
+	start := time.Now().Add(-time.Hour)
+	respondAt(t, store, buffs[0], "alice", 0, start)
+	respondAt(t, store, buffs[0], "bob", 0, start.Add(time.Second))
+	respondAt(t, store, buffs[1], "bob", 0, start.Add(2*time.Second))
+
+	require.NoError(t, store.DeleteBuff(context.Background(), buffs[0].ID), "failed to delete buff")
+
+	assertLeaderboard(t, store, vids[0].ID,
+		newScore("bob", 1, 100, 1, 1, start.Add(2*time.Second)),
+	)
+
+	// The leaderboard goes with its stream
+	require.NoError(t, store.DeleteVideoStream(context.Background(), vids[0].ID), "failed to delete video stream")
+
+	_, err := store.ListLeaderboard(context.Background(), vids[0].ID, 0)
+	assert.Equal(t, model.ErrNotFound, err)
+}
@@ -0,0 +1,136 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPrediction returns a valid, unresolved, prediction for the given stream, created now
+func newPrediction(stream model.VideoStreamID, question string) model.Buff {
+	b := newBuff(stream, question)
+	b.Kind = model.BuffPrediction
+	b.Answers[0].Correct = false
+	return b
+}
+
+// newResolution returns a valid resolution to the answer of the buff, made now
+func newResolution(b model.Buff, answer int) model.Resolution {
+	return model.Resolution{
+		Answer:     b.Answers[answer].ID,
+		ResolvedBy: "match-official",
+		ResolvedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+}
+
+func testResolveBuff(t *testing.T, store model.Store) {
+	vids := createLiveVideoStreams(t, store, time.Now())
+
+	b := newPrediction(vids[0].ID, "will there be a goal?")
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	createResponse(t, store, b, "alice", 1)
+	createResponse(t, store, b, "bob", 1)
+	createResponse(t, store, b, "carol", 0)
+
+	res := newResolution(b, 1)
+	resolved, err := store.ResolveBuff(context.Background(), b.ID, res)
+	require.NoError(t, err, "failed to resolve buff")
+
+	// The winning answer is marked as correct, and the responses are scored
+	expect := b
+	expect.Answers = append([]model.Answer(nil), b.Answers...)
+	expect.Answers[1].Correct = true
+	expect.Resolution = &res
+	expect.Resolution.Responses = 3
+	expect.Resolution.Correct = 2
+	assertBuffEqual(t, expect, *resolved)
+
+	got, err := store.GetBuff(context.Background(), b.ID)
+	require.NoError(t, err, "failed to get buff")
+	assertBuffEqual(t, expect, *got)
+
+	// Resolving again to the same answer keeps the first resolution
+	again := newResolution(b, 1)
+	again.ResolvedBy = "someone-else"
+	resolved, err = store.ResolveBuff(context.Background(), b.ID, again)
+	require.NoError(t, err, "resolving to the same answer should succeed")
+	assertBuffEqual(t, expect, *resolved)
+
+	// Another answer, more responses, and updates are all refused once it is resolved
+	var resolvedErr *model.BuffResolvedError
+
+	_, err = store.ResolveBuff(context.Background(), b.ID, newResolution(b, 0))
+	assert.True(t, errors.As(err, &resolvedErr), "resolving to another answer should be refused, got %v", err)
+	assert.True(t, errors.Is(err, model.ErrConflict))
+
+	err = store.CreateResponse(context.Background(), newResponse(b, "dave", 1))
+	assert.True(t, errors.As(err, &resolvedErr), "responding to a resolved buff should be refused, got %v", err)
+
+	update := b
+	update.Question = "will there be two goals?"
+	err = store.UpdateBuff(context.Background(), b.ID, update)
+	assert.True(t, errors.As(err, &resolvedErr), "updating a resolved buff should be refused, got %v", err)
+
+	got, err = store.GetBuff(context.Background(), b.ID)
+	require.NoError(t, err, "failed to get buff")
+	assertBuffEqual(t, expect, *got)
+}
+
+func testResolveBuffNotPrediction(t *testing.T, store model.Store) {
+	vids := createLiveVideoStreams(t, store, time.Now())
+
+	b := newBuff(vids[0].ID, "what's the answer?")
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	_, err := store.ResolveBuff(context.Background(), b.ID, newResolution(b, 1))
+	assert.Equal(t, &model.BuffKindError{Buff: b.ID, Kind: model.BuffQuiz}, err)
+
+	got, err := store.GetBuff(context.Background(), b.ID)
+	require.NoError(t, err, "failed to get buff")
+	assertBuffEqual(t, b, *got)
+}
+
+func testResolveBuffUnknownAnswer(t *testing.T, store model.Store) {
+	vids := createLiveVideoStreams(t, store, time.Now())
+
+	b := newPrediction(vids[0].ID, "will there be a goal?")
+	other := newPrediction(vids[0].ID, "will there be a penalty?")
+	for _, buff := range []model.Buff{b, other} {
+		require.NoError(t, store.CreateBuff(context.Background(), buff), "failed to create buff")
+	}
+
+	_, err := store.ResolveBuff(context.Background(), b.ID, newResolution(other, 0))
+	assert.True(t, errors.Is(err, model.ErrInvalidReference), "an answer to another buff should be refused, got %v", err)
+
+	got, err := store.GetBuff(context.Background(), b.ID)
+	require.NoError(t, err, "failed to get buff")
+	assertBuffEqual(t, b, *got)
+}
+
+func testResolveBuffNotFound(t *testing.T, store model.Store) {
+	_, err := store.ResolveBuff(context.Background(), model.BuffID(uuid.New()), model.Resolution{
+		Answer:     model.AnswerID(uuid.New()),
+		ResolvedBy: "match-official",
+		ResolvedAt: time.Now().UTC(),
+	})
+	assert.True(t, errors.Is(err, model.ErrNotFound), "expected not found, got %v", err)
+}
+
+func testResolveBuffInvalid(t *testing.T, store model.Store) {
+	vids := createLiveVideoStreams(t, store, time.Now())
+
+	b := newPrediction(vids[0].ID, "will there be a goal?")
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	res := newResolution(b, 1)
+	res.ResolvedBy = ""
+	_, err := store.ResolveBuff(context.Background(), b.ID, res)
+	assertInvalid(t, err)
+}
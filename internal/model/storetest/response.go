@@ -0,0 +1,277 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newResponse returns a valid response to the answer of the buff, given now
+func newResponse(b model.Buff, user string, answer int) model.Response {
+	return model.Response{
+		Buff:      b.ID,
+		User:      model.UserID(user),
+		Answer:    b.Answers[answer].ID,
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+}
+
+// createResponse stores a response to the answer of the buff
+func createResponse(t *testing.T, store model.Store, b model.Buff, user string, answer int) {
+	t.Helper()
+
+	require.NoError(t, store.CreateResponse(context.Background(), newResponse(b, user, answer)), "failed to create response")
+}
+
+// expectResults returns the results of the buff with the given number of responses per answer
+func expectResults(b model.Buff, responses ...int) model.Results {
+	res := model.Results{Buff: b.ID, Answers: make([]model.AnswerResult, 0, len(b.Answers))}
+	for i, ans := range b.Answers {
+		res.Total += responses[i]
+		res.Answers = append(res.Answers, model.AnswerResult{Answer: ans.ID, Text: ans.Text, Responses: responses[i]})
+	}
+	return res
+}
+
+func testGetResults(t *testing.T, store model.Store) {
+	vids := createLiveVideoStreams(t, store, time.Now())
+	buffs := createBuffs(t, store, vids[0].ID, time.Now(), time.Now())
+
+	createResponse(t, store, buffs[0], "alice", 0)
+	createResponse(t, store, buffs[0], "bob", 2)
+	createResponse(t, store, buffs[0], "carol", 2)
+
+	// Responses to other buffs are not counted
+	createResponse(t, store, buffs[1], "alice", 1)
+
+	res, err := store.GetResults(context.Background(), buffs[0].ID)
+	require.NoError(t, err, "failed to get results")
+	assert.Equal(t, expectResults(buffs[0], 1, 0, 2), *res)
+}
+
+func testGetResultsNoResponses(t *testing.T, store model.Store) {
+	vids := createLiveVideoStreams(t, store, time.Now())
+	buffs := createBuffs(t, store, vids[0].ID, time.Now())
+
+	res, err := store.GetResults(context.Background(), buffs[0].ID)
+	require.NoError(t, err, "failed to get results")
+	assert.Equal(t, expectResults(buffs[0], 0, 0, 0), *res)
+}
+
+func testGetResultsNotFound(t *testing.T, store model.Store) {
+	_, err := store.GetResults(context.Background(), model.BuffID(uuid.New()))
+	assert.Equal(t, model.ErrNotFound, err)
+}
+
+func testCreateResponseInvalid(t *testing.T, store model.Store) {
+	vids := createLiveVideoStreams(t, store, time.Now())
+	buffs := createBuffs(t, store, vids[0].ID, time.Now())
+
+	err := store.CreateResponse(context.Background(), newResponse(buffs[0], "", 0))
+	assertInvalid(t, err)
+}
+
+func testCreateResponseUnknownBuff(t *testing.T, store model.Store) {
+	vids := createLiveVideoStreams(t, store, time.Now())
+	buffs := createBuffs(t, store, vids[0].ID, time.Now())
+
+	resp := newResponse(buffs[0], "alice", 0)
+	resp.Buff = model.BuffID(uuid.New())
+
+	err := store.CreateResponse(context.Background(), resp)
+	assert.True(t, errors.Is(err, model.ErrNotFound), "expected ErrNotFound, got %v", err)
+}
+
+func testCreateResponseUnknownAnswer(t *testing.T, store model.Store) {
+	vids := createLiveVideoStreams(t, store, time.Now())
+	buffs := createBuffs(t, store, vids[0].ID, time.Now(), time.Now())
+
+	var tests = []struct {
+		name   string
+		answer model.AnswerID
+	}{
+		{name: "answer to another buff", answer: buffs[1].Answers[0].ID},
+		{name: "unknown answer", answer: model.AnswerID(uuid.New())},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			resp := newResponse(buffs[0], "alice", 0)
+			resp.Answer = tt.answer
+
+			err := store.CreateResponse(context.Background(), resp)
+			assert.True(t, errors.Is(err, model.ErrInvalidReference), "expected ErrInvalidReference, got %v", err)
+		})
+	}
+
+	res, err := store.GetResults(context.Background(), buffs[0].ID)
+	require.NoError(t, err, "failed to get results")
+	assert.Equal(t, expectResults(buffs[0], 0, 0, 0), *res)
+}
+
+func testCreateResponseDuplicate(t *testing.T, store model.Store) {
+	vids := createLiveVideoStreams(t, store, time.Now())
+	buffs := createBuffs(t, store, vids[0].ID, time.Now(), time.Now())
+
+	createResponse(t, store, buffs[0], "alice", 0)
+
+	// A user can't change their mind
+	err := store.CreateResponse(context.Background(), newResponse(buffs[0], "alice", 1))
+	assert.True(t, errors.Is(err, model.ErrConflict), "expected ErrConflict, got %v", err)
+
+	// But they can respond to every buff
+	createResponse(t, store, buffs[1], "alice", 1)
+
+	res, err := store.GetResults(context.Background(), buffs[0].ID)
+	require.NoError(t, err, "failed to get results")
+	assert.Equal(t, expectResults(buffs[0], 1, 0, 0), *res)
+}
+
+func testCreateResponseNotLive(t *testing.T, store model.Store) {
+	vids := createVideoStreams(t, store, time.Now(), time.Now())
+	scheduled, ended := vids[0], vids[1]
+
+	require.NoError(t, store.TransitionVideoStream(context.Background(), ended.ID, model.StreamLive), "failed to start video stream")
+	require.NoError(t, store.TransitionVideoStream(context.Background(), ended.ID, model.StreamEnded), "failed to end video stream")
+
+	var tests = []struct {
+		name   string
+		stream model.VideoStream
+		state  model.StreamState
+	}{
+		{name: "scheduled stream", stream: scheduled, state: model.StreamScheduled},
+		{name: "ended stream", stream: ended, state: model.StreamEnded},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			buffs := createBuffs(t, store, tt.stream.ID, time.Now())
+
+			err := store.CreateResponse(context.Background(), newResponse(buffs[0], "alice", 0))
+			assert.Equal(t, &model.StreamNotLiveError{Stream: tt.stream.ID, State: tt.state}, err)
+
+			res, err := store.GetResults(context.Background(), buffs[0].ID)
+			require.NoError(t, err, "failed to get results")
+			assert.Equal(t, expectResults(buffs[0], 0, 0, 0), *res)
+		})
+	}
+}
+
+func testCreateResponseClosed(t *testing.T, store model.Store) {
+	vids := createLiveVideoStreams(t, store, time.Now())
+	started := *vids[0].StartedAt
+
+	buffs := createBuffs(t, store, vids[0].ID, time.Now())
+	b := buffs[0]
+	b.Schedule = &model.Schedule{Offset: 0, Duration: 30 * time.Second}
+	require.NoError(t, store.UpdateBuff(context.Background(), b.ID, b), "failed to update buff")
+
+	var tests = []struct {
+		name   string
+		user   string
+		at     time.Duration
+		expect error
+	}{
+		{name: "open buff", user: "alice", at: 10 * time.Second},
+		{name: "closing buff", user: "bob", at: 30 * time.Second, expect: &model.BuffClosedError{Buff: b.ID}},
+		{name: "closed buff", user: "carol", at: time.Minute, expect: &model.BuffClosedError{Buff: b.ID}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			resp := newResponse(b, tt.user, 0)
+			resp.CreatedAt = started.Add(tt.at)
+
+			err := store.CreateResponse(context.Background(), resp)
+			assert.Equal(t, tt.expect, err)
+		})
+	}
+
+	// Only the response given while the buff was open is counted
+	res, err := store.GetResults(context.Background(), b.ID)
+	require.NoError(t, err, "failed to get results")
+	assert.Equal(t, expectResults(b, 1, 0, 0), *res)
+}
+
+func testUpdateBuffRemovesResponses(t *testing.T, store model.Store) {
+	vids := createLiveVideoStreams(t, store, time.Now())
+	buffs := createBuffs(t, store, vids[0].ID, time.Now())
+	b := buffs[0]
+
+	createResponse(t, store, b, "alice", 0)
+	createResponse(t, store, b, "bob", 2)
+
+	// Remove the last answer, and with it bob's response
+	b.Answers = b.Answers[:2]
+	require.NoError(t, store.UpdateBuff(context.Background(), b.ID, b), "failed to update buff")
+
+	res, err := store.GetResults(context.Background(), b.ID)
+	require.NoError(t, err, "failed to get results")
+	assert.Equal(t, expectResults(b, 1, 0), *res)
+
+	// Leaving bob free to respond again
+	createResponse(t, store, b, "bob", 1)
+}
+
+func testDeleteBuffRemovesResponses(t *testing.T, store model.Store) {
+	vids := createLiveVideoStreams(t, store, time.Now())
+	buffs := createBuffs(t, store, vids[0].ID, time.Now())
+
+	createResponse(t, store, buffs[0], "alice", 0)
+	require.NoError(t, store.DeleteBuff(context.Background(), buffs[0].ID), "failed to delete buff")
+
+	_, err := store.GetResults(context.Background(), buffs[0].ID)
+	assert.Equal(t, model.ErrNotFound, err)
+
+	// The same buff can be created again, without the old responses
+	require.NoError(t, store.CreateBuff(context.Background(), buffs[0]), "failed to recreate buff")
+
+	res, err := store.GetResults(context.Background(), buffs[0].ID)
+	require.NoError(t, err, "failed to get results")
+	assert.Equal(t, expectResults(buffs[0], 0, 0, 0), *res)
+}
+
+func testListResponseForUser(t *testing.T, store model.Store) {
+	vids := createLiveVideoStreams(t, store, time.Now())
+	buffs := createBuffs(t, store, vids[0].ID, time.Now(), time.Now(), time.Now())
+
+	expect := map[model.BuffID]model.Response{
+		buffs[0].ID: newResponse(buffs[0], "alice", 1),
+		buffs[2].ID: newResponse(buffs[2], "alice", 0),
+	}
+	for _, resp := range expect {
+		require.NoError(t, store.CreateResponse(context.Background(), resp), "failed to create response")
+	}
+	createResponse(t, store, buffs[1], "bob", 2)
+
+	got, err := store.ListResponseForUser(context.Background(), "alice", []model.BuffID{
+		buffs[0].ID, buffs[1].ID, buffs[2].ID, model.BuffID(uuid.New()),
+	})
+	require.NoError(t, err, "failed to list responses")
+	require.Len(t, got, len(expect))
+
+	for _, resp := range got {
+		e, ok := expect[resp.Buff]
+		require.True(t, ok, "unexpected response to buff %s", resp.Buff)
+
+		assert.Equal(t, e.User, resp.User, "user")
+		assert.Equal(t, e.Answer, resp.Answer, "answer")
+		assert.True(t, e.CreatedAt.Equal(resp.CreatedAt), "created at: expected %s, got %s", e.CreatedAt, resp.CreatedAt)
+	}
+
+	none, err := store.ListResponseForUser(context.Background(), "carol", []model.BuffID{buffs[0].ID})
+	require.NoError(t, err, "failed to list responses")
+	assert.NotNil(t, none, "an empty list should not be nil")
+	assert.Empty(t, none)
+
+	none, err = store.ListResponseForUser(context.Background(), "alice", nil)
+	require.NoError(t, err, "failed to list responses")
+	assert.NotNil(t, none, "an empty list should not be nil")
+	assert.Empty(t, none)
+}
@@ -0,0 +1,313 @@
+// Package storetest provides a conformance test suite for model.Store implementations
+//
+// Every backend should run the suite from its own tests, so that they all
+// behave in the same way from the point of view of the API:
+//
+//	func TestConformance(t *testing.T) {
+//		storetest.RunConformance(t, func(t *testing.T) model.Store {
+//			return newEmptyStore(t)
+//		})
+//	}
+package storetest
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/validation"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory returns a new, empty store for a single test
+//
+// The store must use the default validation rules and the
+// model.CascadeBuffs delete policy. Any clean up should be registered
+// with t.Cleanup.
+type Factory func(t *testing.T) model.Store
+
+// RunConformance runs the full conformance suite against the stores built by factory
+//
+// Each test is run as a sub-test with a store of its own. The tests are
+// never run in parallel, so factories are free to share a single database.
+func RunConformance(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store model.Store)
+	}{
+		{"GetVideoStream", testGetVideoStream},
+		{"GetVideoStreamNotFound", testGetVideoStreamNotFound},
+		{"ListVideoStream", testListVideoStream},
+		{"ListVideoStreamPagination", testListVideoStreamPagination},
+		{"ListVideoStreamAfter", testListVideoStreamAfter},
+		{"CreateVideoStreamInvalid", testCreateVideoStreamInvalid},
+		{"CreateVideoStreamDuplicate", testCreateVideoStreamDuplicate},
+		{"UpdateVideoStream", testUpdateVideoStream},
+		{"UpdateVideoStreamNotFound", testUpdateVideoStreamNotFound},
+		{"CreateVideoStreamDefaultState", testCreateVideoStreamDefaultState},
+		{"TransitionVideoStream", testTransitionVideoStream},
+		{"TransitionVideoStreamNotAllowed", testTransitionVideoStreamNotAllowed},
+		{"TransitionVideoStreamNotFound", testTransitionVideoStreamNotFound},
+		{"ListVideoStreamByState", testListVideoStreamByState},
+		{"ListVideoStreamFiltered", testListVideoStreamFiltered},
+		{"ListVideoStreamSorted", testListVideoStreamSorted},
+		{"ListVideoStreamSortedByBytes", testListVideoStreamSortedByBytes},
+		{"DeleteVideoStream", testDeleteVideoStream},
+		{"DeleteVideoStreamNotFound", testDeleteVideoStreamNotFound},
+		{"DeleteVideoStreamCascade", testDeleteVideoStreamCascade},
+		{"GetBuff", testGetBuff},
+		{"GetBuffNotFound", testGetBuffNotFound},
+		{"ListBuff", testListBuff},
+		{"ListBuffPagination", testListBuffPagination},
+		{"ListBuffAfter", testListBuffAfter},
+		{"ListBuffForStream", testListBuffForStream},
+		{"ListBuffForStreamPagination", testListBuffForStreamPagination},
+		{"ListBuffForStreamAfter", testListBuffForStreamAfter},
+		{"CreateBuffInvalid", testCreateBuffInvalid},
+		{"CreateBuffUnknownStream", testCreateBuffUnknownStream},
+		{"CreateBuffDuplicate", testCreateBuffDuplicate},
+		{"CreateBuffAnswerOrder", testCreateBuffAnswerOrder},
+		{"UpdateBuff", testUpdateBuff},
+		{"UpdateBuffAnswerOrder", testUpdateBuffAnswerOrder},
+		{"BuffKind", testBuffKind},
+		{"UpdateBuffNotFound", testUpdateBuffNotFound},
+		{"BuffSchedule", testBuffSchedule},
+		{"ListBuffActiveForStream", testListBuffActiveForStream},
+		{"ListBuffByStreamState", testListBuffByStreamState},
+		{"SearchBuffs", testSearchBuffs},
+		{"DeleteBuff", testDeleteBuff},
+		{"DeleteBuffNotFound", testDeleteBuffNotFound},
+		{"ListResponseForUser", testListResponseForUser},
+		{"GetResults", testGetResults},
+		{"GetResultsNoResponses", testGetResultsNoResponses},
+		{"GetResultsNotFound", testGetResultsNotFound},
+		{"CreateResponseInvalid", testCreateResponseInvalid},
+		{"CreateResponseUnknownBuff", testCreateResponseUnknownBuff},
+		{"CreateResponseUnknownAnswer", testCreateResponseUnknownAnswer},
+		{"CreateResponseDuplicate", testCreateResponseDuplicate},
+		{"CreateResponseNotLive", testCreateResponseNotLive},
+		{"CreateResponseClosed", testCreateResponseClosed},
+		{"UpdateBuffRemovesResponses", testUpdateBuffRemovesResponses},
+		{"ResolveBuff", testResolveBuff},
+		{"ResolveBuffNotPrediction", testResolveBuffNotPrediction},
+		{"ResolveBuffUnknownAnswer", testResolveBuffUnknownAnswer},
+		{"ResolveBuffNotFound", testResolveBuffNotFound},
+		{"ResolveBuffInvalid", testResolveBuffInvalid},
+		{"DeleteBuffRemovesResponses", testDeleteBuffRemovesResponses},
+		{"ListLeaderboard", testListLeaderboard},
+		{"ListLeaderboardEmpty", testListLeaderboardEmpty},
+		{"ListLeaderboardNotFound", testListLeaderboardNotFound},
+		{"GetScore", testGetScore},
+		{"GetScoreNotFound", testGetScoreNotFound},
+		{"LeaderboardResolveBuff", testLeaderboardResolveBuff},
+		{"LeaderboardUpdateBuff", testLeaderboardUpdateBuff},
+		{"LeaderboardDeleteBuff", testLeaderboardDeleteBuff},
+		{"BuffTags", testBuffTags},
+		{"VideoStreamTags", testVideoStreamTags},
+		{"ListBuffByTag", testListBuffByTag},
+		{"ListVideoStreamByTag", testListVideoStreamByTag},
+		{"ListTags", testListTags},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, factory(t))
+		})
+	}
+}
+
+// newVideoStream returns a valid video stream created at the given time
+//
+// Times are kept in UTC with microsecond precision, as that is all a
+// postgres timestamp column can hold.
+func newVideoStream(title string, created time.Time) model.VideoStream {
+	created = created.UTC().Truncate(time.Microsecond)
+
+	return model.VideoStream{
+		ID:        model.VideoStreamID(uuid.New()),
+		Title:     title,
+		State:     model.StreamScheduled,
+		CreatedAt: created,
+		UpdatedAt: created,
+	}
+}
+
+// newBuff returns a valid buff for the given stream, created now
+func newBuff(stream model.VideoStreamID, question string) model.Buff {
+	return model.Buff{
+		ID:        model.BuffID(uuid.New()),
+		Stream:    stream,
+		Kind:      model.BuffQuiz,
+		Question:  question,
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+		Answers: []model.Answer{
+			{ID: model.AnswerID(uuid.New()), Text: "42", Correct: true},
+			{ID: model.AnswerID(uuid.New()), Text: "43", Correct: false},
+			{ID: model.AnswerID(uuid.New()), Text: "44", Correct: false},
+		},
+	}
+}
+
+// createVideoStreams stores a stream for each of the given creation times
+// and returns them in the order the store should list them
+func createVideoStreams(t *testing.T, store model.Store, created ...time.Time) []model.VideoStream {
+	vids := make([]model.VideoStream, 0, len(created))
+	for _, c := range created {
+		v := newVideoStream("a stream", c)
+		require.NoError(t, store.CreateVideoStream(context.Background(), v), "failed to create video stream")
+		vids = append(vids, v)
+	}
+
+	sortVideoStreams(vids)
+	return vids
+}
+
+// createLiveVideoStreams stores a stream for each of the given creation times, and starts
+// them so that their buffs take responses, returning them as the store now holds them
+func createLiveVideoStreams(t *testing.T, store model.Store, created ...time.Time) []model.VideoStream {
+	vids := createVideoStreams(t, store, created...)
+	for i := range vids {
+		require.NoError(t, store.TransitionVideoStream(context.Background(), vids[i].ID, model.StreamLive), "failed to start video stream")
+
+		v, err := store.GetVideoStream(context.Background(), vids[i].ID)
+		require.NoError(t, err, "failed to get video stream")
+		vids[i] = *v
+	}
+	return vids
+}
+
+// videoStreamIDs returns the ids of the streams, in order
+func videoStreamIDs(vids []model.VideoStream) []model.VideoStreamID {
+	ids := make([]model.VideoStreamID, 0, len(vids))
+	for _, v := range vids {
+		ids = append(ids, v.ID)
+	}
+	return ids
+}
+
+// createBuffs stores a buff in the stream for each of the given creation times
+// and returns them in the order the store should list them
+func createBuffs(t *testing.T, store model.Store, stream model.VideoStreamID, created ...time.Time) []model.Buff {
+	buffs := make([]model.Buff, 0, len(created))
+	for _, c := range created {
+		b := newBuff(stream, "what's the answer?")
+		b.CreatedAt = c.UTC().Truncate(time.Microsecond)
+		require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+		buffs = append(buffs, b)
+	}
+
+	sortBuffs(buffs)
+	return buffs
+}
+
+// sortVideoStreams orders the streams by creation time, using the id to break ties
+func sortVideoStreams(vids []model.VideoStream) {
+	sort.Slice(vids, func(i, j int) bool {
+		if !vids[i].CreatedAt.Equal(vids[j].CreatedAt) {
+			return vids[i].CreatedAt.Before(vids[j].CreatedAt)
+		}
+		return vids[i].ID.String() < vids[j].ID.String()
+	})
+}
+
+// sortBuffs orders the buffs by creation time, using the id to break ties
+func sortBuffs(buffs []model.Buff) {
+	sort.Slice(buffs, func(i, j int) bool {
+		if !buffs[i].CreatedAt.Equal(buffs[j].CreatedAt) {
+			return buffs[i].CreatedAt.Before(buffs[j].CreatedAt)
+		}
+		return buffs[i].ID.String() < buffs[j].ID.String()
+	})
+}
+
+// assertVideoStreamEqual compares video streams, using time.Time.Equal
+// for the timestamps so the location they are returned in doesn't matter
+func assertVideoStreamEqual(t *testing.T, expect, actual model.VideoStream) {
+	t.Helper()
+
+	assert.Equal(t, expect.ID, actual.ID, "id")
+	assert.Equal(t, expect.Title, actual.Title, "title")
+	assert.Equal(t, expect.Tags, actual.Tags, "tags")
+	assert.Equal(t, expect.State, actual.State, "state")
+	assertTimeEqual(t, expect.ScheduledStart, actual.ScheduledStart, "scheduled start")
+	assertTimeEqual(t, expect.StartedAt, actual.StartedAt, "started at")
+	assertTimeEqual(t, expect.EndedAt, actual.EndedAt, "ended at")
+	assert.True(t, expect.CreatedAt.Equal(actual.CreatedAt), "created at: expected %s, got %s", expect.CreatedAt, actual.CreatedAt)
+	assert.True(t, expect.UpdatedAt.Equal(actual.UpdatedAt), "updated at: expected %s, got %s", expect.UpdatedAt, actual.UpdatedAt)
+}
+
+// assertTimeEqual compares optional times, using time.Time.Equal when both are set
+func assertTimeEqual(t *testing.T, expect, actual *time.Time, name string) {
+	t.Helper()
+
+	if expect == nil || actual == nil {
+		assert.Equal(t, expect, actual, name)
+		return
+	}
+	assert.True(t, expect.Equal(*actual), "%s: expected %s, got %s", name, *expect, *actual)
+}
+
+// assertVideoStreamsEqual compares lists of video streams, including their order
+func assertVideoStreamsEqual(t *testing.T, expect, actual []model.VideoStream) {
+	t.Helper()
+
+	require.Len(t, actual, len(expect))
+	for i := range expect {
+		assertVideoStreamEqual(t, expect[i], actual[i])
+	}
+}
+
+// assertBuffEqual compares buffs, including the order of the answers
+func assertBuffEqual(t *testing.T, expect, actual model.Buff) {
+	t.Helper()
+
+	assert.Equal(t, expect.ID, actual.ID, "id")
+	assert.Equal(t, expect.Stream, actual.Stream, "stream")
+	assert.Equal(t, expect.Kind, actual.Kind, "kind")
+	assert.Equal(t, expect.Question, actual.Question, "question")
+	assert.True(t, expect.CreatedAt.Equal(actual.CreatedAt), "created at: expected %s, got %s", expect.CreatedAt, actual.CreatedAt)
+	assert.Equal(t, expect.Answers, actual.Answers, "answers")
+	assert.Equal(t, expect.Schedule, actual.Schedule, "schedule")
+	assert.Equal(t, expect.Tags, actual.Tags, "tags")
+	assertResolutionEqual(t, expect.Resolution, actual.Resolution)
+}
+
+// assertResolutionEqual compares resolutions, which may be nil, using time.Equal for the time they were made
+func assertResolutionEqual(t *testing.T, expect, actual *model.Resolution) {
+	t.Helper()
+
+	if expect == nil || actual == nil {
+		assert.Equal(t, expect, actual, "resolution")
+		return
+	}
+
+	assert.True(t, expect.ResolvedAt.Equal(actual.ResolvedAt), "resolved at: expected %s, got %s", expect.ResolvedAt, actual.ResolvedAt)
+
+	e, a := *expect, *actual
+	e.ResolvedAt, a.ResolvedAt = time.Time{}, time.Time{}
+	assert.Equal(t, e, a, "resolution")
+}
+
+// assertBuffsEqual compares lists of buffs, including their order
+func assertBuffsEqual(t *testing.T, expect, actual []model.Buff) {
+	t.Helper()
+
+	require.Len(t, actual, len(expect))
+	for i := range expect {
+		assertBuffEqual(t, expect[i], actual[i])
+	}
+}
+
+// assertInvalid checks the error is a validation.Errors
+func assertInvalid(t *testing.T, err error) {
+	t.Helper()
+
+	var invalid validation.Errors
+	assert.True(t, errors.As(err, &invalid), "the error should be a validation.Errors, got %v", err)
+}
@@ -0,0 +1,201 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testBuffTags(t *testing.T, store model.Store) {
+	vids := createVideoStreams(t, store, time.Now())
+
+	b := newBuff(vids[0].ID, "who wins?")
+	b.Tags = []string{"sport", "football"}
+	require.NoError(t, store.CreateBuff(context.Background(), b), "failed to create buff")
+
+	got, err := store.GetBuff(context.Background(), b.ID)
+	require.NoError(t, err, "failed to get buff")
+	assert.Equal(t, []string{"football", "sport"}, got.Tags, "the tags should be listed in order")
+
+	// The tags are replaced as a whole, rather than added to
+	b.Tags = []string{"sponsor:acme", "sport"}
+	require.NoError(t, store.UpdateBuff(context.Background(), b.ID, b), "failed to update buff")
+
+	got, err = store.GetBuff(context.Background(), b.ID)
+	require.NoError(t, err, "failed to get buff")
+	assert.Equal(t, []string{"sponsor:acme", "sport"}, got.Tags)
+
+	b.Tags = nil
+	require.NoError(t, store.UpdateBuff(context.Background(), b.ID, b), "failed to update buff")
+
+	got, err = store.GetBuff(context.Background(), b.ID)
+	require.NoError(t, err, "failed to get buff")
+	assert.Empty(t, got.Tags)
+
+	invalid := newBuff(vids[0].ID, "who wins?")
+	invalid.Tags = []string{"Football"}
+	assertInvalid(t, store.CreateBuff(context.Background(), invalid))
+}
+
+func testVideoStreamTags(t *testing.T, store model.Store) {
+	v := newVideoStream("a stream", time.Now())
+	v.Tags = []string{"sport", "football"}
+	require.NoError(t, store.CreateVideoStream(context.Background(), v), "failed to create video stream")
+
+	got, err := store.GetVideoStream(context.Background(), v.ID)
+	require.NoError(t, err, "failed to get video stream")
+	assert.Equal(t, []string{"football", "sport"}, got.Tags, "the tags should be listed in order")
+
+	// The tags are replaced as a whole, rather than added to
+	v.Tags = []string{"final"}
+	require.NoError(t, store.UpdateVideoStream(context.Background(), v.ID, v), "failed to update video stream")
+
+	got, err = store.GetVideoStream(context.Background(), v.ID)
+	require.NoError(t, err, "failed to get video stream")
+	assert.Equal(t, []string{"final"}, got.Tags)
+
+	all, err := store.ListVideoStream(context.Background(), model.VideoStreamQuery{}, 0, 0)
+	require.NoError(t, err, "failed to list video streams")
+	require.Len(t, all, 1)
+	assert.Equal(t, []string{"final"}, all[0].Tags, "the tags should be listed with the stream")
+
+	invalid := newVideoStream("a stream", time.Now())
+	invalid.Tags = []string{"final", "final"}
+	assertInvalid(t, store.CreateVideoStream(context.Background(), invalid))
+}
+
+func testListBuffByTag(t *testing.T, store model.Store) {
+	vids := createVideoStreams(t, store, time.Now().Add(-time.Hour), time.Now())
+
+	now := time.Now()
+	buffs := createBuffs(t, store, vids[0].ID, now.Add(-3*time.Minute), now.Add(-2*time.Minute), now.Add(-time.Minute))
+	tags := [][]string{{"football"}, {"football", "sport"}, {"sport"}}
+	for i := range buffs {
+		buffs[i].Tags = tags[i]
+		buffs[i].Schedule = &model.Schedule{Offset: 0, Duration: time.Minute}
+		require.NoError(t, store.UpdateBuff(context.Background(), buffs[i].ID, buffs[i]), "failed to update buff")
+	}
+
+	// A buff of another stream with the same tags must only be listed across streams
+	other := newBuff(vids[1].ID, "is this another stream?")
+	other.CreatedAt = now.UTC().Truncate(time.Microsecond)
+	other.Tags = []string{"football"}
+	require.NoError(t, store.CreateBuff(context.Background(), other), "failed to create buff")
+
+	tests := []struct {
+		name         string
+		tags         []string
+		expect       []model.Buff
+		expectStream []model.Buff
+	}{
+		{name: "no tags", tags: nil, expect: append(buffs[:3:3], other), expectStream: buffs},
+		{name: "one tag", tags: []string{"football"}, expect: []model.Buff{buffs[0], buffs[1], other}, expectStream: buffs[:2]},
+		{name: "every tag", tags: []string{"sport", "football"}, expect: buffs[1:2], expectStream: buffs[1:2]},
+		{name: "repeated tag", tags: []string{"sport", "sport"}, expect: buffs[1:], expectStream: buffs[1:]},
+		{name: "unknown tag", tags: []string{"tennis"}, expect: []model.Buff{}, expectStream: []model.Buff{}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			query := model.BuffQuery{Tags: tt.tags}
+
+			all, err := store.ListBuff(context.Background(), query, 0, 0)
+			require.NoError(t, err, "failed to list buffs")
+			assertBuffsEqual(t, tt.expect, all)
+
+			after, err := store.ListBuffAfter(context.Background(), query, nil, 10)
+			require.NoError(t, err, "failed to list buffs after")
+			assertBuffsEqual(t, tt.expect, after)
+
+			forStream, err := store.ListBuffForStream(context.Background(), vids[0].ID, query, 0, 0)
+			require.NoError(t, err, "failed to list buffs for stream")
+			assertBuffsEqual(t, tt.expectStream, forStream)
+
+			forStreamAfter, err := store.ListBuffForStreamAfter(context.Background(), vids[0].ID, query, nil, 10)
+			require.NoError(t, err, "failed to list buffs for stream after")
+			assertBuffsEqual(t, tt.expectStream, forStreamAfter)
+
+			active, err := store.ListBuffActiveForStream(context.Background(), vids[0].ID, query, 0)
+			require.NoError(t, err, "failed to list active buffs")
+			assertBuffsEqual(t, tt.expectStream, active)
+		})
+	}
+}
+
+func testListVideoStreamByTag(t *testing.T, store model.Store) {
+	vids := make([]model.VideoStream, 0, 3)
+	for i, tags := range [][]string{{"football"}, {"football", "sport"}, nil} {
+		v := newVideoStream("a stream", time.Now().Add(time.Duration(i-3)*time.Minute))
+		v.Tags = tags
+		require.NoError(t, store.CreateVideoStream(context.Background(), v), "failed to create video stream")
+		vids = append(vids, v)
+	}
+
+	tests := []struct {
+		name   string
+		tags   []string
+		expect []model.VideoStream
+	}{
+		{name: "no tags", tags: nil, expect: vids},
+		{name: "one tag", tags: []string{"football"}, expect: vids[:2]},
+		{name: "every tag", tags: []string{"football", "sport"}, expect: vids[1:2]},
+		{name: "unknown tag", tags: []string{"tennis"}, expect: []model.VideoStream{}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			query := model.VideoStreamQuery{Tags: tt.tags}
+
+			all, err := store.ListVideoStream(context.Background(), query, 0, 0)
+			require.NoError(t, err, "failed to list video streams")
+			assert.Equal(t, videoStreamIDs(tt.expect), videoStreamIDs(all))
+
+			after, err := store.ListVideoStreamAfter(context.Background(), query, nil, 10)
+			require.NoError(t, err, "failed to list video streams after")
+			assert.Equal(t, videoStreamIDs(tt.expect), videoStreamIDs(after))
+		})
+	}
+}
+
+func testListTags(t *testing.T, store model.Store) {
+	empty, err := store.ListTags(context.Background())
+	require.NoError(t, err, "failed to list tags")
+	assert.NotNil(t, empty, "an empty list should not be nil")
+	assert.Empty(t, empty)
+
+	v := newVideoStream("a stream", time.Now())
+	v.Tags = []string{"football", "final"}
+	require.NoError(t, store.CreateVideoStream(context.Background(), v), "failed to create video stream")
+
+	kept := newBuff(v.ID, "who wins?")
+	kept.Tags = []string{"football"}
+	require.NoError(t, store.CreateBuff(context.Background(), kept), "failed to create buff")
+
+	deleted := newBuff(v.ID, "who scores first?")
+	deleted.Tags = []string{"football", "scorer"}
+	require.NoError(t, store.CreateBuff(context.Background(), deleted), "failed to create buff")
+
+	got, err := store.ListTags(context.Background())
+	require.NoError(t, err, "failed to list tags")
+	assert.Equal(t, []model.TagCount{
+		{Tag: "final", Buffs: 0, VideoStreams: 1},
+		{Tag: "football", Buffs: 2, VideoStreams: 1},
+		{Tag: "scorer", Buffs: 1, VideoStreams: 0},
+	}, got)
+
+	// A tag nothing has any more is no longer listed
+	require.NoError(t, store.DeleteBuff(context.Background(), deleted.ID), "failed to delete buff")
+
+	got, err = store.ListTags(context.Background())
+	require.NoError(t, err, "failed to list tags")
+	assert.Equal(t, []model.TagCount{
+		{Tag: "final", Buffs: 0, VideoStreams: 1},
+		{Tag: "football", Buffs: 1, VideoStreams: 1},
+	}, got)
+}
@@ -0,0 +1,481 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testGetVideoStream(t *testing.T, store model.Store) {
+	v := newVideoStream("a stream", time.Now().Add(-time.Hour))
+	v.UpdatedAt = v.UpdatedAt.Add(time.Minute)
+	require.NoError(t, store.CreateVideoStream(context.Background(), v), "failed to create video stream")
+
+	got, err := store.GetVideoStream(context.Background(), v.ID)
+	require.NoError(t, err, "failed to get video stream")
+	assertVideoStreamEqual(t, v, *got)
+}
+
+func testGetVideoStreamNotFound(t *testing.T, store model.Store) {
+	_, err := store.GetVideoStream(context.Background(), model.VideoStreamID(uuid.New()))
+	assert.Equal(t, model.ErrNotFound, err)
+}
+
+func testListVideoStream(t *testing.T, store model.Store) {
+	empty, err := store.ListVideoStream(context.Background(), model.VideoStreamQuery{}, 0, 0)
+	require.NoError(t, err, "failed to list video streams")
+	assert.NotNil(t, empty, "an empty list should not be nil")
+	assert.Empty(t, empty)
+
+	// Created out of order, with two streams sharing a creation time
+	// so the id is needed to break the tie
+	now := time.Now()
+	vids := createVideoStreams(t, store, now, now.Add(-time.Hour), now.Add(-2*time.Hour), now)
+
+	all, err := store.ListVideoStream(context.Background(), model.VideoStreamQuery{}, 0, 0)
+	require.NoError(t, err, "failed to list video streams")
+	assertVideoStreamsEqual(t, vids, all)
+}
+
+func testListVideoStreamPagination(t *testing.T, store model.Store) {
+	now := time.Now()
+	vids := createVideoStreams(t, store,
+		now.Add(-5*time.Minute), now.Add(-4*time.Minute), now.Add(-3*time.Minute),
+		now.Add(-2*time.Minute), now.Add(-time.Minute),
+	)
+
+	var tests = []struct {
+		name   string
+		offset int
+		limit  int
+		expect []model.VideoStream
+	}{
+		{name: "no offset or limit", expect: vids},
+		{name: "limit only", limit: 2, expect: vids[:2]},
+		{name: "offset only", offset: 3, expect: vids[3:]},
+		{name: "offset and limit", offset: 1, limit: 3, expect: vids[1:4]},
+		{name: "limit past the end", offset: 3, limit: 10, expect: vids[3:]},
+		{name: "offset at the end", offset: 5, limit: 2, expect: []model.VideoStream{}},
+		{name: "offset past the end", offset: 10, limit: 2, expect: []model.VideoStream{}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := store.ListVideoStream(context.Background(), model.VideoStreamQuery{}, tt.offset, tt.limit)
+			require.NoError(t, err, "failed to list video streams")
+			assertVideoStreamsEqual(t, tt.expect, page)
+		})
+	}
+}
+
+func testListVideoStreamAfter(t *testing.T, store model.Store) {
+	empty, err := store.ListVideoStreamAfter(context.Background(), model.VideoStreamQuery{}, nil, 2)
+	require.NoError(t, err, "failed to list video streams")
+	assert.NotNil(t, empty, "an empty list should not be nil")
+	assert.Empty(t, empty)
+
+	// Two streams share a creation time, so the id is needed to break the tie
+	now := time.Now()
+	vids := createVideoStreams(t, store,
+		now.Add(-4*time.Minute), now.Add(-3*time.Minute), now.Add(-3*time.Minute),
+		now.Add(-2*time.Minute), now.Add(-time.Minute),
+	)
+
+	first, err := store.ListVideoStreamAfter(context.Background(), model.VideoStreamQuery{}, nil, 2)
+	require.NoError(t, err, "failed to list video streams")
+	assertVideoStreamsEqual(t, vids[:2], first)
+
+	// Changes before the cursor must not move the following pages
+	require.NoError(t, store.DeleteVideoStream(context.Background(), vids[0].ID), "failed to delete video stream")
+	createVideoStreams(t, store, now.Add(-time.Hour))
+
+	after := first[1].Cursor()
+	second, err := store.ListVideoStreamAfter(context.Background(), model.VideoStreamQuery{}, &after, 2)
+	require.NoError(t, err, "failed to list video streams")
+	assertVideoStreamsEqual(t, vids[2:4], second)
+
+	after = second[1].Cursor()
+	last, err := store.ListVideoStreamAfter(context.Background(), model.VideoStreamQuery{}, &after, 2)
+	require.NoError(t, err, "failed to list video streams")
+	assertVideoStreamsEqual(t, vids[4:], last)
+
+	after = last[0].Cursor()
+	end, err := store.ListVideoStreamAfter(context.Background(), model.VideoStreamQuery{}, &after, 2)
+	require.NoError(t, err, "failed to list video streams")
+	assert.Empty(t, end)
+
+	// A zero limit returns everything after the cursor
+	after = vids[1].Cursor()
+	rest, err := store.ListVideoStreamAfter(context.Background(), model.VideoStreamQuery{}, &after, 0)
+	require.NoError(t, err, "failed to list video streams")
+	assertVideoStreamsEqual(t, vids[2:], rest)
+}
+
+func testCreateVideoStreamInvalid(t *testing.T, store model.Store) {
+	v := newVideoStream("", time.Now())
+	assertInvalid(t, store.CreateVideoStream(context.Background(), v))
+
+	_, err := store.GetVideoStream(context.Background(), v.ID)
+	assert.Equal(t, model.ErrNotFound, err, "an invalid stream should not be stored")
+}
+
+func testCreateVideoStreamDuplicate(t *testing.T, store model.Store) {
+	v := newVideoStream("a stream", time.Now())
+	require.NoError(t, store.CreateVideoStream(context.Background(), v), "failed to create video stream")
+
+	dup := v
+	dup.Title = "another stream"
+	err := store.CreateVideoStream(context.Background(), dup)
+	assert.True(t, errors.Is(err, model.ErrConflict), "ids must be unique, got %v", err)
+
+	got, err := store.GetVideoStream(context.Background(), v.ID)
+	require.NoError(t, err, "failed to get video stream")
+	assertVideoStreamEqual(t, v, *got)
+}
+
+func testUpdateVideoStream(t *testing.T, store model.Store) {
+	v := newVideoStream("a stream", time.Now().Add(-time.Hour))
+	require.NoError(t, store.CreateVideoStream(context.Background(), v), "failed to create video stream")
+
+	before := time.Now().Truncate(time.Microsecond)
+
+	// The timestamps and state are owned by the store, so these should be ignored
+	scheduled := time.Now().Add(time.Hour).UTC().Truncate(time.Microsecond)
+	started := time.Now().UTC()
+	update := v
+	update.Title = "a new title"
+	update.ScheduledStart = &scheduled
+	update.State = model.StreamLive
+	update.StartedAt = &started
+	update.CreatedAt = time.Now().Add(time.Hour)
+	update.UpdatedAt = time.Now().Add(time.Hour)
+	require.NoError(t, store.UpdateVideoStream(context.Background(), v.ID, update), "failed to update video stream")
+
+	after := time.Now()
+
+	got, err := store.GetVideoStream(context.Background(), v.ID)
+	require.NoError(t, err, "failed to get video stream")
+
+	assert.Equal(t, "a new title", got.Title)
+	assertTimeEqual(t, &scheduled, got.ScheduledStart, "scheduled start")
+	assert.Equal(t, model.StreamScheduled, got.State, "the state must not change")
+	assert.Nil(t, got.StartedAt, "the start time must not change")
+	assert.True(t, v.CreatedAt.Equal(got.CreatedAt), "the creation time must not change")
+	assert.False(t, got.UpdatedAt.Before(before), "the updated time should be set to now, got %s", got.UpdatedAt)
+	assert.False(t, got.UpdatedAt.After(after), "the updated time should be set to now, got %s", got.UpdatedAt)
+
+	invalid := v
+	invalid.Title = ""
+	assertInvalid(t, store.UpdateVideoStream(context.Background(), v.ID, invalid))
+}
+
+func testUpdateVideoStreamNotFound(t *testing.T, store model.Store) {
+	v := newVideoStream("a stream", time.Now())
+	err := store.UpdateVideoStream(context.Background(), v.ID, v)
+	assert.Equal(t, model.ErrNotFound, err)
+}
+
+func testCreateVideoStreamDefaultState(t *testing.T, store model.Store) {
+	v := newVideoStream("a stream", time.Now())
+	v.State = ""
+	require.NoError(t, store.CreateVideoStream(context.Background(), v), "failed to create video stream")
+
+	got, err := store.GetVideoStream(context.Background(), v.ID)
+	require.NoError(t, err, "failed to get video stream")
+	assert.Equal(t, model.StreamScheduled, got.State, "a stream is scheduled unless it is created in another state")
+
+	invalid := newVideoStream("a stream", time.Now())
+	invalid.State = "paused"
+	assertInvalid(t, store.CreateVideoStream(context.Background(), invalid))
+}
+
+func testTransitionVideoStream(t *testing.T, store model.Store) {
+	vids := createVideoStreams(t, store, time.Now().Add(-time.Hour), time.Now())
+	v := vids[0]
+
+	before := time.Now().Truncate(time.Microsecond)
+	require.NoError(t, store.TransitionVideoStream(context.Background(), v.ID, model.StreamLive), "failed to start video stream")
+
+	got, err := store.GetVideoStream(context.Background(), v.ID)
+	require.NoError(t, err, "failed to get video stream")
+	assert.Equal(t, model.StreamLive, got.State)
+	require.NotNil(t, got.StartedAt, "starting the stream should record when it started")
+	assert.False(t, got.StartedAt.Before(before), "the start time should be set to now, got %s", got.StartedAt)
+	assert.Nil(t, got.EndedAt)
+	assert.True(t, got.StartedAt.Equal(got.UpdatedAt), "the updated time should be the start time")
+	started := *got.StartedAt
+
+	require.NoError(t, store.TransitionVideoStream(context.Background(), v.ID, model.StreamEnded), "failed to end video stream")
+
+	got, err = store.GetVideoStream(context.Background(), v.ID)
+	require.NoError(t, err, "failed to get video stream")
+	assert.Equal(t, model.StreamEnded, got.State)
+	assertTimeEqual(t, &started, got.StartedAt, "started at")
+	require.NotNil(t, got.EndedAt, "ending the stream should record when it ended")
+	assert.False(t, got.EndedAt.Before(started), "the stream can't end before it started")
+
+	require.NoError(t, store.TransitionVideoStream(context.Background(), v.ID, model.StreamArchived), "failed to archive video stream")
+
+	got, err = store.GetVideoStream(context.Background(), v.ID)
+	require.NoError(t, err, "failed to get video stream")
+	assert.Equal(t, model.StreamArchived, got.State)
+
+	// The other stream must be untouched
+	got, err = store.GetVideoStream(context.Background(), vids[1].ID)
+	require.NoError(t, err, "failed to get video stream")
+	assertVideoStreamEqual(t, vids[1], *got)
+}
+
+func testTransitionVideoStreamNotAllowed(t *testing.T, store model.Store) {
+	vids := createVideoStreams(t, store, time.Now())
+	v := vids[0]
+
+	err := store.TransitionVideoStream(context.Background(), v.ID, model.StreamEnded)
+	assert.Equal(t, &model.StreamTransitionError{Stream: v.ID, From: model.StreamScheduled, To: model.StreamEnded}, err)
+	assert.True(t, errors.Is(err, model.ErrConflict), "an illegal transition is a conflict, got %v", err)
+
+	got, err := store.GetVideoStream(context.Background(), v.ID)
+	require.NoError(t, err, "failed to get video stream")
+	assertVideoStreamEqual(t, v, *got)
+}
+
+func testTransitionVideoStreamNotFound(t *testing.T, store model.Store) {
+	err := store.TransitionVideoStream(context.Background(), model.VideoStreamID(uuid.New()), model.StreamLive)
+	assert.Equal(t, model.ErrNotFound, err)
+}
+
+func testListVideoStreamByState(t *testing.T, store model.Store) {
+	vids := createVideoStreams(t, store, time.Now().Add(-3*time.Minute), time.Now().Add(-2*time.Minute), time.Now().Add(-time.Minute))
+
+	for _, v := range vids[1:] {
+		require.NoError(t, store.TransitionVideoStream(context.Background(), v.ID, model.StreamLive), "failed to start video stream")
+	}
+	require.NoError(t, store.TransitionVideoStream(context.Background(), vids[2].ID, model.StreamEnded), "failed to end video stream")
+
+	var tests = []struct {
+		name   string
+		states []model.StreamState
+		expect []model.VideoStream
+	}{
+		{name: "any state", expect: vids},
+		{name: "one state", states: []model.StreamState{model.StreamLive}, expect: vids[1:2]},
+		{name: "several states", states: []model.StreamState{model.StreamScheduled, model.StreamEnded}, expect: []model.VideoStream{vids[0], vids[2]}},
+		{name: "no matches", states: []model.StreamState{model.StreamArchived}, expect: []model.VideoStream{}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			query := model.VideoStreamQuery{States: tt.states}
+
+			got, err := store.ListVideoStream(context.Background(), query, 0, 0)
+			require.NoError(t, err, "failed to list video streams")
+			assert.Equal(t, videoStreamIDs(tt.expect), videoStreamIDs(got))
+
+			// The cursor pagination is filtered in the same way
+			expectFirst := tt.expect
+			if len(expectFirst) > 1 {
+				expectFirst = expectFirst[:1]
+			}
+
+			first, err := store.ListVideoStreamAfter(context.Background(), query, nil, 1)
+			require.NoError(t, err, "failed to list video streams")
+			assert.Equal(t, videoStreamIDs(expectFirst), videoStreamIDs(first))
+
+			if len(first) != 0 {
+				after := first[0].Cursor()
+				rest, err := store.ListVideoStreamAfter(context.Background(), query, &after, 0)
+				require.NoError(t, err, "failed to list video streams")
+				assert.Equal(t, videoStreamIDs(tt.expect[1:]), videoStreamIDs(rest))
+			}
+		})
+	}
+}
+
+func testListVideoStreamFiltered(t *testing.T, store model.Store) {
+	start := time.Now().Add(-time.Hour)
+
+	vids := make([]model.VideoStream, 0, 3)
+	for i, title := range []string{"Morning Show", "evening show", "100%_news"} {
+		v := newVideoStream(title, start.Add(time.Duration(i)*time.Minute))
+		require.NoError(t, store.CreateVideoStream(context.Background(), v), "failed to create video stream")
+		vids = append(vids, v)
+	}
+
+	// Only the last stream is updated after the streams were created
+	updated := time.Now().UTC().Truncate(time.Microsecond)
+	require.NoError(t, store.UpdateVideoStream(context.Background(), vids[2].ID, vids[2]), "failed to update video stream")
+
+	var tests = []struct {
+		name   string
+		query  model.VideoStreamQuery
+		expect []model.VideoStream
+	}{
+		{name: "title contains", query: model.VideoStreamQuery{TitleContains: "show"}, expect: vids[:2]},
+		{name: "title contains ignores case", query: model.VideoStreamQuery{TitleContains: "SHOW"}, expect: vids[:2]},
+		{name: "title contains wildcards literally", query: model.VideoStreamQuery{TitleContains: "%_"}, expect: vids[2:]},
+		{name: "title contains no matches", query: model.VideoStreamQuery{TitleContains: "_show"}, expect: []model.VideoStream{}},
+		{name: "created after", query: model.VideoStreamQuery{CreatedAfter: &vids[0].CreatedAt}, expect: vids[1:]},
+		{name: "created before", query: model.VideoStreamQuery{CreatedBefore: &vids[2].CreatedAt}, expect: vids[:2]},
+		{
+			name:   "created between",
+			query:  model.VideoStreamQuery{CreatedAfter: &vids[0].CreatedAt, CreatedBefore: &vids[2].CreatedAt},
+			expect: vids[1:2],
+		},
+		{name: "updated since", query: model.VideoStreamQuery{UpdatedSince: &updated}, expect: vids[2:]},
+		{name: "updated since includes the time", query: model.VideoStreamQuery{UpdatedSince: &vids[0].UpdatedAt}, expect: vids},
+		{
+			name:   "every filter must match",
+			query:  model.VideoStreamQuery{TitleContains: "show", CreatedAfter: &vids[0].CreatedAt},
+			expect: vids[1:2],
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.ListVideoStream(context.Background(), tt.query, 0, 0)
+			require.NoError(t, err, "failed to list video streams")
+			assert.Equal(t, videoStreamIDs(tt.expect), videoStreamIDs(got))
+
+			// The cursor pagination is filtered in the same way
+			got, err = store.ListVideoStreamAfter(context.Background(), tt.query, nil, 0)
+			require.NoError(t, err, "failed to list video streams")
+			assert.Equal(t, videoStreamIDs(tt.expect), videoStreamIDs(got))
+		})
+	}
+}
+
+func testListVideoStreamSorted(t *testing.T, store model.Store) {
+	start := time.Now().Add(-time.Hour)
+
+	vids := make([]model.VideoStream, 0, 4)
+	for i, title := range []string{"b", "a", "c", "a"} {
+		v := newVideoStream(title, start.Add(time.Duration(i)*time.Minute))
+		require.NoError(t, store.CreateVideoStream(context.Background(), v), "failed to create video stream")
+		vids = append(vids, v)
+	}
+
+	// Updating the first stream moves it to the end when sorted by update time
+	require.NoError(t, store.UpdateVideoStream(context.Background(), vids[0].ID, vids[0]), "failed to update video stream")
+
+	var tests = []struct {
+		name   string
+		sort   []model.VideoStreamSort
+		expect []model.VideoStream
+	}{
+		{name: "default order", expect: vids},
+		{
+			name:   "created descending",
+			sort:   []model.VideoStreamSort{{Field: model.SortStreamCreated, Descending: true}},
+			expect: []model.VideoStream{vids[3], vids[2], vids[1], vids[0]},
+		},
+		{
+			name:   "title breaks ties by creation",
+			sort:   []model.VideoStreamSort{{Field: model.SortStreamTitle}},
+			expect: []model.VideoStream{vids[1], vids[3], vids[0], vids[2]},
+		},
+		{
+			name:   "title descending",
+			sort:   []model.VideoStreamSort{{Field: model.SortStreamTitle, Descending: true}},
+			expect: []model.VideoStream{vids[2], vids[0], vids[1], vids[3]},
+		},
+		{
+			name: "title then created descending",
+			sort: []model.VideoStreamSort{
+				{Field: model.SortStreamTitle},
+				{Field: model.SortStreamCreated, Descending: true},
+			},
+			expect: []model.VideoStream{vids[3], vids[1], vids[0], vids[2]},
+		},
+		{
+			name:   "updated",
+			sort:   []model.VideoStreamSort{{Field: model.SortStreamUpdated}},
+			expect: []model.VideoStream{vids[1], vids[2], vids[3], vids[0]},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			query := model.VideoStreamQuery{Sort: tt.sort}
+
+			got, err := store.ListVideoStream(context.Background(), query, 0, 0)
+			require.NoError(t, err, "failed to list video streams")
+			assert.Equal(t, videoStreamIDs(tt.expect), videoStreamIDs(got))
+
+			// The pages follow the same order
+			page, err := store.ListVideoStream(context.Background(), query, 1, 2)
+			require.NoError(t, err, "failed to list video streams")
+			assert.Equal(t, videoStreamIDs(tt.expect[1:3]), videoStreamIDs(page))
+		})
+	}
+}
+
+func testListVideoStreamSortedByBytes(t *testing.T, store model.Store) {
+	start := time.Now().Add(-time.Hour)
+
+	// Upper case letters come before lower case ones byte by byte,
+	// though most locales would put the "a" first
+	lower := newVideoStream("a", start)
+	upper := newVideoStream("B", start.Add(time.Minute))
+	for _, v := range []model.VideoStream{lower, upper} {
+		require.NoError(t, store.CreateVideoStream(context.Background(), v), "failed to create video stream")
+	}
+
+	query := model.VideoStreamQuery{Sort: []model.VideoStreamSort{{Field: model.SortStreamTitle}}}
+	got, err := store.ListVideoStream(context.Background(), query, 0, 0)
+	require.NoError(t, err, "failed to list video streams")
+	assert.Equal(t, videoStreamIDs([]model.VideoStream{upper, lower}), videoStreamIDs(got))
+}
+
+func testDeleteVideoStream(t *testing.T, store model.Store) {
+	vids := createVideoStreams(t, store, time.Now().Add(-time.Hour), time.Now())
+
+	require.NoError(t, store.DeleteVideoStream(context.Background(), vids[0].ID), "failed to delete video stream")
+
+	_, err := store.GetVideoStream(context.Background(), vids[0].ID)
+	assert.Equal(t, model.ErrNotFound, err)
+
+	all, err := store.ListVideoStream(context.Background(), model.VideoStreamQuery{}, 0, 0)
+	require.NoError(t, err, "failed to list video streams")
+	assertVideoStreamsEqual(t, vids[1:], all)
+}
+
+func testDeleteVideoStreamNotFound(t *testing.T, store model.Store) {
+	err := store.DeleteVideoStream(context.Background(), model.VideoStreamID(uuid.New()))
+	assert.Equal(t, model.ErrNotFound, err)
+}
+
+func testDeleteVideoStreamCascade(t *testing.T, store model.Store) {
+	vids := createVideoStreams(t, store, time.Now().Add(-time.Hour), time.Now())
+
+	deleted := newBuff(vids[0].ID, "will this be deleted?")
+	require.NoError(t, store.CreateBuff(context.Background(), deleted), "failed to create buff")
+
+	kept := newBuff(vids[1].ID, "will this be kept?")
+	require.NoError(t, store.CreateBuff(context.Background(), kept), "failed to create buff")
+
+	require.NoError(t, store.DeleteVideoStream(context.Background(), vids[0].ID), "failed to delete video stream")
+
+	_, err := store.GetBuff(context.Background(), deleted.ID)
+	assert.Equal(t, model.ErrNotFound, err, "the buffs of the stream should be deleted with it")
+
+	buffs, err := store.ListBuffForStream(context.Background(), vids[0].ID, model.BuffQuery{}, 0, 0)
+	require.NoError(t, err, "failed to list buffs for stream")
+	assert.Empty(t, buffs)
+
+	all, err := store.ListBuff(context.Background(), model.BuffQuery{}, 0, 0)
+	require.NoError(t, err, "failed to list buffs")
+	assertBuffsEqual(t, []model.Buff{kept}, all)
+
+	// The answers should have gone too, leaving their ids free to use again
+	deleted.Stream = vids[1].ID
+	assert.NoError(t, store.CreateBuff(context.Background(), deleted))
+}
@@ -0,0 +1,42 @@
+package model
+
+import "context"
+
+// TagStore defines the actions needed to report on the tags in use
+// This could be implemented by:
+//   - A relational database (for production)
+//   - A mock implementation (for testing)
+//   - An RPC backend (for unforeseen future developments)
+//
+// Tags aren't written on their own, but along with the buffs and video streams they
+// are on, so a tag is in use for as long as something has it.
+//
+// ListTags returns every tag in use, ordered by the tag.
+type TagStore interface {
+	ListTags(context.Context) ([]TagCount, error)
+}
+
+// TagCount is a tag in use, along with the number of buffs and video streams that have it
+type TagCount struct {
+	Tag          string
+	Buffs        int
+	VideoStreams int
+}
+
+// HasTags reports whether tags holds every one of want
+// Every list of tags has all of an empty want.
+func HasTags(tags, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, t := range tags {
+			if t == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,39 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasTags(t *testing.T) {
+	var tests = []struct {
+		name   string
+		tags   []string
+		want   []string
+		expect bool
+	}{
+		{name: "nothing wanted", tags: nil, want: nil, expect: true},
+		{name: "has every tag", tags: []string{"football", "sponsor:acme", "trivia"}, want: []string{"trivia", "football"}, expect: true},
+		{name: "missing a tag", tags: []string{"football"}, want: []string{"football", "trivia"}, expect: false},
+		{name: "no tags", tags: nil, want: []string{"football"}, expect: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, model.HasTags(tt.tags, tt.want))
+		})
+	}
+}
+
+func TestTagQueriesMatch(t *testing.T) {
+	query := []string{"football", "trivia"}
+
+	assert.True(t, model.BuffQuery{Tags: query}.Matches(model.Buff{Tags: []string{"football", "trivia"}}, model.StreamLive))
+	assert.False(t, model.BuffQuery{Tags: query}.Matches(model.Buff{Tags: []string{"football"}}, model.StreamLive))
+	assert.True(t, model.BuffQuery{}.Matches(model.Buff{}, model.StreamLive))
+
+	assert.True(t, model.VideoStreamQuery{Tags: query}.Matches(model.VideoStream{Tags: []string{"football", "trivia"}}))
+	assert.False(t, model.VideoStreamQuery{Tags: query}.Matches(model.VideoStream{Tags: []string{"trivia"}}))
+}
@@ -1,6 +1,9 @@
 package testmodel
 
 import (
+	"context"
+	"time"
+
 	"github.com/JoeReid/buffassignment/internal/model"
 	"github.com/stretchr/testify/mock"
 )
@@ -13,70 +16,164 @@ type modelMock struct {
 }
 
 // GetVideoStream is a mock method for the same method in the model.Store interface
-func (m *modelMock) GetVideoStream(v model.VideoStreamID) (*model.VideoStream, error) {
-	args := m.MethodCalled("GetVideoStream", v)
+func (m *modelMock) GetVideoStream(ctx context.Context, v model.VideoStreamID) (*model.VideoStream, error) {
+	args := m.MethodCalled("GetVideoStream", ctx, v)
 	return args.Get(0).(*model.VideoStream), args.Error(1)
 }
 
 // ListVideoStream is a mock method for the same method in the model.Store interface
-func (m *modelMock) ListVideoStream(offset, limit int) ([]model.VideoStream, error) {
-	args := m.MethodCalled("ListVideoStream", offset, limit)
+func (m *modelMock) ListVideoStream(ctx context.Context, query model.VideoStreamQuery, offset, limit int) ([]model.VideoStream, error) {
+	args := m.MethodCalled("ListVideoStream", ctx, query, offset, limit)
+	return args.Get(0).([]model.VideoStream), args.Error(1)
+}
+
+// ListVideoStreamAfter is a mock method for the same method in the model.Store interface
+func (m *modelMock) ListVideoStreamAfter(ctx context.Context, query model.VideoStreamQuery, after *model.Cursor, limit int) ([]model.VideoStream, error) {
+	args := m.MethodCalled("ListVideoStreamAfter", ctx, query, after, limit)
 	return args.Get(0).([]model.VideoStream), args.Error(1)
 }
 
 // CreateVideoStream is a mock method for the same method in the model.Store interface
-func (m *modelMock) CreateVideoStream(v model.VideoStream) error {
-	args := m.MethodCalled("CreateVideoStream", v)
+func (m *modelMock) CreateVideoStream(ctx context.Context, v model.VideoStream) error {
+	args := m.MethodCalled("CreateVideoStream", ctx, v)
 	return args.Error(0)
 }
 
 // UpdateVideoStream is a mock method for the same method in the model.Store interface
-func (m *modelMock) UpdateVideoStream(i model.VideoStreamID, v model.VideoStream) error {
-	args := m.MethodCalled("UpdateVideoStream", i, v)
+func (m *modelMock) UpdateVideoStream(ctx context.Context, i model.VideoStreamID, v model.VideoStream) error {
+	args := m.MethodCalled("UpdateVideoStream", ctx, i, v)
+	return args.Error(0)
+}
+
+// TransitionVideoStream is a mock method for the same method in the model.Store interface
+func (m *modelMock) TransitionVideoStream(ctx context.Context, i model.VideoStreamID, to model.StreamState) error {
+	args := m.MethodCalled("TransitionVideoStream", ctx, i, to)
 	return args.Error(0)
 }
 
 // DeleteVideoStream is a mock method for the same method in the model.Store interface
-func (m *modelMock) DeleteVideoStream(v model.VideoStreamID) error {
-	args := m.MethodCalled("DeleteVideoStream", v)
+func (m *modelMock) DeleteVideoStream(ctx context.Context, v model.VideoStreamID) error {
+	args := m.MethodCalled("DeleteVideoStream", ctx, v)
 	return args.Error(0)
 }
 
 // GetBuff is a mock method for the same method in the model.Store interface
-func (m *modelMock) GetBuff(b model.BuffID) (*model.Buff, error) {
-	args := m.MethodCalled("GetBuff", b)
+func (m *modelMock) GetBuff(ctx context.Context, b model.BuffID) (*model.Buff, error) {
+	args := m.MethodCalled("GetBuff", ctx, b)
 	return args.Get(0).(*model.Buff), args.Error(1)
 }
 
 // ListBuff is a mock method for the same method in the model.Store interface
-func (m *modelMock) ListBuff(offset, limit int) ([]model.Buff, error) {
-	args := m.MethodCalled("ListBuff", offset, limit)
+func (m *modelMock) ListBuff(ctx context.Context, query model.BuffQuery, offset, limit int) ([]model.Buff, error) {
+	args := m.MethodCalled("ListBuff", ctx, query, offset, limit)
+	return args.Get(0).([]model.Buff), args.Error(1)
+}
+
+// ListBuffAfter is a mock method for the same method in the model.Store interface
+func (m *modelMock) ListBuffAfter(ctx context.Context, query model.BuffQuery, after *model.Cursor, limit int) ([]model.Buff, error) {
+	args := m.MethodCalled("ListBuffAfter", ctx, query, after, limit)
 	return args.Get(0).([]model.Buff), args.Error(1)
 }
 
 // ListBuffForStream is a mock method for the same method in the model.Store interface
-func (m *modelMock) ListBuffForStream(stream model.VideoStreamID, offset, limit int) ([]model.Buff, error) {
-	args := m.MethodCalled("ListBuffForStream", stream, offset, limit)
+func (m *modelMock) ListBuffForStream(
+	ctx context.Context,
+	stream model.VideoStreamID,
+	query model.BuffQuery,
+	offset, limit int,
+) ([]model.Buff, error) {
+	args := m.MethodCalled("ListBuffForStream", ctx, stream, query, offset, limit)
+	return args.Get(0).([]model.Buff), args.Error(1)
+}
+
+// ListBuffForStreamAfter is a mock method for the same method in the model.Store interface
+func (m *modelMock) ListBuffForStreamAfter(
+	ctx context.Context,
+	stream model.VideoStreamID,
+	query model.BuffQuery,
+	after *model.Cursor,
+	limit int,
+) ([]model.Buff, error) {
+	args := m.MethodCalled("ListBuffForStreamAfter", ctx, stream, query, after, limit)
 	return args.Get(0).([]model.Buff), args.Error(1)
 }
 
+// ListBuffActiveForStream is a mock method for the same method in the model.Store interface
+func (m *modelMock) ListBuffActiveForStream(
+	ctx context.Context,
+	stream model.VideoStreamID,
+	query model.BuffQuery,
+	at time.Duration,
+) ([]model.Buff, error) {
+	args := m.MethodCalled("ListBuffActiveForStream", ctx, stream, query, at)
+	return args.Get(0).([]model.Buff), args.Error(1)
+}
+
+// SearchBuffs is a mock method for the same method in the model.Store interface
+func (m *modelMock) SearchBuffs(ctx context.Context, query string, offset, limit int) ([]model.BuffMatch, error) {
+	args := m.MethodCalled("SearchBuffs", ctx, query, offset, limit)
+	return args.Get(0).([]model.BuffMatch), args.Error(1)
+}
+
 // CreateBuff is a mock method for the same method in the model.Store interface
-func (m *modelMock) CreateBuff(b model.Buff) error {
-	args := m.MethodCalled("CreateBuff", b)
+func (m *modelMock) CreateBuff(ctx context.Context, b model.Buff) error {
+	args := m.MethodCalled("CreateBuff", ctx, b)
 	return args.Error(0)
 }
 
 // UpdateBuff is a mock method for the same method in the model.Store interface
-func (m *modelMock) UpdateBuff(i model.BuffID, b model.Buff) error {
-	args := m.MethodCalled("UpdateBuff", i, b)
+func (m *modelMock) UpdateBuff(ctx context.Context, i model.BuffID, b model.Buff) error {
+	args := m.MethodCalled("UpdateBuff", ctx, i, b)
 	return args.Error(0)
 }
 
+// ResolveBuff is a mock method for the same method in the model.Store interface
+func (m *modelMock) ResolveBuff(ctx context.Context, i model.BuffID, r model.Resolution) (*model.Buff, error) {
+	args := m.MethodCalled("ResolveBuff", ctx, i, r)
+	return args.Get(0).(*model.Buff), args.Error(1)
+}
+
 // DeleteBuff is a mock method for the same method in the model.Store interface
-func (m *modelMock) DeleteBuff(b model.BuffID) error {
-	args := m.MethodCalled("DeleteBuff", b)
+func (m *modelMock) DeleteBuff(ctx context.Context, b model.BuffID) error {
+	args := m.MethodCalled("DeleteBuff", ctx, b)
 	return args.Error(0)
 }
 
+// CreateResponse is a mock method for the same method in the model.Store interface
+func (m *modelMock) CreateResponse(ctx context.Context, r model.Response) error {
+	args := m.MethodCalled("CreateResponse", ctx, r)
+	return args.Error(0)
+}
+
+// ListResponseForUser is a mock method for the same method in the model.Store interface
+func (m *modelMock) ListResponseForUser(ctx context.Context, u model.UserID, b []model.BuffID) ([]model.Response, error) {
+	args := m.MethodCalled("ListResponseForUser", ctx, u, b)
+	return args.Get(0).([]model.Response), args.Error(1)
+}
+
+// GetResults is a mock method for the same method in the model.Store interface
+func (m *modelMock) GetResults(ctx context.Context, b model.BuffID) (*model.Results, error) {
+	args := m.MethodCalled("GetResults", ctx, b)
+	return args.Get(0).(*model.Results), args.Error(1)
+}
+
+// ListLeaderboard is a mock method for the same method in the model.Store interface
+func (m *modelMock) ListLeaderboard(ctx context.Context, v model.VideoStreamID, top int) ([]model.Score, error) {
+	args := m.MethodCalled("ListLeaderboard", ctx, v, top)
+	return args.Get(0).([]model.Score), args.Error(1)
+}
+
+// GetScore is a mock method for the same method in the model.Store interface
+func (m *modelMock) GetScore(ctx context.Context, v model.VideoStreamID, u model.UserID) (*model.Score, error) {
+	args := m.MethodCalled("GetScore", ctx, v, u)
+	return args.Get(0).(*model.Score), args.Error(1)
+}
+
+// ListTags is a mock method for the same method in the model.Store interface
+func (m *modelMock) ListTags(ctx context.Context) ([]model.TagCount, error) {
+	args := m.MethodCalled("ListTags", ctx)
+	return args.Get(0).([]model.TagCount), args.Error(1)
+}
+
 // NewModelMock returns a testify.Mock implementation of the model.Store interface
 func NewModelMock() *modelMock { return &modelMock{} }
@@ -1,7 +1,9 @@
 package testmodel_test
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/JoeReid/buffassignment/internal/model"
 	"github.com/JoeReid/buffassignment/internal/model/testmodel"
@@ -12,93 +14,199 @@ import (
 
 func TestMockGetVideoStream(t *testing.T) {
 	store := testmodel.NewModelMock()
-	store.On("GetVideoStream", mock.Anything).Return(&model.VideoStream{}, nil)
+	store.On("GetVideoStream", mock.Anything, mock.Anything).Return(&model.VideoStream{}, nil)
 
-	v, err := store.GetVideoStream(model.VideoStreamID(uuid.New()))
+	v, err := store.GetVideoStream(context.Background(), model.VideoStreamID(uuid.New()))
 	assert.Equal(t, nil, err)
 	assert.Equal(t, &model.VideoStream{}, v)
 }
 
 func TestMockListVideoStream(t *testing.T) {
 	store := testmodel.NewModelMock()
-	store.On("ListVideoStream", mock.Anything, mock.Anything).Return([]model.VideoStream{}, nil)
+	store.On("ListVideoStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]model.VideoStream{}, nil)
 
-	v, err := store.ListVideoStream(0, 0)
+	v, err := store.ListVideoStream(context.Background(), model.VideoStreamQuery{}, 0, 0)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []model.VideoStream{}, v)
+}
+
+func TestMockListVideoStreamAfter(t *testing.T) {
+	store := testmodel.NewModelMock()
+	store.On("ListVideoStreamAfter", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]model.VideoStream{}, nil)
+
+	v, err := store.ListVideoStreamAfter(context.Background(), model.VideoStreamQuery{}, &model.Cursor{}, 0)
 	assert.Equal(t, nil, err)
 	assert.Equal(t, []model.VideoStream{}, v)
 }
 
 func TestMockCreateVideoStream(t *testing.T) {
 	store := testmodel.NewModelMock()
-	store.On("CreateVideoStream", mock.Anything).Return(nil)
+	store.On("CreateVideoStream", mock.Anything, mock.Anything).Return(nil)
 
-	err := store.CreateVideoStream(model.VideoStream{})
+	err := store.CreateVideoStream(context.Background(), model.VideoStream{})
 	assert.Equal(t, nil, err)
 }
 
 func TestMockUpdateVideoStream(t *testing.T) {
 	store := testmodel.NewModelMock()
-	store.On("UpdateVideoStream", mock.Anything, mock.Anything).Return(nil)
+	store.On("UpdateVideoStream", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	err := store.UpdateVideoStream(context.Background(), model.VideoStreamID(uuid.New()), model.VideoStream{})
+	assert.Equal(t, nil, err)
+}
+
+func TestMockTransitionVideoStream(t *testing.T) {
+	store := testmodel.NewModelMock()
+	store.On("TransitionVideoStream", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
-	err := store.UpdateVideoStream(model.VideoStreamID(uuid.New()), model.VideoStream{})
+	err := store.TransitionVideoStream(context.Background(), model.VideoStreamID(uuid.New()), model.StreamLive)
 	assert.Equal(t, nil, err)
 }
 
 func TestMockDeleteVideoStream(t *testing.T) {
 	store := testmodel.NewModelMock()
-	store.On("DeleteVideoStream", mock.Anything).Return(nil)
+	store.On("DeleteVideoStream", mock.Anything, mock.Anything).Return(nil)
 
-	err := store.DeleteVideoStream(model.VideoStreamID(uuid.New()))
+	err := store.DeleteVideoStream(context.Background(), model.VideoStreamID(uuid.New()))
 	assert.Equal(t, nil, err)
 }
 
 func TestMockGetBuff(t *testing.T) {
 	store := testmodel.NewModelMock()
-	store.On("GetBuff", mock.Anything).Return(&model.Buff{}, nil)
+	store.On("GetBuff", mock.Anything, mock.Anything).Return(&model.Buff{}, nil)
 
-	v, err := store.GetBuff(model.BuffID(uuid.New()))
+	v, err := store.GetBuff(context.Background(), model.BuffID(uuid.New()))
 	assert.Equal(t, nil, err)
 	assert.Equal(t, &model.Buff{}, v)
 }
 
 func TestMockListBuff(t *testing.T) {
 	store := testmodel.NewModelMock()
-	store.On("ListBuff", mock.Anything, mock.Anything).Return([]model.Buff{}, nil)
+	store.On("ListBuff", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]model.Buff{}, nil)
+
+	v, err := store.ListBuff(context.Background(), model.BuffQuery{}, 0, 0)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []model.Buff{}, v)
+}
+
+func TestMockListBuffAfter(t *testing.T) {
+	store := testmodel.NewModelMock()
+	store.On("ListBuffAfter", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]model.Buff{}, nil)
+
+	v, err := store.ListBuffAfter(context.Background(), model.BuffQuery{}, &model.Cursor{}, 0)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []model.Buff{}, v)
+}
+
+func TestMockListBuffForStreamAfter(t *testing.T) {
+	store := testmodel.NewModelMock()
+	store.On("ListBuffForStreamAfter", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]model.Buff{}, nil)
 
-	v, err := store.ListBuff(0, 0)
+	v, err := store.ListBuffForStreamAfter(context.Background(), model.VideoStreamID(uuid.New()), model.BuffQuery{}, &model.Cursor{}, 0)
 	assert.Equal(t, nil, err)
 	assert.Equal(t, []model.Buff{}, v)
 }
 
 func TestMockListForStreamBuff(t *testing.T) {
 	store := testmodel.NewModelMock()
-	store.On("ListBuffForStream", mock.Anything, mock.Anything, mock.Anything).Return([]model.Buff{}, nil)
+	store.On("ListBuffForStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]model.Buff{}, nil)
+
+	v, err := store.ListBuffForStream(context.Background(), model.VideoStreamID(uuid.New()), model.BuffQuery{}, 0, 0)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []model.Buff{}, v)
+}
+
+func TestMockListBuffActiveForStream(t *testing.T) {
+	store := testmodel.NewModelMock()
+	store.On("ListBuffActiveForStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]model.Buff{}, nil)
 
-	v, err := store.ListBuffForStream(model.VideoStreamID(uuid.New()), 0, 0)
+	v, err := store.ListBuffActiveForStream(context.Background(), model.VideoStreamID(uuid.New()), model.BuffQuery{}, time.Minute)
 	assert.Equal(t, nil, err)
 	assert.Equal(t, []model.Buff{}, v)
 }
 
+func TestMockSearchBuffs(t *testing.T) {
+	store := testmodel.NewModelMock()
+	store.On("SearchBuffs", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]model.BuffMatch{}, nil)
+
+	v, err := store.SearchBuffs(context.Background(), "goal", 0, 10)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []model.BuffMatch{}, v)
+}
+
 func TestMockCreateBuff(t *testing.T) {
 	store := testmodel.NewModelMock()
-	store.On("CreateBuff", mock.Anything).Return(nil)
+	store.On("CreateBuff", mock.Anything, mock.Anything).Return(nil)
 
-	err := store.CreateBuff(model.Buff{})
+	err := store.CreateBuff(context.Background(), model.Buff{})
 	assert.Equal(t, nil, err)
 }
 
 func TestMockUpdateBuff(t *testing.T) {
 	store := testmodel.NewModelMock()
-	store.On("UpdateBuff", mock.Anything, mock.Anything).Return(nil)
+	store.On("UpdateBuff", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
-	err := store.UpdateBuff(model.BuffID(uuid.New()), model.Buff{})
+	err := store.UpdateBuff(context.Background(), model.BuffID(uuid.New()), model.Buff{})
 	assert.Equal(t, nil, err)
 }
 
 func TestMockDeleteBuff(t *testing.T) {
 	store := testmodel.NewModelMock()
-	store.On("DeleteBuff", mock.Anything).Return(nil)
+	store.On("DeleteBuff", mock.Anything, mock.Anything).Return(nil)
+
+	err := store.DeleteBuff(context.Background(), model.BuffID(uuid.New()))
+	assert.Equal(t, nil, err)
+}
+
+func TestMockCreateResponse(t *testing.T) {
+	store := testmodel.NewModelMock()
+	store.On("CreateResponse", mock.Anything, mock.Anything).Return(nil)
+
+	err := store.CreateResponse(context.Background(), model.Response{})
+	assert.Equal(t, nil, err)
+}
+
+func TestMockListResponseForUser(t *testing.T) {
+	store := testmodel.NewModelMock()
+	store.On("ListResponseForUser", mock.Anything, mock.Anything, mock.Anything).Return([]model.Response{}, nil)
+
+	r, err := store.ListResponseForUser(context.Background(), model.UserID("user-42"), []model.BuffID{model.BuffID(uuid.New())})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []model.Response{}, r)
+}
+
+func TestMockGetResults(t *testing.T) {
+	store := testmodel.NewModelMock()
+	store.On("GetResults", mock.Anything, mock.Anything).Return(&model.Results{}, nil)
+
+	r, err := store.GetResults(context.Background(), model.BuffID(uuid.New()))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, &model.Results{}, r)
+}
+
+func TestMockListLeaderboard(t *testing.T) {
+	store := testmodel.NewModelMock()
+	store.On("ListLeaderboard", mock.Anything, mock.Anything, mock.Anything).Return([]model.Score{}, nil)
+
+	s, err := store.ListLeaderboard(context.Background(), model.VideoStreamID(uuid.New()), 50)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []model.Score{}, s)
+}
+
+func TestMockGetScore(t *testing.T) {
+	store := testmodel.NewModelMock()
+	store.On("GetScore", mock.Anything, mock.Anything, mock.Anything).Return(&model.Score{}, nil)
+
+	s, err := store.GetScore(context.Background(), model.VideoStreamID(uuid.New()), model.UserID("user-42"))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, &model.Score{}, s)
+}
+
+func TestMockListTags(t *testing.T) {
+	store := testmodel.NewModelMock()
+	store.On("ListTags", mock.Anything).Return([]model.TagCount{}, nil)
 
-	err := store.DeleteBuff(model.BuffID(uuid.New()))
+	v, err := store.ListTags(context.Background())
 	assert.Equal(t, nil, err)
+	assert.Equal(t, []model.TagCount{}, v)
 }
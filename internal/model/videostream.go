@@ -1,6 +1,9 @@
 package model
 
 import (
+	"context"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,24 +17,234 @@ import (
 //
 // Genericising the storage actions in this way makes the code considerably
 // easier to re-factor with respect to storage sub-systems, should they need to change
+//
+// Every action takes a context, which implementations should use to cancel
+// in-flight work and to parent any tracing spans they create
+//
+// The After variants of the list actions return the items following the
+// given Cursor, or the first page if it is nil. The lists only hold the
+// streams matching the VideoStreamQuery. ListVideoStream orders them by the
+// query's Sort, while the After variant always keeps to the order of the
+// cursor, oldest first.
+//
+// TransitionVideoStream moves a stream into the given state with
+// VideoStream.Transition, as a single atomic change.
 type VideoStreamStore interface {
-	GetVideoStream(VideoStreamID) (*VideoStream, error)
-	ListVideoStream(offset, limit int) ([]VideoStream, error)
+	GetVideoStream(context.Context, VideoStreamID) (*VideoStream, error)
+	ListVideoStream(ctx context.Context, query VideoStreamQuery, offset, limit int) ([]VideoStream, error)
+	ListVideoStreamAfter(ctx context.Context, query VideoStreamQuery, after *Cursor, limit int) ([]VideoStream, error)
+
+	CreateVideoStream(context.Context, VideoStream) error
+	UpdateVideoStream(context.Context, VideoStreamID, VideoStream) error
+	TransitionVideoStream(ctx context.Context, id VideoStreamID, to StreamState) error
+	DeleteVideoStream(context.Context, VideoStreamID) error
+}
+
+// VideoStreamQuery selects the video streams to list, and the order to list them in
+// The zero value selects every stream, oldest first
+type VideoStreamQuery struct {
+	// States lists the states a stream can be in, any state if it is empty
+	States []StreamState
+
+	// TitleContains selects the streams with a title containing it, ignoring case
+	TitleContains string
+
+	// CreatedAfter and CreatedBefore select the streams created strictly after or before them
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+
+	// UpdatedSince selects the streams last updated at or after it
+	UpdatedSince *time.Time
+
+	// Tags lists the tags a stream must all have, any stream if it is empty
+	Tags []string
+
+	// Sort orders the streams by each field in turn, with ties broken by
+	// the creation time and then the id, so the order is always stable
+	Sort []VideoStreamSort
+}
+
+// Matches reports whether the stream is selected by the query
+func (q VideoStreamQuery) Matches(v VideoStream) bool {
+	if !q.matchesState(v) {
+		return false
+	}
+	if q.TitleContains != "" && !strings.Contains(strings.ToLower(v.Title), strings.ToLower(q.TitleContains)) {
+		return false
+	}
+	if q.CreatedAfter != nil && !v.CreatedAt.After(*q.CreatedAfter) {
+		return false
+	}
+	if q.CreatedBefore != nil && !v.CreatedAt.Before(*q.CreatedBefore) {
+		return false
+	}
+	if q.UpdatedSince != nil && v.UpdatedAt.Before(*q.UpdatedSince) {
+		return false
+	}
+	return HasTags(v.Tags, q.Tags)
+}
+
+// matchesState reports whether the stream is in one of the states of the query
+func (q VideoStreamQuery) matchesState(v VideoStream) bool {
+	return len(q.States) == 0 || hasState(q.States, v.State)
+}
+
+// hasState reports whether the state is one of the states
+func hasState(states []StreamState, state StreamState) bool {
+	for _, s := range states {
+		if state == s {
+			return true
+		}
+	}
+	return false
+}
 
-	CreateVideoStream(VideoStream) error
-	UpdateVideoStream(VideoStreamID, VideoStream) error
-	DeleteVideoStream(VideoStreamID) error
+// VideoStreamSortField is a field video streams can be sorted by
+type VideoStreamSortField string
+
+// The fields video streams can be sorted by
+const (
+	SortStreamCreated VideoStreamSortField = "created"
+	SortStreamUpdated VideoStreamSortField = "updated"
+	SortStreamTitle   VideoStreamSortField = "title"
+)
+
+// VideoStreamSortFields lists every field video streams can be sorted by
+var VideoStreamSortFields = []VideoStreamSortField{SortStreamCreated, SortStreamUpdated, SortStreamTitle}
+
+// Valid reports whether the field is one video streams can be sorted by
+func (f VideoStreamSortField) Valid() bool {
+	for _, field := range VideoStreamSortFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// VideoStreamSort orders video streams by a single field
+type VideoStreamSort struct {
+	Field      VideoStreamSortField
+	Descending bool
+}
+
+// Compare returns a negative number if a is ordered before b by the sort,
+// a positive number if it is ordered after, and zero if they tie
+func (s VideoStreamSort) Compare(a, b VideoStream) int {
+	var c int
+	switch s.Field {
+	case SortStreamCreated:
+		c = compareTime(a.CreatedAt, b.CreatedAt)
+	case SortStreamUpdated:
+		c = compareTime(a.UpdatedAt, b.UpdatedAt)
+	case SortStreamTitle:
+		c = strings.Compare(a.Title, b.Title)
+	}
+
+	if s.Descending {
+		return -c
+	}
+	return c
+}
+
+// compareTime returns -1, 0 or 1 as a is before, equal to, or after b
+func compareTime(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	default:
+		return 0
+	}
 }
 
 // VideoStream defines the abstract representation of the VideoStream type in the data model
 //
 // This is how we can think about a VideoStream in the application
 // (separate from the database or API encoding representations)
+//
+// ScheduledStart is when the stream is planned to start, and is set by its authors.
+// StartedAt and EndedAt record when it actually started and ended, and are
+// only ever set by a Transition.
+//
+// Tags are a set, and are always listed in order
 type VideoStream struct {
-	ID        VideoStreamID
-	Title     string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID             VideoStreamID
+	Title          string
+	Tags           []string
+	State          StreamState
+	ScheduledStart *time.Time
+	StartedAt      *time.Time
+	EndedAt        *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// Transition moves the stream into the given state at the given time
+//
+// Only the transitions allowed by StreamState.CanTransition are made, any other
+// returns a *StreamTransitionError and leaves the stream untouched.
+// Starting a stream sets StartedAt, and ending it sets EndedAt.
+func (v *VideoStream) Transition(to StreamState, at time.Time) error {
+	if !v.State.CanTransition(to) {
+		return &StreamTransitionError{Stream: v.ID, From: v.State, To: to}
+	}
+
+	switch to {
+	case StreamLive:
+		v.StartedAt = &at
+	case StreamEnded:
+		v.EndedAt = &at
+	}
+
+	v.State = to
+	v.UpdatedAt = at
+	return nil
+}
+
+// StreamState is a step in the lifecycle of a VideoStream
+//
+// A stream is created scheduled, goes live when it starts, and is ended when it stops.
+// An ended stream can then be archived, as can a scheduled stream that never started.
+type StreamState string
+
+// The states a VideoStream can be in
+const (
+	StreamScheduled StreamState = "scheduled"
+	StreamLive      StreamState = "live"
+	StreamEnded     StreamState = "ended"
+	StreamArchived  StreamState = "archived"
+)
+
+// StreamStates lists every StreamState, in the order of the lifecycle
+var StreamStates = []StreamState{StreamScheduled, StreamLive, StreamEnded, StreamArchived}
+
+// transitions maps each state to the states a stream can move to from it
+var transitions = map[StreamState][]StreamState{
+	StreamScheduled: {StreamLive, StreamArchived},
+	StreamLive:      {StreamEnded},
+	StreamEnded:     {StreamArchived},
+}
+
+// Valid reports whether the state is one of the StreamStates
+func (s StreamState) Valid() bool {
+	for _, state := range StreamStates {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// CanTransition reports whether a stream in this state can move to the given state
+func (s StreamState) CanTransition(to StreamState) bool {
+	for _, next := range transitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
 }
 
 // VideoStreamID is a uuid.UUID type
@@ -50,3 +263,74 @@ func ParseVideoStreamID(s string) (VideoStreamID, error) {
 	id, err := uuid.Parse(s)
 	return VideoStreamID(id), err
 }
+
+// StreamDeletePolicy controls what a store does with the buffs
+// ascociated with a VideoStream when the stream is deleted
+type StreamDeletePolicy int
+
+const (
+	// CascadeBuffs removes all the buffs (and their answers) along with the stream
+	CascadeBuffs StreamDeletePolicy = iota
+
+	// RestrictBuffs refuses to remove a stream that still has buffs,
+	// returning a *StreamHasBuffsError
+	RestrictBuffs
+)
+
+// StreamHasBuffsError should be returned by store implementations when they
+// refuse to delete a VideoStream because it still has buffs ascociated with it
+//
+// It is a kind of ErrConflict, so errors.Is(err, ErrConflict) is true for it
+type StreamHasBuffsError struct {
+	Stream VideoStreamID
+	Buffs  int
+}
+
+// Error implements the error interface
+func (e *StreamHasBuffsError) Error() string {
+	return fmt.Sprintf("video stream %s still has %d buffs", e.Stream, e.Buffs)
+}
+
+// Is reports whether the error is an ErrConflict
+func (e *StreamHasBuffsError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// StreamTransitionError should be returned when a VideoStream
+// can't move from the state it is in to the one asked for
+//
+// It is a kind of ErrConflict, so errors.Is(err, ErrConflict) is true for it
+type StreamTransitionError struct {
+	Stream VideoStreamID
+	From   StreamState
+	To     StreamState
+}
+
+// Error implements the error interface
+func (e *StreamTransitionError) Error() string {
+	return fmt.Sprintf("video stream %s can't go from %s to %s", e.Stream, e.From, e.To)
+}
+
+// Is reports whether the error is an ErrConflict
+func (e *StreamTransitionError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// StreamNotLiveError should be returned when buffs are asked to be
+// delivered, or responded to, for a VideoStream that isn't live
+//
+// It is a kind of ErrConflict, so errors.Is(err, ErrConflict) is true for it
+type StreamNotLiveError struct {
+	Stream VideoStreamID
+	State  StreamState
+}
+
+// Error implements the error interface
+func (e *StreamNotLiveError) Error() string {
+	return fmt.Sprintf("video stream %s is %s, not live", e.Stream, e.State)
+}
+
+// Is reports whether the error is an ErrConflict
+func (e *StreamNotLiveError) Is(target error) bool {
+	return target == ErrConflict
+}
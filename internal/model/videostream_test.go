@@ -1,7 +1,10 @@
 package model_test
 
 import (
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/JoeReid/buffassignment/internal/model"
 	"github.com/google/uuid"
@@ -27,3 +30,151 @@ func TestVideoStreamIDString(t *testing.T) {
 	_, err := uuid.Parse(id.String())
 	require.NoError(t, err, "failed to parse UUID")
 }
+
+func TestStreamHasBuffsErrorIsConflict(t *testing.T) {
+	var err error = &model.StreamHasBuffsError{Stream: model.VideoStreamID(uuid.New()), Buffs: 2}
+
+	assert.True(t, errors.Is(err, model.ErrConflict))
+	assert.True(t, errors.Is(fmt.Errorf("wrapped: %w", err), model.ErrConflict))
+	assert.False(t, errors.Is(err, model.ErrNotFound))
+}
+
+func TestStreamStateCanTransition(t *testing.T) {
+	allowed := map[model.StreamState][]model.StreamState{
+		model.StreamScheduled: {model.StreamLive, model.StreamArchived},
+		model.StreamLive:      {model.StreamEnded},
+		model.StreamEnded:     {model.StreamArchived},
+	}
+
+	for _, from := range model.StreamStates {
+		for _, to := range model.StreamStates {
+			expect := false
+			for _, a := range allowed[from] {
+				expect = expect || a == to
+			}
+			assert.Equal(t, expect, from.CanTransition(to), "%s to %s", from, to)
+		}
+	}
+}
+
+func TestStreamStateValid(t *testing.T) {
+	for _, s := range model.StreamStates {
+		assert.True(t, s.Valid(), "%s", s)
+	}
+	assert.False(t, model.StreamState("").Valid())
+	assert.False(t, model.StreamState("paused").Valid())
+}
+
+func TestVideoStreamTransition(t *testing.T) {
+	created := time.Now().UTC().Add(-time.Hour)
+	v := model.VideoStream{
+		ID:        model.VideoStreamID(uuid.New()),
+		Title:     "a stream",
+		State:     model.StreamScheduled,
+		CreatedAt: created,
+		UpdatedAt: created,
+	}
+
+	started := created.Add(time.Minute)
+	require.NoError(t, v.Transition(model.StreamLive, started))
+	assert.Equal(t, model.StreamLive, v.State)
+	assert.Equal(t, &started, v.StartedAt)
+	assert.Nil(t, v.EndedAt)
+	assert.Equal(t, started, v.UpdatedAt)
+
+	// A live stream can't be started again, and is left as it was
+	err := v.Transition(model.StreamLive, started.Add(time.Minute))
+	assert.Equal(t, &model.StreamTransitionError{Stream: v.ID, From: model.StreamLive, To: model.StreamLive}, err)
+	assert.True(t, errors.Is(err, model.ErrConflict))
+	assert.Equal(t, &started, v.StartedAt)
+	assert.Equal(t, started, v.UpdatedAt)
+
+	ended := started.Add(time.Hour)
+	require.NoError(t, v.Transition(model.StreamEnded, ended))
+	assert.Equal(t, model.StreamEnded, v.State)
+	assert.Equal(t, &started, v.StartedAt)
+	assert.Equal(t, &ended, v.EndedAt)
+
+	archived := ended.Add(time.Hour)
+	require.NoError(t, v.Transition(model.StreamArchived, archived))
+	assert.Equal(t, model.StreamArchived, v.State)
+	assert.Equal(t, &ended, v.EndedAt)
+	assert.Equal(t, archived, v.UpdatedAt)
+
+	assert.Error(t, v.Transition(model.StreamLive, archived.Add(time.Minute)), "an archived stream is final")
+}
+
+func TestVideoStreamQueryMatches(t *testing.T) {
+	live := model.VideoStream{State: model.StreamLive}
+	ended := model.VideoStream{State: model.StreamEnded}
+
+	assert.True(t, model.VideoStreamQuery{}.Matches(live))
+	assert.True(t, model.VideoStreamQuery{States: []model.StreamState{model.StreamLive}}.Matches(live))
+	assert.False(t, model.VideoStreamQuery{States: []model.StreamState{model.StreamLive}}.Matches(ended))
+	assert.True(t, model.VideoStreamQuery{States: []model.StreamState{model.StreamLive, model.StreamEnded}}.Matches(ended))
+}
+
+func TestVideoStreamQueryMatchesFilters(t *testing.T) {
+	created := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	before, after := created.Add(-time.Second), created.Add(time.Second)
+	v := model.VideoStream{Title: "The Morning Show", State: model.StreamLive, CreatedAt: created, UpdatedAt: created}
+
+	var tests = []struct {
+		name   string
+		query  model.VideoStreamQuery
+		expect bool
+	}{
+		{name: "title contains", query: model.VideoStreamQuery{TitleContains: "morning"}, expect: true},
+		{name: "title doesn't contain", query: model.VideoStreamQuery{TitleContains: "evening"}, expect: false},
+		{name: "created after", query: model.VideoStreamQuery{CreatedAfter: &before}, expect: true},
+		{name: "created after is strict", query: model.VideoStreamQuery{CreatedAfter: &created}, expect: false},
+		{name: "created before", query: model.VideoStreamQuery{CreatedBefore: &after}, expect: true},
+		{name: "created before is strict", query: model.VideoStreamQuery{CreatedBefore: &created}, expect: false},
+		{name: "updated since includes the time", query: model.VideoStreamQuery{UpdatedSince: &created}, expect: true},
+		{name: "updated since", query: model.VideoStreamQuery{UpdatedSince: &after}, expect: false},
+		{
+			name:   "every filter must match",
+			query:  model.VideoStreamQuery{TitleContains: "show", States: []model.StreamState{model.StreamEnded}},
+			expect: false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, tt.query.Matches(v))
+		})
+	}
+}
+
+func TestVideoStreamSortCompare(t *testing.T) {
+	created := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	a := model.VideoStream{Title: "a", CreatedAt: created, UpdatedAt: created.Add(time.Hour)}
+	b := model.VideoStream{Title: "b", CreatedAt: created.Add(time.Minute), UpdatedAt: created.Add(time.Minute)}
+
+	assert.Less(t, model.VideoStreamSort{Field: model.SortStreamCreated}.Compare(a, b), 0)
+	assert.Greater(t, model.VideoStreamSort{Field: model.SortStreamCreated, Descending: true}.Compare(a, b), 0)
+	assert.Greater(t, model.VideoStreamSort{Field: model.SortStreamUpdated}.Compare(a, b), 0)
+	assert.Less(t, model.VideoStreamSort{Field: model.SortStreamTitle}.Compare(a, b), 0)
+	assert.Zero(t, model.VideoStreamSort{Field: model.SortStreamTitle}.Compare(a, a))
+}
+
+func TestVideoStreamSortFieldValid(t *testing.T) {
+	for _, f := range model.VideoStreamSortFields {
+		assert.True(t, f.Valid(), f)
+	}
+	assert.False(t, model.VideoStreamSortField("id").Valid())
+	assert.False(t, model.VideoStreamSortField("").Valid())
+}
+
+func TestStreamErrorsAreConflicts(t *testing.T) {
+	id := model.VideoStreamID(uuid.New())
+
+	for _, err := range []error{
+		&model.StreamTransitionError{Stream: id, From: model.StreamEnded, To: model.StreamLive},
+		&model.StreamNotLiveError{Stream: id, State: model.StreamScheduled},
+	} {
+		assert.True(t, errors.Is(err, model.ErrConflict))
+		assert.True(t, errors.Is(fmt.Errorf("wrapped: %w", err), model.ErrConflict))
+		assert.False(t, errors.Is(err, model.ErrNotFound))
+	}
+}
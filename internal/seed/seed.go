@@ -0,0 +1,119 @@
+// Package seed populates a model.Store with generated example data
+//
+// It is shared by the seed tool, which fills the postgres database,
+// and the API server, which can fill the memory store on start up.
+package seed
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/JoeReid/apiutils/tracer"
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/brianvoe/gofakeit/v5"
+	"github.com/google/uuid"
+	"github.com/opentracing/opentracing-go"
+)
+
+const (
+	// Streams is the number of video streams created by Populate
+	Streams = 100
+
+	// BuffsPerStream is the number of buffs created for each stream by Populate
+	BuffsPerStream = 10
+
+	// AnswersPerBuff is the number of answers given to each buff by Populate
+	AnswersPerBuff = 5
+)
+
+// Populate fills the given store with generated video streams, each with
+// their own buffs
+func Populate(ctx context.Context, store model.Store) error {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Seed:Populate")
+	defer sp.Finish()
+
+	gofakeit.Seed(0)
+
+	for i := 0; i < Streams; i++ {
+		u, err := uuid.Parse(gofakeit.UUID())
+		if err != nil {
+			tracer.Log(sp, "failed to create new uuid")
+			tracer.SetError(sp, err)
+			return err
+		}
+		vID := model.VideoStreamID(u)
+
+		// start sometime in the last week, updated between then and now
+		week := time.Hour * 24 * 7
+		now := time.Now()
+		startdate := gofakeit.DateRange(now.Add(-week), now)
+		updated := gofakeit.DateRange(startdate, now)
+		started := startdate.UTC()
+
+		// The streams are live, so their buffs can be followed straight away
+		if err := store.CreateVideoStream(ctx, model.VideoStream{
+			ID:        vID,
+			Title:     fmt.Sprintf("%s %s stream", gofakeit.Adverb(), gofakeit.Adjective()),
+			State:     model.StreamLive,
+			StartedAt: &started,
+			CreatedAt: startdate.UTC(),
+			UpdatedAt: updated.UTC(),
+		}); err != nil {
+			tracer.Log(sp, "failed to create video stream")
+			tracer.SetError(sp, err)
+			return err
+		}
+
+		// create buffs
+		for j := 0; j < BuffsPerStream; j++ {
+			u, err := uuid.Parse(gofakeit.UUID())
+			if err != nil {
+				tracer.Log(sp, "failed to create new uuid")
+				tracer.SetError(sp, err)
+				return err
+			}
+			bID := model.BuffID(u)
+
+			ans := []model.Answer{}
+			used := map[string]bool{}
+
+			// Create answers
+			for k := 0; k < AnswersPerBuff; k++ {
+				// The store rejects buffs with repeated answers, so make any repeats unique
+				text := gofakeit.Noun()
+				if used[text] {
+					text = fmt.Sprintf("%s %d", text, k)
+				}
+				used[text] = true
+
+				u, err := uuid.Parse(gofakeit.UUID())
+				if err != nil {
+					tracer.Log(sp, "failed to create new uuid")
+					tracer.SetError(sp, err)
+					return err
+				}
+				aID := model.AnswerID(u)
+
+				ans = append(ans, model.Answer{
+					ID:      aID,
+					Text:    text,
+					Correct: k == 0,
+				})
+			}
+
+			if err := store.CreateBuff(ctx, model.Buff{
+				ID:        bID,
+				Stream:    vID,
+				Question:  gofakeit.Question(),
+				CreatedAt: gofakeit.DateRange(startdate, now).UTC(),
+				Answers:   ans,
+			}); err != nil {
+				tracer.Log(sp, "failed to create buff")
+				tracer.SetError(sp, err)
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package seed_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/model/memory"
+	"github.com/JoeReid/buffassignment/internal/seed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// populated returns a new memory store filled by seed.Populate
+func populated(t *testing.T) *memory.Store {
+	store, err := memory.NewStore()
+	require.NoError(t, err, "failed to create store")
+
+	require.NoError(t, seed.Populate(context.Background(), store), "failed to populate store")
+	return store
+}
+
+func TestPopulate(t *testing.T) {
+	store := populated(t)
+
+	streams, err := store.ListVideoStream(context.Background(), model.VideoStreamQuery{}, 0, 0)
+	require.NoError(t, err, "failed to list video streams")
+	assert.Len(t, streams, seed.Streams)
+
+	for _, v := range streams {
+		assert.Equal(t, model.StreamLive, v.State)
+
+		buffs, err := store.ListBuffForStream(context.Background(), v.ID, model.BuffQuery{}, 0, 0)
+		require.NoError(t, err, "failed to list buffs for stream")
+		require.Len(t, buffs, seed.BuffsPerStream)
+
+		for _, b := range buffs {
+			assert.Len(t, b.Answers, seed.AnswersPerBuff)
+		}
+	}
+}
@@ -0,0 +1,366 @@
+// Package validation checks the model types against the rules of the domain
+//
+// It is used by both the store implementations and the API handlers, so that
+// bad data is rejected as early as possible, and can never reach the store.
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+)
+
+// The rules that can be broken, used as the Rule of a FieldError
+const (
+	RuleRequired          = "required"
+	RuleMaxLength         = "max_length"
+	RuleMinAnswers        = "min_answers"
+	RuleMaxAnswers        = "max_answers"
+	RuleExactlyOneCorrect = "exactly_one_correct"
+	RuleUnique            = "unique"
+	RuleNotNegative       = "not_negative"
+	RulePositive          = "positive"
+	RuleOneOf             = "one_of"
+	RuleMaxTags           = "max_tags"
+	RuleTagFormat         = "tag_format"
+	RuleNotCorrect        = "not_correct"
+	RuleTrimmed           = "trimmed"
+)
+
+// FieldError describes a single rule broken by a single field
+type FieldError struct {
+	Field   string
+	Rule    string
+	Message string
+}
+
+// Errors is the list of every FieldError found when validating an object
+//
+// It implements the error interface so it can be returned through the store
+// methods, and recovered by callers using errors.As
+type Errors []FieldError
+
+// Error implements the error interface
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", fe.Field, fe.Message))
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// Validator checks model types against a configurable set of rules
+type Validator struct {
+	minAnswers        int
+	maxAnswers        int
+	maxQuestionLength int
+	exactlyOneCorrect bool
+	maxTitleLength    int
+}
+
+// Option is a function option for New
+type Option func(*Validator) error
+
+// New returns a new Validator with the given rules
+// Any rule that isn't set is left at a sensible default
+func New(options ...Option) (*Validator, error) {
+	const (
+		defaultMinAnswers        = 2
+		defaultMaxAnswers        = 10
+		defaultMaxQuestionLength = 280
+		defaultMaxTitleLength    = 200
+	)
+
+	v := &Validator{
+		minAnswers:        defaultMinAnswers,
+		maxAnswers:        defaultMaxAnswers,
+		maxQuestionLength: defaultMaxQuestionLength,
+		exactlyOneCorrect: true,
+		maxTitleLength:    defaultMaxTitleLength,
+	}
+
+	for _, opt := range options {
+		if err := opt(v); err != nil {
+			return nil, err
+		}
+	}
+
+	if v.minAnswers > v.maxAnswers {
+		return nil, fmt.Errorf("min answers %d cannot be more than max answers %d", v.minAnswers, v.maxAnswers)
+	}
+	return v, nil
+}
+
+// MinAnswers sets the fewest answers a buff can have
+func MinAnswers(n int) Option {
+	return func(v *Validator) error {
+		if n < 1 {
+			return fmt.Errorf("cannot set minAnswers to %d", n)
+		}
+
+		v.minAnswers = n
+		return nil
+	}
+}
+
+// MaxAnswers sets the most answers a buff can have
+func MaxAnswers(n int) Option {
+	return func(v *Validator) error {
+		if n < 1 {
+			return fmt.Errorf("cannot set maxAnswers to %d", n)
+		}
+
+		v.maxAnswers = n
+		return nil
+	}
+}
+
+// MaxQuestionLength sets the longest question a buff can have, in characters
+func MaxQuestionLength(n int) Option {
+	return func(v *Validator) error {
+		if n < 1 {
+			return fmt.Errorf("cannot set maxQuestionLength to %d", n)
+		}
+
+		v.maxQuestionLength = n
+		return nil
+	}
+}
+
+// ExactlyOneCorrect sets whether a buff must have exactly one correct answer
+func ExactlyOneCorrect(b bool) Option {
+	return func(v *Validator) error {
+		v.exactlyOneCorrect = b
+		return nil
+	}
+}
+
+// MaxTitleLength sets the longest title a video stream can have, in characters
+func MaxTitleLength(n int) Option {
+	return func(v *Validator) error {
+		if n < 1 {
+			return fmt.Errorf("cannot set maxTitleLength to %d", n)
+		}
+
+		v.maxTitleLength = n
+		return nil
+	}
+}
+
+// Buff checks the given buff against the rules of the validator
+// The returned error is nil, or an Errors listing every broken rule
+func (v *Validator) Buff(b model.Buff) error {
+	var errs Errors
+
+	switch question := strings.TrimSpace(b.Question); {
+	case question == "":
+		errs = append(errs, FieldError{"question", RuleRequired, "must not be empty"})
+	case utf8.RuneCountInString(question) > v.maxQuestionLength:
+		errs = append(errs, FieldError{
+			"question", RuleMaxLength,
+			fmt.Sprintf("must be at most %d characters", v.maxQuestionLength),
+		})
+	}
+
+	// A buff without a kind is a quiz
+	kind := b.Kind
+	if kind == "" {
+		kind = model.BuffQuiz
+	}
+	if !kind.Valid() {
+		errs = append(errs, FieldError{"kind", RuleOneOf, "must be one of quiz, poll or prediction"})
+	}
+
+	switch {
+	case len(b.Answers) < v.minAnswers:
+		errs = append(errs, FieldError{
+			"answers", RuleMinAnswers,
+			fmt.Sprintf("must have at least %d answers", v.minAnswers),
+		})
+	case len(b.Answers) > v.maxAnswers:
+		errs = append(errs, FieldError{
+			"answers", RuleMaxAnswers,
+			fmt.Sprintf("must have at most %d answers", v.maxAnswers),
+		})
+	}
+
+	correct := 0
+	texts := make(map[string]bool, len(b.Answers))
+	ids := make(map[model.AnswerID]bool, len(b.Answers))
+
+	for i, ans := range b.Answers {
+		if ans.Correct {
+			correct++
+
+			if msg, ok := notCorrect[kind]; ok {
+				errs = append(errs, FieldError{fmt.Sprintf("answers[%d].correct", i), RuleNotCorrect, msg})
+			}
+		}
+
+		if ids[ans.ID] {
+			errs = append(errs, FieldError{fmt.Sprintf("answers[%d].id", i), RuleUnique, "must not be repeated"})
+		}
+		ids[ans.ID] = true
+
+		// Answers that only differ by case or whitespace count as duplicates
+		text := strings.ToLower(strings.TrimSpace(ans.Text))
+		switch {
+		case text == "":
+			errs = append(errs, FieldError{fmt.Sprintf("answers[%d].text", i), RuleRequired, "must not be empty"})
+		case texts[text]:
+			errs = append(errs, FieldError{fmt.Sprintf("answers[%d].text", i), RuleUnique, "must not be repeated"})
+		}
+		texts[text] = true
+	}
+
+	if kind == model.BuffQuiz && v.exactlyOneCorrect && correct != 1 {
+		errs = append(errs, FieldError{
+			"answers", RuleExactlyOneCorrect,
+			fmt.Sprintf("must have exactly one correct answer, found %d", correct),
+		})
+	}
+
+	errs = append(errs, checkTags(b.Tags)...)
+
+	if b.Schedule != nil {
+		if b.Schedule.Offset < 0 {
+			errs = append(errs, FieldError{"schedule.offset", RuleNotNegative, "must not be before the start of the stream"})
+		}
+		if b.Schedule.Duration <= 0 {
+			errs = append(errs, FieldError{"schedule.duration", RulePositive, "must be longer than zero"})
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// VideoStream checks the given video stream against the rules of the validator
+// The returned error is nil, or an Errors listing every broken rule
+func (v *Validator) VideoStream(vs model.VideoStream) error {
+	var errs Errors
+
+	switch title := strings.TrimSpace(vs.Title); {
+	case title == "":
+		errs = append(errs, FieldError{"title", RuleRequired, "must not be empty"})
+	case utf8.RuneCountInString(title) > v.maxTitleLength:
+		errs = append(errs, FieldError{
+			"title", RuleMaxLength,
+			fmt.Sprintf("must be at most %d characters", v.maxTitleLength),
+		})
+	}
+
+	// An empty state is left for the store to fill in
+	if vs.State != "" && !vs.State.Valid() {
+		errs = append(errs, FieldError{"state", RuleOneOf, "must be one of scheduled, live, ended or archived"})
+	}
+
+	errs = append(errs, checkTags(vs.Tags)...)
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// notCorrect maps the kinds of buff that can't have a correct answer
+// to the reason given when one of their answers is marked as correct
+var notCorrect = map[model.BuffKind]string{
+	model.BuffPoll:       "must not be correct, as a poll has no correct answer",
+	model.BuffPrediction: "must not be correct, as a prediction is only resolved once the result is known",
+}
+
+// maxTags is the most tags a buff or video stream can have
+const maxTags = 20
+
+// tagFormat is the format of a tag, such as football or sponsor:acme
+// Tags are lower case so that the same tag can't be written in two ways.
+var tagFormat = regexp.MustCompile(`^[a-z0-9][a-z0-9_:-]{0,63}$`)
+
+// checkTags checks the tags of a buff or video stream
+// The tags are not configurable, as they are shared by every buff and stream
+func checkTags(tags []string) Errors {
+	var errs Errors
+
+	if len(tags) > maxTags {
+		errs = append(errs, FieldError{"tags", RuleMaxTags, fmt.Sprintf("must have at most %d tags", maxTags)})
+	}
+
+	seen := make(map[string]bool, len(tags))
+	for i, tag := range tags {
+		switch {
+		case !tagFormat.MatchString(tag):
+			errs = append(errs, FieldError{
+				fmt.Sprintf("tags[%d]", i), RuleTagFormat,
+				"must be 1 to 64 lower case letters, digits, '_', ':' or '-', starting with a letter or digit",
+			})
+		case seen[tag]:
+			errs = append(errs, FieldError{fmt.Sprintf("tags[%d]", i), RuleUnique, "must not be repeated"})
+		}
+		seen[tag] = true
+	}
+	return errs
+}
+
+// maxUserIDLength is the longest user ID a response can have
+// It isn't configurable, as the IDs come from outside the service
+const maxUserIDLength = 255
+
+// Response checks the given response against the rules of the validator
+// The returned error is nil, or an Errors listing every broken rule
+//
+// Whether the answer belongs to the buff is checked by the store,
+// as the validator has no access to the stored buffs
+//
+// The user ID is stored as it is given, so one with surrounding whitespace is
+// refused rather than being scored apart from the same ID without it.
+func (v *Validator) Response(r model.Response) error {
+	var errs Errors
+
+	switch user := strings.TrimSpace(r.User.String()); {
+	case user == "":
+		errs = append(errs, FieldError{"user_id", RuleRequired, "must not be empty"})
+	case user != r.User.String():
+		errs = append(errs, FieldError{"user_id", RuleTrimmed, "must not start or end with whitespace"})
+	case utf8.RuneCountInString(user) > maxUserIDLength:
+		errs = append(errs, FieldError{
+			"user_id", RuleMaxLength,
+			fmt.Sprintf("must be at most %d characters", maxUserIDLength),
+		})
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// Resolution checks the given resolution of a prediction against the rules of the validator
+// The returned error is nil, or an Errors listing every broken rule
+//
+// Whoever resolved the buff comes from outside the service, as the users do,
+// so it is held to the same length. Whether the answer belongs to the buff is
+// checked by the store.
+func (v *Validator) Resolution(r model.Resolution) error {
+	var errs Errors
+
+	switch by := strings.TrimSpace(r.ResolvedBy); {
+	case by == "":
+		errs = append(errs, FieldError{"resolved_by", RuleRequired, "must not be empty"})
+	case utf8.RuneCountInString(by) > maxUserIDLength:
+		errs = append(errs, FieldError{
+			"resolved_by", RuleMaxLength,
+			fmt.Sprintf("must be at most %d characters", maxUserIDLength),
+		})
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
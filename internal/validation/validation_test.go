@@ -0,0 +1,308 @@
+package validation_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JoeReid/buffassignment/internal/model"
+	"github.com/JoeReid/buffassignment/internal/validation"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func answer(text string, correct bool) model.Answer {
+	return model.Answer{ID: model.AnswerID(uuid.New()), Text: text, Correct: correct}
+}
+
+func TestValidateBuff(t *testing.T) {
+	sharedID := model.AnswerID(uuid.New())
+
+	var tests = []struct {
+		name      string
+		options   []validation.Option
+		buff      model.Buff
+		expectErr validation.Errors
+	}{
+		{
+			name: "valid buff passes",
+			buff: model.Buff{
+				Question: "what's the answer to life, the universe, and everything?",
+				Answers:  []model.Answer{answer("42", true), answer("43", false)},
+			},
+		},
+		{
+			name: "empty question and too few answers are both reported",
+			buff: model.Buff{
+				Question: "   ",
+				Answers:  []model.Answer{answer("42", true)},
+			},
+			expectErr: validation.Errors{
+				{Field: "question", Rule: validation.RuleRequired, Message: "must not be empty"},
+				{Field: "answers", Rule: validation.RuleMinAnswers, Message: "must have at least 2 answers"},
+			},
+		},
+		{
+			name: "zero answers",
+			buff: model.Buff{Question: "why?"},
+			expectErr: validation.Errors{
+				{Field: "answers", Rule: validation.RuleMinAnswers, Message: "must have at least 2 answers"},
+				{Field: "answers", Rule: validation.RuleExactlyOneCorrect, Message: "must have exactly one correct answer, found 0"},
+			},
+		},
+		{
+			name: "several correct answers",
+			buff: model.Buff{
+				Question: "why?",
+				Answers:  []model.Answer{answer("42", true), answer("43", true)},
+			},
+			expectErr: validation.Errors{
+				{Field: "answers", Rule: validation.RuleExactlyOneCorrect, Message: "must have exactly one correct answer, found 2"},
+			},
+		},
+		{
+			name:    "several correct answers allowed when configured",
+			options: []validation.Option{validation.ExactlyOneCorrect(false)},
+			buff: model.Buff{
+				Question: "why?",
+				Answers:  []model.Answer{answer("42", true), answer("43", true)},
+			},
+		},
+		{
+			name: "duplicate and empty answer text",
+			buff: model.Buff{
+				Question: "why?",
+				Answers:  []model.Answer{answer("42", true), answer(" 42", false), answer("", false)},
+			},
+			expectErr: validation.Errors{
+				{Field: "answers[1].text", Rule: validation.RuleUnique, Message: "must not be repeated"},
+				{Field: "answers[2].text", Rule: validation.RuleRequired, Message: "must not be empty"},
+			},
+		},
+		{
+			name: "duplicate answer ids",
+			buff: model.Buff{
+				Question: "why?",
+				Answers: []model.Answer{
+					{ID: sharedID, Text: "42", Correct: true},
+					{ID: sharedID, Text: "43", Correct: false},
+				},
+			},
+			expectErr: validation.Errors{
+				{Field: "answers[1].id", Rule: validation.RuleUnique, Message: "must not be repeated"},
+			},
+		},
+		{
+			name:    "configured answer and question limits",
+			options: []validation.Option{validation.MaxAnswers(2), validation.MaxQuestionLength(5)},
+			buff: model.Buff{
+				Question: "why oh why?",
+				Answers:  []model.Answer{answer("42", true), answer("43", false), answer("44", false)},
+			},
+			expectErr: validation.Errors{
+				{Field: "question", Rule: validation.RuleMaxLength, Message: "must be at most 5 characters"},
+				{Field: "answers", Rule: validation.RuleMaxAnswers, Message: "must have at most 2 answers"},
+			},
+		},
+		{
+			name: "scheduled buff passes",
+			buff: model.Buff{
+				Question: "why?",
+				Answers:  []model.Answer{answer("42", true), answer("43", false)},
+				Schedule: &model.Schedule{Offset: 0, Duration: time.Second},
+			},
+		},
+		{
+			name: "schedule before the stream starts, that never closes",
+			buff: model.Buff{
+				Question: "why?",
+				Answers:  []model.Answer{answer("42", true), answer("43", false)},
+				Schedule: &model.Schedule{Offset: -time.Second},
+			},
+			expectErr: validation.Errors{
+				{Field: "schedule.offset", Rule: validation.RuleNotNegative, Message: "must not be before the start of the stream"},
+				{Field: "schedule.duration", Rule: validation.RulePositive, Message: "must be longer than zero"},
+			},
+		},
+		{
+			name: "quiz buff passes",
+			buff: model.Buff{
+				Kind:     model.BuffQuiz,
+				Question: "why?",
+				Answers:  []model.Answer{answer("42", true), answer("43", false)},
+			},
+		},
+		{
+			name: "poll buff without a correct answer passes",
+			buff: model.Buff{
+				Kind:     model.BuffPoll,
+				Question: "who is the best?",
+				Answers:  []model.Answer{answer("home", false), answer("away", false)},
+			},
+		},
+		{
+			name: "poll buff with a correct answer",
+			buff: model.Buff{
+				Kind:     model.BuffPoll,
+				Question: "who is the best?",
+				Answers:  []model.Answer{answer("home", false), answer("away", true)},
+			},
+			expectErr: validation.Errors{
+				{Field: "answers[1].correct", Rule: validation.RuleNotCorrect, Message: "must not be correct, as a poll has no correct answer"},
+			},
+		},
+		{
+			name: "prediction buff with a correct answer",
+			buff: model.Buff{
+				Kind:     model.BuffPrediction,
+				Question: "who scores first?",
+				Answers:  []model.Answer{answer("home", true), answer("away", false)},
+			},
+			expectErr: validation.Errors{
+				{Field: "answers[0].correct", Rule: validation.RuleNotCorrect, Message: "must not be correct, as a prediction is only resolved once the result is known"},
+			},
+		},
+		{
+			name: "unknown kind",
+			buff: model.Buff{
+				Kind:     "survey",
+				Question: "why?",
+				Answers:  []model.Answer{answer("42", false), answer("43", false)},
+			},
+			expectErr: validation.Errors{
+				{Field: "kind", Rule: validation.RuleOneOf, Message: "must be one of quiz, poll or prediction"},
+			},
+		},
+		{
+			name: "tagged buff passes",
+			buff: model.Buff{
+				Question: "why?",
+				Answers:  []model.Answer{answer("42", true), answer("43", false)},
+				Tags:     []string{"football", "sponsor:acme", "under_18s", "2020-final"},
+			},
+		},
+		{
+			name: "badly formatted and repeated tags",
+			buff: model.Buff{
+				Question: "why?",
+				Answers:  []model.Answer{answer("42", true), answer("43", false)},
+				Tags:     []string{"football", "Football", "", ":acme", "football", strings.Repeat("a", 65)},
+			},
+			expectErr: validation.Errors{
+				{Field: "tags[1]", Rule: validation.RuleTagFormat, Message: "must be 1 to 64 lower case letters, digits, '_', ':' or '-', starting with a letter or digit"},
+				{Field: "tags[2]", Rule: validation.RuleTagFormat, Message: "must be 1 to 64 lower case letters, digits, '_', ':' or '-', starting with a letter or digit"},
+				{Field: "tags[3]", Rule: validation.RuleTagFormat, Message: "must be 1 to 64 lower case letters, digits, '_', ':' or '-', starting with a letter or digit"},
+				{Field: "tags[4]", Rule: validation.RuleUnique, Message: "must not be repeated"},
+				{Field: "tags[5]", Rule: validation.RuleTagFormat, Message: "must be 1 to 64 lower case letters, digits, '_', ':' or '-', starting with a letter or digit"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := validation.New(tt.options...)
+			require.NoError(t, err, "failed to build validator")
+
+			err = v.Buff(tt.buff)
+			if tt.expectErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var errs validation.Errors
+			require.True(t, errors.As(err, &errs), "the error should be a validation.Errors")
+			assert.Equal(t, tt.expectErr, errs)
+		})
+	}
+}
+
+func TestValidateVideoStream(t *testing.T) {
+	v, err := validation.New(validation.MaxTitleLength(10))
+	require.NoError(t, err, "failed to build validator")
+
+	assert.NoError(t, v.VideoStream(model.VideoStream{Title: "a stream"}))
+
+	assert.Equal(t, validation.Errors{
+		{Field: "title", Rule: validation.RuleRequired, Message: "must not be empty"},
+	}, v.VideoStream(model.VideoStream{}))
+
+	assert.Equal(t, validation.Errors{
+		{Field: "title", Rule: validation.RuleMaxLength, Message: "must be at most 10 characters"},
+	}, v.VideoStream(model.VideoStream{Title: strings.Repeat("a", 11)}))
+
+	assert.NoError(t, v.VideoStream(model.VideoStream{Title: "a stream", State: model.StreamLive}))
+
+	assert.Equal(t, validation.Errors{
+		{Field: "state", Rule: validation.RuleOneOf, Message: "must be one of scheduled, live, ended or archived"},
+	}, v.VideoStream(model.VideoStream{Title: "a stream", State: "paused"}))
+
+	assert.NoError(t, v.VideoStream(model.VideoStream{Title: "a stream", Tags: []string{"football", "sponsor:acme"}}))
+
+	tooMany := make([]string, 21)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("tag-%d", i)
+	}
+	assert.Equal(t, validation.Errors{
+		{Field: "tags", Rule: validation.RuleMaxTags, Message: "must have at most 20 tags"},
+	}, v.VideoStream(model.VideoStream{Title: "a stream", Tags: tooMany}))
+}
+
+func TestValidateResponse(t *testing.T) {
+	v, err := validation.New()
+	require.NoError(t, err, "failed to build validator")
+
+	assert.NoError(t, v.Response(model.Response{User: "user-42"}))
+
+	assert.Equal(t, validation.Errors{
+		{Field: "user_id", Rule: validation.RuleRequired, Message: "must not be empty"},
+	}, v.Response(model.Response{User: "  "}))
+
+	for _, user := range []model.UserID{" bob", "bob ", "\tbob\n"} {
+		assert.Equal(t, validation.Errors{
+			{Field: "user_id", Rule: validation.RuleTrimmed, Message: "must not start or end with whitespace"},
+		}, v.Response(model.Response{User: user}), "user %q", user)
+	}
+
+	// The whitespace doesn't pad a user ID out past the longest allowed
+	assert.Equal(t, validation.Errors{
+		{Field: "user_id", Rule: validation.RuleTrimmed, Message: "must not start or end with whitespace"},
+	}, v.Response(model.Response{User: model.UserID(strings.Repeat(" ", 300) + "bob")}))
+
+	assert.Equal(t, validation.Errors{
+		{Field: "user_id", Rule: validation.RuleMaxLength, Message: "must be at most 255 characters"},
+	}, v.Response(model.Response{User: model.UserID(strings.Repeat("a", 256))}))
+}
+
+func TestValidateResolution(t *testing.T) {
+	v, err := validation.New()
+	require.NoError(t, err, "failed to build validator")
+
+	assert.NoError(t, v.Resolution(model.Resolution{ResolvedBy: "match-official"}))
+
+	assert.Equal(t, validation.Errors{
+		{Field: "resolved_by", Rule: validation.RuleRequired, Message: "must not be empty"},
+	}, v.Resolution(model.Resolution{ResolvedBy: " "}))
+
+	assert.Equal(t, validation.Errors{
+		{Field: "resolved_by", Rule: validation.RuleMaxLength, Message: "must be at most 255 characters"},
+	}, v.Resolution(model.Resolution{ResolvedBy: strings.Repeat("a", 256)}))
+}
+
+func TestNewValidator(t *testing.T) {
+	_, err := validation.New(validation.MinAnswers(0))
+	assert.Error(t, err, "min answers must be positive")
+
+	_, err = validation.New(validation.MinAnswers(5), validation.MaxAnswers(4))
+	assert.Error(t, err, "min answers must not exceed max answers")
+}
+
+func TestErrorsMessage(t *testing.T) {
+	errs := validation.Errors{
+		{Field: "question", Rule: validation.RuleRequired, Message: "must not be empty"},
+		{Field: "answers", Rule: validation.RuleMinAnswers, Message: "must have at least 2 answers"},
+	}
+	assert.Equal(t, "validation failed: question: must not be empty; answers: must have at least 2 answers", errs.Error())
+}